@@ -72,6 +72,7 @@ func InitEnv() {
 
 	// Initialize variables from constants.go that were using environment variables
 	DebugEnabled = os.Getenv("DEBUG") == "true"
+	LogJSONEnabled = os.Getenv("LOG_FORMAT") == "json"
 	MemoryCacheEnabled = os.Getenv("MEMORY_CACHE_ENABLED") == "true"
 	IsMasterNode = os.Getenv("NODE_TYPE") != "slave"
 
@@ -117,4 +118,10 @@ func initConstantEnv() {
 	constant.GenerateDefaultToken = GetEnvOrDefaultBool("GENERATE_DEFAULT_TOKEN", false)
 	// 是否启用错误日志
 	constant.ErrorLogEnabled = GetEnvOrDefaultBool("ERROR_LOG_ENABLED", false)
+	// Files API 本地存储目录与单文件大小上限
+	constant.FileStorageDir = GetEnvOrDefaultString("FILE_STORAGE_DIR", "./data/files")
+	constant.MaxFileUploadMB = GetEnvOrDefault("MAX_FILE_UPLOAD_MB", 512)
+	// Prometheus 指标：设置令牌后在主端口暴露 /metrics，设置端口后在独立端口暴露
+	constant.MetricsToken = GetEnvOrDefaultString("METRICS_TOKEN", "")
+	constant.MetricsPort = GetEnvOrDefaultString("METRICS_PORT", "")
 }
@@ -0,0 +1,22 @@
+-- 并发数限制，使用有序集合记录进行中的请求，超过 TTL 的记录视为已结束
+-- KEYS[1]: 限制器唯一标识
+-- ARGV[1]: 请求唯一标识
+-- ARGV[2]: 并发上限
+-- ARGV[3]: 记录过期时间（毫秒）
+
+local key = KEYS[1]
+local member = ARGV[1]
+local limit = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+
+local now = redis.call('TIME')
+local nowInMs = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', nowInMs - ttl)
+local current = redis.call('ZCARD', key)
+if current >= limit then
+    return {0, current}
+end
+redis.call('ZADD', key, nowInMs, member)
+redis.call('PEXPIRE', key, ttl)
+return {1, current + 1}
@@ -0,0 +1,20 @@
+-- 固定窗口计数器
+-- KEYS[1]: 计数器唯一标识（包含窗口编号）
+-- ARGV[1]: 本次增加的计数，为 0 时仅查询计数是否已达到上限
+-- ARGV[2]: 窗口内上限，为 0 时不检查上限直接累加
+-- ARGV[3]: 过期时间（毫秒）
+
+local key = KEYS[1]
+local cost = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+
+local current = tonumber(redis.call('GET', key) or '0')
+if limit > 0 and (current + cost > limit or (cost == 0 and current >= limit)) then
+    return {0, current}
+end
+if cost > 0 then
+    current = redis.call('INCRBY', key, cost)
+    redis.call('PEXPIRE', key, ttl)
+end
+return {1, current}
@@ -0,0 +1,135 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"one-api/common"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//go:embed lua/window_limit.lua
+var windowLimitScriptSource string
+
+//go:embed lua/concurrency_limit.lua
+var concurrencyLimitScriptSource string
+
+var (
+	windowLimitScript      = redis.NewScript(windowLimitScriptSource)
+	concurrencyLimitScript = redis.NewScript(concurrencyLimitScriptSource)
+)
+
+// WindowResult 固定窗口计数结果
+type WindowResult struct {
+	Allowed bool
+	Count   int64         // 窗口内当前计数
+	Limit   int64         // 窗口内上限
+	Reset   time.Duration // 距离窗口重置的时间
+}
+
+// Remaining 返回窗口内剩余额度
+func (r WindowResult) Remaining() int64 {
+	return max(r.Limit-r.Count, 0)
+}
+
+type memoryWindow struct {
+	index int64
+	count int64
+}
+
+type memoryConcurrency struct {
+	members map[string]time.Time
+}
+
+var (
+	memoryWindows       = make(map[string]*memoryWindow)
+	memoryConcurrencies = make(map[string]*memoryConcurrency)
+	memoryLock          sync.Mutex
+)
+
+func windowPosition(window time.Duration) (int64, time.Duration) {
+	now := time.Now().UnixMilli()
+	windowMs := window.Milliseconds()
+	index := now / windowMs
+	return index, time.Duration((index+1)*windowMs-now) * time.Millisecond
+}
+
+// WindowIncr 在固定窗口内累加 cost，limit 大于 0 时若累加后超过上限则拒绝且不计数；
+// cost 为 0 时仅查询当前计数是否已达到上限。启用 Redis 时使用 Redis，否则使用内存计数
+func WindowIncr(ctx context.Context, key string, cost int64, limit int64, window time.Duration) (WindowResult, error) {
+	index, reset := windowPosition(window)
+	result := WindowResult{Limit: limit, Reset: reset}
+	if common.RedisEnabled {
+		windowKey := fmt.Sprintf("%s:%d", key, index)
+		values, err := windowLimitScript.Run(ctx, common.RDB, []string{windowKey}, cost, limit, window.Milliseconds()).Int64Slice()
+		if err != nil {
+			return result, fmt.Errorf("window limit failed: %w", err)
+		}
+		result.Allowed = values[0] == 1
+		result.Count = values[1]
+		return result, nil
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	w, ok := memoryWindows[key]
+	if !ok || w.index != index {
+		w = &memoryWindow{index: index}
+		memoryWindows[key] = w
+	}
+	result.Count = w.count
+	if limit > 0 && (w.count+cost > limit || (cost == 0 && w.count >= limit)) {
+		return result, nil
+	}
+	w.count += cost
+	result.Count = w.count
+	result.Allowed = true
+	return result, nil
+}
+
+// ConcurrencyAcquire 占用一个并发名额，ttl 为单个请求的最长占用时间，防止异常退出导致名额泄漏
+func ConcurrencyAcquire(ctx context.Context, key string, member string, limit int64, ttl time.Duration) (bool, int64, error) {
+	if common.RedisEnabled {
+		values, err := concurrencyLimitScript.Run(ctx, common.RDB, []string{key}, member, limit, ttl.Milliseconds()).Int64Slice()
+		if err != nil {
+			return false, 0, fmt.Errorf("concurrency limit failed: %w", err)
+		}
+		return values[0] == 1, values[1], nil
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	c, ok := memoryConcurrencies[key]
+	if !ok {
+		c = &memoryConcurrency{members: make(map[string]time.Time)}
+		memoryConcurrencies[key] = c
+	}
+	now := time.Now()
+	for m, start := range c.members {
+		if now.Sub(start) > ttl {
+			delete(c.members, m)
+		}
+	}
+	if int64(len(c.members)) >= limit {
+		return false, int64(len(c.members)), nil
+	}
+	c.members[member] = now
+	return true, int64(len(c.members)), nil
+}
+
+// ConcurrencyRelease 释放并发名额
+func ConcurrencyRelease(ctx context.Context, key string, member string) error {
+	if common.RedisEnabled {
+		return common.RDB.ZRem(ctx, key, member).Err()
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	if c, ok := memoryConcurrencies[key]; ok {
+		delete(c.members, member)
+		if len(c.members) == 0 {
+			delete(memoryConcurrencies, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"os"
+	"testing"
+	"time"
+)
+
+// 测试不依赖 Redis，计数均保存在内存中
+func TestMain(m *testing.M) {
+	common.RedisEnabled = false
+	os.Exit(m.Run())
+}
+
+func resetMemoryLimits() {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryWindows = make(map[string]*memoryWindow)
+	memoryConcurrencies = make(map[string]*memoryConcurrency)
+}
+
+func TestWindowIncr(t *testing.T) {
+	resetMemoryLimits()
+	type step struct {
+		cost        int64
+		wantAllowed bool
+		wantCount   int64
+	}
+	tests := []struct {
+		name  string
+		limit int64
+		steps []step
+	}{
+		{
+			name:  "counts up to the limit then rejects",
+			limit: 3,
+			steps: []step{{1, true, 1}, {1, true, 2}, {1, true, 3}, {1, false, 3}},
+		},
+		{
+			name:  "rejected cost is not counted",
+			limit: 10,
+			steps: []step{{6, true, 6}, {5, false, 6}, {4, true, 10}},
+		},
+		{
+			name:  "zero cost only checks the limit",
+			limit: 2,
+			steps: []step{{0, true, 0}, {2, true, 2}, {0, false, 2}},
+		},
+		{
+			name:  "no limit always accumulates",
+			limit: 0,
+			steps: []step{{100, true, 100}, {1000, true, 1100}, {0, true, 1100}},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := fmt.Sprintf("test:window:%d", i)
+			for n, s := range tt.steps {
+				result, err := WindowIncr(context.Background(), key, s.cost, tt.limit, time.Hour)
+				if err != nil {
+					t.Fatalf("step %d: %v", n, err)
+				}
+				if result.Allowed != s.wantAllowed || result.Count != s.wantCount {
+					t.Fatalf("step %d: allowed = %v count = %d, want %v %d", n, result.Allowed, result.Count, s.wantAllowed, s.wantCount)
+				}
+				if result.Reset <= 0 || result.Reset > time.Hour {
+					t.Fatalf("step %d: reset = %v out of window", n, result.Reset)
+				}
+			}
+		})
+	}
+}
+
+func TestWindowIncrResetsOnNextWindow(t *testing.T) {
+	resetMemoryLimits()
+	window := 50 * time.Millisecond
+	result, err := WindowIncr(context.Background(), "test:window:reset", 1, 1, window)
+	if err != nil || !result.Allowed {
+		t.Fatalf("first request allowed = %v, err = %v", result.Allowed, err)
+	}
+	time.Sleep(result.Reset + 5*time.Millisecond)
+	result, err = WindowIncr(context.Background(), "test:window:reset", 1, 1, window)
+	if err != nil || !result.Allowed || result.Count != 1 {
+		t.Fatalf("next window allowed = %v count = %d, err = %v", result.Allowed, result.Count, err)
+	}
+}
+
+func TestWindowResultRemaining(t *testing.T) {
+	tests := []struct {
+		result WindowResult
+		want   int64
+	}{
+		{WindowResult{Count: 3, Limit: 10}, 7},
+		{WindowResult{Count: 10, Limit: 10}, 0},
+		{WindowResult{Count: 12, Limit: 10}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.result.Remaining(); got != tt.want {
+			t.Fatalf("Remaining(%+v) = %d, want %d", tt.result, got, tt.want)
+		}
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	resetMemoryLimits()
+	ctx := context.Background()
+	key := "test:concurrency"
+	for i, want := range []bool{true, true, false} {
+		allowed, _, err := ConcurrencyAcquire(ctx, key, fmt.Sprintf("m%d", i), 2, time.Minute)
+		if err != nil || allowed != want {
+			t.Fatalf("acquire %d allowed = %v, want %v, err = %v", i, allowed, want, err)
+		}
+	}
+	if err := ConcurrencyRelease(ctx, key, "m0"); err != nil {
+		t.Fatal(err)
+	}
+	if allowed, count, _ := ConcurrencyAcquire(ctx, key, "m3", 2, time.Minute); !allowed || count != 2 {
+		t.Fatalf("acquire after release allowed = %v count = %d", allowed, count)
+	}
+}
+
+func TestConcurrencyExpiresStaleMembers(t *testing.T) {
+	resetMemoryLimits()
+	ctx := context.Background()
+	key := "test:concurrency:ttl"
+	if allowed, _, _ := ConcurrencyAcquire(ctx, key, "stale", 1, 20*time.Millisecond); !allowed {
+		t.Fatal("first acquire rejected")
+	}
+	if allowed, _, _ := ConcurrencyAcquire(ctx, key, "blocked", 1, 20*time.Millisecond); allowed {
+		t.Fatal("second acquire allowed while slot is held")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if allowed, _, _ := ConcurrencyAcquire(ctx, key, "fresh", 1, 20*time.Millisecond); !allowed {
+		t.Fatal("acquire rejected after stale member expired")
+	}
+}
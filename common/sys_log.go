@@ -1,23 +1,86 @@
 package common
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"io"
 	"os"
 	"time"
 )
 
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+	LogLevelFatal = "fatal"
+)
+
+// LogModuleSystem SysLog/SysError 所属的模块
+const LogModuleSystem = "system"
+
+// LogJSONEnabled 由环境变量 LOG_FORMAT=json 开启，每行输出一个 JSON 对象
+var LogJSONEnabled bool
+
+// LogLevelFilter 由 logger 包按日志设置注入，返回模块在该级别下是否输出；未注入时全部输出
+var LogLevelFilter func(module string, level string) bool
+
+func LogLevelEnabled(module string, level string) bool {
+	if LogLevelFilter == nil {
+		return true
+	}
+	return LogLevelFilter(module, level)
+}
+
+// WriteJSONLog 输出一行结构化日志，fields 中的空值会被省略
+func WriteJSONLog(writer io.Writer, level string, module string, msg string, fields map[string]any) {
+	entry := make(map[string]any, len(fields)+4)
+	for k, v := range fields {
+		entry[k] = v
+	}
+	entry["time"] = time.Now().Format(time.RFC3339Nano)
+	entry["level"] = level
+	entry["module"] = module
+	entry["msg"] = msg
+	data, err := json.Marshal(entry)
+	if err != nil {
+		_, _ = fmt.Fprintf(writer, "{\"level\":\"error\",\"msg\":%q}\n", "failed to marshal log entry: "+err.Error())
+		return
+	}
+	data = append(data, '\n')
+	_, _ = writer.Write(data)
+}
+
 func SysLog(s string) {
+	if !LogLevelEnabled(LogModuleSystem, LogLevelInfo) {
+		return
+	}
+	if LogJSONEnabled {
+		WriteJSONLog(gin.DefaultWriter, LogLevelInfo, LogModuleSystem, s, nil)
+		return
+	}
 	t := time.Now()
 	_, _ = fmt.Fprintf(gin.DefaultWriter, "[SYS] %v | %s \n", t.Format("2006/01/02 - 15:04:05"), s)
 }
 
 func SysError(s string) {
+	if !LogLevelEnabled(LogModuleSystem, LogLevelError) {
+		return
+	}
+	if LogJSONEnabled {
+		WriteJSONLog(gin.DefaultErrorWriter, LogLevelError, LogModuleSystem, s, nil)
+		return
+	}
 	t := time.Now()
 	_, _ = fmt.Fprintf(gin.DefaultErrorWriter, "[SYS] %v | %s \n", t.Format("2006/01/02 - 15:04:05"), s)
 }
 
 func FatalLog(v ...any) {
+	if LogJSONEnabled {
+		WriteJSONLog(gin.DefaultErrorWriter, LogLevelFatal, LogModuleSystem, fmt.Sprint(v...), nil)
+		os.Exit(1)
+	}
 	t := time.Now()
 	_, _ = fmt.Fprintf(gin.DefaultErrorWriter, "[FATAL] %v | %v \n", t.Format("2006/01/02 - 15:04:05"), v)
 	os.Exit(1)
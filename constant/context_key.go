@@ -8,6 +8,18 @@ const (
 
 	ContextKeyOriginalModel    ContextKey = "original_model"
 	ContextKeyRequestStartTime ContextKey = "request_start_time"
+	// 发生模型降级时记录用户请求的原始模型
+	ContextKeyFallbackFromModel ContextKey = "fallback_from_model"
+	// 本次请求上游返回的用量，供响应缓存写入
+	ContextKeyResponseUsage ContextKey = "response_usage"
+	// 响应缓存语义匹配的查询，未命中时写入缓存复用已计算的向量
+	ContextKeyResponseCacheSemantic ContextKey = "response_cache_semantic"
+
+	/* hedge related keys */
+	// 对冲请求的上游请求上下文，落败方被取消时中断上游请求
+	ContextKeyHedgeContext ContextKey = "hedge_context"
+	// 对冲请求抢占响应的函数，返回 false 表示已落败，不应计费
+	ContextKeyHedgeClaim ContextKey = "hedge_claim"
 
 	/* token related keys */
 	ContextKeyTokenUnlimited         ContextKey = "token_unlimited_quota"
@@ -17,6 +29,12 @@ const (
 	ContextKeyTokenSpecificChannelId ContextKey = "specific_channel_id"
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
+	ContextKeyTokenRateLimitTPM      ContextKey = "token_rate_limit_tpm"
+	ContextKeyTokenModelFallbacks    ContextKey = "token_model_fallbacks"
+	ContextKeyTokenResponseCache     ContextKey = "token_response_cache"
+	ContextKeyTokenBudgetPeriod      ContextKey = "token_budget_period"
+	ContextKeyTokenBudgetTimezone    ContextKey = "token_budget_timezone"
+	ContextKeyTokenOrgId             ContextKey = "token_org_id"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -47,4 +65,15 @@ const (
 	ContextKeyUserName    ContextKey = "username"
 
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
+
+	/* batch related keys */
+	ContextKeyBatchId            ContextKey = "batch_id"
+	ContextKeyBatchDiscountRatio ContextKey = "batch_discount_ratio"
+
+	/* idempotency related keys */
+	ContextKeyConsumeLogId ContextKey = "consume_log_id"
+	ContextKeyConsumeQuota ContextKey = "consume_quota"
+
+	/* payload capture related keys */
+	ContextKeyPayloadCapture ContextKey = "payload_capture"
 )
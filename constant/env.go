@@ -13,3 +13,7 @@ var NotifyLimitCount int
 var NotificationLimitDurationMinute int
 var GenerateDefaultToken bool
 var ErrorLogEnabled bool
+var FileStorageDir string
+var MaxFileUploadMB int
+var MetricsToken string
+var MetricsPort string
@@ -3,6 +3,9 @@ package constant
 type MultiKeyMode string
 
 const (
-	MultiKeyModeRandom  MultiKeyMode = "random"  // 随机
-	MultiKeyModePolling MultiKeyMode = "polling" // 轮询
+	MultiKeyModeRandom   MultiKeyMode = "random"   // 随机
+	MultiKeyModePolling  MultiKeyMode = "polling"  // 轮询
+	MultiKeyModeWeighted MultiKeyMode = "weighted" // 按 key 权重随机
+	MultiKeyModeLRU      MultiKeyMode = "lru"      // 最久未使用优先
+	MultiKeyModeUsage    MultiKeyMode = "usage"    // 按 RPM/TPM 用量，跳过接近限额的 key
 )
@@ -6,6 +6,8 @@ const (
 	TaskPlatformSuno       TaskPlatform = "suno"
 	TaskPlatformMidjourney              = "mj"
 	TaskPlatformCoze                    = "coze"
+	TaskPlatformBatch                   = "batch" // /v1/batches，由批处理 worker 自行推进，不参与任务轮询
+	TaskPlatformFineTuning              = "fine_tuning"
 )
 
 const (
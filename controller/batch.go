@@ -0,0 +1,658 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	batchCompletionWindow   = "24h"
+	batchProgressSavePeriod = 5 * time.Second
+)
+
+// 批处理支持的端点及其对应的 relay 格式
+var batchSupportedEndpoints = map[string]types.RelayFormat{
+	"/v1/chat/completions": types.RelayFormatOpenAI,
+	"/v1/completions":      types.RelayFormatOpenAI,
+	"/v1/embeddings":       types.RelayFormatEmbedding,
+	"/v1/responses":        types.RelayFormatOpenAIResponses,
+}
+
+func batchTimePtr(t int64) *int64 {
+	if t == 0 {
+		return nil
+	}
+	return &t
+}
+
+func batchStringPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func taskToOpenAIBatch(task *model.Task) dto.OpenAIBatch {
+	data := task.GetBatchData()
+	batch := dto.OpenAIBatch{
+		Id:               task.TaskID,
+		Object:           "batch",
+		Endpoint:         data.Endpoint,
+		InputFileId:      data.InputFileId,
+		CompletionWindow: data.CompletionWindow,
+		Status:           data.Status,
+		OutputFileId:     batchStringPtr(data.OutputFileId),
+		ErrorFileId:      batchStringPtr(data.ErrorFileId),
+		CreatedAt:        task.SubmitTime,
+		InProgressAt:     batchTimePtr(data.InProgressAt),
+		ExpiresAt:        batchTimePtr(data.ExpiresAt),
+		FinalizingAt:     batchTimePtr(data.FinalizingAt),
+		CompletedAt:      batchTimePtr(data.CompletedAt),
+		FailedAt:         batchTimePtr(data.FailedAt),
+		ExpiredAt:        batchTimePtr(data.ExpiredAt),
+		CancellingAt:     batchTimePtr(data.CancellingAt),
+		CancelledAt:      batchTimePtr(data.CancelledAt),
+		RequestCounts:    data.RequestCounts,
+		Metadata:         data.Metadata,
+	}
+	if len(data.Errors) > 0 {
+		batch.Errors = &dto.BatchErrors{
+			Object: "list",
+			Data:   data.Errors,
+		}
+	}
+	return batch
+}
+
+func getUserBatchOrAbort(c *gin.Context) *model.Task {
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		fileErrorResponse(c, http.StatusInternalServerError, types.ErrorCodeQueryDataError, err.Error())
+		return nil
+	}
+	if !exist || task.Platform != constant.TaskPlatformBatch {
+		fileErrorResponse(c, http.StatusNotFound, types.ErrorCodeInvalidRequest, "batch not found")
+		return nil
+	}
+	return task
+}
+
+func CreateBatch(c *gin.Context) {
+	var req dto.BatchRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeInvalidRequest, err.Error())
+		return
+	}
+	if _, ok := batchSupportedEndpoints[req.Endpoint]; !ok {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeInvalidRequest, fmt.Sprintf("unsupported endpoint: %s", req.Endpoint))
+		return
+	}
+	if req.CompletionWindow != batchCompletionWindow {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeInvalidRequest, "completion_window must be "+batchCompletionWindow)
+		return
+	}
+	userId := c.GetInt("id")
+	file, err := model.GetUserFileByFileId(userId, req.InputFileId)
+	if err != nil {
+		fileErrorResponse(c, http.StatusNotFound, types.ErrorCodeInvalidRequest, "input file not found")
+		return
+	}
+	if file.Purpose != "batch" {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeInvalidRequest, "input file purpose must be batch")
+		return
+	}
+
+	now := time.Now().Unix()
+	task := &model.Task{
+		TaskID:     "batch_" + common.GetRandomString(24),
+		Platform:   constant.TaskPlatformBatch,
+		UserId:     userId,
+		Action:     req.Endpoint,
+		Status:     model.TaskStatusQueued,
+		Progress:   "0%",
+		SubmitTime: now,
+	}
+	task.SetData(model.BatchData{
+		Endpoint:         req.Endpoint,
+		InputFileId:      file.FileId,
+		CompletionWindow: req.CompletionWindow,
+		Status:           model.BatchStatusValidating,
+		TokenId:          c.GetInt("token_id"),
+		ClientIp:         c.ClientIP(),
+		Metadata:         req.Metadata,
+		ExpiresAt:        now + int64(24*time.Hour/time.Second),
+	})
+	if err = task.Insert(); err != nil {
+		fileErrorResponse(c, http.StatusInternalServerError, types.ErrorCodeUpdateDataError, err.Error())
+		return
+	}
+	notifyBatchWorker()
+	c.JSON(http.StatusOK, taskToOpenAIBatch(task))
+}
+
+func RetrieveBatch(c *gin.Context) {
+	task := getUserBatchOrAbort(c)
+	if task == nil {
+		return
+	}
+	c.JSON(http.StatusOK, taskToOpenAIBatch(task))
+}
+
+func ListBatches(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	tasks, err := model.GetUserBatchTasks(c.GetInt("id"), c.Query("after"), limit+1)
+	if err != nil {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeQueryDataError, err.Error())
+		return
+	}
+	resp := dto.OpenAIBatchList{
+		Object: "list",
+		Data:   make([]dto.OpenAIBatch, 0, len(tasks)),
+	}
+	if len(tasks) > limit {
+		resp.HasMore = true
+		tasks = tasks[:limit]
+	}
+	for _, task := range tasks {
+		resp.Data = append(resp.Data, taskToOpenAIBatch(task))
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstId = resp.Data[0].Id
+		resp.LastId = resp.Data[len(resp.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func CancelBatch(c *gin.Context) {
+	task := getUserBatchOrAbort(c)
+	if task == nil {
+		return
+	}
+	data := task.GetBatchData()
+	data.Status = model.BatchStatusCancelling
+	data.CancellingAt = time.Now().Unix()
+	task.SetData(data)
+	ok, err := model.UpdateTaskIfStatus(task.ID, []model.TaskStatus{model.TaskStatusQueued, model.TaskStatusInProgress}, map[string]any{
+		"status": model.TaskStatusCancelling,
+		"data":   task.Data,
+	})
+	if err != nil {
+		fileErrorResponse(c, http.StatusInternalServerError, types.ErrorCodeUpdateDataError, err.Error())
+		return
+	}
+	if !ok {
+		fileErrorResponse(c, http.StatusConflict, types.ErrorCodeInvalidRequest, "batch cannot be cancelled in its current status")
+		return
+	}
+	notifyBatchWorker()
+	task.Status = model.TaskStatusCancelling
+	c.JSON(http.StatusOK, taskToOpenAIBatch(task))
+}
+
+// ---------------- worker ----------------
+
+type batchIdContextKey struct{}
+
+var (
+	batchWorkerSignal = make(chan struct{}, 1)
+	batchRunning      sync.Map
+
+	batchRelayEngine     *gin.Engine
+	batchRelayEngineOnce sync.Once
+
+	batchLimiterMu   sync.Mutex
+	batchLimiterCond = sync.NewCond(&batchLimiterMu)
+	batchLimiterUsed int
+)
+
+func notifyBatchWorker() {
+	select {
+	case batchWorkerSignal <- struct{}{}:
+	default:
+	}
+}
+
+// acquireBatchSlot 所有批处理共享 worker_concurrency 个并发名额，修改配置后即时生效
+func acquireBatchSlot() {
+	batchLimiterMu.Lock()
+	for batchLimiterUsed >= max(1, operation_setting.GetBatchSetting().WorkerConcurrency) {
+		batchLimiterCond.Wait()
+	}
+	batchLimiterUsed++
+	batchLimiterMu.Unlock()
+}
+
+func releaseBatchSlot() {
+	batchLimiterMu.Lock()
+	batchLimiterUsed--
+	batchLimiterMu.Unlock()
+	batchLimiterCond.Broadcast()
+}
+
+// getBatchRelayEngine 批处理的每一行都经过与在线请求相同的鉴权、分发与 Relay 流程
+func getBatchRelayEngine() *gin.Engine {
+	batchRelayEngineOnce.Do(func() {
+		engine := gin.New()
+		engine.Use(middleware.RequestId(), func(c *gin.Context) {
+			batchId, _ := c.Request.Context().Value(batchIdContextKey{}).(string)
+			common.SetContextKey(c, constant.ContextKeyBatchId, batchId)
+			common.SetContextKey(c, constant.ContextKeyBatchDiscountRatio, operation_setting.GetBatchSetting().DiscountRatio)
+			c.Next()
+		})
+		router := engine.Group("/v1")
+		router.Use(middleware.TokenAuth(), middleware.Distribute())
+		for endpoint, relayFormat := range batchSupportedEndpoints {
+			relayFormat := relayFormat
+			router.POST(strings.TrimPrefix(endpoint, "/v1"), func(c *gin.Context) {
+				Relay(c, relayFormat)
+			})
+		}
+		batchRelayEngine = engine
+	})
+	return batchRelayEngine
+}
+
+// StartBatchWorker 批处理调度循环，仅在主节点运行
+func StartBatchWorker() {
+	for {
+		dispatchBatchTasks()
+		interval := operation_setting.GetBatchSetting().PollIntervalSeconds
+		if interval <= 0 {
+			interval = 10
+		}
+		select {
+		case <-batchWorkerSignal:
+		case <-time.After(time.Duration(interval) * time.Second):
+		}
+	}
+}
+
+func dispatchBatchTasks() {
+	tasks, err := model.GetUnfinishedBatchTasks()
+	if err != nil {
+		common.SysError("get unfinished batch tasks failed: " + err.Error())
+		return
+	}
+	for _, task := range tasks {
+		if _, running := batchRunning.Load(task.ID); running {
+			continue
+		}
+		switch task.Status {
+		case model.TaskStatusQueued:
+			batchRunning.Store(task.ID, struct{}{})
+			task := task
+			gopool.Go(func() {
+				defer batchRunning.Delete(task.ID)
+				runBatch(task)
+			})
+		case model.TaskStatusInProgress:
+			// 不在本进程中执行却处于执行中，说明服务曾中断；已执行的请求均已计费，无法安全续跑
+			failBatch(task, "batch_interrupted", "batch was interrupted by a server restart")
+		case model.TaskStatusCancelling:
+			finishBatch(task, model.TaskStatusCancelled, task.GetBatchData())
+		}
+	}
+}
+
+func failBatch(task *model.Task, code string, message string, lineErrors ...dto.BatchError) {
+	data := task.GetBatchData()
+	data.Errors = append(data.Errors, lineErrors...)
+	if len(lineErrors) == 0 {
+		data.Errors = append(data.Errors, dto.BatchError{Code: code, Message: message})
+	}
+	task.FailReason = message
+	finishBatch(task, model.TaskStatusFailure, data)
+}
+
+// finishBatch 写入终态，仅当批处理尚未结束时生效
+func finishBatch(task *model.Task, status model.TaskStatus, data *model.BatchData) {
+	now := time.Now().Unix()
+	switch status {
+	case model.TaskStatusSuccess:
+		data.Status = model.BatchStatusCompleted
+		data.CompletedAt = now
+	case model.TaskStatusFailure:
+		data.Status = model.BatchStatusFailed
+		data.FailedAt = now
+	case model.TaskStatusExpired:
+		data.Status = model.BatchStatusExpired
+		data.ExpiredAt = now
+	case model.TaskStatusCancelled:
+		data.Status = model.BatchStatusCancelled
+		data.CancelledAt = now
+	}
+	task.SetData(data)
+	_, err := model.UpdateTaskIfStatus(task.ID, []model.TaskStatus{model.TaskStatusQueued, model.TaskStatusInProgress, model.TaskStatusCancelling}, map[string]any{
+		"status":      status,
+		"progress":    "100%",
+		"finish_time": now,
+		"fail_reason": task.FailReason,
+		"data":        task.Data,
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("finish batch %s failed: %s", task.TaskID, err.Error()))
+	}
+}
+
+// loadBatchInputLines 读取并校验输入文件，任意一行不合法则整个批处理失败
+func loadBatchInputLines(userId int, data *model.BatchData) ([]dto.BatchInputLine, []dto.BatchError, error) {
+	file, err := model.GetUserFileByFileId(userId, data.InputFileId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("input file %s not found", data.InputFileId)
+	}
+	content, err := service.GetFileStorage().Open(file.StoragePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open input file failed: %w", err)
+	}
+	defer content.Close()
+
+	maxRequests := operation_setting.GetBatchSetting().MaxRequestsPerBatch
+	lines := make([]dto.BatchInputLine, 0)
+	lineErrors := make([]dto.BatchError, 0)
+	customIds := make(map[string]struct{})
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		n := lineNo
+		addError := func(message string) {
+			lineErrors = append(lineErrors, dto.BatchError{Code: "invalid_request", Message: message, Line: &n})
+		}
+		var line dto.BatchInputLine
+		if err = common.Unmarshal(raw, &line); err != nil {
+			addError("invalid json: " + err.Error())
+			continue
+		}
+		if line.CustomId == "" {
+			addError("custom_id is required")
+			continue
+		}
+		if _, ok := customIds[line.CustomId]; ok {
+			addError("duplicate custom_id: " + line.CustomId)
+			continue
+		}
+		customIds[line.CustomId] = struct{}{}
+		if line.Method != "" && !strings.EqualFold(line.Method, http.MethodPost) {
+			addError("method must be POST")
+			continue
+		}
+		if line.Url != data.Endpoint {
+			addError(fmt.Sprintf("url %s does not match batch endpoint %s", line.Url, data.Endpoint))
+			continue
+		}
+		var body struct {
+			Model  string `json:"model"`
+			Stream bool   `json:"stream"`
+		}
+		if err = common.Unmarshal(line.Body, &body); err != nil || body.Model == "" {
+			addError("body must be a json object with model")
+			continue
+		}
+		if body.Stream {
+			addError("stream is not supported in batch")
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read input file failed: %w", err)
+	}
+	if len(lines)+len(lineErrors) == 0 {
+		return nil, nil, errors.New("input file is empty")
+	}
+	if maxRequests > 0 && len(lines)+len(lineErrors) > maxRequests {
+		return nil, nil, fmt.Errorf("input file exceeds max requests per batch (%d)", maxRequests)
+	}
+	return lines, lineErrors, nil
+}
+
+// executeBatchLine 以批处理令牌的身份在进程内执行一行请求，计费由 Relay 内部完成
+func executeBatchLine(task *model.Task, token *model.Token, clientIp string, line dto.BatchInputLine) (*dto.BatchOutputLine, bool) {
+	ctx := context.WithValue(context.Background(), batchIdContextKey{}, task.TaskID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, line.Url, bytes.NewReader(line.Body))
+	result := &dto.BatchOutputLine{
+		Id:       "batch_req_" + common.GetRandomString(24),
+		CustomId: line.CustomId,
+	}
+	if err != nil {
+		result.Error = &dto.BatchError{Code: "invalid_request", Message: err.Error()}
+		return result, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-"+token.Key)
+	if clientIp != "" {
+		req.RemoteAddr = clientIp + ":0"
+	}
+	recorder := httptest.NewRecorder()
+	getBatchRelayEngine().ServeHTTP(recorder, req)
+
+	body := recorder.Body.Bytes()
+	if !json.Valid(body) {
+		body, _ = common.Marshal(string(body))
+	}
+	result.Response = &dto.BatchOutputResponse{
+		StatusCode: recorder.Code,
+		RequestId:  recorder.Header().Get(common.RequestIdKey),
+		Body:       body,
+	}
+	return result, recorder.Code == http.StatusOK
+}
+
+type batchResultWriter struct {
+	file  *os.File
+	count int
+}
+
+func (w *batchResultWriter) write(line *dto.BatchOutputLine) error {
+	if w.file == nil {
+		f, err := os.CreateTemp("", "batch_*.jsonl")
+		if err != nil {
+			return err
+		}
+		w.file = f
+	}
+	b, err := common.Marshal(line)
+	if err != nil {
+		return err
+	}
+	w.count++
+	_, err = w.file.Write(append(b, '\n'))
+	return err
+}
+
+func (w *batchResultWriter) close() {
+	if w.file != nil {
+		_ = w.file.Close()
+		_ = os.Remove(w.file.Name())
+	}
+}
+
+// save 将结果保存为用户可通过 /v1/files 下载的文件
+func (w *batchResultWriter) save(task *model.Task, suffix string) (string, error) {
+	if w.file == nil || w.count == 0 {
+		return "", nil
+	}
+	if _, err := w.file.Seek(0, 0); err != nil {
+		return "", err
+	}
+	file := &model.File{
+		FileId:    "file-" + common.GetRandomString(24),
+		UserId:    task.UserId,
+		Filename:  fmt.Sprintf("%s_%s.jsonl", task.TaskID, suffix),
+		Purpose:   "batch_output",
+		Status:    model.FileStatusProcessed,
+		CreatedAt: time.Now().Unix(),
+	}
+	file.StoragePath = fmt.Sprintf("%d/%s", task.UserId, file.FileId)
+	n, err := service.GetFileStorage().Save(file.StoragePath, w.file)
+	if err != nil {
+		return "", err
+	}
+	file.Bytes = n
+	if err = file.Insert(); err != nil {
+		_ = service.GetFileStorage().Delete(file.StoragePath)
+		return "", err
+	}
+	return file.FileId, nil
+}
+
+func runBatch(task *model.Task) {
+	data := task.GetBatchData()
+	lines, lineErrors, err := loadBatchInputLines(task.UserId, data)
+	if err != nil {
+		failBatch(task, "invalid_file", err.Error())
+		return
+	}
+	if len(lineErrors) > 0 {
+		failBatch(task, "invalid_request", "input file contains invalid lines", lineErrors...)
+		return
+	}
+	token, err := model.GetTokenById(data.TokenId)
+	if err != nil {
+		failBatch(task, "invalid_token", "token of this batch is no longer available")
+		return
+	}
+
+	now := time.Now().Unix()
+	data.Status = model.BatchStatusInProgress
+	data.InProgressAt = now
+	data.RequestCounts = dto.BatchRequestCounts{Total: len(lines)}
+	task.SetData(data)
+	ok, err := model.UpdateTaskIfStatus(task.ID, []model.TaskStatus{model.TaskStatusQueued}, map[string]any{
+		"status":     model.TaskStatusInProgress,
+		"start_time": now,
+		"data":       task.Data,
+	})
+	if err != nil || !ok {
+		// 已被取消，交由下一轮调度收尾
+		return
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		stopped   atomic.Bool
+		expired   atomic.Bool
+		lastSave  = time.Now()
+		outWriter = &batchResultWriter{}
+		errWriter = &batchResultWriter{}
+	)
+	defer outWriter.close()
+	defer errWriter.close()
+
+	// saveProgress 仅在仍处于执行中时写入，返回 false 表示批处理已被取消
+	saveProgress := func() bool {
+		task.SetData(data)
+		ok, err := model.UpdateTaskIfStatus(task.ID, []model.TaskStatus{model.TaskStatusInProgress}, map[string]any{
+			"progress": fmt.Sprintf("%d%%", min(99, (data.RequestCounts.Completed+data.RequestCounts.Failed)*100/max(1, data.RequestCounts.Total))),
+			"data":     task.Data,
+		})
+		if err != nil {
+			common.SysError(fmt.Sprintf("save batch %s progress failed: %s", task.TaskID, err.Error()))
+			return true
+		}
+		return ok
+	}
+
+	processed := 0
+	for _, line := range lines {
+		if time.Now().Unix() > data.ExpiresAt {
+			expired.Store(true)
+		}
+		if stopped.Load() || expired.Load() {
+			break
+		}
+		acquireBatchSlot()
+		processed++
+		wg.Add(1)
+		line := line
+		gopool.Go(func() {
+			defer wg.Done()
+			defer releaseBatchSlot()
+			result, success := executeBatchLine(task, token, data.ClientIp, line)
+
+			mu.Lock()
+			defer mu.Unlock()
+			writer := outWriter
+			if success {
+				data.RequestCounts.Completed++
+			} else {
+				data.RequestCounts.Failed++
+				writer = errWriter
+			}
+			if err := writer.write(result); err != nil {
+				common.SysError(fmt.Sprintf("write batch %s result failed: %s", task.TaskID, err.Error()))
+			}
+			if time.Since(lastSave) >= batchProgressSavePeriod {
+				lastSave = time.Now()
+				if !saveProgress() {
+					stopped.Store(true)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	finalStatus := model.TaskStatus(model.TaskStatusSuccess)
+	if expired.Load() {
+		finalStatus = model.TaskStatusExpired
+		for _, line := range lines[processed:] {
+			_ = errWriter.write(&dto.BatchOutputLine{
+				Id:       "batch_req_" + common.GetRandomString(24),
+				CustomId: line.CustomId,
+				Error:    &dto.BatchError{Code: "batch_expired", Message: "This request could not be executed before the completion window expired."},
+			})
+		}
+	}
+	if stopped.Load() || !saveProgress() {
+		finalStatus = model.TaskStatusCancelled
+	}
+
+	data.FinalizingAt = time.Now().Unix()
+	if data.OutputFileId, err = outWriter.save(task, "output"); err != nil {
+		common.SysError(fmt.Sprintf("save batch %s output file failed: %s", task.TaskID, err.Error()))
+	}
+	if data.ErrorFileId, err = errWriter.save(task, "error"); err != nil {
+		common.SysError(fmt.Sprintf("save batch %s error file failed: %s", task.TaskID, err.Error()))
+	}
+	if finalStatus == model.TaskStatusCancelled {
+		// 取消请求写入的 cancelling_at 以数据库为准
+		if latest, exist, err := model.GetByTaskId(task.UserId, task.TaskID); err == nil && exist {
+			data.CancellingAt = latest.GetBatchData().CancellingAt
+		}
+	}
+	finishBatch(task, finalStatus, data)
+	common.SysLog(fmt.Sprintf("batch %s finished: status=%s, completed=%d, failed=%d",
+		task.TaskID, finalStatus, data.RequestCounts.Completed, data.RequestCounts.Failed))
+}
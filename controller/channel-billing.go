@@ -462,6 +462,7 @@ func updateAllChannelsBalance() error {
 		if err != nil {
 			continue
 		} else {
+			service.ReconcileChannelBalance(channel, balance)
 			// err is nil & balance <= 0 means quota is used up
 			if balance <= 0 {
 				service.DisableChannel(*types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, "", channel.GetAutoBan()), "余额不足")
@@ -494,3 +495,35 @@ func AutomaticallyUpdateChannels(frequency int) {
 		common.SysLog("channels update done")
 	}
 }
+
+// GetChannelReconciliations 查询渠道对账历史，channel_id 为空时返回全部渠道
+func GetChannelReconciliations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	items, total, err := model.GetChannelReconciliations(channelId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(items)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetChannelBalanceSnapshots 查询渠道余额快照
+func GetChannelBalanceSnapshots(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	snapshots, total, err := model.GetChannelBalanceSnapshots(id, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(snapshots)
+	common.ApiSuccess(c, pageInfo)
+}
@@ -8,6 +8,7 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	"one-api/setting/operation_setting"
 	"strconv"
 	"strings"
 
@@ -467,6 +468,10 @@ func validateChannel(channel *model.Channel, isAdd bool) error {
 		return fmt.Errorf("渠道额外设置[channel setting] 格式错误：%s", err.Error())
 	}
 
+	if err := channel.ValidateCostPrices(); err != nil {
+		return fmt.Errorf("渠道成本价[cost prices] 格式错误：%s", err.Error())
+	}
+
 	// 如果是添加操作，检查 channel 和 key 是否为空
 	if isAdd {
 		if channel == nil || channel.Key == "" {
@@ -1101,11 +1106,14 @@ func CopyChannel(c *gin.Context) {
 // MultiKeyManageRequest represents the request for multi-key management operations
 type MultiKeyManageRequest struct {
 	ChannelId int    `json:"channel_id"`
-	Action    string `json:"action"`              // "disable_key", "enable_key", "delete_disabled_keys", "get_key_status"
+	Action    string `json:"action"`              // "disable_key", "enable_key", "delete_disabled_keys", "get_key_status", "set_key_config"
 	KeyIndex  *int   `json:"key_index,omitempty"` // for disable_key and enable_key actions
 	Page      int    `json:"page,omitempty"`      // for get_key_status pagination
 	PageSize  int    `json:"page_size,omitempty"` // for get_key_status pagination
 	Status    *int   `json:"status,omitempty"`    // for get_key_status filtering: 1=enabled, 2=manual_disabled, 3=auto_disabled, nil=all
+	Weight    *int   `json:"weight,omitempty"`    // for set_key_config: weighted 模式下的 key 权重
+	RPMLimit  *int   `json:"rpm_limit,omitempty"` // for set_key_config: 不指定 key_index 时设置所有 key 的默认值
+	TPMLimit  *int   `json:"tpm_limit,omitempty"` // for set_key_config: 不指定 key_index 时设置所有 key 的默认值
 }
 
 // MultiKeyStatusResponse represents the response for key status query
@@ -1119,6 +1127,9 @@ type MultiKeyStatusResponse struct {
 	EnabledCount        int `json:"enabled_count"`
 	ManualDisabledCount int `json:"manual_disabled_count"`
 	AutoDisabledCount   int `json:"auto_disabled_count"`
+	// usage 模式下每个 key 的默认限额
+	RPMLimit int `json:"rpm_limit"`
+	TPMLimit int `json:"tpm_limit"`
 }
 
 type KeyStatus struct {
@@ -1127,6 +1138,10 @@ type KeyStatus struct {
 	DisabledTime int64  `json:"disabled_time,omitempty"`
 	Reason       string `json:"reason,omitempty"`
 	KeyPreview   string `json:"key_preview"` // first 10 chars of key for identification
+	Weight       int    `json:"weight"`
+	RPMLimit     int    `json:"rpm_limit"`
+	TPMLimit     int    `json:"tpm_limit"`
+	model.ChannelKeyUsage
 }
 
 // ManageMultiKeys handles multi-key management operations
@@ -1176,6 +1191,8 @@ func ManageMultiKeys(c *gin.Context) {
 		// Statistics for all keys (unchanged by filtering)
 		var enabledCount, manualDisabledCount, autoDisabledCount int
 
+		keyUsages := model.GetChannelKeyUsage(channel.Id)
+
 		// Build all key status data first
 		var allKeyStatusList []KeyStatus
 		for i, key := range keys {
@@ -1215,11 +1232,15 @@ func ManageMultiKeys(c *gin.Context) {
 			}
 
 			allKeyStatusList = append(allKeyStatusList, KeyStatus{
-				Index:        i,
-				Status:       status,
-				DisabledTime: disabledTime,
-				Reason:       reason,
-				KeyPreview:   keyPreview,
+				Index:           i,
+				Status:          status,
+				DisabledTime:    disabledTime,
+				Reason:          reason,
+				KeyPreview:      keyPreview,
+				Weight:          channel.ChannelInfo.KeyWeight(i),
+				RPMLimit:        channel.ChannelInfo.KeyRPMLimit(i),
+				TPMLimit:        channel.ChannelInfo.KeyTPMLimit(i),
+				ChannelKeyUsage: keyUsages[i],
 			})
 		}
 
@@ -1270,6 +1291,8 @@ func ManageMultiKeys(c *gin.Context) {
 				EnabledCount:        enabledCount,        // Overall statistics
 				ManualDisabledCount: manualDisabledCount, // Overall statistics
 				AutoDisabledCount:   autoDisabledCount,   // Overall statistics
+				RPMLimit:            channel.ChannelInfo.MultiKeyRPMLimit,
+				TPMLimit:            channel.ChannelInfo.MultiKeyTPMLimit,
 			},
 		})
 		return
@@ -1430,6 +1453,57 @@ func ManageMultiKeys(c *gin.Context) {
 		})
 		return
 
+	case "set_key_config":
+		if request.KeyIndex == nil {
+			// 未指定 key 时设置所有 key 的默认限额
+			if request.RPMLimit != nil {
+				channel.ChannelInfo.MultiKeyRPMLimit = max(*request.RPMLimit, 0)
+			}
+			if request.TPMLimit != nil {
+				channel.ChannelInfo.MultiKeyTPMLimit = max(*request.TPMLimit, 0)
+			}
+		} else {
+			keyIndex := *request.KeyIndex
+			if keyIndex < 0 || keyIndex >= channel.ChannelInfo.MultiKeySize {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": "密钥索引超出范围",
+				})
+				return
+			}
+			if request.Weight != nil {
+				if channel.ChannelInfo.MultiKeyWeights == nil {
+					channel.ChannelInfo.MultiKeyWeights = make(map[int]int)
+				}
+				channel.ChannelInfo.MultiKeyWeights[keyIndex] = max(*request.Weight, 1)
+			}
+			if request.RPMLimit != nil {
+				if channel.ChannelInfo.MultiKeyRPMLimits == nil {
+					channel.ChannelInfo.MultiKeyRPMLimits = make(map[int]int)
+				}
+				channel.ChannelInfo.MultiKeyRPMLimits[keyIndex] = max(*request.RPMLimit, 0)
+			}
+			if request.TPMLimit != nil {
+				if channel.ChannelInfo.MultiKeyTPMLimits == nil {
+					channel.ChannelInfo.MultiKeyTPMLimits = make(map[int]int)
+				}
+				channel.ChannelInfo.MultiKeyTPMLimits[keyIndex] = max(*request.TPMLimit, 0)
+			}
+		}
+
+		err = channel.Update()
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+
+		model.InitChannelCache()
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "密钥配置已更新",
+		})
+		return
+
 	case "delete_disabled_keys":
 		keys := channel.GetKeys()
 		var remainingKeys []string
@@ -1437,6 +1511,9 @@ func ManageMultiKeys(c *gin.Context) {
 		var newStatusList = make(map[int]int)
 		var newDisabledTime = make(map[int]int64)
 		var newDisabledReason = make(map[int]string)
+		var newWeights = make(map[int]int)
+		var newRPMLimits = make(map[int]int)
+		var newTPMLimits = make(map[int]int)
 
 		newIndex := 0
 		for i, key := range keys {
@@ -1452,6 +1529,15 @@ func ManageMultiKeys(c *gin.Context) {
 				deletedCount++
 			} else {
 				remainingKeys = append(remainingKeys, key)
+				if w, exists := channel.ChannelInfo.MultiKeyWeights[i]; exists {
+					newWeights[newIndex] = w
+				}
+				if l, exists := channel.ChannelInfo.MultiKeyRPMLimits[i]; exists {
+					newRPMLimits[newIndex] = l
+				}
+				if l, exists := channel.ChannelInfo.MultiKeyTPMLimits[i]; exists {
+					newTPMLimits[newIndex] = l
+				}
 				// 保留非自动禁用密钥的状态信息，重新索引
 				if status != 1 {
 					newStatusList[newIndex] = status
@@ -1484,6 +1570,9 @@ func ManageMultiKeys(c *gin.Context) {
 		channel.ChannelInfo.MultiKeyStatusList = newStatusList
 		channel.ChannelInfo.MultiKeyDisabledTime = newDisabledTime
 		channel.ChannelInfo.MultiKeyDisabledReason = newDisabledReason
+		channel.ChannelInfo.MultiKeyWeights = newWeights
+		channel.ChannelInfo.MultiKeyRPMLimits = newRPMLimits
+		channel.ChannelInfo.MultiKeyTPMLimits = newTPMLimits
 
 		err = channel.Update()
 		if err != nil {
@@ -1507,3 +1596,74 @@ func ManageMultiKeys(c *gin.Context) {
 		return
 	}
 }
+
+// GetChannelStats 返回动态渠道选择使用的滑动窗口统计，可按 channel_id、model 过滤
+func GetChannelStats(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	modelName := c.Query("model")
+	stats := make([]model.ChannelModelStats, 0)
+	for _, s := range model.GetAllChannelModelStats() {
+		if channelId != 0 && s.ChannelId != channelId {
+			continue
+		}
+		if modelName != "" && s.Model != modelName {
+			continue
+		}
+		stats = append(stats, s)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"setting": operation_setting.GetChannelSelectionSetting(),
+			"stats":   stats,
+		},
+	})
+}
+
+// GetChannelBreaker 查看渠道及其各 key 的熔断状态
+func GetChannelBreaker(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(id, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channelState, keyStates := model.GetChannelBreakerStates(channel)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"enabled":    operation_setting.GetCircuitBreakerSetting().Enabled,
+			"channel":    channelState,
+			"keys":       keyStates,
+			"channel_id": channel.Id,
+		},
+	})
+}
+
+// ResetChannelBreaker 手动关闭渠道及其各 key 的熔断
+func ResetChannelBreaker(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(id, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.ResetChannelBreaker(channel); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
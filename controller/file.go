@@ -0,0 +1,313 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/model"
+	"one-api/relay"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const fileBillingModelName = "files"
+
+func fileErrorResponse(c *gin.Context, statusCode int, code types.ErrorCode, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": types.OpenAIError{
+			Message: common.MessageWithRequestId(message, c.GetString(common.RequestIdKey)),
+			Type:    string(types.ErrorTypeNewAPIError),
+			Code:    code,
+		},
+	})
+}
+
+func fileToOpenAIFile(file *model.File) dto.OpenAIFile {
+	return dto.OpenAIFile{
+		Id:        file.FileId,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+		Status:    file.Status,
+	}
+}
+
+// calculateFileQuota 按 MB 向上取整计费
+func calculateFileQuota(bytes int64) int {
+	quotaPerMB := operation_setting.GetFileSetting().QuotaPerMB
+	if quotaPerMB <= 0 || bytes <= 0 {
+		return 0
+	}
+	mb := math.Ceil(float64(bytes) / float64(1024*1024))
+	return int(mb) * quotaPerMB
+}
+
+func getUserFileOrAbort(c *gin.Context) *model.File {
+	file, err := model.GetUserFileByFileId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			fileErrorResponse(c, http.StatusNotFound, types.ErrorCodeInvalidRequest, fmt.Sprintf("No such File object: %s", c.Param("id")))
+		} else {
+			fileErrorResponse(c, http.StatusInternalServerError, types.ErrorCodeQueryDataError, err.Error())
+		}
+		return nil
+	}
+	return file
+}
+
+// selectFileChannel 为上传选择上游渠道，未配置或不支持时返回 nil，文件仅保存在本地
+func selectFileChannel(c *gin.Context) *model.Channel {
+	upstreamModel := operation_setting.GetFileSetting().UpstreamModel
+	if upstreamModel == "" {
+		return nil
+	}
+	group := common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
+	channel, _, err := model.CacheGetRandomSatisfiedChannel(c, group, upstreamModel, 0)
+	if err != nil || channel == nil {
+		return nil
+	}
+	if !relay.IsFileRelaySupported(channel) {
+		return nil
+	}
+	return channel
+}
+
+func UploadFile(c *gin.Context) {
+	userId := c.GetInt("id")
+	maxBytes := int64(constant.MaxFileUploadMB) << 20
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+(1<<20))
+
+	purpose := c.PostForm("purpose")
+	if purpose == "" {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeInvalidRequest, "purpose is required")
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeInvalidRequest, "file is required: "+err.Error())
+		return
+	}
+	if header.Size > maxBytes {
+		fileErrorResponse(c, http.StatusRequestEntityTooLarge, types.ErrorCodeInvalidRequest, fmt.Sprintf("file size exceeds the limit of %d MB", constant.MaxFileUploadMB))
+		return
+	}
+
+	// 额度检查：上传前按文件大小校验用户和令牌余额
+	fileQuota := calculateFileQuota(header.Size)
+	userQuota, err := model.GetUserQuota(userId, false)
+	if err != nil {
+		fileErrorResponse(c, http.StatusInternalServerError, types.ErrorCodeQueryDataError, err.Error())
+		return
+	}
+	if userQuota <= 0 || userQuota < fileQuota {
+		fileErrorResponse(c, http.StatusForbidden, types.ErrorCodeInsufficientUserQuota, fmt.Sprintf("用户额度不足, 剩余额度: %s, 需要额度: %s", logger.FormatQuota(userQuota), logger.FormatQuota(fileQuota)))
+		return
+	}
+	if !c.GetBool("token_unlimited_quota") && c.GetInt("token_quota") < fileQuota {
+		fileErrorResponse(c, http.StatusForbidden, types.ErrorCodePreConsumeTokenQuotaFailed, fmt.Sprintf("令牌额度不足, 需要额度: %s", logger.FormatQuota(fileQuota)))
+		return
+	}
+
+	file := &model.File{
+		FileId:    "file-" + common.GetRandomString(24),
+		UserId:    userId,
+		TokenId:   c.GetInt("token_id"),
+		Filename:  header.Filename,
+		Purpose:   purpose,
+		Status:    model.FileStatusUploaded,
+		CreatedAt: common.GetTimestamp(),
+	}
+	file.StoragePath = fmt.Sprintf("%d/%s", userId, file.FileId)
+
+	src, err := header.Open()
+	if err != nil {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeReadRequestBodyFailed, err.Error())
+		return
+	}
+	defer src.Close()
+	storage := service.GetFileStorage()
+	file.Bytes, err = storage.Save(file.StoragePath, src)
+	if err != nil {
+		fileErrorResponse(c, http.StatusInternalServerError, types.ErrorCodeUpdateDataError, "failed to store file: "+err.Error())
+		return
+	}
+
+	if channel := selectFileChannel(c); channel != nil {
+		key, keyIndex, apiErr := channel.GetNextEnabledKey()
+		if apiErr != nil {
+			_ = storage.Delete(file.StoragePath)
+			fileErrorResponse(c, http.StatusServiceUnavailable, types.ErrorCodeGetChannelFailed, apiErr.Error())
+			return
+		}
+		content, err := storage.Open(file.StoragePath)
+		if err != nil {
+			_ = storage.Delete(file.StoragePath)
+			fileErrorResponse(c, http.StatusInternalServerError, types.ErrorCodeQueryDataError, err.Error())
+			return
+		}
+		upstreamFile, err := relay.UploadFileToChannel(channel, key, file.Filename, file.Purpose, content)
+		content.Close()
+		if err != nil {
+			_ = storage.Delete(file.StoragePath)
+			logger.LogError(c, fmt.Sprintf("upload file to channel #%d failed: %s", channel.Id, err.Error()))
+			fileErrorResponse(c, http.StatusBadGateway, types.ErrorCodeDoRequestFailed, "failed to upload file to upstream")
+			return
+		}
+		file.ChannelId = channel.Id
+		file.KeyIndex = keyIndex
+		file.UpstreamFileId = upstreamFile.Id
+		if upstreamFile.Status != "" {
+			file.Status = upstreamFile.Status
+		}
+	}
+
+	file.Quota = fileQuota
+	if err = file.Insert(); err != nil {
+		_ = storage.Delete(file.StoragePath)
+		fileErrorResponse(c, http.StatusInternalServerError, types.ErrorCodeUpdateDataError, err.Error())
+		return
+	}
+
+	if fileQuota > 0 {
+		relayInfo := &relaycommon.RelayInfo{
+			UserId:   userId,
+			TokenId:  file.TokenId,
+			TokenKey: c.GetString("token_key"),
+		}
+		if err = service.PostConsumeQuota(relayInfo, fileQuota, 0, false); err != nil {
+			logger.LogError(c, "error consuming file quota: "+err.Error())
+		}
+		model.UpdateUserUsedQuotaAndRequestCount(userId, fileQuota)
+		if file.ChannelId != 0 {
+			model.UpdateChannelUsedQuota(file.ChannelId, fileQuota)
+		}
+		model.RecordConsumeLog(c, userId, model.RecordConsumeLogParams{
+			ChannelId: file.ChannelId,
+			ModelName: fileBillingModelName,
+			TokenName: c.GetString("token_name"),
+			Quota:     fileQuota,
+			Content:   fmt.Sprintf("上传文件 %s（%s），%s", file.FileId, common.Bytes2Size(file.Bytes), file.Purpose),
+			TokenId:   file.TokenId,
+			Group:     common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
+			Other: map[string]interface{}{
+				"file_id": file.FileId,
+				"bytes":   file.Bytes,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, fileToOpenAIFile(file))
+}
+
+func ListFiles(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 10000 {
+		limit = 10000
+	}
+	files, err := model.GetUserFiles(c.GetInt("id"), c.Query("purpose"), c.Query("after"), limit+1)
+	if err != nil {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeQueryDataError, err.Error())
+		return
+	}
+	resp := dto.OpenAIFileList{
+		Object: "list",
+		Data:   make([]dto.OpenAIFile, 0, len(files)),
+	}
+	if len(files) > limit {
+		resp.HasMore = true
+		files = files[:limit]
+	}
+	for _, file := range files {
+		resp.Data = append(resp.Data, fileToOpenAIFile(file))
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstId = resp.Data[0].Id
+		resp.LastId = resp.Data[len(resp.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func RetrieveFile(c *gin.Context) {
+	file := getUserFileOrAbort(c)
+	if file == nil {
+		return
+	}
+	c.JSON(http.StatusOK, fileToOpenAIFile(file))
+}
+
+func DeleteFile(c *gin.Context) {
+	file := getUserFileOrAbort(c)
+	if file == nil {
+		return
+	}
+	if file.UpstreamFileId != "" {
+		channel, err := model.CacheGetChannel(file.ChannelId)
+		if err == nil {
+			err = relay.DeleteFileFromChannel(channel, relay.GetChannelKeyByIndex(channel, file.KeyIndex), file.UpstreamFileId)
+		}
+		if err != nil {
+			// 上游删除失败不影响本地删除，仅记录日志
+			logger.LogWarn(c, fmt.Sprintf("delete upstream file %s on channel #%d failed: %s", file.UpstreamFileId, file.ChannelId, err.Error()))
+		}
+	}
+	if err := service.GetFileStorage().Delete(file.StoragePath); err != nil {
+		logger.LogWarn(c, fmt.Sprintf("delete local file %s failed: %s", file.FileId, err.Error()))
+	}
+	if err := file.Delete(); err != nil {
+		fileErrorResponse(c, http.StatusInternalServerError, types.ErrorCodeUpdateDataError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileDeleted{
+		Id:      file.FileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
+
+func GetFileContent(c *gin.Context) {
+	file := getUserFileOrAbort(c)
+	if file == nil {
+		return
+	}
+	content, err := service.GetFileStorage().Open(file.StoragePath)
+	if err == nil {
+		defer content.Close()
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+		c.DataFromReader(http.StatusOK, file.Bytes, "application/octet-stream", content, nil)
+		return
+	}
+	// 本地内容缺失时回退到上传时的渠道
+	if file.UpstreamFileId == "" {
+		fileErrorResponse(c, http.StatusNotFound, types.ErrorCodeQueryDataError, "file content not found")
+		return
+	}
+	channel, err := model.CacheGetChannel(file.ChannelId)
+	if err != nil {
+		fileErrorResponse(c, http.StatusServiceUnavailable, types.ErrorCodeGetChannelFailed, err.Error())
+		return
+	}
+	resp, err := relay.FetchFileContentFromChannel(channel, relay.GetChannelKeyByIndex(channel, file.KeyIndex), file.UpstreamFileId)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("fetch upstream file %s content failed: %s", file.UpstreamFileId, err.Error()))
+		fileErrorResponse(c, http.StatusBadGateway, types.ErrorCodeDoRequestFailed, "failed to fetch file content from upstream")
+		return
+	}
+	defer resp.Body.Close()
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", resp.Header.Get("Content-Type"))
+	_, _ = io.Copy(c.Writer, resp.Body)
+}
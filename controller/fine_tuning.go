@@ -0,0 +1,591 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/model"
+	"one-api/relay"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/setting/ratio_setting"
+	"one-api/types"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 微调训练计费优先使用 "<基础模型>-fine-tuning" 的模型倍率，未配置时回退到基础模型倍率
+const fineTuningRatioSuffix = "-fine-tuning"
+
+const (
+	fineTuningBytesPerToken = 4
+	fineTuningDefaultEpochs = 3
+)
+
+var fineTuningUnfinishedStatus = []model.TaskStatus{
+	model.TaskStatusNotStart,
+	model.TaskStatusSubmitted,
+	model.TaskStatusQueued,
+	model.TaskStatusInProgress,
+}
+
+func getFineTuningModelRatio(baseModel string) (float64, bool) {
+	if ratio, ok := ratio_setting.GetModelRatioCopy()[baseModel+fineTuningRatioSuffix]; ok {
+		return ratio, true
+	}
+	ratio, ok, _ := ratio_setting.GetModelRatio(baseModel)
+	return ratio, ok
+}
+
+// fineTuningJobResponse 将上游任务对象中的文件 ID 替换为本地文件 ID
+func fineTuningJobResponse(task *model.Task) map[string]any {
+	data := task.GetFineTuningData()
+	job := make(map[string]any)
+	_ = common.Unmarshal(data.Job, &job)
+	job["training_file"] = data.TrainingFileId
+	if data.ValidationFileId != "" {
+		job["validation_file"] = data.ValidationFileId
+	}
+	return job
+}
+
+func getUserFineTuningTaskOrAbort(c *gin.Context) *model.Task {
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		fileErrorResponse(c, http.StatusInternalServerError, types.ErrorCodeQueryDataError, err.Error())
+		return nil
+	}
+	if !exist || task.Platform != constant.TaskPlatformFineTuning {
+		fileErrorResponse(c, http.StatusNotFound, types.ErrorCodeInvalidRequest, fmt.Sprintf("No such fine-tuning job: %s", c.Param("id")))
+		return nil
+	}
+	return task
+}
+
+// getFineTuningTaskChannel 返回任务所在渠道以及创建任务时使用的 key
+func getFineTuningTaskChannel(task *model.Task) (*model.Channel, string, error) {
+	channel, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		return nil, "", err
+	}
+	return channel, relay.GetChannelKeyByIndex(channel, task.GetFineTuningData().KeyIndex), nil
+}
+
+// selectFineTuningChannel 训练文件已上传至某个渠道时沿用该渠道，否则按基础模型选择 OpenAI 兼容渠道
+func selectFineTuningChannel(c *gin.Context, group string, baseModel string, trainingFile *model.File) (*model.Channel, string, int, string, error) {
+	if trainingFile.UpstreamFileId != "" {
+		channel, err := model.CacheGetChannel(trainingFile.ChannelId)
+		if err == nil && channel.Status == common.ChannelStatusEnabled && relay.IsFileRelaySupported(channel) {
+			return channel, relay.GetChannelKeyByIndex(channel, trainingFile.KeyIndex), trainingFile.KeyIndex, group, nil
+		}
+	}
+	channel, selectGroup, err := model.CacheGetRandomSatisfiedChannel(c, group, baseModel, 0)
+	if err != nil {
+		return nil, "", 0, selectGroup, err
+	}
+	if channel == nil {
+		return nil, "", 0, selectGroup, fmt.Errorf("分组 %s 下模型 %s 无可用渠道", selectGroup, baseModel)
+	}
+	if !relay.IsFileRelaySupported(channel) {
+		return nil, "", 0, selectGroup, fmt.Errorf("渠道 #%d 不支持微调", channel.Id)
+	}
+	key, keyIndex, apiErr := channel.GetNextEnabledKey()
+	if apiErr != nil {
+		return nil, "", 0, selectGroup, apiErr
+	}
+	return channel, key, keyIndex, selectGroup, nil
+}
+
+// ensureUpstreamFile 确保文件已存在于目标渠道的同一上游账号上，必要时从本地存储上传
+func ensureUpstreamFile(file *model.File, channel *model.Channel, key string, keyIndex int) (string, error) {
+	if file.UpstreamFileId != "" && file.ChannelId == channel.Id && file.KeyIndex == keyIndex {
+		return file.UpstreamFileId, nil
+	}
+	content, err := service.GetFileStorage().Open(file.StoragePath)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+	upstreamFile, err := relay.UploadFileToChannel(channel, key, file.Filename, file.Purpose, content)
+	if err != nil {
+		return "", err
+	}
+	if file.UpstreamFileId == "" {
+		file.ChannelId = channel.Id
+		file.KeyIndex = keyIndex
+		file.UpstreamFileId = upstreamFile.Id
+		if err = file.Update(); err != nil {
+			common.SysError(fmt.Sprintf("update file %s upstream id failed: %s", file.FileId, err.Error()))
+		}
+	}
+	return upstreamFile.Id, nil
+}
+
+func CreateFineTuningJob(c *gin.Context) {
+	req := make(map[string]any)
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeInvalidRequest, err.Error())
+		return
+	}
+	baseModel, _ := req["model"].(string)
+	trainingFileId, _ := req["training_file"].(string)
+	validationFileId, _ := req["validation_file"].(string)
+	if baseModel == "" || trainingFileId == "" {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeInvalidRequest, "model and training_file are required")
+		return
+	}
+	if common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		tokenModelLimit, _ := common.GetContextKeyType[map[string]bool](c, constant.ContextKeyTokenModelLimit)
+		if !tokenModelLimit[baseModel] {
+			fileErrorResponse(c, http.StatusForbidden, types.ErrorCodeAccessDenied, "该令牌无权访问模型 "+baseModel)
+			return
+		}
+	}
+	if _, ok := getFineTuningModelRatio(baseModel); !ok {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeModelPriceError, fmt.Sprintf("模型 %s 倍率未配置，请联系管理员设置", baseModel))
+		return
+	}
+
+	userId := c.GetInt("id")
+	trainingFile, err := model.GetUserFileByFileId(userId, trainingFileId)
+	if err != nil {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeInvalidRequest, fmt.Sprintf("No such File object: %s", trainingFileId))
+		return
+	}
+	var validationFile *model.File
+	if validationFileId != "" {
+		validationFile, err = model.GetUserFileByFileId(userId, validationFileId)
+		if err != nil {
+			fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeInvalidRequest, fmt.Sprintf("No such File object: %s", validationFileId))
+			return
+		}
+	}
+
+	group := common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
+	channel, key, keyIndex, selectGroup, err := selectFineTuningChannel(c, group, baseModel, trainingFile)
+	if err != nil {
+		fileErrorResponse(c, http.StatusServiceUnavailable, types.ErrorCodeGetChannelFailed, err.Error())
+		return
+	}
+	if selectGroup == "" || selectGroup == "auto" {
+		selectGroup = common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+	}
+
+	// 训练结束前无法得知实际用量，按训练文件大小预扣估算费用，结算时多退少补
+	relayInfo := &relaycommon.RelayInfo{
+		UserId:              userId,
+		TokenId:             c.GetInt("token_id"),
+		TokenKey:            c.GetString("token_key"),
+		TokenUnlimited:      c.GetBool("token_unlimited_quota"),
+		TokenBudgetPeriod:   common.GetContextKeyString(c, constant.ContextKeyTokenBudgetPeriod),
+		TokenBudgetTimezone: common.GetContextKeyString(c, constant.ContextKeyTokenBudgetTimezone),
+		OrgId:               common.GetContextKeyInt(c, constant.ContextKeyTokenOrgId),
+		OriginModelName:     baseModel,
+	}
+	if apiErr := service.PreConsumeQuota(c, estimateFineTuningQuota(baseModel, selectGroup, trainingFile, req), relayInfo); apiErr != nil {
+		fileErrorResponse(c, apiErr.StatusCode, apiErr.GetErrorCode(), apiErr.Error())
+		return
+	}
+	created := false
+	defer func() {
+		if !created {
+			service.ReturnPreConsumedQuota(c, relayInfo)
+		}
+	}()
+
+	upstreamFileId, err := ensureUpstreamFile(trainingFile, channel, key, keyIndex)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("upload training file to channel #%d failed: %s", channel.Id, err.Error()))
+		fileErrorResponse(c, http.StatusBadGateway, types.ErrorCodeDoRequestFailed, "failed to upload training file to upstream")
+		return
+	}
+	req["training_file"] = upstreamFileId
+	if validationFile != nil {
+		upstreamFileId, err = ensureUpstreamFile(validationFile, channel, key, keyIndex)
+		if err != nil {
+			logger.LogError(c, fmt.Sprintf("upload validation file to channel #%d failed: %s", channel.Id, err.Error()))
+			fileErrorResponse(c, http.StatusBadGateway, types.ErrorCodeDoRequestFailed, "failed to upload validation file to upstream")
+			return
+		}
+		req["validation_file"] = upstreamFileId
+	}
+
+	body, err := common.Marshal(req)
+	if err != nil {
+		fileErrorResponse(c, http.StatusInternalServerError, types.ErrorCodeJsonMarshalFailed, err.Error())
+		return
+	}
+	statusCode, respBody, err := relay.DoFineTuningRequest(channel, key, http.MethodPost, "/v1/fine_tuning/jobs", body)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("create fine-tuning job on channel #%d failed: %s", channel.Id, err.Error()))
+		fileErrorResponse(c, http.StatusBadGateway, types.ErrorCodeDoRequestFailed, "failed to create fine-tuning job upstream")
+		return
+	}
+	if statusCode != http.StatusOK {
+		c.Data(statusCode, "application/json", respBody)
+		return
+	}
+	var job dto.FineTuningJob
+	if err = common.Unmarshal(respBody, &job); err != nil || job.Id == "" {
+		fileErrorResponse(c, http.StatusBadGateway, types.ErrorCodeBadResponseBody, "invalid fine-tuning job response from upstream")
+		return
+	}
+
+	task := &model.Task{
+		TaskID:     job.Id,
+		Platform:   constant.TaskPlatformFineTuning,
+		UserId:     userId,
+		ChannelId:  channel.Id,
+		Action:     "fine_tune",
+		SubmitTime: time.Now().Unix(),
+		Properties: model.Properties{Input: baseModel},
+	}
+	applyFineTuningJobStatus(task, &job)
+	task.SetData(model.FineTuningData{
+		Job:              respBody,
+		BaseModel:        baseModel,
+		Group:            selectGroup,
+		KeyIndex:         keyIndex,
+		TokenId:          c.GetInt("token_id"),
+		TokenName:        c.GetString("token_name"),
+		OrgId:            relayInfo.OrgId,
+		TrainingFileId:   trainingFileId,
+		ValidationFileId: validationFileId,
+		PreConsumedQuota: relayInfo.FinalPreConsumedQuota,
+	})
+	if err = task.Insert(); err != nil {
+		fileErrorResponse(c, http.StatusInternalServerError, types.ErrorCodeUpdateDataError, err.Error())
+		return
+	}
+	created = true
+	c.JSON(http.StatusOK, fineTuningJobResponse(task))
+}
+
+func ListFineTuningJobs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	tasks, err := model.GetUserFineTuningTasks(c.GetInt("id"), c.Query("after"), limit+1)
+	if err != nil {
+		fileErrorResponse(c, http.StatusBadRequest, types.ErrorCodeQueryDataError, err.Error())
+		return
+	}
+	resp := gin.H{
+		"object":   "list",
+		"has_more": len(tasks) > limit,
+	}
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+	}
+	data := make([]map[string]any, 0, len(tasks))
+	for _, task := range tasks {
+		data = append(data, fineTuningJobResponse(task))
+	}
+	resp["data"] = data
+	c.JSON(http.StatusOK, resp)
+}
+
+func RetrieveFineTuningJob(c *gin.Context) {
+	task := getUserFineTuningTaskOrAbort(c)
+	if task == nil {
+		return
+	}
+	if err := refreshFineTuningTask(c, task); err != nil {
+		// 上游暂时不可用时返回最近一次的状态
+		logger.LogWarn(c, fmt.Sprintf("refresh fine-tuning job %s failed: %s", task.TaskID, err.Error()))
+	}
+	c.JSON(http.StatusOK, fineTuningJobResponse(task))
+}
+
+func CancelFineTuningJob(c *gin.Context) {
+	task := getUserFineTuningTaskOrAbort(c)
+	if task == nil {
+		return
+	}
+	channel, key, err := getFineTuningTaskChannel(task)
+	if err != nil {
+		fileErrorResponse(c, http.StatusServiceUnavailable, types.ErrorCodeGetChannelFailed, err.Error())
+		return
+	}
+	statusCode, respBody, err := relay.DoFineTuningRequest(channel, key, http.MethodPost, "/v1/fine_tuning/jobs/"+task.TaskID+"/cancel", nil)
+	if err != nil {
+		fileErrorResponse(c, http.StatusBadGateway, types.ErrorCodeDoRequestFailed, err.Error())
+		return
+	}
+	if statusCode != http.StatusOK {
+		c.Data(statusCode, "application/json", respBody)
+		return
+	}
+	if err = updateFineTuningTask(c, task, respBody); err != nil {
+		logger.LogError(c, fmt.Sprintf("update fine-tuning job %s failed: %s", task.TaskID, err.Error()))
+	}
+	c.JSON(http.StatusOK, fineTuningJobResponse(task))
+}
+
+func ListFineTuningEvents(c *gin.Context) {
+	task := getUserFineTuningTaskOrAbort(c)
+	if task == nil {
+		return
+	}
+	channel, key, err := getFineTuningTaskChannel(task)
+	if err != nil {
+		fileErrorResponse(c, http.StatusServiceUnavailable, types.ErrorCodeGetChannelFailed, err.Error())
+		return
+	}
+	path := "/v1/fine_tuning/jobs/" + task.TaskID + "/events"
+	if c.Request.URL.RawQuery != "" {
+		path += "?" + c.Request.URL.RawQuery
+	}
+	statusCode, respBody, err := relay.DoFineTuningRequest(channel, key, http.MethodGet, path, nil)
+	if err != nil {
+		fileErrorResponse(c, http.StatusBadGateway, types.ErrorCodeDoRequestFailed, err.Error())
+		return
+	}
+	c.Data(statusCode, "application/json", respBody)
+}
+
+// DeleteFineTunedModel 仅允许删除自己训练得到的模型
+func DeleteFineTunedModel(c *gin.Context) {
+	modelName := c.Param("model")
+	ftModel, err := model.GetFineTunedModel(modelName)
+	if err != nil || ftModel.UserId != c.GetInt("id") {
+		fileErrorResponse(c, http.StatusNotFound, types.ErrorCodeModelNotFound, fmt.Sprintf("The model '%s' does not exist", modelName))
+		return
+	}
+	channel, err := model.CacheGetChannel(ftModel.ChannelId)
+	if err == nil {
+		var statusCode int
+		var respBody []byte
+		statusCode, respBody, err = relay.DoFineTuningRequest(channel, relay.GetChannelKeyByIndex(channel, ftModel.KeyIndex), http.MethodDelete, "/v1/models/"+modelName, nil)
+		if err == nil && statusCode != http.StatusOK && statusCode != http.StatusNotFound {
+			c.Data(statusCode, "application/json", respBody)
+			return
+		}
+	}
+	if err != nil {
+		// 渠道已不存在或上游不可达时仍移除本地路由
+		logger.LogWarn(c, fmt.Sprintf("delete fine-tuned model %s on channel #%d failed: %s", modelName, ftModel.ChannelId, err.Error()))
+	}
+	if err = model.DeleteFineTunedModel(ftModel); err != nil {
+		fileErrorResponse(c, http.StatusInternalServerError, types.ErrorCodeUpdateDataError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      modelName,
+		"object":  "model",
+		"deleted": true,
+	})
+}
+
+func applyFineTuningJobStatus(task *model.Task, job *dto.FineTuningJob) {
+	now := time.Now().Unix()
+	switch job.Status {
+	case "validating_files", "queued":
+		task.Status = model.TaskStatusQueued
+		task.Progress = "10%"
+	case "running":
+		task.Status = model.TaskStatusInProgress
+		task.Progress = "50%"
+		if task.StartTime == 0 {
+			task.StartTime = now
+		}
+	case "succeeded":
+		task.Status = model.TaskStatusSuccess
+		task.Progress = "100%"
+	case "failed":
+		task.Status = model.TaskStatusFailure
+		task.Progress = "100%"
+		if job.Error != nil {
+			task.FailReason = job.Error.Message
+		}
+	case "cancelled":
+		task.Status = model.TaskStatusCancelled
+		task.Progress = "100%"
+	default:
+		// pausing、paused、cancelling 等中间状态以及未知状态均视为仍在进行，继续轮询直到进入终态
+		task.Status = model.TaskStatusInProgress
+		if task.Progress == "" {
+			task.Progress = "50%"
+		}
+	}
+	if task.Progress == "100%" && task.FinishTime == 0 {
+		task.FinishTime = now
+	}
+}
+
+func refreshFineTuningTask(ctx context.Context, task *model.Task) error {
+	channel, key, err := getFineTuningTaskChannel(task)
+	if err != nil {
+		return err
+	}
+	statusCode, respBody, err := relay.DoFineTuningRequest(channel, key, http.MethodGet, "/v1/fine_tuning/jobs/"+task.TaskID, nil)
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("upstream status code %d: %s", statusCode, string(respBody))
+	}
+	return updateFineTuningTask(ctx, task, respBody)
+}
+
+// updateFineTuningTask 写入上游最新状态，任务首次进入成功状态时结算费用并注册微调模型
+func updateFineTuningTask(ctx context.Context, task *model.Task, jobBody []byte) error {
+	var job dto.FineTuningJob
+	if err := common.Unmarshal(jobBody, &job); err != nil {
+		return err
+	}
+	if job.Status == "" {
+		return errors.New("fine-tuning job status is empty")
+	}
+	data := task.GetFineTuningData()
+	data.Job = jobBody
+	task.SetData(data)
+	applyFineTuningJobStatus(task, &job)
+	updated, err := model.UpdateTaskIfStatus(task.ID, fineTuningUnfinishedStatus, map[string]any{
+		"status":      task.Status,
+		"progress":    task.Progress,
+		"start_time":  task.StartTime,
+		"finish_time": task.FinishTime,
+		"fail_reason": task.FailReason,
+		"data":        task.Data,
+	})
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return nil
+	}
+	switch task.Status {
+	case model.TaskStatusSuccess:
+		settleFineTuningTask(ctx, task, data, &job)
+	case model.TaskStatusFailure, model.TaskStatusCancelled:
+		if data.PreConsumedQuota > 0 {
+			if err := service.PostConsumeQuota(fineTuningRelayInfo(task.UserId, data), -data.PreConsumedQuota, 0, false); err != nil {
+				logger.LogError(ctx, fmt.Sprintf("fine-tuning job %s return pre-consumed quota failed: %s", task.TaskID, err.Error()))
+			}
+		}
+	}
+	return nil
+}
+
+// fineTuningRelayInfo 构造结算使用的 RelayInfo，令牌已删除时仅扣除用户额度
+func fineTuningRelayInfo(userId int, data *model.FineTuningData) *relaycommon.RelayInfo {
+	relayInfo := &relaycommon.RelayInfo{
+		UserId:          userId,
+		TokenId:         data.TokenId,
+		OrgId:           data.OrgId,
+		OriginModelName: data.BaseModel,
+	}
+	if token, err := model.GetTokenById(data.TokenId); err == nil {
+		relayInfo.TokenKey = token.Key
+		relayInfo.TokenBudgetPeriod = token.BudgetPeriod
+		relayInfo.TokenBudgetTimezone = token.BudgetTimezone
+	} else {
+		relayInfo.IsPlayground = true
+	}
+	return relayInfo
+}
+
+// estimateFineTuningQuota 按训练文件大小估算训练 token 数：约 4 字节一个 token，乘以训练轮数，
+// 未指定轮数或为 auto 时按 fineTuningDefaultEpochs 估算
+func estimateFineTuningQuota(baseModel string, group string, trainingFile *model.File, req map[string]any) int {
+	epochs := fineTuningDefaultEpochs
+	hyperparameters, _ := req["hyperparameters"].(map[string]any)
+	if method, ok := req["method"].(map[string]any); ok {
+		if methodType, ok := method["type"].(string); ok {
+			if methodConfig, ok := method[methodType].(map[string]any); ok {
+				if h, ok := methodConfig["hyperparameters"].(map[string]any); ok {
+					hyperparameters = h
+				}
+			}
+		}
+	}
+	if n, ok := hyperparameters["n_epochs"].(float64); ok && n >= 1 {
+		epochs = int(n)
+	}
+	modelRatio, _ := getFineTuningModelRatio(baseModel)
+	tokens := float64(trainingFile.Bytes) / fineTuningBytesPerToken * float64(epochs)
+	return int(tokens * modelRatio * ratio_setting.GetGroupRatio(group))
+}
+
+func settleFineTuningTask(ctx context.Context, task *model.Task, data *model.FineTuningData, job *dto.FineTuningJob) {
+	modelRatio, _ := getFineTuningModelRatio(data.BaseModel)
+	groupRatio := ratio_setting.GetGroupRatio(data.Group)
+	quota := int(float64(job.TrainedTokens) * modelRatio * groupRatio)
+	if delta := quota - data.PreConsumedQuota; delta != 0 {
+		if err := service.PostConsumeQuota(fineTuningRelayInfo(task.UserId, data), delta, data.PreConsumedQuota, false); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("fine-tuning job %s consume quota failed: %s", task.TaskID, err.Error()))
+		}
+	}
+	if quota > 0 {
+		model.UpdateUserUsedQuotaAndRequestCount(task.UserId, quota)
+		model.UpdateChannelUsedQuota(task.ChannelId, quota)
+		if err := model.TaskBulkUpdateByID([]int64{task.ID}, map[string]any{"quota": quota}); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("update fine-tuning job %s quota failed: %s", task.TaskID, err.Error()))
+		}
+		task.Quota = quota
+	}
+
+	// 后台轮询没有请求上下文，构造一个用于记录消费日志
+	logCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	logCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/fine_tuning/jobs", nil)
+	if username, err := model.GetUsernameById(task.UserId, false); err == nil {
+		logCtx.Set("username", username)
+	}
+	model.RecordConsumeLog(logCtx, task.UserId, model.RecordConsumeLogParams{
+		ChannelId:    task.ChannelId,
+		PromptTokens: job.TrainedTokens,
+		ModelName:    data.BaseModel,
+		TokenName:    data.TokenName,
+		Quota:        quota,
+		Content:      fmt.Sprintf("微调任务 %s 训练 %d tokens，模型倍率 %.2f，分组倍率 %.2f", task.TaskID, job.TrainedTokens, modelRatio, groupRatio),
+		TokenId:      data.TokenId,
+		Group:        data.Group,
+		Other: map[string]interface{}{
+			"fine_tuning_job_id": task.TaskID,
+			"fine_tuned_model":   job.FineTunedModel,
+			"trained_tokens":     job.TrainedTokens,
+			"model_ratio":        modelRatio,
+			"group_ratio":        groupRatio,
+		},
+	})
+
+	if job.FineTunedModel == "" {
+		return
+	}
+	err := model.RegisterFineTunedModel(&model.FineTunedModel{
+		Model:     job.FineTunedModel,
+		BaseModel: data.BaseModel,
+		UserId:    task.UserId,
+		Group:     data.Group,
+		ChannelId: task.ChannelId,
+		KeyIndex:  data.KeyIndex,
+		TaskId:    task.TaskID,
+	})
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("register fine-tuned model %s failed: %s", job.FineTunedModel, err.Error()))
+	}
+}
+
+func UpdateFineTuningTaskAll(ctx context.Context, taskChannelM map[int][]string, taskM map[string]*model.Task) {
+	for channelId, taskIds := range taskChannelM {
+		for _, taskId := range taskIds {
+			task := taskM[taskId]
+			if task == nil {
+				continue
+			}
+			if err := refreshFineTuningTask(ctx, task); err != nil {
+				logger.LogError(ctx, fmt.Sprintf("渠道 #%d 更新微调任务 %s 失败: %s", channelId, taskId, err.Error()))
+			}
+		}
+	}
+}
@@ -121,6 +121,30 @@ func GetLogsStat(c *gin.Context) {
 	return
 }
 
+// GetMarginReport 按渠道、模型、分组或日期汇总收入、上游成本与利润
+func GetMarginReport(c *gin.Context) {
+	groupBy := c.DefaultQuery("group_by", model.MarginGroupByChannel)
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	items, err := model.GetMarginReport(groupBy, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var revenue, cost int64
+	for _, item := range items {
+		revenue += item.Revenue
+		cost += item.Cost
+	}
+	common.ApiSuccess(c, gin.H{
+		"group_by": groupBy,
+		"items":    items,
+		"revenue":  revenue,
+		"cost":     cost,
+		"margin":   revenue - cost,
+	})
+}
+
 func GetLogsSelfStat(c *gin.Context) {
 	username := c.GetString("username")
 	logType, _ := strconv.Atoi(c.Query("type"))
@@ -154,6 +178,12 @@ func DeleteHistoryLogs(c *gin.Context) {
 		})
 		return
 	}
+	// 按整天删除，删除前先把这些日期的日志汇总到 log_rollups，统计接口仍可读取；当天的日志不删除
+	targetTimestamp = min(model.AlignLogRollupDay(targetTimestamp), model.AlignLogRollupDay(common.GetTimestamp()))
+	if _, err := model.RollupLogs(c.Request.Context(), targetTimestamp); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	count, err := model.DeleteOldLog(c.Request.Context(), targetTimestamp, 100)
 	if err != nil {
 		common.ApiError(c, err)
@@ -10,6 +10,7 @@ import (
 	"one-api/common"
 	"one-api/dto"
 	"one-api/logger"
+	"one-api/metrics"
 	"one-api/model"
 	"one-api/service"
 	"one-api/setting"
@@ -176,9 +177,11 @@ func UpdateMidjourneyTaskBulk() {
 					logger.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
 				} else {
 					if shouldReturnQuota {
-						err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
+						err = model.IncreaseUserQuota(task.UserId, task.Quota, false, model.LedgerRef{Reason: model.LedgerReasonTaskRefund, RefType: model.LedgerRefTask, RefId: task.MjId})
 						if err != nil {
 							logger.LogError(ctx, "fail to increase user quota: "+err.Error())
+						} else {
+							metrics.QuotaRefunded.WithLabelValues(metrics.RefundTask).Add(float64(task.Quota))
 						}
 						logContent := fmt.Sprintf("构图失败 %s，补偿 %s", task.MjId, logger.LogQuota(task.Quota))
 						model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
@@ -8,6 +8,7 @@ import (
 	"one-api/model"
 	"one-api/setting"
 	"one-api/setting/console_setting"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 	"one-api/setting/system_setting"
 	"strings"
@@ -200,6 +201,24 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "price_rule_setting.rules":
+		err = operation_setting.ValidatePriceRules(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "price_rule_setting.timezone":
+		err = operation_setting.ValidatePriceRuleTimezone(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	}
 	err = model.UpdateOption(option.Key, option.Value.(string))
 	if err != nil {
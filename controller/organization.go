@@ -0,0 +1,313 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/logger"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type OrgMemberRequest struct {
+	UserId      int    `json:"user_id"`
+	Username    string `json:"username"`
+	Role        string `json:"role"`
+	QuotaLimit  int    `json:"quota_limit"`
+	LimitPeriod string `json:"limit_period"`
+	ResetUsed   bool   `json:"reset_used"`
+}
+
+type OrgQuotaRequest struct {
+	Quota int `json:"quota"`
+}
+
+// getOrgMembership 解析路径中的组织 id 并返回当前用户在该组织中的成员信息
+func getOrgMembership(c *gin.Context) (*model.Organization, *model.OrganizationMember, error) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, nil, err
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		return nil, nil, err
+	}
+	member, err := model.GetOrgMember(orgId, c.GetInt("id"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return org, member, nil
+}
+
+func getOrgManager(c *gin.Context) (*model.Organization, *model.OrganizationMember, error) {
+	org, member, err := getOrgMembership(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !member.CanManage() {
+		return nil, nil, errors.New("无权管理该组织")
+	}
+	return org, member, nil
+}
+
+func GetSelfOrganizations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, orgs)
+}
+
+func CreateOrganization(c *gin.Context) {
+	var req CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org, err := model.CreateOrganization(req.Name, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	org, member, err := getOrgMembership(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 普通成员只能看到组织信息与自己的用量
+	if !member.CanManage() {
+		member.UsedQuota = member.GetUsed(time.Now())
+		common.ApiSuccess(c, gin.H{"organization": org, "members": []*model.OrganizationMember{member}})
+		return
+	}
+	members, err := model.GetOrgMembers(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{"organization": org, "members": members})
+}
+
+func AddOrganizationMember(c *gin.Context) {
+	org, manager, err := getOrgManager(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req OrgMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = model.OrgRoleMember
+	}
+	if req.Role == model.OrgRoleAdmin && manager.Role != model.OrgRoleOwner {
+		common.ApiError(c, errors.New("只有组织所有者可以添加管理员"))
+		return
+	}
+	userId := req.UserId
+	if userId == 0 {
+		userId, err = model.GetUserIdByUsername(req.Username)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	member := &model.OrganizationMember{
+		OrgId:       org.Id,
+		UserId:      userId,
+		Role:        req.Role,
+		QuotaLimit:  req.QuotaLimit,
+		LimitPeriod: req.LimitPeriod,
+	}
+	if err := model.AddOrgMember(member); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	org, manager, err := getOrgManager(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req OrgMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	member, err := model.GetOrgMember(org.Id, req.UserId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 所有者的角色不可变更，管理员之间的调整只能由所有者进行
+	if member.Role == model.OrgRoleOwner && req.Role != model.OrgRoleOwner {
+		common.ApiError(c, errors.New("不能变更组织所有者的角色"))
+		return
+	}
+	if req.Role == model.OrgRoleOwner && member.Role != model.OrgRoleOwner {
+		common.ApiError(c, errors.New("不能将成员设为所有者"))
+		return
+	}
+	if manager.Role != model.OrgRoleOwner && (member.Role == model.OrgRoleAdmin || req.Role == model.OrgRoleAdmin) {
+		common.ApiError(c, errors.New("只有组织所有者可以调整管理员"))
+		return
+	}
+	member.Role = req.Role
+	member.QuotaLimit = req.QuotaLimit
+	member.LimitPeriod = req.LimitPeriod
+	if err := model.UpdateOrgMember(member, req.ResetUsed); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+func RemoveOrganizationMember(c *gin.Context) {
+	org, manager, err := getOrgManager(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	member, err := model.GetOrgMember(org.Id, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if member.Role == model.OrgRoleAdmin && manager.Role != model.OrgRoleOwner {
+		common.ApiError(c, errors.New("只有组织所有者可以移除管理员"))
+		return
+	}
+	if err := model.RemoveOrgMember(member); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// TransferQuotaToOrganization 成员将个人余额转入组织额度池
+func TransferQuotaToOrganization(c *gin.Context) {
+	org, _, err := getOrgMembership(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req OrgQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.TransferQuotaToOrganization(c.GetInt("id"), org.Id, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(c.GetInt("id"), model.LogTypeManage, fmt.Sprintf("转入组织「%s」额度池 %s", org.Name, logger.LogQuota(req.Quota)))
+	common.ApiSuccess(c, nil)
+}
+
+// GetOrganizationLogs 组织管理员查看使用组织令牌的消费日志，普通成员只能查看自己的
+func GetOrganizationLogs(c *gin.Context) {
+	org, member, err := getOrgMembership(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	if !member.CanManage() {
+		userId = member.UserId
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	pageInfo := common.GetPageQuery(c)
+	logs, total, err := model.GetOrgLogs(org.Id, userId, startTimestamp, endTimestamp, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetAllOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(c.Query("keyword"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// UpdateOrganization 管理员修改组织名称与状态
+func UpdateOrganization(c *gin.Context) {
+	var req model.Organization
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org, err := model.GetOrganizationById(req.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Status != model.OrganizationStatusEnabled && req.Status != model.OrganizationStatusDisabled {
+		common.ApiError(c, errors.New("无效的组织状态"))
+		return
+	}
+	org.Name = req.Name
+	org.Status = req.Status
+	if err := org.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+// AdjustOrganizationQuota 管理员调整组织额度池，quota 为负时扣减
+func AdjustOrganizationQuota(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req OrgQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.AdjustOrganizationQuota(org.Id, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(c.GetInt("id"), model.LogTypeManage, fmt.Sprintf("管理员调整组织「%s」（#%d）额度池 %s", org.Name, org.Id, logger.LogQuota(req.Quota)))
+	common.ApiSuccess(c, nil)
+}
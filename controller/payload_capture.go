@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetPayloadCaptures(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	query := model.PayloadCaptureQuery{RequestId: c.Query("request_id")}
+	query.LogId, _ = strconv.Atoi(c.Query("log_id"))
+	query.UserId, _ = strconv.Atoi(c.Query("user_id"))
+	query.TokenId, _ = strconv.Atoi(c.Query("token_id"))
+	query.ChannelId, _ = strconv.Atoi(c.Query("channel_id"))
+	captures, total, err := model.GetPayloadCaptures(query, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(captures)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetPayloadCapture 返回客户端请求、上游请求与上游响应三部分内容，JSON 内容格式化后便于对照查看
+func GetPayloadCapture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	capture, err := model.GetPayloadCaptureById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"id":             capture.Id,
+		"log_id":         capture.LogId,
+		"request_id":     capture.RequestId,
+		"user_id":        capture.UserId,
+		"token_id":       capture.TokenId,
+		"channel_id":     capture.ChannelId,
+		"model_name":     capture.ModelName,
+		"upstream_model": capture.UpstreamModel,
+		"upstream_url":   capture.UpstreamUrl,
+		"is_stream":      capture.IsStream,
+		"status_code":    capture.StatusCode,
+		"truncated":      capture.Truncated,
+		"created_at":     capture.CreatedAt,
+		"inbound":        indentPayload(capture.InboundBody),
+		"upstream":       indentPayload(capture.UpstreamBody),
+		"response":       indentPayload(capture.ResponseBody),
+		"response_text":  capture.ResponseText,
+	})
+}
+
+func indentPayload(body string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(body), "", "  "); err != nil {
+		return body
+	}
+	return buf.String()
+}
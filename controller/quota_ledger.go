@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetSelfQuotaLedgers(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	ledgers, total, err := model.GetUserQuotaLedgers(c.GetInt("id"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(ledgers)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetUserQuotaLedgers(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	ledgers, total, err := model.GetUserQuotaLedgers(userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(ledgers)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// ReconcileQuotaLedger 对比用户余额与额度流水合计，返回存在偏差的用户
+func ReconcileQuotaLedger(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	checked, drifts, err := model.ReconcileQuotaLedger(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	totalDrift := 0
+	for _, d := range drifts {
+		totalDrift += d.Drift
+	}
+	common.ApiSuccess(c, gin.H{
+		"checked":     checked,
+		"drift_count": len(drifts),
+		"total_drift": totalDrift,
+		"drifts":      drifts,
+	})
+}
@@ -89,16 +89,21 @@ func AddRedemption(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
 		return
 	}
+	if redemption.CreditValidDays < 0 {
+		c.JSON(http.StatusOK, gin.H{"success": false, "message": "额度有效天数不能为负数"})
+		return
+	}
 	var keys []string
 	for i := 0; i < redemption.Count; i++ {
 		key := common.GetUUID()
 		cleanRedemption := model.Redemption{
-			UserId:      c.GetInt("id"),
-			Name:        redemption.Name,
-			Key:         key,
-			CreatedTime: common.GetTimestamp(),
-			Quota:       redemption.Quota,
-			ExpiredTime: redemption.ExpiredTime,
+			UserId:          c.GetInt("id"),
+			Name:            redemption.Name,
+			Key:             key,
+			CreatedTime:     common.GetTimestamp(),
+			Quota:           redemption.Quota,
+			ExpiredTime:     redemption.ExpiredTime,
+			CreditValidDays: redemption.CreditValidDays,
 		}
 		err = cleanRedemption.Insert()
 		if err != nil {
@@ -155,6 +160,7 @@ func UpdateRedemption(c *gin.Context) {
 		cleanRedemption.Name = redemption.Name
 		cleanRedemption.Quota = redemption.Quota
 		cleanRedemption.ExpiredTime = redemption.ExpiredTime
+		cleanRedemption.CreditValidDays = redemption.CreditValidDays
 	}
 	if statusOnly != "" {
 		cleanRedemption.Status = redemption.Status
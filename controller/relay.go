@@ -4,12 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"one-api/common"
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/logger"
+	"one-api/metrics"
 	"one-api/middleware"
 	"one-api/model"
 	"one-api/relay"
@@ -19,8 +19,11 @@ import (
 	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting"
+	"one-api/tracing"
 	"one-api/types"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
 
@@ -113,6 +116,13 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	defer func() {
+		recordRelayMetrics(c, relayInfo, newAPIError)
+	}()
+
+	service.BeginPayloadCapture(c)
+	defer service.FinishPayloadCapture(c, relayInfo)
+
 	meta := request.GetTokenCountMeta()
 
 	if setting.ShouldCheckPromptSensitive() {
@@ -124,7 +134,10 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}
 
+	_, countSpan := tracing.StartSpan(c, "CountRequestToken")
 	tokens, err := service.CountRequestToken(c, meta, relayInfo)
+	countSpan.SetAttributes(tracing.AttrPromptTokens.Int(tokens))
+	countSpan.End()
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeCountTokenFailed)
 		return
@@ -139,6 +152,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	}
 
 	applyCozeWorkflowPricingIfNeeded(c, relayInfo, request, &priceData)
+	applyBatchDiscountIfNeeded(c, &priceData)
 	relayInfo.PriceData = priceData
 	relayInfo.UsePrice = priceData.UsePrice
 
@@ -156,38 +170,100 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}()
 
-	for i := 0; i <= common.RetryTimes; i++ {
-		channel, err := getChannel(c, group, originalModel, i)
-		if err != nil {
-			logger.LogError(c, err.Error())
-			newAPIError = err
-			break
+	// 命中响应缓存时直接回放，未命中时捕获响应以便写入缓存
+	cacheKey := relay.ResponseCacheKey(c, relayInfo, request)
+	var responseCapture *relay.ResponseCapture
+	if cacheKey != "" {
+		if relay.ServeCachedResponse(c, relayInfo, cacheKey) {
+			return
 		}
+		responseCapture = relay.NewResponseCapture(c)
+	}
 
-		addUsedChannel(c, channel.Id)
-		requestBody, _ := common.GetRequestBody(c)
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	requestedModel := originalModel
+	if fallbackFrom := common.GetContextKeyString(c, constant.ContextKeyFallbackFromModel); fallbackFrom != "" {
+		// 分发阶段已降级
+		requestedModel = fallbackFrom
+	}
+	fallbackModels := remainingFallbackModels(service.GetModelFallbacks(c, group, requestedModel), originalModel)
 
-		switch relayFormat {
-		case types.RelayFormatOpenAIRealtime:
-			newAPIError = relay.WssHelper(c, relayInfo)
-		case types.RelayFormatClaude:
-			newAPIError = relay.ClaudeHelper(c, relayInfo)
-		case types.RelayFormatGemini:
-			newAPIError = geminiRelayHandler(c, relayInfo)
-		default:
-			newAPIError = relayHandler(c, relayInfo)
-		}
+	// 当前模型的渠道全部失败后沿降级链切换模型继续重试
+	for {
+		for i := 0; i <= common.RetryTimes; i++ {
+			channel, err := getChannel(c, group, originalModel, i)
+			if err != nil {
+				logger.LogError(c, err.Error())
+				newAPIError = err
+				break
+			}
 
-		if newAPIError == nil {
-			return
-		}
+			addUsedChannel(c, channel.Id)
+			// 每次尝试都按本次选中的渠道与 key 重建 ChannelMeta，渠道健康统计从中读取多 Key 信息
+			relayInfo.InitChannelMeta(c)
+			requestBody, _ := common.GetRequestBody(c)
+			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+
+			if responseCapture != nil {
+				responseCapture.Reset()
+			}
+			attemptSpan, endAttemptSpan := tracing.StartRequestSpan(c, "relay attempt",
+				tracing.AttrChannelId.Int(channel.Id),
+				tracing.AttrChannelType.Int(channel.Type),
+				tracing.AttrModel.String(originalModel),
+				tracing.AttrGroup.String(group),
+				tracing.AttrRetryIndex.Int(i),
+			)
+			attemptStart := time.Now()
+			hedged := false
+			switch relayFormat {
+			case types.RelayFormatOpenAIRealtime:
+				newAPIError = relay.WssHelper(c, relayInfo)
+			case types.RelayFormatClaude:
+				newAPIError = relay.ClaudeHelper(c, relayInfo)
+			case types.RelayFormatGemini:
+				newAPIError = geminiRelayHandler(c, relayInfo)
+			default:
+				if delay, ok := getHedgeDelay(c, relayFormat, relayInfo, group); ok {
+					// 对冲请求的渠道健康统计已在 hedgedRelay 中记录
+					hedged = true
+					channel, newAPIError = hedgedRelay(c, group, originalModel, relayInfo, channel, delay)
+				} else {
+					newAPIError = relayHandler(c, relayInfo)
+				}
+			}
 
-		processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+			if newAPIError != nil {
+				tracing.SetError(attemptSpan, newAPIError.Error())
+			}
+			endAttemptSpan()
+
+			if newAPIError == nil {
+				if !hedged {
+					recordChannelSuccess(c, channel, originalModel, relayInfo, attemptStart)
+				}
+				if responseCapture != nil {
+					relay.StoreCachedResponse(c, relayInfo, cacheKey, responseCapture)
+				}
+				return
+			}
+
+			if !hedged {
+				processChannelError(c, newRelayChannelError(channel, relayInfo), newAPIError)
+			}
 
-		if !shouldRetry(c, newAPIError, common.RetryTimes-i) {
+			if !shouldRetry(c, newAPIError, common.RetryTimes-i) {
+				break
+			}
+		}
+
+		if len(fallbackModels) == 0 || !shouldFallbackModel(c, relayFormat, relayInfo, newAPIError) {
+			break
+		}
+		nextModel, ok := switchFallbackModel(c, group, relayInfo, meta, &fallbackModels, requestedModel)
+		if !ok {
 			break
 		}
+		originalModel = nextModel
 	}
 
 	useChannel := c.GetStringSlice("use_channel")
@@ -204,6 +280,26 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// recordRelayMetrics 记录请求量、耗时、首字耗时与重试次数
+func recordRelayMetrics(c *gin.Context, relayInfo *relaycommon.RelayInfo, newAPIError *types.NewAPIError) {
+	status := c.Writer.Status()
+	if newAPIError != nil {
+		status = newAPIError.StatusCode
+	}
+	modelName := relayInfo.OriginModelName
+	channel := metrics.ChannelLabel(common.GetContextKeyInt(c, constant.ContextKeyChannelId))
+	group := relayInfo.UsingGroup
+	statusLabel := strconv.Itoa(status)
+	metrics.RelayRequests.WithLabelValues(modelName, channel, group, statusLabel).Inc()
+	metrics.RelayDuration.WithLabelValues(modelName, channel, group, statusLabel).Observe(time.Since(relayInfo.StartTime).Seconds())
+	if relayInfo.IsStream && relayInfo.HasSendResponse() {
+		metrics.RelayFirstToken.WithLabelValues(modelName, channel, group).Observe(relayInfo.FirstResponseTime.Sub(relayInfo.StartTime).Seconds())
+	}
+	if retries := len(c.GetStringSlice("use_channel")) - 1; retries > 0 {
+		metrics.RelayRetries.WithLabelValues(modelName, group).Add(float64(retries))
+	}
+}
+
 func addUsedChannel(c *gin.Context, channelId int) {
 	useChannel := c.GetStringSlice("use_channel")
 	useChannel = append(useChannel, fmt.Sprintf("%d", channelId))
@@ -226,7 +322,7 @@ func applyCozeWorkflowPricingIfNeeded(c *gin.Context, relayInfo *relaycommon.Rel
 	}
 
 	channelId := common.GetContextKeyInt(c, constant.ContextKeyChannelId)
-	workflowQuota := coze.GetWorkflowPricePerCall(generalReq.WorkflowId, channelId)
+	workflowQuota := coze.GetWorkflowPricePerCall(c, generalReq.WorkflowId, channelId)
 	if workflowQuota <= 0 {
 		return
 	}
@@ -242,10 +338,83 @@ func applyCozeWorkflowPricingIfNeeded(c *gin.Context, relayInfo *relaycommon.Rel
 	priceData.ShouldPreConsumedQuota = preConsumed
 
 	relayInfo.BillingModelName = generalReq.WorkflowId
-	common.SysLog(fmt.Sprintf("[WorkflowPricing] 同步工作流按次计费: workflow=%s, base_quota=%d, group_ratio=%.2f, channel_ratio=%.2f, preconsume=%d",
+	logger.LogInfo(c, fmt.Sprintf("[WorkflowPricing] 同步工作流按次计费: workflow=%s, base_quota=%d, group_ratio=%.2f, channel_ratio=%.2f, preconsume=%d",
 		generalReq.WorkflowId, workflowQuota, priceData.GroupRatioInfo.GroupRatio, priceData.GroupRatioInfo.ChannelRatio, preConsumed))
 }
 
+// applyBatchDiscountIfNeeded 批处理请求按 batch_setting.discount_ratio 折扣计费
+func applyBatchDiscountIfNeeded(c *gin.Context, priceData *types.PriceData) {
+	discountRatio, ok := common.GetContextKeyType[float64](c, constant.ContextKeyBatchDiscountRatio)
+	if !ok || discountRatio < 0 || discountRatio == 1 {
+		return
+	}
+	priceData.GroupRatioInfo.GroupRatio *= discountRatio
+	priceData.ShouldPreConsumedQuota = int(float64(priceData.ShouldPreConsumedQuota) * discountRatio)
+}
+
+// remainingFallbackModels 分发阶段已降级到链中某个模型时，只保留其后的备选模型
+func remainingFallbackModels(fallbackModels []string, currentModel string) []string {
+	for i, fallbackModel := range fallbackModels {
+		if fallbackModel == currentModel {
+			return fallbackModels[i+1:]
+		}
+	}
+	return fallbackModels
+}
+
+// shouldFallbackModel 当前模型的渠道全部失败且尚未向客户端输出内容时才降级
+func shouldFallbackModel(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo, err *types.NewAPIError) bool {
+	if err == nil || relayFormat == types.RelayFormatOpenAIRealtime || relayInfo.HasSendResponse() {
+		return false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return false
+	}
+	if err.GetErrorCode() == types.ErrorCodeGetChannelFailed || types.IsChannelError(err) {
+		return true
+	}
+	return err.StatusCode == http.StatusTooManyRequests || err.StatusCode/100 == 5
+}
+
+// switchFallbackModel 切换到降级链中下一个有可用渠道的模型，并按该模型重新计算价格，
+// 预扣费保持不变，结算时按实际服务的模型补扣或返还
+func switchFallbackModel(c *gin.Context, group string, relayInfo *relaycommon.RelayInfo, meta *types.TokenCountMeta, fallbackModels *[]string, requestedModel string) (string, bool) {
+	previousModel := relayInfo.OriginModelName
+	for len(*fallbackModels) > 0 {
+		nextModel := (*fallbackModels)[0]
+		*fallbackModels = (*fallbackModels)[1:]
+		if !model.CanUseFineTunedModel(nextModel, relayInfo.UserId) {
+			continue
+		}
+		channel, _, err := model.CacheGetRandomSatisfiedChannel(c, group, nextModel, 0)
+		if err != nil || channel == nil {
+			continue
+		}
+		if apiErr := middleware.SetupContextForSelectedChannel(c, channel, nextModel); apiErr != nil {
+			continue
+		}
+		relayInfo.OriginModelName = nextModel
+		priceData, err := helper.ModelPriceHelper(c, relayInfo, relayInfo.PromptTokens, meta)
+		if err != nil {
+			logger.LogWarn(c, fmt.Sprintf("fallback model %s price error: %s", nextModel, err.Error()))
+			relayInfo.OriginModelName = previousModel
+			continue
+		}
+		// 确定切换后才占用半开渠道的探测名额
+		if !model.AcquireChannelBreaker(channel.Id) {
+			relayInfo.OriginModelName = previousModel
+			continue
+		}
+		applyBatchDiscountIfNeeded(c, &priceData)
+		relayInfo.PriceData = priceData
+		relayInfo.UsePrice = priceData.UsePrice
+		common.SetContextKey(c, constant.ContextKeyFallbackFromModel, requestedModel)
+		logger.LogInfo(c, fmt.Sprintf("模型 %s 的渠道均不可用，降级到 %s", previousModel, nextModel))
+		return nextModel, true
+	}
+	return "", false
+}
+
 func getChannel(c *gin.Context, group, originalModel string, retryCount int) (*model.Channel, *types.NewAPIError) {
 	if retryCount == 0 {
 		autoBan := c.GetBool("auto_ban")
@@ -260,7 +429,7 @@ func getChannel(c *gin.Context, group, originalModel string, retryCount int) (*m
 			AutoBan: &autoBanInt,
 		}, nil
 	}
-	channel, selectGroup, err := model.CacheGetRandomSatisfiedChannel(c, group, originalModel, retryCount)
+	channel, selectGroup, err := model.CacheGetDispatchChannel(c, group, originalModel, retryCount)
 	if err != nil {
 		return nil, types.NewError(fmt.Errorf("获取分组 %s 下模型 %s 的可用渠道失败（retry）: %s", selectGroup, originalModel, err.Error()), types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
 	}
@@ -316,8 +485,31 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	return true
 }
 
+// recordChannelSuccess 记录渠道本次成功请求的首字延迟与总耗时，供动态渠道选择使用
+func recordChannelSuccess(c *gin.Context, channel *model.Channel, modelName string, relayInfo *relaycommon.RelayInfo, attemptStart time.Time) {
+	var ttft time.Duration
+	if relayInfo.IsStream && relayInfo.HasSendResponse() && relayInfo.FirstResponseTime.After(attemptStart) {
+		ttft = relayInfo.FirstResponseTime.Sub(attemptStart)
+	}
+	model.RecordChannelSuccess(channel.Id, modelName, ttft, time.Since(attemptStart))
+	model.RecordChannelBreakerResult(channel.Id, relayInfo.ChannelIsMultiKey, relayInfo.ChannelMultiKeyIndex, true)
+}
+
+// newRelayChannelError 多 Key 信息与使用的 key 取自本次尝试的 ChannelMeta：
+// 首次尝试的渠道来自分发阶段的上下文，不含 ChannelInfo
+func newRelayChannelError(channel *model.Channel, relayInfo *relaycommon.RelayInfo) types.ChannelError {
+	channelError := types.NewChannelError(channel.Id, channel.Type, channel.Name, relayInfo.ChannelIsMultiKey, relayInfo.ApiKey, channel.GetAutoBan())
+	channelError.KeyIndex = relayInfo.ChannelMultiKeyIndex
+	return *channelError
+}
+
 func processChannelError(c *gin.Context, channelError types.ChannelError, err *types.NewAPIError) {
 	logger.LogError(c, fmt.Sprintf("relay error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, err.Error()))
+	// 请求本身有误时不计入渠道错误率
+	if err.StatusCode != http.StatusBadRequest {
+		model.RecordChannelFailure(channelError.ChannelId, c.GetString("original_model"))
+		model.RecordChannelBreakerResult(channelError.ChannelId, channelError.IsMultiKey, channelError.KeyIndex, false)
+	}
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
 	if service.ShouldDisableChannel(channelError.ChannelId, err) && channelError.AutoBan {
@@ -380,7 +572,6 @@ func RelayMidjourney(c *gin.Context) {
 		mjErr = relay.RelayMidjourneySubmit(c, relayInfo)
 	}
 	//err = relayMidjourneySubmit(c, relayMode)
-	log.Println(mjErr)
 	if mjErr != nil {
 		statusCode := http.StatusBadRequest
 		if mjErr.Code == 30 {
@@ -424,10 +615,9 @@ func RelayNotFound(c *gin.Context) {
 func RelayTask(c *gin.Context) {
 	// 🆕 检查渠道类型，如果是 Bltcy 就使用透传模式
 	channelType := c.GetInt("channel_type")
-	fmt.Printf("[DEBUG RelayTask] Method: %s, Path: %s, channel_type: %d\n",
-		c.Request.Method, c.Request.URL.Path, channelType)
+	logger.LogDebug(c, fmt.Sprintf("[RelayTask] Method: %s, Path: %s, channel_type: %d", c.Request.Method, c.Request.URL.Path, channelType))
 	if channelType == constant.ChannelTypeBltcy {
-		fmt.Printf("[DEBUG RelayTask] Using Bltcy passthrough mode\n")
+		logger.LogDebug(c, "[RelayTask] Using Bltcy passthrough mode")
 		RelayBltcy(c)
 		return
 	}
@@ -0,0 +1,398 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/middleware"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 对冲请求：首个渠道在等待时间内未返回首字节时，向另一个渠道发送相同请求，
+// 先向客户端写出内容的一方胜出，另一方被取消且不计费，但其结果仍计入渠道健康统计，上游成本记录在额度为 0 的消费日志中
+
+var errHedgeLost = errors.New("hedged request lost")
+
+// hedgeState 多个对冲请求共享的胜负状态
+type hedgeState struct {
+	mu      sync.Mutex
+	winner  int
+	cancels []context.CancelFunc
+	claimed chan struct{}
+}
+
+// claim 尝试成为胜出方，成功后取消其余请求
+func (s *hedgeState) claim(index int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.winner >= 0 {
+		return s.winner == index
+	}
+	s.winner = index
+	close(s.claimed)
+	for i, cancel := range s.cancels {
+		if i != index {
+			cancel()
+		}
+	}
+	return true
+}
+
+func (s *hedgeState) getWinner() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.winner
+}
+
+// hedgeWriter 在胜出前缓存响应头与状态码，胜出后才写入真实的 ResponseWriter
+type hedgeWriter struct {
+	gin.ResponseWriter
+	state     *hedgeState
+	index     int
+	header    http.Header
+	status    int
+	won       bool
+	attempted bool // 是否已尝试写出响应
+}
+
+func (w *hedgeWriter) claim() bool {
+	if w.won {
+		return true
+	}
+	w.attempted = true
+	if !w.state.claim(w.index) {
+		return false
+	}
+	realHeader := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		realHeader[k] = v
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	w.won = true
+	return true
+}
+
+func (w *hedgeWriter) Header() http.Header {
+	if w.won {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *hedgeWriter) WriteHeader(code int) {
+	if w.won {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *hedgeWriter) WriteHeaderNow() {
+	if w.won {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *hedgeWriter) Write(data []byte) (int, error) {
+	// SSE 保活 ping 不算首字节
+	if !w.won && bytes.HasPrefix(data, []byte(": PING")) {
+		return len(data), nil
+	}
+	if !w.claim() {
+		return 0, errHedgeLost
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *hedgeWriter) WriteString(s string) (int, error) {
+	if !w.won && strings.HasPrefix(s, ": PING") {
+		return len(s), nil
+	}
+	if !w.claim() {
+		return 0, errHedgeLost
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *hedgeWriter) Status() int {
+	if w.won {
+		return w.ResponseWriter.Status()
+	}
+	if w.status != 0 {
+		return w.status
+	}
+	return http.StatusOK
+}
+
+func (w *hedgeWriter) Size() int {
+	if w.won {
+		return w.ResponseWriter.Size()
+	}
+	return -1
+}
+
+func (w *hedgeWriter) Written() bool {
+	return w.won && w.ResponseWriter.Written()
+}
+
+func (w *hedgeWriter) Flush() {
+	if w.won {
+		w.ResponseWriter.Flush()
+	}
+}
+
+type hedgeRacer struct {
+	index    int
+	channel  *model.Channel
+	ctx      *gin.Context
+	info     *relaycommon.RelayInfo
+	writer   *hedgeWriter
+	hedgeCtx context.Context
+	start    time.Time
+	err      *types.NewAPIError
+	// 结束时是否已因另一方胜出而被取消
+	cancelled bool
+}
+
+// getHedgeDelay 返回本次请求的对冲等待时间，仅对 OpenAI 格式的对话补全启用
+func getHedgeDelay(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo, group string) (time.Duration, bool) {
+	if relayFormat != types.RelayFormatOpenAI || relayInfo.RelayMode != relayconstant.RelayModeChatCompletions {
+		return 0, false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return 0, false
+	}
+	delayMs := operation_setting.GetHedgeDelayMs(group)
+	if delayMs <= 0 {
+		return 0, false
+	}
+	return time.Duration(delayMs) * time.Millisecond, true
+}
+
+// selectHedgeChannel 为对冲请求选择一个与首个渠道不同的渠道
+func selectHedgeChannel(c *gin.Context, group string, modelName string, excludeId int) *model.Channel {
+	for i := 0; i < 3; i++ {
+		channel, _, err := model.CacheGetRandomSatisfiedChannel(c, group, modelName, 0)
+		if err != nil || channel == nil {
+			return nil
+		}
+		if channel.Id != excludeId {
+			return channel
+		}
+	}
+	return nil
+}
+
+func startHedgeRacer(c *gin.Context, rc *gin.Context, state *hedgeState, index int, channel *model.Channel, relayInfo *relaycommon.RelayInfo, results chan<- *hedgeRacer) *hedgeRacer {
+	hedgeCtx, cancel := context.WithCancel(c.Request.Context())
+	state.mu.Lock()
+	state.cancels = append(state.cancels, cancel)
+	if state.winner >= 0 {
+		// 另一方已胜出
+		cancel()
+	}
+	state.mu.Unlock()
+
+	info, err := cloneHedgeRelayInfo(relayInfo)
+	writer := &hedgeWriter{
+		ResponseWriter: c.Writer,
+		state:          state,
+		index:          index,
+		header:         make(http.Header),
+	}
+	rc.Writer = writer
+	rc.Request = c.Request.Clone(c.Request.Context())
+	requestBody, _ := common.GetRequestBody(c)
+	rc.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	common.SetContextKey(rc, constant.ContextKeyHedgeContext, context.Context(hedgeCtx))
+	common.SetContextKey(rc, constant.ContextKeyHedgeClaim, writer.claim)
+
+	racer := &hedgeRacer{
+		index:    index,
+		channel:  channel,
+		ctx:      rc,
+		info:     info,
+		writer:   writer,
+		hedgeCtx: hedgeCtx,
+		start:    time.Now(),
+	}
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				racer.err = types.NewError(fmt.Errorf("hedged request panic: %v", r), types.ErrorCodeDoRequestFailed)
+			}
+			racer.cancelled = hedgeCtx.Err() != nil
+			results <- racer
+		}()
+		if err != nil {
+			racer.err = types.NewError(fmt.Errorf("failed to copy hedged request: %w", err), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+			return
+		}
+		racer.err = relayHandler(rc, racer.info)
+	}()
+	return racer
+}
+
+// cloneHedgeRelayInfo 为每个对冲请求复制一份 RelayInfo，请求体与转换过程中会修改的状态各自独立，
+// 避免两个并发请求同时读写同一份数据
+func cloneHedgeRelayInfo(relayInfo *relaycommon.RelayInfo) (*relaycommon.RelayInfo, error) {
+	info := *relayInfo
+	if textRequest, ok := relayInfo.Request.(*dto.GeneralOpenAIRequest); ok {
+		request, err := common.DeepCopy(textRequest)
+		if err != nil {
+			return nil, err
+		}
+		info.Request = request
+	} else if relayInfo.Request != nil {
+		return nil, fmt.Errorf("unsupported hedged request type %T", relayInfo.Request)
+	}
+	if relayInfo.ClaudeConvertInfo != nil {
+		claudeInfo := *relayInfo.ClaudeConvertInfo
+		if claudeInfo.Usage != nil {
+			usage := *claudeInfo.Usage
+			claudeInfo.Usage = &usage
+		}
+		info.ClaudeConvertInfo = &claudeInfo
+	}
+	if relayInfo.ResponsesUsageInfo != nil {
+		builtInTools := make(map[string]*relaycommon.BuildInToolInfo, len(relayInfo.BuiltInTools))
+		for name, tool := range relayInfo.BuiltInTools {
+			toolCopy := *tool
+			builtInTools[name] = &toolCopy
+		}
+		info.ResponsesUsageInfo = &relaycommon.ResponsesUsageInfo{BuiltInTools: builtInTools}
+	}
+	if relayInfo.ChannelMeta != nil {
+		channelMeta := *relayInfo.ChannelMeta
+		info.ChannelMeta = &channelMeta
+	}
+	if relayInfo.RerankerInfo != nil {
+		rerankerInfo := *relayInfo.RerankerInfo
+		info.RerankerInfo = &rerankerInfo
+	}
+	if relayInfo.TaskRelayInfo != nil {
+		taskInfo := *relayInfo.TaskRelayInfo
+		info.TaskRelayInfo = &taskInfo
+	}
+	return &info, nil
+}
+
+// hedgedRelay 对首个渠道发起请求，超过等待时间仍无首字节时向另一渠道发起对冲请求。
+// 渠道健康统计在此完成，返回胜出方（或首个请求）的错误与渠道
+func hedgedRelay(c *gin.Context, group string, modelName string, relayInfo *relaycommon.RelayInfo, channel *model.Channel, delay time.Duration) (*model.Channel, *types.NewAPIError) {
+	state := &hedgeState{winner: -1, claimed: make(chan struct{})}
+	results := make(chan *hedgeRacer, 2)
+	racers := []*hedgeRacer{startHedgeRacer(c, c.Copy(), state, 0, channel, relayInfo, results)}
+	finished := make([]*hedgeRacer, 0, 2)
+
+	timer := time.NewTimer(delay)
+	select {
+	case <-state.claimed:
+	case r := <-results:
+		finished = append(finished, r)
+	case <-timer.C:
+		rc := c.Copy()
+		if hedgeChannel := selectHedgeChannel(rc, group, modelName, channel.Id); hedgeChannel != nil {
+			// 确定发出对冲请求后才占用半开渠道的探测名额
+			if err := middleware.SetupContextForSelectedChannel(rc, hedgeChannel, modelName); err == nil && model.AcquireChannelBreaker(hedgeChannel.Id) {
+				addUsedChannel(c, hedgeChannel.Id)
+				addUsedChannel(rc, hedgeChannel.Id)
+				logger.LogInfo(c, fmt.Sprintf("hedged request: channel #%d has no first byte after %s, racing channel #%d", channel.Id, delay, hedgeChannel.Id))
+				racers = append(racers, startHedgeRacer(c, rc, state, 1, hedgeChannel, relayInfo, results))
+			}
+		}
+	}
+	timer.Stop()
+	for len(finished) < len(racers) {
+		finished = append(finished, <-results)
+	}
+	state.mu.Lock()
+	for _, cancel := range state.cancels {
+		cancel()
+	}
+	state.mu.Unlock()
+
+	winnerIndex := state.getWinner()
+	result := racers[0]
+	if winnerIndex >= 0 {
+		result = racers[winnerIndex]
+	}
+	for _, r := range racers {
+		recordHedgeRacerResult(r, r == result, modelName)
+		if r != result {
+			recordHedgeLoserCost(r)
+		}
+	}
+	*relayInfo = *result.info
+	// 响应缓存需要胜出方的用量
+	if usage, ok := result.ctx.Get(string(constant.ContextKeyResponseUsage)); ok {
+		c.Set(string(constant.ContextKeyResponseUsage), usage)
+	}
+	return result.channel, result.err
+}
+
+// recordHedgeRacerResult 记录对冲请求各方的渠道健康统计，被取消且未返回首字节的一方不计入
+func recordHedgeRacerResult(r *hedgeRacer, selected bool, modelName string) {
+	cancelled := !selected && r.cancelled
+	switch {
+	case r.err == nil || (cancelled && r.writer.attempted):
+		recordChannelSuccess(r.ctx, r.channel, modelName, r.info, r.start)
+	case cancelled:
+		return
+	default:
+		processChannelError(r.ctx, newRelayChannelError(r.channel, r.info), r.err)
+	}
+}
+
+// recordHedgeLoserCost 落败方不向用户计费，但上游可能已按用量收费，记录一条额度为 0 的消费日志保留其上游成本。
+// 上游返回错误时不记录；被取消时没有用量，按输入 token 估算
+func recordHedgeLoserCost(r *hedgeRacer) {
+	if r.info == nil || (r.err != nil && !r.cancelled) {
+		return
+	}
+	content := "对冲请求落败，仅记录上游成本"
+	usage, ok := common.GetContextKeyType[*dto.Usage](r.ctx, constant.ContextKeyResponseUsage)
+	if !ok || usage == nil {
+		usage = &dto.Usage{PromptTokens: r.info.PromptTokens, TotalTokens: r.info.PromptTokens}
+		content += "（用量按输入估算）"
+	}
+	other := make(map[string]interface{})
+	other["hedge_lost"] = true
+	other["cache_tokens"] = usage.PromptTokensDetails.CachedTokens
+	other["cache_creation_tokens"] = usage.PromptTokensDetails.CachedCreationTokens
+	if r.info.IsModelMapped {
+		other["upstream_model_name"] = r.info.UpstreamModelName
+	}
+	model.RecordConsumeLog(r.ctx, r.info.UserId, model.RecordConsumeLogParams{
+		ChannelId:        r.channel.Id,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		ModelName:        r.info.OriginModelName,
+		TokenName:        r.ctx.GetString("token_name"),
+		Quota:            0,
+		Content:          content,
+		TokenId:          r.info.TokenId,
+		UseTimeSeconds:   int(time.Since(r.start).Seconds()),
+		IsStream:         r.info.IsStream,
+		Group:            r.info.UsingGroup,
+		Other:            other,
+	})
+}
@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GenerateStatementRequest struct {
+	UserId int    `json:"user_id"` // 仅管理员可用，为 0 时为账期内所有有消费或充值的用户生成
+	Period string `json:"period"`  // YYYY-MM
+}
+
+func GetSelfStatements(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	statements, total, err := model.GetStatements(c.GetInt("id"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetAllStatements(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	statements, total, err := model.GetStatements(userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GenerateSelfStatement 为当前用户生成已结束账期的对账单，已生成过则返回原对账单
+func GenerateSelfStatement(c *gin.Context) {
+	var req GenerateStatementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	start, end, err := model.StatementMonthBounds(req.Period)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	statement, err := model.GenerateStatement(c.GetInt("id"), start, end)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, statement)
+}
+
+func GenerateStatements(c *gin.Context) {
+	var req GenerateStatementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	start, end, err := model.StatementMonthBounds(req.Period)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.UserId != 0 {
+		statement, err := model.GenerateStatement(req.UserId, start, end)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		common.ApiSuccess(c, statement)
+		return
+	}
+	if end > common.GetTimestamp() {
+		common.ApiError(c, errors.New("账期尚未结束"))
+		return
+	}
+	count, err := model.GenerateStatementsForPeriod(start, end)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{"count": count})
+}
+
+func DownloadSelfStatement(c *gin.Context) {
+	downloadStatement(c, c.GetInt("id"))
+}
+
+func DownloadStatement(c *gin.Context) {
+	downloadStatement(c, 0)
+}
+
+// downloadStatement 以 CSV 或可打印的 HTML 下载对账单，currency 可选 USD、CNY、QUOTA
+func downloadStatement(c *gin.Context, userId int) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	statement, err := model.GetStatementById(id, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	currency := c.Query("currency")
+	switch c.DefaultQuery("format", "csv") {
+	case "csv":
+		data, err := service.RenderStatementCSV(statement, currency)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", service.StatementFileName(statement, "csv")))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+	case "html":
+		data, err := service.RenderStatementHTML(statement, currency)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", data)
+	default:
+		common.ApiError(c, errors.New("不支持的格式"))
+	}
+}
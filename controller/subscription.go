@@ -0,0 +1,420 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/system_setting"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/checkout/session"
+	stripesubscription "github.com/stripe/stripe-go/v81/subscription"
+	"github.com/thanhpk/randstr"
+)
+
+func validatePlan(plan *model.Plan) error {
+	if utf8.RuneCountInString(plan.Name) == 0 || utf8.RuneCountInString(plan.Name) > 64 {
+		return errors.New("计划名称长度必须在1-64之间")
+	}
+	if plan.Quota < 0 || plan.Price < 0 {
+		return errors.New("计划额度与价格不能为负数")
+	}
+	if plan.ResetPeriod == "" {
+		plan.ResetPeriod = model.PlanPeriodMonth
+	}
+	if !model.IsValidPlanPeriod(plan.ResetPeriod) {
+		return errors.New("重置周期只能为 day、week 或 month")
+	}
+	return nil
+}
+
+func GetAllPlans(c *gin.Context) {
+	plans, err := model.GetAllPlans()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plans)
+}
+
+func AddPlan(c *gin.Context) {
+	plan := model.Plan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validatePlan(&plan); err != nil {
+		common.ApiErrorMsg(c, err.Error())
+		return
+	}
+	if plan.Status == 0 {
+		plan.Status = model.PlanStatusEnabled
+	}
+	if err := plan.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plan)
+}
+
+func UpdatePlan(c *gin.Context) {
+	plan := model.Plan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if _, err := model.GetPlanById(plan.Id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validatePlan(&plan); err != nil {
+		common.ApiErrorMsg(c, err.Error())
+		return
+	}
+	if err := plan.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plan)
+}
+
+func DeletePlan(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	plan, err := model.GetPlanById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 已有订阅不受影响，订阅激活时已复制计划内容
+	if err := plan.Delete(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetSubscriptionPlans 用户可订阅的计划
+func GetSubscriptionPlans(c *gin.Context) {
+	plans, err := model.GetEnabledPlans()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plans)
+}
+
+func GetSelfSubscription(c *gin.Context) {
+	userId := c.GetInt("id")
+	active, err := model.GetActiveSubscription(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	subs, err := model.GetUserSubscriptions(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"active":        active,
+		"subscriptions": subs,
+	})
+}
+
+// cancelStripeSubscription atPeriodEnd 为 true 时在当前周期结束后取消，否则立即取消
+func cancelStripeSubscription(sub *model.Subscription, atPeriodEnd bool) error {
+	if sub.Source != model.SubscriptionSourceStripe || sub.StripeSubscriptionId == "" {
+		return nil
+	}
+	stripe.Key = setting.StripeApiSecret
+	var err error
+	if atPeriodEnd {
+		_, err = stripesubscription.Update(sub.StripeSubscriptionId, &stripe.SubscriptionParams{
+			CancelAtPeriodEnd: stripe.Bool(true),
+		})
+	} else {
+		_, err = stripesubscription.Cancel(sub.StripeSubscriptionId, nil)
+	}
+	return err
+}
+
+// CancelSelfSubscription 取消续订，当前订阅在到期前仍然有效
+func CancelSelfSubscription(c *gin.Context) {
+	sub, err := model.GetActiveSubscription(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if sub == nil {
+		common.ApiErrorMsg(c, "当前没有生效的订阅")
+		return
+	}
+	if err := cancelStripeSubscription(sub, true); err != nil {
+		log.Println("取消Stripe订阅失败", sub.StripeSubscriptionId, err)
+		common.ApiErrorMsg(c, "取消订阅失败")
+		return
+	}
+	if err := model.CancelSubscription(sub); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, sub)
+}
+
+func GetAllSubscriptions(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	subs, total, err := model.GetAllSubscriptions(userId, c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(subs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+type GrantSubscriptionRequest struct {
+	UserId  int `json:"user_id"`
+	PlanId  int `json:"plan_id"`
+	Periods int `json:"periods"`
+}
+
+// GrantSubscription 管理员手动开通订阅，替换用户当前的订阅
+func GrantSubscription(c *gin.Context) {
+	var req GrantSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Periods <= 0 {
+		req.Periods = 1
+	}
+	if _, err := model.GetUserById(req.UserId, false); err != nil {
+		common.ApiErrorMsg(c, "用户不存在")
+		return
+	}
+	plan, err := model.GetPlanById(req.PlanId)
+	if err != nil {
+		common.ApiErrorMsg(c, "计划不存在")
+		return
+	}
+	sub := model.NewSubscriptionFromPlan(req.UserId, plan, model.SubscriptionSourceManual)
+	if err := sub.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.ActivateSubscription(sub, req.Periods); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(req.UserId, model.LogTypeManage, fmt.Sprintf("管理员开通订阅计划 %s，共 %d 个周期", plan.Name, req.Periods))
+	common.ApiSuccess(c, sub)
+}
+
+// RevokeSubscription 立即终止订阅并恢复用户分组
+func RevokeSubscription(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	sub, err := model.GetSubscriptionById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if sub.Status == model.SubscriptionStatusExpired {
+		common.ApiErrorMsg(c, "订阅已失效")
+		return
+	}
+	if err := cancelStripeSubscription(sub, false); err != nil {
+		log.Println("取消Stripe订阅失败", sub.StripeSubscriptionId, err)
+	}
+	if err := model.ExpireSubscription(sub); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, sub)
+}
+
+type StripeSubscriptionRequest struct {
+	PlanId int `json:"plan_id"`
+}
+
+// RequestStripeSubscription 创建 Stripe 订阅模式的 Checkout 会话，支付完成后由 Webhook 激活订阅
+func RequestStripeSubscription(c *gin.Context) {
+	var req StripeSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": "参数错误"})
+		return
+	}
+	plan, err := model.GetPlanById(req.PlanId)
+	if err != nil || plan.Status != model.PlanStatusEnabled {
+		c.JSON(200, gin.H{"message": "error", "data": "计划不存在"})
+		return
+	}
+	if plan.StripePriceId == "" {
+		c.JSON(200, gin.H{"message": "error", "data": "该计划不支持在线订阅"})
+		return
+	}
+
+	id := c.GetInt("id")
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": "用户不存在"})
+		return
+	}
+	reference := fmt.Sprintf("new-api-sub-%d-%d-%s", user.Id, time.Now().UnixMilli(), randstr.String(4))
+	referenceId := "sub_" + common.Sha1([]byte(reference))
+
+	payLink, err := genStripeSubscriptionLink(referenceId, user.StripeCustomer, user.Email, plan.StripePriceId)
+	if err != nil {
+		log.Println("获取Stripe订阅支付链接失败", err)
+		c.JSON(200, gin.H{"message": "error", "data": "拉起支付失败"})
+		return
+	}
+
+	sub := model.NewSubscriptionFromPlan(id, plan, model.SubscriptionSourceStripe)
+	sub.TradeNo = referenceId
+	if err := sub.Insert(); err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": "创建订单失败"})
+		return
+	}
+	c.JSON(200, gin.H{
+		"message": "success",
+		"data": gin.H{
+			"pay_link": payLink,
+		},
+	})
+}
+
+func genStripeSubscriptionLink(referenceId string, customerId string, email string, priceId string) (string, error) {
+	if !strings.HasPrefix(setting.StripeApiSecret, "sk_") && !strings.HasPrefix(setting.StripeApiSecret, "rk_") {
+		return "", fmt.Errorf("无效的Stripe API密钥")
+	}
+
+	stripe.Key = setting.StripeApiSecret
+
+	params := &stripe.CheckoutSessionParams{
+		ClientReferenceID: stripe.String(referenceId),
+		SuccessURL:        stripe.String(system_setting.ServerAddress + "/console/log"),
+		CancelURL:         stripe.String(system_setting.ServerAddress + "/topup"),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				Price:    stripe.String(priceId),
+				Quantity: stripe.Int64(1),
+			},
+		},
+		Mode: stripe.String(string(stripe.CheckoutSessionModeSubscription)),
+	}
+
+	// 订阅模式会自动创建客户
+	if "" == customerId {
+		if "" != email {
+			params.CustomerEmail = stripe.String(email)
+		}
+	} else {
+		params.Customer = stripe.String(customerId)
+	}
+
+	result, err := session.New(params)
+	if err != nil {
+		return "", err
+	}
+
+	return result.URL, nil
+}
+
+func subscriptionSessionCompleted(event stripe.Event) {
+	customerId := event.GetObjectValue("customer")
+	referenceId := event.GetObjectValue("client_reference_id")
+	status := event.GetObjectValue("status")
+	if "complete" != status {
+		log.Println("错误的Stripe订阅Checkout完成状态:", status, ",", referenceId)
+		return
+	}
+
+	sub, err := model.GetSubscriptionByTradeNo(referenceId)
+	if err != nil {
+		log.Println("订阅订单不存在", referenceId)
+		return
+	}
+	if sub.Status != model.SubscriptionStatusPending {
+		log.Println("订阅订单状态错误", referenceId)
+		return
+	}
+	sub.StripeSubscriptionId = event.GetObjectValue("subscription")
+	if err := model.ActivateSubscription(sub, 1); err != nil {
+		log.Println("激活订阅失败", referenceId, ", err:", err.Error())
+		return
+	}
+	if err := model.SetUserStripeCustomer(sub.UserId, customerId); err != nil {
+		log.Println("更新Stripe客户失败", referenceId, ", err:", err.Error())
+	}
+	log.Printf("订阅已激活：%s, stripe subscription %s", referenceId, sub.StripeSubscriptionId)
+}
+
+func subscriptionSessionExpired(event stripe.Event) {
+	referenceId := event.GetObjectValue("client_reference_id")
+	sub, err := model.GetSubscriptionByTradeNo(referenceId)
+	if err != nil {
+		log.Println("订阅订单不存在", referenceId)
+		return
+	}
+	if sub.Status != model.SubscriptionStatusPending {
+		return
+	}
+	sub.Status = model.SubscriptionStatusExpired
+	if err := sub.Update(); err != nil {
+		log.Println("过期订阅订单失败", referenceId, ", err:", err.Error())
+		return
+	}
+	log.Println("订阅订单已过期", referenceId)
+}
+
+// stripeInvoicePaid 周期续费成功后续订一个周期，首次账单由 Checkout 完成事件处理
+func stripeInvoicePaid(event stripe.Event) {
+	if event.GetObjectValue("billing_reason") == string(stripe.InvoiceBillingReasonSubscriptionCreate) {
+		return
+	}
+	invoiceId := event.GetObjectValue("id")
+	stripeSubscriptionId := event.GetObjectValue("subscription")
+	if stripeSubscriptionId == "" {
+		return
+	}
+	sub, err := model.GetSubscriptionByStripeId(stripeSubscriptionId)
+	if err != nil {
+		log.Println("订阅不存在", stripeSubscriptionId)
+		return
+	}
+	if sub.LastInvoiceId == invoiceId {
+		return
+	}
+	sub.LastInvoiceId = invoiceId
+	if err := model.RenewSubscription(sub); err != nil {
+		log.Println("续订失败", stripeSubscriptionId, ", err:", err.Error())
+		return
+	}
+	log.Printf("订阅已续订：%s, invoice %s", stripeSubscriptionId, invoiceId)
+}
+
+// stripeSubscriptionDeleted Stripe 订阅终止后不再续订，当前周期到期后由调度器使其失效
+func stripeSubscriptionDeleted(event stripe.Event) {
+	stripeSubscriptionId := event.GetObjectValue("id")
+	sub, err := model.GetSubscriptionByStripeId(stripeSubscriptionId)
+	if err != nil {
+		log.Println("订阅不存在", stripeSubscriptionId)
+		return
+	}
+	if sub.Status != model.SubscriptionStatusActive {
+		return
+	}
+	if err := model.CancelSubscription(sub); err != nil {
+		log.Println("取消订阅失败", stripeSubscriptionId, ", err:", err.Error())
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/logger"
+	"one-api/metrics"
 	"one-api/model"
 	"one-api/relay"
 	"sort"
@@ -76,6 +77,8 @@ func UpdateTaskByPlatform(platform constant.TaskPlatform, taskChannelM map[int][
 		//_ = UpdateMidjourneyTaskAll(context.Background(), tasks)
 	case constant.TaskPlatformSuno:
 		_ = UpdateSunoTaskAll(context.Background(), taskChannelM, taskM)
+	case constant.TaskPlatformFineTuning:
+		UpdateFineTuningTaskAll(context.Background(), taskChannelM, taskM)
 	default:
 		if err := UpdateVideoTaskAll(context.Background(), platform, taskChannelM, taskM); err != nil {
 			common.SysLog(fmt.Sprintf("UpdateVideoTaskAll fail: %s", err))
@@ -163,9 +166,11 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 			} else {
 				quota := task.Quota
 				if quota != 0 {
-					err = model.IncreaseUserQuota(task.UserId, quota, false)
+					err = model.IncreaseUserQuota(task.UserId, quota, false, model.LedgerRef{Reason: model.LedgerReasonTaskRefund, RefType: model.LedgerRefTask, RefId: task.TaskID})
 					if err != nil {
 						logger.LogError(ctx, "fail to increase user quota: "+err.Error())
+					} else {
+						metrics.QuotaRefunded.WithLabelValues(metrics.RefundTask).Add(float64(quota))
 					}
 					logContent := fmt.Sprintf("异步任务执行失败 %s，补偿 %s", task.TaskID, logger.LogQuota(quota))
 					model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
@@ -9,6 +9,7 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/logger"
+	"one-api/metrics"
 	"one-api/model"
 	"one-api/relay"
 	"one-api/relay/channel"
@@ -130,8 +131,10 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 		logger.LogInfo(ctx, fmt.Sprintf("Task %s failed: %s", task.TaskID, task.FailReason))
 		quota := task.Quota
 		if quota != 0 {
-			if err := model.IncreaseUserQuota(task.UserId, quota, false); err != nil {
+			if err := model.IncreaseUserQuota(task.UserId, quota, false, model.LedgerRef{Reason: model.LedgerReasonTaskRefund, RefType: model.LedgerRefTask, RefId: task.TaskID}); err != nil {
 				logger.LogError(ctx, "Failed to increase user quota: "+err.Error())
+			} else {
+				metrics.QuotaRefunded.WithLabelValues(metrics.RefundTask).Add(float64(quota))
 			}
 			logContent := fmt.Sprintf("Video async task failed %s, refund %s", task.TaskID, logger.LogQuota(quota))
 			model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
@@ -6,6 +6,7 @@ import (
 	"one-api/model"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -130,10 +131,29 @@ func GetTokenUsage(c *gin.Context) {
 			"model_limits":         token.GetModelLimitsMap(),
 			"model_limits_enabled": token.ModelLimitsEnabled,
 			"expires_at":           expiredAt,
+			"budget":               getTokenBudgetSummary(token),
 		},
 	})
 }
 
+// getTokenBudgetSummary 返回令牌当前周期的预算使用情况，未设置预算时为 nil
+func getTokenBudgetSummary(token *model.Token) gin.H {
+	if !token.HasBudget() {
+		return nil
+	}
+	now := time.Now()
+	periodStart, periodEnd := model.TokenBudgetPeriodBounds(token.BudgetPeriod, token.BudgetTimezone, now)
+	return gin.H{
+		"quota":        token.BudgetQuota,
+		"period":       token.BudgetPeriod,
+		"timezone":     token.BudgetTimezone,
+		"used":         token.GetBudgetUsed(now),
+		"available":    token.GetBudgetRemain(now),
+		"period_start": periodStart,
+		"resets_at":    periodEnd,
+	}
+}
+
 func AddToken(c *gin.Context) {
 	token := model.Token{}
 	err := c.ShouldBindJSON(&token)
@@ -148,6 +168,33 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if _, err := token.GetModelFallbacks(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "模型降级链格式错误",
+		})
+		return
+	}
+	if token.ResponseCache != "" && token.ResponseCache != "enabled" && token.ResponseCache != "semantic" && token.ResponseCache != "disabled" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "响应缓存配置无效",
+		})
+		return
+	}
+	if err := model.ValidateTokenBudget(token.BudgetQuota, token.BudgetPeriod, token.BudgetTimezone); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if token.OrgId != 0 {
+		if _, err := model.GetOrgMember(token.OrgId, c.GetInt("id")); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
 	key, err := common.GenerateKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -170,6 +217,15 @@ func AddToken(c *gin.Context) {
 		ModelLimits:        token.ModelLimits,
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
+		RateLimitRPM:       max(token.RateLimitRPM, 0),
+		RateLimitTPM:       max(token.RateLimitTPM, 0),
+		MaxConcurrency:     max(token.MaxConcurrency, 0),
+		ModelFallbacks:     token.ModelFallbacks,
+		ResponseCache:      token.ResponseCache,
+		BudgetQuota:        token.BudgetQuota,
+		BudgetPeriod:       token.BudgetPeriod,
+		BudgetTimezone:     token.BudgetTimezone,
+		OrgId:              token.OrgId,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -214,6 +270,27 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if _, err := token.GetModelFallbacks(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "模型降级链格式错误",
+		})
+		return
+	}
+	if token.ResponseCache != "" && token.ResponseCache != "enabled" && token.ResponseCache != "semantic" && token.ResponseCache != "disabled" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "响应缓存配置无效",
+		})
+		return
+	}
+	if err := model.ValidateTokenBudget(token.BudgetQuota, token.BudgetPeriod, token.BudgetTimezone); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		common.ApiError(c, err)
@@ -235,6 +312,7 @@ func UpdateToken(c *gin.Context) {
 			return
 		}
 	}
+	resetBudget := false
 	if statusOnly != "" {
 		cleanToken.Status = token.Status
 	} else {
@@ -247,8 +325,22 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.ModelLimits = token.ModelLimits
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
+		cleanToken.RateLimitRPM = max(token.RateLimitRPM, 0)
+		cleanToken.RateLimitTPM = max(token.RateLimitTPM, 0)
+		cleanToken.MaxConcurrency = max(token.MaxConcurrency, 0)
+		cleanToken.ModelFallbacks = token.ModelFallbacks
+		cleanToken.ResponseCache = token.ResponseCache
+		// 周期划分变化后重新开始计算已用预算
+		resetBudget = cleanToken.BudgetPeriod != token.BudgetPeriod || cleanToken.BudgetTimezone != token.BudgetTimezone
+		cleanToken.BudgetQuota = token.BudgetQuota
+		cleanToken.BudgetPeriod = token.BudgetPeriod
+		cleanToken.BudgetTimezone = token.BudgetTimezone
+	}
+	if resetBudget {
+		err = cleanToken.UpdateAndResetBudget()
+	} else {
+		err = cleanToken.Update()
 	}
-	err = cleanToken.Update()
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -145,9 +145,21 @@ func StripeWebhook(c *gin.Context) {
 
 	switch event.Type {
 	case stripe.EventTypeCheckoutSessionCompleted:
-		sessionCompleted(event)
+		if event.GetObjectValue("mode") == string(stripe.CheckoutSessionModeSubscription) {
+			subscriptionSessionCompleted(event)
+		} else {
+			sessionCompleted(event)
+		}
 	case stripe.EventTypeCheckoutSessionExpired:
-		sessionExpired(event)
+		if event.GetObjectValue("mode") == string(stripe.CheckoutSessionModeSubscription) {
+			subscriptionSessionExpired(event)
+		} else {
+			sessionExpired(event)
+		}
+	case stripe.EventTypeInvoicePaid:
+		stripeInvoicePaid(event)
+	case stripe.EventTypeCustomerSubscriptionDeleted:
+		stripeSubscriptionDeleted(event)
 	default:
 		log.Printf("不支持的Stripe Webhook事件类型: %s\n", event.Type)
 	}
@@ -442,6 +442,13 @@ func GetSelf(c *gin.Context) {
 	// 获取用户设置并提取sidebar_modules
 	userSetting := user.GetSetting()
 
+	// 余额按额度批次拆分，quota 仍为各批次之和
+	creditLots, err := model.GetUserCreditLots(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
 	// 构建响应数据，包含用户信息和权限
 	responseData := map[string]interface{}{
 		"id":                user.Id,
@@ -464,6 +471,7 @@ func GetSelf(c *gin.Context) {
 		"stripe_customer":   user.StripeCustomer,
 		"sidebar_modules":   userSetting.SidebarModules, // 正确提取sidebar_modules字段
 		"permissions":       permissions,                // 新增权限字段
+		"credit_lots":       creditLots,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
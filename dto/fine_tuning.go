@@ -0,0 +1,28 @@
+package dto
+
+import "encoding/json"
+
+type FineTuningJobError struct {
+	Code    string  `json:"code"`
+	Message string  `json:"message"`
+	Param   *string `json:"param"`
+}
+
+// FineTuningJob https://platform.openai.com/docs/api-reference/fine-tuning/object
+// 只解析计费与状态需要的字段，返回给用户的仍是上游原始对象
+type FineTuningJob struct {
+	Id             string              `json:"id"`
+	Model          string              `json:"model"`
+	Status         string              `json:"status"`
+	FineTunedModel string              `json:"fine_tuned_model"`
+	TrainedTokens  int                 `json:"trained_tokens"`
+	TrainingFile   string              `json:"training_file"`
+	ValidationFile string              `json:"validation_file"`
+	Error          *FineTuningJobError `json:"error"`
+}
+
+type FineTuningJobList struct {
+	Object  string            `json:"object"`
+	Data    []json.RawMessage `json:"data"`
+	HasMore bool              `json:"has_more"`
+}
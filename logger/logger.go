@@ -7,6 +7,8 @@ import (
 	"io"
 	"log"
 	"one-api/common"
+	"one-api/constant"
+	"one-api/setting/operation_setting"
 	"os"
 	"path/filepath"
 	"sync"
@@ -52,35 +54,144 @@ func SetupLogger() {
 	}
 }
 
+// ModuleRelay 未指定模块时的默认模块，覆盖请求处理链路上的日志
+const ModuleRelay = "relay"
+
+var levelRanks = map[string]int{
+	common.LogLevelDebug: 0,
+	common.LogLevelInfo:  1,
+	common.LogLevelWarn:  2,
+	common.LogLevelError: 3,
+}
+
+var textLevels = map[string]string{
+	common.LogLevelDebug: loggerDebug,
+	common.LogLevelInfo:  loggerINFO,
+	common.LogLevelWarn:  loggerWarn,
+	common.LogLevelError: loggerError,
+}
+
+func init() {
+	common.LogLevelFilter = levelEnabled
+}
+
+// levelEnabled 按日志设置判断模块在该级别下是否输出，DEBUG 环境变量开启时始终输出调试日志
+func levelEnabled(module string, level string) bool {
+	if level == common.LogLevelDebug && common.DebugEnabled {
+		return true
+	}
+	threshold, ok := levelRanks[operation_setting.GetLogSetting().LevelFor(module)]
+	if !ok {
+		threshold = levelRanks[common.LogLevelInfo]
+	}
+	return levelRanks[level] >= threshold
+}
+
+// Logger 指定模块的日志，模块级别可通过 log_setting.module_levels 单独调整
+type Logger struct {
+	module string
+}
+
+func Module(module string) Logger {
+	return Logger{module: module}
+}
+
+func (l Logger) Info(ctx context.Context, msg string) {
+	logHelper(ctx, l.module, common.LogLevelInfo, msg)
+}
+
+func (l Logger) Warn(ctx context.Context, msg string) {
+	logHelper(ctx, l.module, common.LogLevelWarn, msg)
+}
+
+func (l Logger) Error(ctx context.Context, msg string) {
+	logHelper(ctx, l.module, common.LogLevelError, msg)
+}
+
+func (l Logger) Debug(ctx context.Context, msg string) {
+	logHelper(ctx, l.module, common.LogLevelDebug, msg)
+}
+
 func LogInfo(ctx context.Context, msg string) {
-	logHelper(ctx, loggerINFO, msg)
+	logHelper(ctx, ModuleRelay, common.LogLevelInfo, msg)
 }
 
 func LogWarn(ctx context.Context, msg string) {
-	logHelper(ctx, loggerWarn, msg)
+	logHelper(ctx, ModuleRelay, common.LogLevelWarn, msg)
 }
 
 func LogError(ctx context.Context, msg string) {
-	logHelper(ctx, loggerError, msg)
+	logHelper(ctx, ModuleRelay, common.LogLevelError, msg)
 }
 
 func LogDebug(ctx context.Context, msg string) {
-	if common.DebugEnabled {
-		logHelper(ctx, loggerDebug, msg)
+	logHelper(ctx, ModuleRelay, common.LogLevelDebug, msg)
+}
+
+// contextFields 从请求上下文中取出用于关联同一请求日志的字段，ctx 为 *gin.Context 时可取到用户、令牌与渠道
+func contextFields(ctx context.Context) map[string]any {
+	fields := make(map[string]any)
+	if id, ok := ctx.Value(common.RequestIdKey).(string); ok && id != "" {
+		fields["request_id"] = id
+	}
+	for field, key := range map[string]constant.ContextKey{
+		"user_id":    constant.ContextKeyUserId,
+		"token_id":   constant.ContextKeyTokenId,
+		"channel_id": constant.ContextKeyChannelId,
+	} {
+		if v, ok := ctx.Value(string(key)).(int); ok && v != 0 {
+			fields[field] = v
+		}
+	}
+	if model, ok := ctx.Value(string(constant.ContextKeyOriginalModel)).(string); ok && model != "" {
+		fields["model"] = model
+	}
+	return fields
+}
+
+// valuesContext 以与 gin.Context 相同的字符串键携带请求关联字段
+type valuesContext struct {
+	context.Context
+	values map[string]any
+}
+
+func (c valuesContext) Value(key any) any {
+	if k, ok := key.(string); ok {
+		if v, ok := c.values[k]; ok {
+			return v
+		}
 	}
+	return c.Context.Value(key)
+}
+
+// WithValues 返回携带请求 id、用户等关联字段的上下文，键与 gin.Context 中的键一致，
+// 请求结束后仍在运行的后台任务用它记录日志，使其与原请求的日志关联
+func WithValues(ctx context.Context, values map[string]any) context.Context {
+	return valuesContext{Context: ctx, values: values}
 }
 
-func logHelper(ctx context.Context, level string, msg string) {
+func logHelper(ctx context.Context, module string, level string, msg string) {
+	if !levelEnabled(module, level) {
+		return
+	}
+	// 后台任务可能传入值为 nil 的 *gin.Context
+	if gc, ok := ctx.(*gin.Context); ctx == nil || (ok && gc == nil) {
+		ctx = context.Background()
+	}
 	writer := gin.DefaultErrorWriter
-	if level == loggerINFO {
+	if level == common.LogLevelInfo {
 		writer = gin.DefaultWriter
 	}
-	id := ctx.Value(common.RequestIdKey)
-	if id == nil {
-		id = "SYSTEM"
+	if common.LogJSONEnabled {
+		common.WriteJSONLog(writer, level, module, msg, contextFields(ctx))
+	} else {
+		id := ctx.Value(common.RequestIdKey)
+		if id == nil {
+			id = "SYSTEM"
+		}
+		now := time.Now()
+		_, _ = fmt.Fprintf(writer, "[%s] %v | %s | %s \n", textLevels[level], now.Format("2006/01/02 - 15:04:05"), id, msg)
 	}
-	now := time.Now()
-	_, _ = fmt.Fprintf(writer, "[%s] %v | %s | %s \n", level, now.Format("2006/01/02 - 15:04:05"), id, msg)
 	logCount++ // we don't need accurate count, so no lock here
 	if logCount > maxLogCount && !setupLogWorking {
 		logCount = 0
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"log"
@@ -9,11 +10,13 @@ import (
 	"one-api/constant"
 	"one-api/controller"
 	"one-api/logger"
+	"one-api/metrics"
 	"one-api/middleware"
 	"one-api/model"
 	"one-api/router"
 	"one-api/service"
 	"one-api/setting/ratio_setting"
+	"one-api/tracing"
 	"os"
 	"strconv"
 
@@ -105,6 +108,26 @@ func main() {
 			controller.UpdateTaskBulk()
 		})
 	}
+	if common.IsMasterNode {
+		gopool.Go(func() {
+			controller.StartBatchWorker()
+		})
+		gopool.Go(func() {
+			service.StartSubscriptionWorker()
+		})
+		gopool.Go(func() {
+			service.StartCreditLotWorker()
+		})
+		gopool.Go(func() {
+			service.StartIdempotencyCleanupWorker()
+		})
+		gopool.Go(func() {
+			service.StartPayloadCaptureCleanupWorker()
+		})
+		gopool.Go(func() {
+			service.StartLogRollupWorker()
+		})
+	}
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		common.BatchUpdateEnabled = true
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
@@ -119,6 +142,22 @@ func main() {
 		common.SysLog("pprof enabled")
 	}
 
+	shutdownTracing, err := tracing.Init()
+	if err != nil {
+		common.FatalLog("failed to initialize tracing: " + err.Error())
+	}
+	defer shutdownTracing(context.Background())
+
+	if constant.MetricsPort != "" {
+		// 独立端口仅应绑定在内网或管理网络
+		gopool.Go(func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics.Handler())
+			log.Println(http.ListenAndServe("0.0.0.0:"+constant.MetricsPort, mux))
+		})
+		common.SysLog("metrics enabled on port " + constant.MetricsPort)
+	}
+
 	// Initialize HTTP server
 	server := gin.New()
 	server.Use(gin.CustomRecovery(func(c *gin.Context, err any) {
@@ -133,6 +172,7 @@ func main() {
 	// This will cause SSE not to work!!!
 	//server.Use(gzip.Gzip(gzip.DefaultCompression))
 	server.Use(middleware.RequestId())
+	server.Use(middleware.Tracing())
 	middleware.SetUpLogger(server)
 	// Initialize session store
 	store := cookie.NewStore([]byte(common.SessionSecret))
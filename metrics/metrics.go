@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus 指标。标签只使用模型、渠道 id、分组、状态码等取值有限的字段，
+// 不使用用户、令牌等会随业务无限增长的字段。
+
+const namespace = "newapi"
+
+// latencyBuckets 覆盖从秒级对话到分钟级图片、视频生成的耗时
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30, 60, 120, 300}
+
+var registry = prometheus.NewRegistry()
+
+var (
+	RelayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_requests_total",
+		Help:      "Relay requests by model, channel, group and HTTP status.",
+	}, []string{"model", "channel", "group", "status"})
+
+	RelayDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_request_duration_seconds",
+		Help:      "Total relay request duration.",
+		Buckets:   latencyBuckets,
+	}, []string{"model", "channel", "group", "status"})
+
+	RelayFirstToken = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_first_token_seconds",
+		Help:      "Time to first response byte of streaming relay requests.",
+		Buckets:   latencyBuckets,
+	}, []string{"model", "channel", "group"})
+
+	RelayTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_tokens_total",
+		Help:      "Billed tokens by model, channel, group and type (prompt or completion).",
+	}, []string{"model", "channel", "group", "type"})
+
+	RelayRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_retries_total",
+		Help:      "Relay retries on another channel.",
+	}, []string{"model", "group"})
+
+	ChannelAutoDisabled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "channel_auto_disabled_total",
+		Help:      "Channels automatically disabled after upstream errors.",
+	}, []string{"channel"})
+
+	QuotaPreConsumed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "quota_pre_consumed_total",
+		Help:      "Quota pre-consumed before relaying.",
+	})
+
+	QuotaRefunded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "quota_refunded_total",
+		Help:      "Quota refunded by type (pre_consume or task).",
+	}, []string{"type"})
+
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by cache (token from Redis, channel from memory) and result (hit or miss).",
+	}, []string{"cache", "result"})
+)
+
+const (
+	CacheToken   = "token"
+	CacheChannel = "channel"
+
+	RefundPreConsume = "pre_consume"
+	RefundTask       = "task"
+)
+
+// PendingTaskCounter 返回各任务平台未完成的任务数，由 service 在启动时设置，采集时调用
+type PendingTaskCounter func() (map[string]int64, error)
+
+type pendingTaskCollector struct {
+	desc    *prometheus.Desc
+	counter PendingTaskCounter
+	mu      sync.Mutex
+}
+
+func (p *pendingTaskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.desc
+}
+
+func (p *pendingTaskCollector) Collect(ch chan<- prometheus.Metric) {
+	p.mu.Lock()
+	counter := p.counter
+	p.mu.Unlock()
+	if counter == nil {
+		return
+	}
+	counts, err := counter()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(p.desc, err)
+		return
+	}
+	for platform, count := range counts {
+		ch <- prometheus.MustNewConstMetric(p.desc, prometheus.GaugeValue, float64(count), platform)
+	}
+}
+
+var pendingTasks = &pendingTaskCollector{
+	desc: prometheus.NewDesc(namespace+"_tasks_pending", "Unfinished async tasks by task platform.", []string{"platform"}, nil),
+}
+
+func SetPendingTaskCounter(counter PendingTaskCounter) {
+	pendingTasks.mu.Lock()
+	defer pendingTasks.mu.Unlock()
+	pendingTasks.counter = counter
+}
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RelayRequests,
+		RelayDuration,
+		RelayFirstToken,
+		RelayTokens,
+		RelayRetries,
+		ChannelAutoDisabled,
+		QuotaPreConsumed,
+		QuotaRefunded,
+		CacheRequests,
+		pendingTasks,
+	)
+}
+
+// Handler 返回 Prometheus 文本格式的指标
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func ChannelLabel(channelId int) string {
+	return strconv.Itoa(channelId)
+}
+
+func RecordCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheRequests.WithLabelValues(cache, result).Inc()
+}
@@ -8,8 +8,10 @@ import (
 	"one-api/model"
 	"one-api/setting"
 	"one-api/setting/ratio_setting"
+	"one-api/tracing"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -177,6 +179,10 @@ func WssAuth(c *gin.Context) {
 
 func TokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		_, span := tracing.StartSpan(c, "TokenAuth")
+		// 鉴权通过后在进入后续处理前结束 span，提前返回时由 defer 结束
+		endSpan := sync.OnceFunc(func() { span.End() })
+		defer endSpan()
 		// 先检测是否为ws
 		if c.Request.Header.Get("Sec-WebSocket-Protocol") != "" {
 			// Sec-WebSocket-Protocol: realtime, openai-insecure-api-key.sk-xxx, openai-beta.realtime-v1
@@ -310,6 +316,12 @@ func TokenAuth() func(c *gin.Context) {
 		if err != nil {
 			return
 		}
+		release, ok := checkTokenRateLimit(c, token)
+		if !ok {
+			return
+		}
+		defer release()
+		endSpan()
 		c.Next()
 	}
 }
@@ -333,6 +345,17 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 		c.Set("token_model_limit_enabled", false)
 	}
 	c.Set("token_group", token.Group)
+	if fallbacks, err := token.GetModelFallbacks(); err == nil && len(fallbacks) > 0 {
+		common.SetContextKey(c, constant.ContextKeyTokenModelFallbacks, fallbacks)
+	}
+	common.SetContextKey(c, constant.ContextKeyTokenResponseCache, token.ResponseCache)
+	if token.HasBudget() {
+		common.SetContextKey(c, constant.ContextKeyTokenBudgetPeriod, token.BudgetPeriod)
+		common.SetContextKey(c, constant.ContextKeyTokenBudgetTimezone, token.BudgetTimezone)
+	}
+	if token.OrgId != 0 {
+		common.SetContextKey(c, constant.ContextKeyTokenOrgId, token.OrgId)
+	}
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
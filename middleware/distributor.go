@@ -7,14 +7,17 @@ import (
 	"one-api/common"
 	"one-api/constant"
 	"one-api/dto"
+	"one-api/logger"
 	"one-api/model"
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
 	"one-api/setting"
 	"one-api/setting/ratio_setting"
+	"one-api/tracing"
 	"one-api/types"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -25,8 +28,15 @@ type ModelRequest struct {
 	Group string `json:"group,omitempty"`
 }
 
+// distributorLogger 渠道分发的诊断日志，可通过 log_setting.module_levels 单独调整级别
+var distributorLogger = logger.Module("distributor")
+
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		_, span := tracing.StartSpan(c, "Distribute")
+		// 渠道选择完成后即结束 span，不计入后续转发的耗时
+		endSpan := sync.OnceFunc(func() { span.End() })
+		defer endSpan()
 		var channel *model.Channel
 		channelId, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId)
 		modelRequest, shouldSelectChannel, err := getModelRequest(c)
@@ -77,6 +87,12 @@ func Distribute() func(c *gin.Context) {
 					abortWithOpenAiMessage(c, http.StatusBadRequest, "未指定模型名称，模型名称不能为空")
 					return
 				}
+				// 微调模型的能力注册在所属用户的分组上，同分组的其他用户不可调用
+				userId := common.GetContextKeyInt(c, constant.ContextKeyUserId)
+				if !model.CanUseFineTunedModel(modelRequest.Model, userId) {
+					abortWithOpenAiMessage(c, http.StatusForbidden, "无权访问模型 "+modelRequest.Model)
+					return
+				}
 				var selectGroup string
 				userGroup := common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
 				// check path is /pg/chat/completions
@@ -96,9 +112,26 @@ func Distribute() func(c *gin.Context) {
 					}
 				}
 				// 🔧 增强诊断日志: 记录渠道选择请求
-				common.SysLog(fmt.Sprintf("[Distributor] 请求渠道: group=%s, model=%s, path=%s", userGroup, modelRequest.Model, c.Request.URL.Path))
+				distributorLogger.Info(c, fmt.Sprintf("请求渠道: group=%s, model=%s, path=%s", userGroup, modelRequest.Model, c.Request.URL.Path))
 
-				channel, selectGroup, err = model.CacheGetRandomSatisfiedChannel(c, userGroup, modelRequest.Model, 0)
+				channel, selectGroup, err = model.CacheGetDispatchChannel(c, userGroup, modelRequest.Model, 0)
+				if err != nil || channel == nil {
+					// 请求的模型没有可用渠道时，沿降级链寻找可用模型
+					for _, fallbackModel := range service.GetModelFallbacks(c, userGroup, modelRequest.Model) {
+						if !model.CanUseFineTunedModel(fallbackModel, userId) {
+							continue
+						}
+						fallbackChannel, fallbackGroup, fallbackErr := model.CacheGetDispatchChannel(c, userGroup, fallbackModel, 0)
+						if fallbackErr != nil || fallbackChannel == nil {
+							continue
+						}
+						distributorLogger.Info(c, fmt.Sprintf("模型 %s 无可用渠道，降级到 %s", modelRequest.Model, fallbackModel))
+						common.SetContextKey(c, constant.ContextKeyFallbackFromModel, modelRequest.Model)
+						channel, selectGroup, err = fallbackChannel, fallbackGroup, nil
+						modelRequest.Model = fallbackModel
+						break
+					}
+				}
 				if err != nil {
 					showGroup := userGroup
 					if userGroup == "auto" {
@@ -107,7 +140,7 @@ func Distribute() func(c *gin.Context) {
 					message := fmt.Sprintf("获取分组 %s 下模型 %s 的可用渠道失败（数据库一致性已被破坏，distributor）: %s", showGroup, modelRequest.Model, err.Error())
 
 					// 🔧 增强错误诊断
-					common.SysError(fmt.Sprintf("[Distributor] 渠道选择失败! group=%s, model=%s, error=%v, memory_cache=%v",
+					distributorLogger.Error(c, fmt.Sprintf("渠道选择失败! group=%s, model=%s, error=%v, memory_cache=%v",
 						userGroup, modelRequest.Model, err, common.MemoryCacheEnabled))
 
 					abortWithOpenAiMessage(c, http.StatusServiceUnavailable, message, string(types.ErrorCodeModelNotFound))
@@ -115,7 +148,7 @@ func Distribute() func(c *gin.Context) {
 				}
 				if channel == nil {
 					// 🔧 增强诊断: 无可用渠道时记录更多信息
-					common.SysError(fmt.Sprintf("[Distributor] 无可用渠道! group=%s, model=%s, path=%s, memory_cache=%v",
+					distributorLogger.Error(c, fmt.Sprintf("无可用渠道! group=%s, model=%s, path=%s, memory_cache=%v",
 						userGroup, modelRequest.Model, c.Request.URL.Path, common.MemoryCacheEnabled))
 
 					abortWithOpenAiMessage(c, http.StatusServiceUnavailable, fmt.Sprintf("分组 %s 下模型 %s 无可用渠道（distributor）", userGroup, modelRequest.Model), string(types.ErrorCodeModelNotFound))
@@ -123,7 +156,7 @@ func Distribute() func(c *gin.Context) {
 				}
 
 				// 🔧 记录成功选择的渠道
-				common.SysLog(fmt.Sprintf("[Distributor] 渠道选择成功: channel_id=%d, type=%d, group=%s, model=%s",
+				distributorLogger.Info(c, fmt.Sprintf("渠道选择成功: channel_id=%d, type=%d, group=%s, model=%s",
 					channel.Id, channel.Type, userGroup, modelRequest.Model))
 			}
 		}
@@ -139,6 +172,8 @@ func Distribute() func(c *gin.Context) {
 			// 2. 在价格设置中为 "runway" 模型配置价格
 		}
 
+		span.SetAttributes(tracing.AttrChannelId.Int(common.GetContextKeyInt(c, constant.ContextKeyChannelId)), tracing.AttrModel.String(modelRequest.Model))
+		endSpan()
 		c.Next()
 	}
 }
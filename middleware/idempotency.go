@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/logger"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	idempotencyMaxKeyLength   = 255
+	idempotencyPollInterval   = 200 * time.Millisecond
+	idempotencyDefaultTimeout = 60
+)
+
+// idempotencyWriter 在写出响应的同时保留一份副本，超过 limit 时放弃保存
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (w *idempotencyWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.limit > 0 && w.buf.Len()+len(data) > w.limit {
+		w.overflow = true
+		w.buf.Reset()
+		return
+	}
+	w.buf.Write(data)
+}
+
+func (w *idempotencyWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// isStreamRelayRequest 流式请求不支持幂等键，直接放行
+func isStreamRelayRequest(c *gin.Context, body []byte) bool {
+	if strings.Contains(c.Request.URL.Path, ":streamGenerateContent") || c.Query("alt") == "sse" {
+		return true
+	}
+	if !strings.HasPrefix(c.GetHeader("Content-Type"), "application/json") {
+		return false
+	}
+	var req struct {
+		Stream bool `json:"stream"`
+	}
+	_ = common.Unmarshal(body, &req)
+	return req.Stream
+}
+
+func idempotencyRecordKey(tokenId int, c *gin.Context, key string) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d|%s|%s|%s", tokenId, c.Request.Method, c.Request.URL.Path, key)))
+	return hex.EncodeToString(hash[:])
+}
+
+// replayIdempotentResponse 返回首个请求保存的响应；未保存响应体时返回 409，告知请求已成功处理且已计费
+func replayIdempotentResponse(c *gin.Context, record *model.IdempotencyRecord) {
+	c.Header(IdempotencyReplayedHeader, "true")
+	if record.LogId != 0 {
+		c.Header("X-Idempotent-Log-Id", strconv.Itoa(record.LogId))
+	}
+	if record.BodyOmitted {
+		abortWithOpenAiMessage(c, http.StatusConflict, "相同 Idempotency-Key 的请求已成功处理，但响应未保存，无法回放", "idempotency_response_unavailable")
+		return
+	}
+	c.Data(record.StatusCode, record.ContentType, record.Body)
+	c.Abort()
+}
+
+// Idempotency 支持 Idempotency-Key 请求头：相同令牌与幂等键的重试直接返回首个请求保存的响应，
+// 不再请求上游也不重复计费；首个请求未完成时，并发的重复请求等待其完成。
+// 需放在 TokenAuth 之后，只处理非流式的 POST 请求；失败的请求释放幂等键，成功的请求保留记录，响应过大时不保存响应体。
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencySetting := operation_setting.GetIdempotencySetting()
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if !idempotencySetting.Enabled || key == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		if len(key) > idempotencyMaxKeyLength {
+			abortWithOpenAiMessage(c, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key 长度不能超过 %d", idempotencyMaxKeyLength), "invalid_idempotency_key")
+			return
+		}
+		body, err := common.GetRequestBody(c)
+		if err != nil {
+			abortWithOpenAiMessage(c, http.StatusBadRequest, "读取请求体失败")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+		if isStreamRelayRequest(c, body) {
+			c.Next()
+			return
+		}
+
+		bodyHash := sha256.Sum256(body)
+		record := &model.IdempotencyRecord{
+			Key:         idempotencyRecordKey(c.GetInt("token_id"), c, key),
+			TokenId:     c.GetInt("token_id"),
+			Fingerprint: hex.EncodeToString(bodyHash[:]),
+		}
+		waitTimeout := idempotencySetting.WaitTimeoutSeconds
+		if waitTimeout <= 0 {
+			waitTimeout = idempotencyDefaultTimeout
+		}
+		deadline := time.Now().Add(time.Duration(waitTimeout) * time.Second)
+		for {
+			acquired, err := service.AcquireIdempotencyKey(record)
+			if err != nil {
+				// 存储不可用时不阻断请求
+				logger.LogError(c, "failed to acquire idempotency key: "+err.Error())
+				c.Next()
+				return
+			}
+			if acquired {
+				break
+			}
+			existing, err := service.GetIdempotencyRecord(record.Key)
+			if err != nil {
+				logger.LogError(c, "failed to get idempotency record: "+err.Error())
+				c.Next()
+				return
+			}
+			if existing != nil {
+				if existing.Fingerprint != record.Fingerprint {
+					abortWithOpenAiMessage(c, http.StatusUnprocessableEntity, "该 Idempotency-Key 已用于不同的请求", "idempotency_key_reused")
+					return
+				}
+				if existing.Status == model.IdempotencyStatusCompleted {
+					replayIdempotentResponse(c, existing)
+					return
+				}
+			}
+			// 首个请求仍在处理中，或刚刚释放，稍后重试
+			if time.Now().After(deadline) {
+				abortWithOpenAiMessage(c, http.StatusConflict, "相同 Idempotency-Key 的请求仍在处理中，请稍后重试", "idempotency_key_in_progress")
+				return
+			}
+			select {
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			case <-time.After(idempotencyPollInterval):
+			}
+		}
+
+		writer := &idempotencyWriter{ResponseWriter: c.Writer, limit: idempotencySetting.MaxBodyBytes}
+		c.Writer = writer
+		c.Next()
+
+		status := writer.Status()
+		// 失败的请求不会计费，释放幂等键允许重试
+		if status < 200 || status >= 300 {
+			if err := service.ReleaseIdempotencyKey(record); err != nil {
+				logger.LogError(c, "failed to release idempotency key: "+err.Error())
+			}
+			return
+		}
+		// 成功的请求已经计费，即使响应无法保存也要保留记录，避免重试重复计费
+		contentType := writer.Header().Get("Content-Type")
+		record.StatusCode = status
+		record.ContentType = contentType
+		if writer.overflow || strings.HasPrefix(contentType, "text/event-stream") {
+			record.BodyOmitted = true
+		} else {
+			record.Body = writer.buf.Bytes()
+		}
+		record.LogId = common.GetContextKeyInt(c, constant.ContextKeyConsumeLogId)
+		record.Quota = common.GetContextKeyInt(c, constant.ContextKeyConsumeQuota)
+		if err := service.CompleteIdempotencyKey(record); err != nil {
+			logger.LogError(c, "failed to save idempotency record: "+err.Error())
+		}
+	}
+}
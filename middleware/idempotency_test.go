@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 测试不依赖 Redis，幂等记录保存在 SQLite 中
+func TestMain(m *testing.M) {
+	common.RedisEnabled = false
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+func setupIdempotencyTest(t *testing.T, maxBodyBytes int) {
+	t.Helper()
+	savedDB, savedSQLitePath, savedUsingSQLite := model.DB, common.SQLitePath, common.UsingSQLite
+	t.Setenv("SQL_DSN", "")
+	common.SQLitePath = filepath.Join(t.TempDir(), "test.db")
+	if err := model.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.DB.AutoMigrate(&model.IdempotencyRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	setting := operation_setting.GetIdempotencySetting()
+	savedSetting := *setting
+	setting.Enabled = true
+	setting.TTLSeconds = 60
+	setting.WaitTimeoutSeconds = 1
+	setting.MaxBodyBytes = maxBodyBytes
+	t.Cleanup(func() {
+		if sqlDB, err := model.DB.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+		model.DB, common.SQLitePath, common.UsingSQLite = savedDB, savedSQLitePath, savedUsingSQLite
+		*setting = savedSetting
+	})
+}
+
+// newIdempotencyRouter 返回的路由依次以 statuses 中的状态码响应，并统计处理次数
+func newIdempotencyRouter(statuses []int, body string, calls *int) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("token_id", 1)
+	}, Idempotency())
+	router.POST("/v1/chat/completions", func(c *gin.Context) {
+		status := statuses[min(*calls, len(statuses)-1)]
+		*calls++
+		c.Data(status, "application/json", []byte(body))
+	})
+	return router
+}
+
+func doIdempotentRequest(router *gin.Engine, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency(t *testing.T) {
+	const request = `{"model":"gpt-4o"}`
+	const response = `{"id":"chatcmpl-1"}`
+	tests := []struct {
+		name         string
+		maxBodyBytes int
+		statuses     []int
+		retryBody    string
+		wantCalls    int
+		wantStatus   int
+		wantReplayed bool
+	}{
+		{
+			name:         "retry replays saved response",
+			maxBodyBytes: 1024,
+			statuses:     []int{http.StatusOK},
+			retryBody:    request,
+			wantCalls:    1,
+			wantStatus:   http.StatusOK,
+			wantReplayed: true,
+		},
+		{
+			name:         "failed request releases the key",
+			maxBodyBytes: 1024,
+			statuses:     []int{http.StatusInternalServerError, http.StatusOK},
+			retryBody:    request,
+			wantCalls:    2,
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:         "oversized response keeps the key without body",
+			maxBodyBytes: 4,
+			statuses:     []int{http.StatusOK},
+			retryBody:    request,
+			wantCalls:    1,
+			wantStatus:   http.StatusConflict,
+			wantReplayed: true,
+		},
+		{
+			name:         "key reused for a different request",
+			maxBodyBytes: 1024,
+			statuses:     []int{http.StatusOK},
+			retryBody:    `{"model":"gpt-4o-mini"}`,
+			wantCalls:    1,
+			wantStatus:   http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupIdempotencyTest(t, tt.maxBodyBytes)
+			calls := 0
+			router := newIdempotencyRouter(tt.statuses, response, &calls)
+			if w := doIdempotentRequest(router, "key-1", request); w.Code != tt.statuses[0] {
+				t.Fatalf("first request status = %d, want %d", w.Code, tt.statuses[0])
+			}
+			w := doIdempotentRequest(router, "key-1", tt.retryBody)
+			if calls != tt.wantCalls || w.Code != tt.wantStatus {
+				t.Fatalf("handler calls = %d status = %d, want %d %d", calls, w.Code, tt.wantCalls, tt.wantStatus)
+			}
+			if replayed := w.Header().Get(IdempotencyReplayedHeader) == "true"; replayed != tt.wantReplayed {
+				t.Fatalf("replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+			if tt.wantStatus == http.StatusOK && w.Body.String() != response {
+				t.Fatalf("body = %s, want %s", w.Body.String(), response)
+			}
+		})
+	}
+}
+
+func TestReleaseIdempotencyKeyChecksOwner(t *testing.T) {
+	setupIdempotencyTest(t, 1024)
+	first := &model.IdempotencyRecord{Key: "owner-test"}
+	if acquired, err := service.AcquireIdempotencyKey(first); err != nil || !acquired {
+		t.Fatalf("acquire = %v, err = %v", acquired, err)
+	}
+	// 占用超时后被其他请求重新占用
+	stale := *first
+	stale.Owner = "expired-owner"
+	if err := service.ReleaseIdempotencyKey(&stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.CompleteIdempotencyKey(&stale); err == nil {
+		t.Fatal("complete by a stale owner succeeded")
+	}
+	record, err := service.GetIdempotencyRecord(first.Key)
+	if err != nil || record == nil || record.Owner != first.Owner || record.Status != model.IdempotencyStatusProcessing {
+		t.Fatalf("record after stale release = %+v, err = %v", record, err)
+	}
+	if err := service.ReleaseIdempotencyKey(first); err != nil {
+		t.Fatal(err)
+	}
+	if record, _ := service.GetIdempotencyRecord(first.Key); record != nil {
+		t.Fatalf("record still present after owner released it: %+v", record)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"one-api/common"
 	"one-api/constant"
+	"one-api/logger"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -14,8 +15,7 @@ import (
 
 func KlingRequestConvert() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		fmt.Printf("[DEBUG KlingRequestConvert] START - Method: %s, Path: %s\n",
-			c.Request.Method, c.Request.URL.Path)
+		logger.LogDebug(c, fmt.Sprintf("[KlingRequestConvert] START - Method: %s, Path: %s", c.Request.Method, c.Request.URL.Path))
 
 		// 保存原始路径，用于 Bltcy 透传
 		originalPath := c.Request.URL.Path
@@ -25,8 +25,7 @@ func KlingRequestConvert() func(c *gin.Context) {
 
 		// GET 请求不需要转换请求体，也不需要选择渠道（任务模式从数据库查询）
 		if c.Request.Method == "GET" {
-			fmt.Printf("[DEBUG Kling GET] Path: %s, Query: %s\n",
-				originalPath, originalRawQuery)
+			logger.LogDebug(c, fmt.Sprintf("[Kling GET] Path: %s, Query: %s", originalPath, originalRawQuery))
 			// 为 GET 请求设置空的请求体，避免后续中间件尝试读取导致错误
 			c.Set(common.KeyRequestBody, []byte{})
 			c.Next()
@@ -56,7 +55,7 @@ func KlingRequestConvert() func(c *gin.Context) {
 			model = "kling-v1"
 		}
 		c.Set("billing_model_name", model)
-		fmt.Printf("[DEBUG KlingRequestConvert] Set billing_model_name=%q\n", model)
+		logger.LogDebug(c, fmt.Sprintf("[KlingRequestConvert] Set billing_model_name=%q", model))
 
 		prompt, _ := originalReq["prompt"].(string)
 
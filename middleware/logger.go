@@ -1,16 +1,41 @@
 package middleware
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"one-api/common"
+	"time"
 )
 
+// logModuleAccess 访问日志所属的模块
+const logModuleAccess = "access"
+
 func SetUpLogger(server *gin.Engine) {
 	server.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		if !common.LogLevelEnabled(logModuleAccess, common.LogLevelInfo) {
+			return ""
+		}
 		var requestID string
 		if param.Keys != nil {
-			requestID = param.Keys[common.RequestIdKey].(string)
+			requestID, _ = param.Keys[common.RequestIdKey].(string)
+		}
+		if common.LogJSONEnabled {
+			data, _ := json.Marshal(map[string]any{
+				"time":       param.TimeStamp.Format(time.RFC3339Nano),
+				"level":      common.LogLevelInfo,
+				"module":     logModuleAccess,
+				"request_id": requestID,
+				"status":     param.StatusCode,
+				"latency_ms": param.Latency.Milliseconds(),
+				"client_ip":  param.ClientIP,
+				"method":     param.Method,
+				"path":       param.Path,
+				"user_id":    param.Keys["id"],
+				"token_id":   param.Keys["token_id"],
+				"channel_id": param.Keys["channel_id"],
+			})
+			return string(data) + "\n"
 		}
 		return fmt.Sprintf("[GIN] %s | %s | %3d | %13v | %15s | %7s %s\n",
 			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"one-api/constant"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsAuth 校验 Prometheus 采集请求携带的 METRICS_TOKEN（Authorization: Bearer <token>）
+func MetricsAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if constant.MetricsToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(constant.MetricsToken)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
@@ -2,10 +2,10 @@ package middleware
 
 import (
 	"context"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"one-api/common"
+	"one-api/logger"
 	"time"
 )
 
@@ -23,7 +23,7 @@ func redisRateLimiter(c *gin.Context, maxRequestNum int, duration int64, mark st
 	key := "rateLimit:" + mark + c.ClientIP()
 	listLength, err := rdb.LLen(ctx, key).Result()
 	if err != nil {
-		fmt.Println(err.Error())
+		logger.LogError(c, "rate limit check failed: "+err.Error())
 		c.Status(http.StatusInternalServerError)
 		c.Abort()
 		return
@@ -35,7 +35,7 @@ func redisRateLimiter(c *gin.Context, maxRequestNum int, duration int64, mark st
 		oldTimeStr, _ := rdb.LIndex(ctx, key, -1).Result()
 		oldTime, err := time.Parse(timeFormat, oldTimeStr)
 		if err != nil {
-			fmt.Println(err)
+			logger.LogError(c, "rate limit check failed: "+err.Error())
 			c.Status(http.StatusInternalServerError)
 			c.Abort()
 			return
@@ -43,7 +43,7 @@ func redisRateLimiter(c *gin.Context, maxRequestNum int, duration int64, mark st
 		nowTimeStr := time.Now().Format(timeFormat)
 		nowTime, err := time.Parse(timeFormat, nowTimeStr)
 		if err != nil {
-			fmt.Println(err)
+			logger.LogError(c, "rate limit check failed: "+err.Error())
 			c.Status(http.StatusInternalServerError)
 			c.Abort()
 			return
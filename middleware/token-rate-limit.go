@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/common/limiter"
+	"one-api/constant"
+	"one-api/logger"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenConcurrencyTTL 单个请求最长占用并发名额的时间，防止进程异常退出导致名额无法释放
+const tokenConcurrencyTTL = 30 * time.Minute
+
+func formatResetDuration(d time.Duration) string {
+	return fmt.Sprintf("%ds", int(math.Ceil(d.Seconds())))
+}
+
+func setRateLimitHeaders(c *gin.Context, kind string, result limiter.WindowResult) {
+	c.Header("x-ratelimit-limit-"+kind, strconv.FormatInt(result.Limit, 10))
+	c.Header("x-ratelimit-remaining-"+kind, strconv.FormatInt(result.Remaining(), 10))
+	c.Header("x-ratelimit-reset-"+kind, formatResetDuration(result.Reset))
+}
+
+func abortWithTokenRateLimit(c *gin.Context, retryAfter time.Duration, message string) {
+	c.Header("Retry-After", strconv.Itoa(max(int(math.Ceil(retryAfter.Seconds())), 1)))
+	abortWithOpenAiMessage(c, http.StatusTooManyRequests, message, "rate_limit_exceeded")
+}
+
+// checkTokenRateLimit 检查令牌的 RPM、TPM 与并发限制，通过时返回请求结束后需调用的释放函数
+func checkTokenRateLimit(c *gin.Context, token *model.Token) (func(), bool) {
+	release := func() {}
+	ctx := context.Background()
+	if token.RateLimitTPM > 0 {
+		// TPM 按响应后的实际用量累计，这里只检查当前窗口是否已用完
+		result, err := limiter.WindowIncr(ctx, service.TokenTPMLimitKey(token.Id), 0, int64(token.RateLimitTPM), service.TokenRateLimitWindow)
+		if err != nil {
+			logger.LogError(c, "token tpm limit check failed: "+err.Error())
+		} else {
+			setRateLimitHeaders(c, "tokens", result)
+			if !result.Allowed {
+				abortWithTokenRateLimit(c, result.Reset, fmt.Sprintf("令牌已达到每分钟 token 数限制：%d", token.RateLimitTPM))
+				return release, false
+			}
+			common.SetContextKey(c, constant.ContextKeyTokenRateLimitTPM, token.RateLimitTPM)
+		}
+	}
+	// 先占用并发名额再计入 RPM，避免因并发超限被拒绝的请求也消耗每分钟请求数
+	if token.MaxConcurrency > 0 {
+		key := service.TokenConcurrencyKey(token.Id)
+		member := c.GetString(common.RequestIdKey)
+		if member == "" {
+			member = common.GetUUID()
+		}
+		allowed, _, err := limiter.ConcurrencyAcquire(ctx, key, member, int64(token.MaxConcurrency), tokenConcurrencyTTL)
+		if err != nil {
+			logger.LogError(c, "token concurrency limit check failed: "+err.Error())
+		} else if !allowed {
+			abortWithTokenRateLimit(c, time.Second, fmt.Sprintf("令牌已达到最大并发请求数：%d", token.MaxConcurrency))
+			return release, false
+		} else {
+			release = func() {
+				if err := limiter.ConcurrencyRelease(context.Background(), key, member); err != nil {
+					logger.LogError(c, "token concurrency release failed: "+err.Error())
+				}
+			}
+		}
+	}
+	if token.RateLimitRPM > 0 {
+		result, err := limiter.WindowIncr(ctx, service.TokenRPMLimitKey(token.Id), 1, int64(token.RateLimitRPM), service.TokenRateLimitWindow)
+		if err != nil {
+			logger.LogError(c, "token rpm limit check failed: "+err.Error())
+		} else {
+			setRateLimitHeaders(c, "requests", result)
+			if !result.Allowed {
+				// 被拒绝的请求不占用并发名额
+				release()
+				abortWithTokenRateLimit(c, result.Reset, fmt.Sprintf("令牌已达到每分钟请求数限制：%d", token.RateLimitRPM))
+				return func() {}, false
+			}
+		}
+	}
+	return release, true
+}
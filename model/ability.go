@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"one-api/common"
+	"one-api/setting/operation_setting"
 	"strings"
 	"sync"
 
@@ -120,8 +121,20 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	if err != nil {
 		return nil, err
 	}
+	abilities = filterBreakerAbilities(abilities)
 	channel := Channel{}
-	if len(abilities) > 0 {
+	if len(abilities) > 0 && operation_setting.IsAdaptiveChannelSelection(group) {
+		candidates := make([]*Channel, 0, len(abilities))
+		for _, ability_ := range abilities {
+			weight := ability_.Weight
+			candidates = append(candidates, &Channel{Id: ability_.ChannelId, Weight: &weight})
+		}
+		selected, err := pickAdaptiveChannel(candidates, model, 10)
+		if err != nil {
+			return nil, err
+		}
+		channel.Id = selected.Id
+	} else if len(abilities) > 0 {
 		// Randomly choose one
 		weightSum := uint(0)
 		for _, ability_ := range abilities {
@@ -175,14 +188,15 @@ func (channel *Channel) AddAbilities(tx *gorm.DB) error {
 			abilities = append(abilities, ability)
 		}
 	}
-	if len(abilities) == 0 {
-		return nil
-	}
 	// choose DB or provided tx
 	useDB := DB
 	if tx != nil {
 		useDB = tx
 	}
+	abilities = append(abilities, channel.fineTunedAbilities(useDB)...)
+	if len(abilities) == 0 {
+		return nil
+	}
 	for _, chunk := range lo.Chunk(abilities, 50) {
 		err := useDB.Clauses(clause.OnConflict{DoNothing: true}).Create(&chunk).Error
 		if err != nil {
@@ -254,6 +268,7 @@ func (channel *Channel) UpdateAbilities(tx *gorm.DB) error {
 			abilities = append(abilities, ability)
 		}
 	}
+	abilities = append(abilities, channel.fineTunedAbilities(tx)...)
 
 	if len(abilities) > 0 {
 		for _, chunk := range lo.Chunk(abilities, 50) {
@@ -10,6 +10,7 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/types"
+	"slices"
 	"strings"
 	"sync"
 
@@ -52,6 +53,7 @@ type Channel struct {
 
 	OtherSettings string   `json:"settings" gorm:"column:settings"` // 其他设置，存储azure版本等不需要检索的信息，详见dto.ChannelOtherSettings
 	ChannelRatio  *float64 `json:"channel_ratio" gorm:"type:real;default:1"` // 渠道专属倍率
+	CostPrices    *string  `json:"cost_prices" gorm:"type:text"`            // 上游成本价 JSON：模型 -> 成本价，见 ChannelCostPrice
 
 	// cache info
 	Keys []string `json:"-" gorm:"-"`
@@ -65,6 +67,11 @@ type ChannelInfo struct {
 	MultiKeyDisabledTime   map[int]int64         `json:"multi_key_disabled_time,omitempty"`   // key禁用时间列表，key index -> time
 	MultiKeyPollingIndex   int                   `json:"multi_key_polling_index"`             // 多Key模式下轮询的key索引
 	MultiKeyMode           constant.MultiKeyMode `json:"multi_key_mode"`
+	MultiKeyWeights        map[int]int           `json:"multi_key_weights,omitempty"`    // weighted 模式下的 key 权重，key index -> weight，缺省为 1
+	MultiKeyRPMLimit       int                   `json:"multi_key_rpm_limit,omitempty"`  // usage 模式下每个 key 的默认 RPM 上限，0 表示不限制
+	MultiKeyTPMLimit       int                   `json:"multi_key_tpm_limit,omitempty"`  // usage 模式下每个 key 的默认 TPM 上限，0 表示不限制
+	MultiKeyRPMLimits      map[int]int           `json:"multi_key_rpm_limits,omitempty"` // 单个 key 的 RPM 上限，覆盖默认值
+	MultiKeyTPMLimits      map[int]int           `json:"multi_key_tpm_limits,omitempty"` // 单个 key 的 TPM 上限，覆盖默认值
 }
 
 // Value implements driver.Valuer interface
@@ -140,20 +147,53 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	}
 	// If no specific status list or none enabled, fall back to first key
 	if len(enabledIdx) == 0 {
+		recordChannelKeyRequest(channel.Id, 0)
 		return keys[0], 0, nil
 	}
+	// 跳过处于熔断中的 key；选出 key 后才占用半开 key 的探测名额，名额已被其他请求占满时移除该 key 重新选择
+	enabledIdx = filterBreakerKeys(channel.Id, enabledIdx)
+	for {
+		selectedIdx, apiErr := channel.selectEnabledKey(len(keys), enabledIdx)
+		if apiErr != nil {
+			return "", 0, apiErr
+		}
+		if len(enabledIdx) == 1 || acquireKeyBreaker(channel.Id, selectedIdx) {
+			return keys[selectedIdx], selectedIdx, nil
+		}
+		revertChannelKeyRequest(channel.Id, selectedIdx)
+		enabledIdx = slices.DeleteFunc(slices.Clone(enabledIdx), func(idx int) bool { return idx == selectedIdx })
+	}
+}
+
+// selectEnabledKey 按多 Key 模式从可用 key 中选择一个并计入一次请求，调用方需持有渠道的轮询锁
+func (channel *Channel) selectEnabledKey(keyCount int, enabledIdx []int) (int, *types.NewAPIError) {
+	switch channel.ChannelInfo.MultiKeyMode {
+	case constant.MultiKeyModeLRU, constant.MultiKeyModeUsage:
+		return claimChannelKey(channel.Id, &channel.ChannelInfo, enabledIdx), nil
+	}
+	selectedIdx, apiErr := channel.pickEnabledKey(keyCount, enabledIdx)
+	if apiErr == nil {
+		recordChannelKeyRequest(channel.Id, selectedIdx)
+	}
+	return selectedIdx, apiErr
+}
+
+func (channel *Channel) pickEnabledKey(keyCount int, enabledIdx []int) (int, *types.NewAPIError) {
+	availableIdx := make(map[int]bool, len(enabledIdx))
+	for _, idx := range enabledIdx {
+		availableIdx[idx] = true
+	}
 
 	switch channel.ChannelInfo.MultiKeyMode {
 	case constant.MultiKeyModeRandom:
 		// Randomly pick one enabled key
-		selectedIdx := enabledIdx[rand.Intn(len(enabledIdx))]
-		return keys[selectedIdx], selectedIdx, nil
+		return enabledIdx[rand.Intn(len(enabledIdx))], nil
 	case constant.MultiKeyModePolling:
 		// Use channel-specific lock to ensure thread-safe polling
 
 		channelInfo, err := CacheGetChannelInfo(channel.Id)
 		if err != nil {
-			return "", 0, types.NewError(err, types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
+			return 0, types.NewError(err, types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
 		}
 		//println("before polling index:", channel.ChannelInfo.MultiKeyPollingIndex)
 		defer func() {
@@ -168,22 +208,24 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		}()
 		// Start from the saved polling index and look for the next enabled key
 		start := channelInfo.MultiKeyPollingIndex
-		if start < 0 || start >= len(keys) {
+		if start < 0 || start >= keyCount {
 			start = 0
 		}
-		for i := 0; i < len(keys); i++ {
-			idx := (start + i) % len(keys)
-			if getStatus(idx) == common.ChannelStatusEnabled {
+		for i := 0; i < keyCount; i++ {
+			idx := (start + i) % keyCount
+			if availableIdx[idx] {
 				// update polling index for next call (point to the next position)
-				channel.ChannelInfo.MultiKeyPollingIndex = (idx + 1) % len(keys)
-				return keys[idx], idx, nil
+				channel.ChannelInfo.MultiKeyPollingIndex = (idx + 1) % keyCount
+				return idx, nil
 			}
 		}
 		// Fallback – should not happen, but return first enabled key
-		return keys[enabledIdx[0]], enabledIdx[0], nil
+		return enabledIdx[0], nil
+	case constant.MultiKeyModeWeighted:
+		return selectWeightedKey(&channel.ChannelInfo, enabledIdx), nil
 	default:
 		// Unknown mode, default to first enabled key (or original key string)
-		return keys[enabledIdx[0]], enabledIdx[0], nil
+		return enabledIdx[0], nil
 	}
 }
 
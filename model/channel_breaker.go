@@ -0,0 +1,410 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/go-redis/redis/v8"
+)
+
+// 渠道与多 Key 熔断器：closed 正常放行；open 冷却期内拒绝；
+// half-open 放行有限的真实请求作为探测，全部成功后恢复，任一失败重新熔断。
+// 启用 Redis 时状态保存在 Redis 中，集群内各节点共享
+const (
+	BreakerStateClosed   = "closed"
+	BreakerStateOpen     = "open"
+	BreakerStateHalfOpen = "half_open"
+)
+
+const breakerRedisTTL = 24 * time.Hour
+
+type ChannelBreakerState struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	OpenedAt            int64  `json:"opened_at"`
+	HalfOpenAt          int64  `json:"half_open_at"`
+	Probes              int    `json:"probes"`
+	ProbeSuccesses      int    `json:"probe_successes"`
+	WindowStart         int64  `json:"window_start"`
+	WindowRequests      int    `json:"window_requests"`
+	WindowFailures      int    `json:"window_failures"`
+}
+
+// breakerLocalCacheTTL 启用 Redis 时本地缓存熔断状态的时长
+const breakerLocalCacheTTL = 2 * time.Second
+
+type cachedBreakerState struct {
+	state    ChannelBreakerState
+	expireAt time.Time
+}
+
+var (
+	breakerStates     = make(map[string]*ChannelBreakerState)
+	breakerStatesLock sync.Mutex
+
+	breakerLocalCache     = make(map[string]cachedBreakerState)
+	breakerLocalCacheLock sync.RWMutex
+)
+
+func channelBreakerKey(channelId int) string {
+	return fmt.Sprintf("breaker:channel:%d", channelId)
+}
+
+func channelKeyBreakerKey(channelId int, keyIndex int) string {
+	return fmt.Sprintf("breaker:channel:%d:key:%d", channelId, keyIndex)
+}
+
+func (s ChannelBreakerState) isClosed() bool {
+	return s.State == "" || s.State == BreakerStateClosed
+}
+
+// refresh 冷却结束转为半开；半开探测长时间没有结果时重新放出探测名额
+func (s *ChannelBreakerState) refresh(now int64, setting *operation_setting.CircuitBreakerSetting) bool {
+	switch s.State {
+	case BreakerStateOpen:
+		if now-s.OpenedAt >= int64(setting.CooldownSeconds) {
+			*s = ChannelBreakerState{State: BreakerStateHalfOpen, OpenedAt: s.OpenedAt, HalfOpenAt: now}
+			return true
+		}
+	case BreakerStateHalfOpen:
+		if s.Probes >= setting.HalfOpenProbes && now-s.HalfOpenAt >= int64(setting.CooldownSeconds) {
+			s.HalfOpenAt = now
+			s.Probes = s.ProbeSuccesses
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ChannelBreakerState) allows(setting *operation_setting.CircuitBreakerSetting) bool {
+	switch s.State {
+	case BreakerStateOpen:
+		return false
+	case BreakerStateHalfOpen:
+		return s.Probes < setting.HalfOpenProbes
+	default:
+		return true
+	}
+}
+
+func (s *ChannelBreakerState) open(now int64) {
+	*s = ChannelBreakerState{State: BreakerStateOpen, OpenedAt: now}
+}
+
+// record 记录一次请求结果，返回状态是否发生了切换
+func (s *ChannelBreakerState) record(success bool, now int64, setting *operation_setting.CircuitBreakerSetting) bool {
+	switch s.State {
+	case BreakerStateOpen:
+		// 熔断前已发出的请求，结果不影响状态
+		return false
+	case BreakerStateHalfOpen:
+		if !success {
+			s.open(now)
+			return true
+		}
+		s.ProbeSuccesses++
+		if s.ProbeSuccesses >= setting.HalfOpenProbes {
+			*s = ChannelBreakerState{State: BreakerStateClosed}
+			return true
+		}
+		return false
+	}
+	if now-s.WindowStart >= int64(setting.RatioWindowSeconds) {
+		s.WindowStart = now
+		s.WindowRequests = 0
+		s.WindowFailures = 0
+	}
+	s.WindowRequests++
+	if success {
+		s.ConsecutiveFailures = 0
+		return false
+	}
+	s.ConsecutiveFailures++
+	s.WindowFailures++
+	if setting.ConsecutiveFailures > 0 && s.ConsecutiveFailures >= setting.ConsecutiveFailures {
+		s.open(now)
+		return true
+	}
+	if setting.FailureRatio > 0 && s.WindowRequests >= setting.RatioMinRequests &&
+		float64(s.WindowFailures)/float64(s.WindowRequests) >= setting.FailureRatio {
+		s.open(now)
+		return true
+	}
+	return false
+}
+
+func getBreakerState(key string) ChannelBreakerState {
+	if common.RedisEnabled {
+		var state ChannelBreakerState
+		data, err := common.RDB.Get(context.Background(), key).Bytes()
+		if err == nil {
+			_ = common.Unmarshal(data, &state)
+		}
+		return state
+	}
+	breakerStatesLock.Lock()
+	defer breakerStatesLock.Unlock()
+	if state, ok := breakerStates[key]; ok {
+		return *state
+	}
+	return ChannelBreakerState{}
+}
+
+// getBreakerStateCached 选渠道时读取状态，启用 Redis 时在本地缓存一小段时间，避免每个候选渠道都读一次 Redis。
+// 其他节点触发的状态切换最多延迟 breakerLocalCacheTTL 生效，半开探测名额的占用始终在 Redis 中原子完成
+func getBreakerStateCached(key string) ChannelBreakerState {
+	if !common.RedisEnabled {
+		return getBreakerState(key)
+	}
+	now := time.Now()
+	breakerLocalCacheLock.RLock()
+	cached, ok := breakerLocalCache[key]
+	breakerLocalCacheLock.RUnlock()
+	if ok && now.Before(cached.expireAt) {
+		return cached.state
+	}
+	state := getBreakerState(key)
+	setBreakerLocalCache(key, state)
+	return state
+}
+
+func setBreakerLocalCache(key string, state ChannelBreakerState) {
+	breakerLocalCacheLock.Lock()
+	defer breakerLocalCacheLock.Unlock()
+	breakerLocalCache[key] = cachedBreakerState{state: state, expireAt: time.Now().Add(breakerLocalCacheTTL)}
+}
+
+// updateBreakerState 原子地修改状态，fn 返回 false 时不写回。Redis 事务冲突重试时 fn 会被再次调用
+func updateBreakerState(key string, fn func(state *ChannelBreakerState) bool) error {
+	if !common.RedisEnabled {
+		breakerStatesLock.Lock()
+		defer breakerStatesLock.Unlock()
+		state, ok := breakerStates[key]
+		if !ok {
+			state = &ChannelBreakerState{}
+		}
+		if fn(state) {
+			breakerStates[key] = state
+		}
+		return nil
+	}
+	ctx := context.Background()
+	var latest ChannelBreakerState
+	txf := func(tx *redis.Tx) error {
+		var state ChannelBreakerState
+		data, err := tx.Get(ctx, key).Bytes()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if err == nil {
+			_ = common.Unmarshal(data, &state)
+		}
+		if !fn(&state) {
+			latest = state
+			return nil
+		}
+		newData, err := common.Marshal(state)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, newData, breakerRedisTTL)
+			return nil
+		})
+		latest = state
+		return err
+	}
+	var err error
+	for i := 0; i < 5; i++ {
+		err = common.RDB.Watch(ctx, txf, key)
+		if err == nil {
+			setBreakerLocalCache(key, latest)
+			return nil
+		}
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return err
+}
+
+// acquire 判断是否放行，半开状态下占用一个探测名额，名额用完时拒绝；changed 表示状态需要写回
+func (s *ChannelBreakerState) acquire(now int64, setting *operation_setting.CircuitBreakerSetting) (allowed bool, changed bool) {
+	changed = s.refresh(now, setting)
+	switch {
+	case s.isClosed():
+		return true, changed
+	case s.State == BreakerStateHalfOpen && s.Probes < setting.HalfOpenProbes:
+		s.Probes++
+		return true, true
+	}
+	return false, changed
+}
+
+// tryAcquireBreaker 原子地执行 acquire，启用 Redis 时在事务中完成，避免并发请求超额占用探测名额
+func tryAcquireBreaker(key string) bool {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	now := time.Now().Unix()
+	state := getBreakerStateCached(key)
+	if state.isClosed() {
+		return true
+	}
+	if state.State == BreakerStateOpen && now-state.OpenedAt < int64(setting.CooldownSeconds) {
+		return false
+	}
+	allowed := false
+	err := updateBreakerState(key, func(state *ChannelBreakerState) bool {
+		var changed bool
+		allowed, changed = state.acquire(now, setting)
+		return changed
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to acquire breaker probe %s: %v", key, err))
+		return false
+	}
+	return allowed
+}
+
+func recordBreakerResult(key string, success bool) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	var newState string
+	err := updateBreakerState(key, func(state *ChannelBreakerState) bool {
+		now := time.Now().Unix()
+		changed := state.refresh(now, setting)
+		if state.record(success, now, setting) {
+			newState = state.State
+		}
+		// 未统计失败率且一直成功时无需写回
+		return changed || newState != "" || !state.isClosed() || state.ConsecutiveFailures > 0 || setting.FailureRatio > 0
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to record breaker result %s: %v", key, err))
+		return
+	}
+	if newState != "" {
+		common.SysLog(fmt.Sprintf("circuit breaker %s switched to %s", key, newState))
+	}
+}
+
+// breakerAvailable 只读地判断候选是否可用：冷却中或半开探测名额已用完时不可用。
+// 选择渠道或 key 时只过滤不占用名额，确定发出请求后再由 tryAcquireBreaker 占用
+func breakerAvailable(key string, now int64, setting *operation_setting.CircuitBreakerSetting) bool {
+	state := getBreakerStateCached(key)
+	state.refresh(now, setting)
+	return state.allows(setting)
+}
+
+// filterBreaker 过滤不可用的候选，全部不可用时返回原列表，避免无渠道可用
+func filterBreaker[T any](items []T, keyOf func(T) string) []T {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	now := time.Now().Unix()
+	available := make([]T, 0, len(items))
+	for _, item := range items {
+		if breakerAvailable(keyOf(item), now, setting) {
+			available = append(available, item)
+		}
+	}
+	if len(available) == 0 {
+		return items
+	}
+	return available
+}
+
+func filterBreakerChannels(channelIds []int) []int {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return channelIds
+	}
+	return filterBreaker(channelIds, channelBreakerKey)
+}
+
+func filterBreakerAbilities(abilities []Ability) []Ability {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return abilities
+	}
+	return filterBreaker(abilities, func(ability Ability) string {
+		return channelBreakerKey(ability.ChannelId)
+	})
+}
+
+// filterBreakerKeys 过滤多 Key 渠道中处于熔断中的 key 下标
+func filterBreakerKeys(channelId int, keyIndexes []int) []int {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return keyIndexes
+	}
+	return filterBreaker(keyIndexes, func(idx int) string {
+		return channelKeyBreakerKey(channelId, idx)
+	})
+}
+
+// AcquireChannelBreaker 确定向渠道发出请求时调用，渠道处于半开状态时占用一个探测名额。
+// 渠道仍在冷却中或名额已被其他请求占满时返回 false，调用方应改选其他渠道
+func AcquireChannelBreaker(channelId int) bool {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return true
+	}
+	return tryAcquireBreaker(channelBreakerKey(channelId))
+}
+
+func acquireKeyBreaker(channelId int, keyIndex int) bool {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return true
+	}
+	return tryAcquireBreaker(channelKeyBreakerKey(channelId, keyIndex))
+}
+
+// RecordChannelBreakerResult 记录渠道（及 key）一次请求的结果
+func RecordChannelBreakerResult(channelId int, isMultiKey bool, keyIndex int, success bool) {
+	if channelId == 0 || !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return
+	}
+	gopool.Go(func() {
+		recordBreakerResult(channelBreakerKey(channelId), success)
+		if isMultiKey {
+			recordBreakerResult(channelKeyBreakerKey(channelId, keyIndex), success)
+		}
+	})
+}
+
+// GetChannelBreakerStates 返回渠道及其各 key 的熔断状态
+func GetChannelBreakerStates(channel *Channel) (ChannelBreakerState, map[int]ChannelBreakerState) {
+	channelState := getBreakerState(channelBreakerKey(channel.Id))
+	keyStates := make(map[int]ChannelBreakerState)
+	if channel.ChannelInfo.IsMultiKey {
+		for i := range channel.GetKeys() {
+			if state := getBreakerState(channelKeyBreakerKey(channel.Id, i)); !state.isClosed() {
+				keyStates[i] = state
+			}
+		}
+	}
+	return channelState, keyStates
+}
+
+// ResetChannelBreaker 手动将渠道及其所有 key 的熔断状态恢复为 closed
+func ResetChannelBreaker(channel *Channel) error {
+	keys := []string{channelBreakerKey(channel.Id)}
+	if channel.ChannelInfo.IsMultiKey {
+		for i := range channel.GetKeys() {
+			keys = append(keys, channelKeyBreakerKey(channel.Id, i))
+		}
+	}
+	if common.RedisEnabled {
+		breakerLocalCacheLock.Lock()
+		for _, key := range keys {
+			delete(breakerLocalCache, key)
+		}
+		breakerLocalCacheLock.Unlock()
+		return common.RDB.Del(context.Background(), keys...).Err()
+	}
+	breakerStatesLock.Lock()
+	defer breakerStatesLock.Unlock()
+	for _, key := range keys {
+		delete(breakerStates, key)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package model
+
+import (
+	"one-api/setting/operation_setting"
+	"testing"
+)
+
+func testBreakerSetting() *operation_setting.CircuitBreakerSetting {
+	return &operation_setting.CircuitBreakerSetting{
+		Enabled:             true,
+		ConsecutiveFailures: 3,
+		FailureRatio:        0.5,
+		RatioWindowSeconds:  60,
+		RatioMinRequests:    4,
+		CooldownSeconds:     30,
+		HalfOpenProbes:      2,
+	}
+}
+
+func TestChannelBreakerStateRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   ChannelBreakerState
+		results []bool
+		want    string
+	}{
+		{
+			name:    "consecutive failures open the breaker",
+			results: []bool{false, false, false},
+			want:    BreakerStateOpen,
+		},
+		{
+			name:    "success resets consecutive failures",
+			results: []bool{false, false, true, true, true, true, true, false, false},
+			want:    BreakerStateClosed,
+		},
+		{
+			name:    "failure ratio opens the breaker once min requests reached",
+			results: []bool{true, false, true, false},
+			want:    BreakerStateOpen,
+		},
+		{
+			name:    "failure ratio below min requests keeps closed",
+			results: []bool{false, true, false},
+			want:    BreakerStateClosed,
+		},
+		{
+			name:    "half-open failure reopens",
+			state:   ChannelBreakerState{State: BreakerStateHalfOpen, HalfOpenAt: 100, Probes: 1},
+			results: []bool{false},
+			want:    BreakerStateOpen,
+		},
+		{
+			name:    "half-open closes after all probes succeed",
+			state:   ChannelBreakerState{State: BreakerStateHalfOpen, HalfOpenAt: 100, Probes: 2},
+			results: []bool{true, true},
+			want:    BreakerStateClosed,
+		},
+		{
+			name:    "half-open stays until all probes succeed",
+			state:   ChannelBreakerState{State: BreakerStateHalfOpen, HalfOpenAt: 100, Probes: 2},
+			results: []bool{true},
+			want:    BreakerStateHalfOpen,
+		},
+		{
+			name:    "results while open are ignored",
+			state:   ChannelBreakerState{State: BreakerStateOpen, OpenedAt: 100},
+			results: []bool{true, true, true},
+			want:    BreakerStateOpen,
+		},
+	}
+	setting := testBreakerSetting()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state
+			for _, success := range tt.results {
+				state.record(success, 110, setting)
+			}
+			got := state.State
+			if got == "" {
+				got = BreakerStateClosed
+			}
+			if got != tt.want {
+				t.Fatalf("state = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelBreakerStateAcquire(t *testing.T) {
+	tests := []struct {
+		name        string
+		state       ChannelBreakerState
+		now         int64
+		wantAllowed bool
+		wantState   string
+		wantProbes  int
+	}{
+		{
+			name:        "closed allows without probe",
+			state:       ChannelBreakerState{},
+			now:         100,
+			wantAllowed: true,
+			wantState:   "",
+		},
+		{
+			name:        "open within cooldown rejects",
+			state:       ChannelBreakerState{State: BreakerStateOpen, OpenedAt: 100},
+			now:         110,
+			wantAllowed: false,
+			wantState:   BreakerStateOpen,
+		},
+		{
+			name:        "open after cooldown becomes half-open and claims a probe",
+			state:       ChannelBreakerState{State: BreakerStateOpen, OpenedAt: 100},
+			now:         130,
+			wantAllowed: true,
+			wantState:   BreakerStateHalfOpen,
+			wantProbes:  1,
+		},
+		{
+			name:        "half-open with free slot claims a probe",
+			state:       ChannelBreakerState{State: BreakerStateHalfOpen, HalfOpenAt: 130, Probes: 1},
+			now:         135,
+			wantAllowed: true,
+			wantState:   BreakerStateHalfOpen,
+			wantProbes:  2,
+		},
+		{
+			name:        "half-open with all slots taken rejects",
+			state:       ChannelBreakerState{State: BreakerStateHalfOpen, HalfOpenAt: 130, Probes: 2},
+			now:         135,
+			wantAllowed: false,
+			wantState:   BreakerStateHalfOpen,
+			wantProbes:  2,
+		},
+		{
+			name:        "stale half-open probes are released after cooldown",
+			state:       ChannelBreakerState{State: BreakerStateHalfOpen, HalfOpenAt: 130, Probes: 2, ProbeSuccesses: 1},
+			now:         170,
+			wantAllowed: true,
+			wantState:   BreakerStateHalfOpen,
+			wantProbes:  2,
+		},
+	}
+	setting := testBreakerSetting()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state
+			allowed, _ := state.acquire(tt.now, setting)
+			if allowed != tt.wantAllowed {
+				t.Fatalf("allowed = %v, want %v", allowed, tt.wantAllowed)
+			}
+			if state.State != tt.wantState {
+				t.Fatalf("state = %q, want %q", state.State, tt.wantState)
+			}
+			if state.Probes != tt.wantProbes {
+				t.Fatalf("probes = %d, want %d", state.Probes, tt.wantProbes)
+			}
+		})
+	}
+}
+
+func TestFilterBreakerDoesNotClaimProbes(t *testing.T) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	saved := *setting
+	defer func() { *setting = saved }()
+	*setting = *testBreakerSetting()
+
+	breakerStatesLock.Lock()
+	breakerStates[channelBreakerKey(1)] = &ChannelBreakerState{State: BreakerStateOpen, OpenedAt: 0}
+	breakerStates[channelBreakerKey(2)] = &ChannelBreakerState{State: BreakerStateOpen, OpenedAt: 1 << 40}
+	breakerStatesLock.Unlock()
+	defer func() {
+		breakerStatesLock.Lock()
+		delete(breakerStates, channelBreakerKey(1))
+		delete(breakerStates, channelBreakerKey(2))
+		breakerStatesLock.Unlock()
+	}()
+
+	got := filterBreakerChannels([]int{1, 2, 3})
+	if len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Fatalf("filterBreakerChannels = %v, want [1 3]", got)
+	}
+	if state := getBreakerState(channelBreakerKey(1)); state.State != BreakerStateOpen || state.Probes != 0 {
+		t.Fatalf("filtering changed state to %+v", state)
+	}
+	if !AcquireChannelBreaker(1) || !AcquireChannelBreaker(1) || AcquireChannelBreaker(1) {
+		t.Fatal("expected exactly two probes to be claimed")
+	}
+	if got := filterBreakerChannels([]int{1, 3}); len(got) != 1 || got[0] != 3 {
+		t.Fatalf("filterBreakerChannels after probes claimed = %v, want [3]", got)
+	}
+	if got := filterBreakerChannels([]int{1, 2}); len(got) != 2 {
+		t.Fatalf("filterBreakerChannels with none available = %v, want original list", got)
+	}
+}
@@ -1,12 +1,16 @@
 package model
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
 	"one-api/common"
 	"one-api/constant"
+	"one-api/logger"
+	"one-api/metrics"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 	"sort"
 	"strings"
@@ -18,8 +22,12 @@ import (
 
 var group2model2channels map[string]map[string][]int // enabled channel
 var channelsIDM map[int]*Channel                     // all channels include disabled
+var fineTunedModelOwners map[string]int              // fine-tuned model -> owner user id
 var channelSyncLock sync.RWMutex
 
+// channelCacheLogger 渠道缓存查询与降级的诊断日志
+var channelCacheLogger = logger.Module("channel_cache")
+
 func InitChannelCache() {
 	if !common.MemoryCacheEnabled {
 		return
@@ -55,6 +63,21 @@ func InitChannelCache() {
 			}
 		}
 	}
+	// 微调模型只对所属分组开放
+	var ftModels []*FineTunedModel
+	DB.Find(&ftModels)
+	newFineTunedModelOwners := make(map[string]int, len(ftModels))
+	for _, ftModel := range ftModels {
+		newFineTunedModelOwners[ftModel.Model] = ftModel.UserId
+		channel, ok := newChannelId2channel[ftModel.ChannelId]
+		if !ok || channel.Status != common.ChannelStatusEnabled {
+			continue
+		}
+		if _, ok := newGroup2model2channels[ftModel.Group]; !ok {
+			newGroup2model2channels[ftModel.Group] = make(map[string][]int)
+		}
+		newGroup2model2channels[ftModel.Group][ftModel.Model] = append(newGroup2model2channels[ftModel.Group][ftModel.Model], channel.Id)
+	}
 
 	// sort by priority
 	for group, model2channels := range newGroup2model2channels {
@@ -83,6 +106,7 @@ func InitChannelCache() {
 		}
 	}
 	channelsIDM = newChannelId2channel
+	fineTunedModelOwners = newFineTunedModelOwners
 	channelSyncLock.Unlock()
 	common.SysLog("channels synced from database")
 }
@@ -107,7 +131,7 @@ func CacheGetRandomSatisfiedChannel(c *gin.Context, group string, model string,
 			if common.DebugEnabled {
 				println("autoGroup:", autoGroup)
 			}
-			channel, _ = getRandomSatisfiedChannel(autoGroup, model, retry)
+			channel, _ = getRandomSatisfiedChannel(c, autoGroup, model, retry)
 			if channel == nil {
 				continue
 			} else {
@@ -120,48 +144,60 @@ func CacheGetRandomSatisfiedChannel(c *gin.Context, group string, model string,
 			}
 		}
 	} else {
-		channel, err = getRandomSatisfiedChannel(group, model, retry)
+		channel, err = getRandomSatisfiedChannel(c, group, model, retry)
 		if err != nil {
 			return nil, group, err
 		}
 
 		// 🔧 智能重试机制: 如果查询不到渠道,尝试再次查询(处理缓存过期/同步延迟问题)
 		if channel == nil {
-			common.SysLog(fmt.Sprintf("[CacheRetry] 首次查询未找到渠道 group=%s, model=%s, 准备重试", group, model))
+			channelCacheLogger.Info(c, fmt.Sprintf("[CacheRetry] 首次查询未找到渠道 group=%s, model=%s, 准备重试", group, model))
 
 			// 如果启用了内存缓存,强制刷新后重试
 			if common.MemoryCacheEnabled {
-				common.SysLog("[CacheRetry] 内存缓存已启用,刷新缓存后重试")
+				channelCacheLogger.Info(c, "[CacheRetry] 内存缓存已启用,刷新缓存后重试")
 				InitChannelCache()
 			} else {
-				common.SysLog("[CacheRetry] 内存缓存未启用,直接重试数据库查询")
+				channelCacheLogger.Info(c, "[CacheRetry] 内存缓存未启用,直接重试数据库查询")
 			}
 
 			// 重试查询
-			channel, err = getRandomSatisfiedChannel(group, model, retry)
+			channel, err = getRandomSatisfiedChannel(c, group, model, retry)
 			if err != nil {
 				return nil, group, err
 			}
 
 			if channel != nil {
-				common.SysLog(fmt.Sprintf("[CacheRetry] 重试成功! 找到渠道 channel_id=%d", channel.Id))
+				channelCacheLogger.Info(c, fmt.Sprintf("[CacheRetry] 重试成功! 找到渠道 channel_id=%d", channel.Id))
 			} else {
-				common.SysLog(fmt.Sprintf("[CacheRetry] 重试失败! 第二次查询仍未找到可用渠道"))
+				channelCacheLogger.Info(c, fmt.Sprintf("[CacheRetry] 重试失败! 第二次查询仍未找到可用渠道"))
 			}
 		}
 	}
 	return channel, selectGroup, nil
 }
 
-func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+// breakerDispatchAttempts 选中的半开渠道探测名额已被占满时，重新选择渠道的最大次数
+const breakerDispatchAttempts = 3
+
+// CacheGetDispatchChannel 选择即将发出请求的渠道：在优先级与权重选择之后才占用半开渠道的探测名额，
+// 名额已被其他请求占满时重新选择，多次失败后仍返回最后选中的渠道。只查询渠道而不一定发出请求时使用 CacheGetRandomSatisfiedChannel
+func CacheGetDispatchChannel(c *gin.Context, group string, model string, retry int) (*Channel, string, error) {
+	for i := 1; ; i++ {
+		channel, selectGroup, err := CacheGetRandomSatisfiedChannel(c, group, model, retry)
+		if err != nil || channel == nil || AcquireChannelBreaker(channel.Id) || i >= breakerDispatchAttempts {
+			return channel, selectGroup, err
+		}
+	}
+}
+
+func getRandomSatisfiedChannel(ctx context.Context, group string, model string, retry int) (*Channel, error) {
 	// if memory cache is disabled, get channel directly from database
 	if !common.MemoryCacheEnabled {
 		return GetRandomSatisfiedChannel(group, model, retry)
 	}
 
 	channelSyncLock.RLock()
-	defer channelSyncLock.RUnlock()
-
 	// First, try to find channels with the exact model name.
 	channels := group2model2channels[group][model]
 
@@ -170,30 +206,35 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		normalizedModel := ratio_setting.FormatMatchingModelName(model)
 		channels = group2model2channels[group][normalizedModel]
 	}
+	channels = append([]int(nil), channels...)
+	channelSyncLock.RUnlock()
 
+	metrics.RecordCache(metrics.CacheChannel, len(channels) > 0)
 	// 🔧 降级查询策略: 如果缓存中仍然找不到,尝试直接查询数据库(避免缓存过期问题)
 	if len(channels) == 0 {
-		common.SysLog(fmt.Sprintf("[CacheFallback] 缓存中未找到渠道, 降级到数据库查询: group=%s, model=%s", group, model))
+		channelCacheLogger.Info(ctx, fmt.Sprintf("[CacheFallback] 缓存中未找到渠道, 降级到数据库查询: group=%s, model=%s", group, model))
 
-		// 释放读锁后再查询数据库,避免死锁
-		channelSyncLock.RUnlock()
 		dbChannel, err := GetRandomSatisfiedChannel(group, model, retry)
-		channelSyncLock.RLock()
-
 		if err != nil {
-			common.SysLog(fmt.Sprintf("[CacheFallback] 数据库查询失败: %v", err))
+			channelCacheLogger.Info(ctx, fmt.Sprintf("[CacheFallback] 数据库查询失败: %v", err))
 			return nil, err
 		}
 
 		if dbChannel != nil {
-			common.SysLog(fmt.Sprintf("[CacheFallback] 数据库查询成功! 找到渠道 channel_id=%d", dbChannel.Id))
+			channelCacheLogger.Info(ctx, fmt.Sprintf("[CacheFallback] 数据库查询成功! 找到渠道 channel_id=%d", dbChannel.Id))
 			return dbChannel, nil
 		}
 
-		common.SysLog(fmt.Sprintf("[CacheFallback] 数据库查询也未找到可用渠道"))
+		channelCacheLogger.Info(ctx, fmt.Sprintf("[CacheFallback] 数据库查询也未找到可用渠道"))
 		return nil, nil
 	}
 
+	// 熔断状态可能需要读取 Redis，在释放读锁后过滤，避免 InitChannelCache 等待网络 I/O
+	channels = filterBreakerChannels(channels)
+
+	channelSyncLock.RLock()
+	defer channelSyncLock.RUnlock()
+
 	if len(channels) == 1 {
 		if channel, ok := channelsIDM[channels[0]]; ok {
 			return channel, nil
@@ -234,6 +275,9 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 
 	// 平滑系数
 	smoothingFactor := 10
+	if operation_setting.IsAdaptiveChannelSelection(group) {
+		return pickAdaptiveChannel(targetChannels, model, smoothingFactor)
+	}
 	// Calculate the total weight of all channels up to endIdx
 	totalWeight := 0
 	for _, channel := range targetChannels {
@@ -261,6 +305,7 @@ func CacheGetChannel(id int) (*Channel, error) {
 	defer channelSyncLock.RUnlock()
 
 	c, ok := channelsIDM[id]
+	metrics.RecordCache(metrics.CacheChannel, ok)
 	if !ok {
 		return nil, fmt.Errorf("渠道# %d，已不存在", id)
 	}
@@ -326,3 +371,23 @@ func CacheUpdateChannel(channel *Channel) {
 	channelsIDM[channel.Id] = channel
 	println("after :", channelsIDM[channel.Id].ChannelInfo.MultiKeyPollingIndex)
 }
+
+// pickAdaptiveChannel 按运行时统计调整后的权重随机选择渠道
+func pickAdaptiveChannel(targetChannels []*Channel, model string, smoothingFactor int) (*Channel, error) {
+	weights := adaptiveChannelWeights(targetChannels, model, smoothingFactor)
+	totalWeight := 0.0
+	for _, weight := range weights {
+		totalWeight += weight
+	}
+	randomWeight := rand.Float64() * totalWeight
+	for i, channel := range targetChannels {
+		randomWeight -= weights[i]
+		if randomWeight < 0 {
+			return channel, nil
+		}
+	}
+	if len(targetChannels) > 0 {
+		return targetChannels[len(targetChannels)-1], nil
+	}
+	return nil, errors.New("channel not found")
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// ChannelCostPrice 渠道上游成本价，token 价格单位为美元 / 百万 token
+type ChannelCostPrice struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read"`
+	CacheWrite float64 `json:"cache_write"`
+	PerCall    float64 `json:"per_call"` // 每次调用的固定成本（美元），用于按次计费的任务平台
+}
+
+// ChannelCostUsage 计算成本所需的用量，PromptTokens 包含缓存 token，与计费逻辑一致
+type ChannelCostUsage struct {
+	PromptTokens        int
+	CompletionTokens    int
+	CacheTokens         int
+	CacheCreationTokens int
+}
+
+// GetCostPrices 解析渠道成本价配置：模型名 -> 成本价，"*" 为默认成本价
+func (channel *Channel) GetCostPrices() map[string]ChannelCostPrice {
+	prices := make(map[string]ChannelCostPrice)
+	if channel.CostPrices == nil || strings.TrimSpace(*channel.CostPrices) == "" {
+		return prices
+	}
+	if err := common.Unmarshal([]byte(*channel.CostPrices), &prices); err != nil {
+		common.SysError(fmt.Sprintf("failed to unmarshal cost prices: channel_id=%d, error=%v", channel.Id, err))
+	}
+	return prices
+}
+
+func (channel *Channel) ValidateCostPrices() error {
+	if channel.CostPrices == nil || strings.TrimSpace(*channel.CostPrices) == "" {
+		return nil
+	}
+	prices := make(map[string]ChannelCostPrice)
+	if err := common.Unmarshal([]byte(*channel.CostPrices), &prices); err != nil {
+		return err
+	}
+	for modelName, price := range prices {
+		if price.Input < 0 || price.Output < 0 || price.CacheRead < 0 || price.CacheWrite < 0 || price.PerCall < 0 {
+			return fmt.Errorf("模型 %s 的成本价不能为负数", modelName)
+		}
+	}
+	return nil
+}
+
+// GetCostPrice 按顺序查找模型的成本价，均未单独配置时使用 "*"
+func (channel *Channel) GetCostPrice(modelNames ...string) (ChannelCostPrice, bool) {
+	prices := channel.GetCostPrices()
+	for _, modelName := range modelNames {
+		if price, ok := prices[modelName]; ok && modelName != "" {
+			return price, true
+		}
+	}
+	price, ok := prices["*"]
+	return price, ok
+}
+
+// Quota 将成本换算为额度单位，便于与实际扣费直接比较
+func (price ChannelCostPrice) Quota(usage ChannelCostUsage) int {
+	million := decimal.NewFromInt(1000000)
+	baseTokens := usage.PromptTokens - usage.CacheTokens - usage.CacheCreationTokens
+	if baseTokens < 0 {
+		baseTokens = 0
+	}
+	cost := decimal.NewFromFloat(price.PerCall).
+		Add(decimal.NewFromInt(int64(baseTokens)).Mul(decimal.NewFromFloat(price.Input)).Div(million)).
+		Add(decimal.NewFromInt(int64(usage.CompletionTokens)).Mul(decimal.NewFromFloat(price.Output)).Div(million)).
+		Add(decimal.NewFromInt(int64(usage.CacheTokens)).Mul(decimal.NewFromFloat(price.CacheRead)).Div(million)).
+		Add(decimal.NewFromInt(int64(usage.CacheCreationTokens)).Mul(decimal.NewFromFloat(price.CacheWrite)).Div(million))
+	return int(cost.Mul(decimal.NewFromFloat(common.QuotaPerUnit)).Round(0).IntPart())
+}
+
+// CalculateUpstreamCost 计算一次请求在渠道上的上游成本（额度单位），渠道未配置成本价时返回 0 与 false。
+// modelNames 按优先级排列，通常为上游模型名与请求模型名
+func CalculateUpstreamCost(channelId int, usage ChannelCostUsage, modelNames ...string) (int, bool) {
+	if channelId == 0 {
+		return 0, false
+	}
+	channel, err := CacheGetChannel(channelId)
+	if err != nil || channel == nil {
+		return 0, false
+	}
+	price, ok := channel.GetCostPrice(modelNames...)
+	if !ok {
+		return 0, false
+	}
+	return price.Quota(usage), true
+}
+
+// getOtherInt 读取日志 other 字段中的整数值
+func getOtherInt(other map[string]interface{}, key string) int {
+	switch v := other[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
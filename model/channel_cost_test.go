@@ -0,0 +1,83 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestChannelCostPriceQuota(t *testing.T) {
+	tests := []struct {
+		name  string
+		price ChannelCostPrice
+		usage ChannelCostUsage
+		want  int
+	}{
+		{
+			name:  "input only",
+			price: ChannelCostPrice{Input: 2},
+			usage: ChannelCostUsage{PromptTokens: 1_000_000},
+			want:  1_000_000,
+		},
+		{
+			name:  "input and output",
+			price: ChannelCostPrice{Input: 3, Output: 15},
+			usage: ChannelCostUsage{PromptTokens: 1000, CompletionTokens: 500},
+			want:  5250,
+		},
+		{
+			name:  "cache tokens are priced separately from prompt",
+			price: ChannelCostPrice{Input: 3, CacheRead: 0.3, CacheWrite: 3.75},
+			usage: ChannelCostUsage{PromptTokens: 1000, CacheTokens: 400, CacheCreationTokens: 100},
+			want:  998,
+		},
+		{
+			name:  "cache tokens larger than prompt do not go negative",
+			price: ChannelCostPrice{Input: 3, CacheRead: 1},
+			usage: ChannelCostUsage{PromptTokens: 100, CacheTokens: 150},
+			want:  75,
+		},
+		{
+			name:  "per call cost",
+			price: ChannelCostPrice{PerCall: 0.05},
+			usage: ChannelCostUsage{},
+			want:  25000,
+		},
+		{
+			name:  "zero price",
+			price: ChannelCostPrice{},
+			usage: ChannelCostUsage{PromptTokens: 1000, CompletionTokens: 1000},
+			want:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.price.Quota(tt.usage); got != tt.want {
+				t.Fatalf("Quota = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelGetCostPrice(t *testing.T) {
+	prices := `{"gpt-4o":{"input":2.5},"*":{"input":1}}`
+	channel := &Channel{CostPrices: &prices}
+	tests := []struct {
+		name       string
+		channel    *Channel
+		modelNames []string
+		wantInput  float64
+		wantOk     bool
+	}{
+		{name: "exact model", channel: channel, modelNames: []string{"gpt-4o"}, wantInput: 2.5, wantOk: true},
+		{name: "first configured name wins", channel: channel, modelNames: []string{"upstream-name", "gpt-4o"}, wantInput: 2.5, wantOk: true},
+		{name: "falls back to default", channel: channel, modelNames: []string{"", "other"}, wantInput: 1, wantOk: true},
+		{name: "no cost prices", channel: &Channel{}, modelNames: []string{"gpt-4o"}, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, ok := tt.channel.GetCostPrice(tt.modelNames...)
+			if ok != tt.wantOk || price.Input != tt.wantInput {
+				t.Fatalf("GetCostPrice = %+v, %v, want input %v, %v", price, ok, tt.wantInput, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+package model
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 多 Key 渠道的 key 用量统计：按分钟计数请求数与 token 数，并记录累计用量与最近使用时间。
+// RPM/TPM 使用当前分钟与上一分钟按时间加权估算；启用 Redis 时各节点共享计数
+const keyUsageRedisTTL = 2 * time.Minute
+
+//go:embed lua/claim_channel_key.lua
+var claimChannelKeyScriptSource string
+
+var claimChannelKeyScript = redis.NewScript(claimChannelKeyScriptSource)
+
+// multiKeyUsageThreshold usage 模式下用量达到限额的该比例即视为接近上限
+const multiKeyUsageThreshold = 0.9
+
+type ChannelKeyUsage struct {
+	RPM           int   `json:"rpm"`
+	TPM           int   `json:"tpm"`
+	TotalRequests int64 `json:"total_requests"`
+	TotalTokens   int64 `json:"total_tokens"`
+	LastUsedTime  int64 `json:"last_used_time"` // 毫秒时间戳
+}
+
+type keyUsageCount struct {
+	requests int64
+	tokens   int64
+}
+
+type channelKeyUsageCounter struct {
+	mu       sync.Mutex
+	minute   int64
+	current  map[int]*keyUsageCount
+	previous map[int]*keyUsageCount
+	totals   map[int]*ChannelKeyUsage
+}
+
+var channelKeyUsageCounters sync.Map // channelId -> *channelKeyUsageCounter
+
+func getChannelKeyUsageCounter(channelId int) *channelKeyUsageCounter {
+	if v, ok := channelKeyUsageCounters.Load(channelId); ok {
+		return v.(*channelKeyUsageCounter)
+	}
+	v, _ := channelKeyUsageCounters.LoadOrStore(channelId, &channelKeyUsageCounter{
+		current:  make(map[int]*keyUsageCount),
+		previous: make(map[int]*keyUsageCount),
+		totals:   make(map[int]*ChannelKeyUsage),
+	})
+	return v.(*channelKeyUsageCounter)
+}
+
+// rotate 切换到当前分钟，调用方需持有锁
+func (u *channelKeyUsageCounter) rotate(minute int64) {
+	if u.minute == minute {
+		return
+	}
+	if u.minute == minute-1 {
+		u.previous = u.current
+	} else {
+		u.previous = make(map[int]*keyUsageCount)
+	}
+	u.current = make(map[int]*keyUsageCount)
+	u.minute = minute
+}
+
+func (u *channelKeyUsageCounter) add(keyIndex int, requests int64, tokens int64, now time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.addLocked(keyIndex, requests, tokens, now)
+}
+
+// addLocked 累加 key 的用量，调用方需持有锁
+func (u *channelKeyUsageCounter) addLocked(keyIndex int, requests int64, tokens int64, now time.Time) {
+	u.rotate(now.Unix() / 60)
+	count, ok := u.current[keyIndex]
+	if !ok {
+		count = &keyUsageCount{}
+		u.current[keyIndex] = count
+	}
+	count.requests += requests
+	count.tokens += tokens
+	total, ok := u.totals[keyIndex]
+	if !ok {
+		total = &ChannelKeyUsage{}
+		u.totals[keyIndex] = total
+	}
+	total.TotalRequests += requests
+	total.TotalTokens += tokens
+	if requests > 0 {
+		total.LastUsedTime = now.UnixMilli()
+	}
+}
+
+// usagesLocked 返回各 key 的用量快照，调用方需持有锁
+func (u *channelKeyUsageCounter) usagesLocked(now time.Time) map[int]ChannelKeyUsage {
+	u.rotate(now.Unix() / 60)
+	result := make(map[int]ChannelKeyUsage, len(u.totals))
+	for idx, total := range u.totals {
+		usage := *total
+		var cur, prev keyUsageCount
+		if c, ok := u.current[idx]; ok {
+			cur = *c
+		}
+		if p, ok := u.previous[idx]; ok {
+			prev = *p
+		}
+		usage.RPM = estimateRate(cur.requests, prev.requests, now)
+		usage.TPM = estimateRate(cur.tokens, prev.tokens, now)
+		result[idx] = usage
+	}
+	return result
+}
+
+// claim 在同一把锁内按用量选出 key 并计入一次请求
+func (u *channelKeyUsageCounter) claim(enabledIdx []int, now time.Time, pick func(map[int]ChannelKeyUsage, []int) int) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	selected := pick(u.usagesLocked(now), enabledIdx)
+	u.addLocked(selected, 1, 0, now)
+	return selected
+}
+
+func keyUsageMinuteKey(channelId int, minute int64) string {
+	return fmt.Sprintf("key_usage:%d:%d", channelId, minute)
+}
+
+func keyUsageTotalKey(channelId int) string {
+	return fmt.Sprintf("key_usage:%d:total", channelId)
+}
+
+func addChannelKeyUsage(channelId int, keyIndex int, requests int64, tokens int64) {
+	now := time.Now()
+	if !common.RedisEnabled {
+		getChannelKeyUsageCounter(channelId).add(keyIndex, requests, tokens, now)
+		return
+	}
+	ctx := context.Background()
+	minuteKey := keyUsageMinuteKey(channelId, now.Unix()/60)
+	totalKey := keyUsageTotalKey(channelId)
+	pipe := common.RDB.TxPipeline()
+	if requests != 0 {
+		pipe.HIncrBy(ctx, minuteKey, "r:"+strconv.Itoa(keyIndex), requests)
+		pipe.HIncrBy(ctx, totalKey, "r:"+strconv.Itoa(keyIndex), requests)
+	}
+	if requests > 0 {
+		pipe.HSet(ctx, totalKey, "l:"+strconv.Itoa(keyIndex), now.UnixMilli())
+	}
+	if tokens > 0 {
+		pipe.HIncrBy(ctx, minuteKey, "t:"+strconv.Itoa(keyIndex), tokens)
+		pipe.HIncrBy(ctx, totalKey, "t:"+strconv.Itoa(keyIndex), tokens)
+	}
+	pipe.Expire(ctx, minuteKey, keyUsageRedisTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		common.SysError(fmt.Sprintf("failed to record key usage for channel %d: %v", channelId, err))
+	}
+}
+
+// recordChannelKeyRequest 记录多 Key 渠道的 key 被选中发起了一次请求
+func recordChannelKeyRequest(channelId int, keyIndex int) {
+	addChannelKeyUsage(channelId, keyIndex, 1, 0)
+}
+
+// revertChannelKeyRequest 撤销选中 key 时计入的请求数，用于 key 最终未被使用的情况
+func revertChannelKeyRequest(channelId int, keyIndex int) {
+	addChannelKeyUsage(channelId, keyIndex, -1, 0)
+}
+
+// RecordChannelKeyTokens 请求结束后记录 key 消耗的 token 数
+func RecordChannelKeyTokens(channelId int, keyIndex int, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	addChannelKeyUsage(channelId, keyIndex, 0, int64(tokens))
+}
+
+// estimateRate 用上一分钟计数按剩余比例补足当前分钟，得到近似滑动窗口的每分钟用量
+func estimateRate(current int64, previous int64, now time.Time) int {
+	elapsed := float64(now.Unix()%60) / 60
+	return int(float64(current) + float64(previous)*(1-elapsed))
+}
+
+// GetChannelKeyUsage 返回渠道各 key 的用量，未使用过的 key 不在结果中
+func GetChannelKeyUsage(channelId int) map[int]ChannelKeyUsage {
+	now := time.Now()
+	minute := now.Unix() / 60
+	if !common.RedisEnabled {
+		u := getChannelKeyUsageCounter(channelId)
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		return u.usagesLocked(now)
+	}
+	result := make(map[int]ChannelKeyUsage)
+	ctx := context.Background()
+	pipe := common.RDB.Pipeline()
+	curCmd := pipe.HGetAll(ctx, keyUsageMinuteKey(channelId, minute))
+	prevCmd := pipe.HGetAll(ctx, keyUsageMinuteKey(channelId, minute-1))
+	totalCmd := pipe.HGetAll(ctx, keyUsageTotalKey(channelId))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		common.SysError(fmt.Sprintf("failed to get key usage for channel %d: %v", channelId, err))
+		return result
+	}
+	cur := parseKeyUsageFields(curCmd.Val())
+	prev := parseKeyUsageFields(prevCmd.Val())
+	totals := parseKeyUsageFields(totalCmd.Val())
+	for idx, fields := range totals {
+		result[idx] = ChannelKeyUsage{
+			RPM:           estimateRate(cur[idx]["r"], prev[idx]["r"], now),
+			TPM:           estimateRate(cur[idx]["t"], prev[idx]["t"], now),
+			TotalRequests: fields["r"],
+			TotalTokens:   fields["t"],
+			LastUsedTime:  fields["l"],
+		}
+	}
+	return result
+}
+
+// parseKeyUsageFields 将 "r:0" 形式的字段解析为 key index -> 字段 -> 值
+func parseKeyUsageFields(values map[string]string) map[int]map[string]int64 {
+	result := make(map[int]map[string]int64)
+	for field, value := range values {
+		name, idxStr, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		idx, err := strconv.Atoi(idxStr)
+		if err != nil {
+			continue
+		}
+		v, _ := strconv.ParseInt(value, 10, 64)
+		if result[idx] == nil {
+			result[idx] = make(map[string]int64)
+		}
+		result[idx][name] = v
+	}
+	return result
+}
+
+// KeyWeight 返回 weighted 模式下 key 的权重，缺省为 1
+func (info *ChannelInfo) KeyWeight(keyIndex int) int {
+	if weight, ok := info.MultiKeyWeights[keyIndex]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// KeyRPMLimit 返回 key 的 RPM 上限，0 表示不限制
+func (info *ChannelInfo) KeyRPMLimit(keyIndex int) int {
+	if limit, ok := info.MultiKeyRPMLimits[keyIndex]; ok {
+		return limit
+	}
+	return info.MultiKeyRPMLimit
+}
+
+// KeyTPMLimit 返回 key 的 TPM 上限，0 表示不限制
+func (info *ChannelInfo) KeyTPMLimit(keyIndex int) int {
+	if limit, ok := info.MultiKeyTPMLimits[keyIndex]; ok {
+		return limit
+	}
+	return info.MultiKeyTPMLimit
+}
+
+// keyUtilization 返回 key 的 RPM/TPM 用量占上限的最大比例，均未设置上限时返回 -1
+func (info *ChannelInfo) keyUtilization(keyIndex int, usage ChannelKeyUsage) float64 {
+	utilization := -1.0
+	if limit := info.KeyRPMLimit(keyIndex); limit > 0 {
+		utilization = max(utilization, float64(usage.RPM)/float64(limit))
+	}
+	if limit := info.KeyTPMLimit(keyIndex); limit > 0 {
+		utilization = max(utilization, float64(usage.TPM)/float64(limit))
+	}
+	return utilization
+}
+
+func selectWeightedKey(info *ChannelInfo, enabledIdx []int) int {
+	totalWeight := 0
+	for _, idx := range enabledIdx {
+		totalWeight += info.KeyWeight(idx)
+	}
+	randomWeight := common.GetRandomInt(totalWeight)
+	for _, idx := range enabledIdx {
+		randomWeight -= info.KeyWeight(idx)
+		if randomWeight < 0 {
+			return idx
+		}
+	}
+	return enabledIdx[0]
+}
+
+func pickLRUKey(usages map[int]ChannelKeyUsage, enabledIdx []int) int {
+	selected := enabledIdx[0]
+	for _, idx := range enabledIdx[1:] {
+		if usages[idx].LastUsedTime < usages[selected].LastUsedTime {
+			selected = idx
+		}
+	}
+	return selected
+}
+
+// pickUsageKey 跳过 RPM/TPM 接近上限的 key，在其余 key 中选择用量占比最低的；
+// 全部接近上限时仍选择占比最低的 key，交由上游限流与重试处理
+func (info *ChannelInfo) pickUsageKey(usages map[int]ChannelKeyUsage, enabledIdx []int) int {
+	selected := -1
+	selectedScore := 0.0
+	for _, idx := range enabledIdx {
+		usage := usages[idx]
+		score := info.keyUtilization(idx, usage)
+		if score < 0 {
+			// 未设置上限的 key 按 RPM 排序，且优先级低于有余量的限额 key
+			score = multiKeyUsageThreshold + float64(usage.RPM)
+		} else if score >= multiKeyUsageThreshold {
+			score = 1e9 + score
+		}
+		if selected < 0 || score < selectedScore {
+			selected = idx
+			selectedScore = score
+		}
+	}
+	return selected
+}
+
+// claimChannelKey 按 lru 或 usage 模式选出 key 并计入一次请求。选择与计数是一次原子操作，
+// 否则并发请求会读到相同的用量而选中同一个 key
+func claimChannelKey(channelId int, info *ChannelInfo, enabledIdx []int) int {
+	now := time.Now()
+	pick := pickLRUKey
+	if info.MultiKeyMode == constant.MultiKeyModeUsage {
+		pick = info.pickUsageKey
+	}
+	if !common.RedisEnabled {
+		return getChannelKeyUsageCounter(channelId).claim(enabledIdx, now, pick)
+	}
+	minute := now.Unix() / 60
+	keys := []string{
+		keyUsageMinuteKey(channelId, minute),
+		keyUsageMinuteKey(channelId, minute-1),
+		keyUsageTotalKey(channelId),
+	}
+	previousWeight := 1 - float64(now.Unix()%60)/60
+	args := []interface{}{string(info.MultiKeyMode), now.UnixMilli(), previousWeight, multiKeyUsageThreshold, keyUsageRedisTTL.Milliseconds()}
+	for _, idx := range enabledIdx {
+		args = append(args, idx, info.KeyRPMLimit(idx), info.KeyTPMLimit(idx))
+	}
+	selected, err := claimChannelKeyScript.Run(context.Background(), common.RDB, keys, args...).Int()
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to claim key for channel %d: %v", channelId, err))
+		selected = pick(GetChannelKeyUsage(channelId), enabledIdx)
+		recordChannelKeyRequest(channelId, selected)
+	}
+	return selected
+}
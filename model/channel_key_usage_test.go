@@ -0,0 +1,187 @@
+package model
+
+import (
+	"one-api/constant"
+	"testing"
+	"time"
+)
+
+func TestPickLRUKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		usages     map[int]ChannelKeyUsage
+		enabledIdx []int
+		want       int
+	}{
+		{
+			name:       "unused keys keep order",
+			usages:     map[int]ChannelKeyUsage{},
+			enabledIdx: []int{0, 1, 2},
+			want:       0,
+		},
+		{
+			name:       "unused key preferred over used",
+			usages:     map[int]ChannelKeyUsage{0: {LastUsedTime: 100}, 2: {LastUsedTime: 50}},
+			enabledIdx: []int{0, 1, 2},
+			want:       1,
+		},
+		{
+			name:       "least recently used wins",
+			usages:     map[int]ChannelKeyUsage{0: {LastUsedTime: 300}, 1: {LastUsedTime: 100}, 2: {LastUsedTime: 200}},
+			enabledIdx: []int{0, 1, 2},
+			want:       1,
+		},
+		{
+			name:       "disabled keys are not considered",
+			usages:     map[int]ChannelKeyUsage{0: {LastUsedTime: 300}, 1: {LastUsedTime: 100}, 2: {LastUsedTime: 200}},
+			enabledIdx: []int{0, 2},
+			want:       2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickLRUKey(tt.usages, tt.enabledIdx); got != tt.want {
+				t.Fatalf("pickLRUKey = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickUsageKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		info       ChannelInfo
+		usages     map[int]ChannelKeyUsage
+		enabledIdx []int
+		want       int
+	}{
+		{
+			name:       "without limits lowest rpm wins",
+			usages:     map[int]ChannelKeyUsage{0: {RPM: 5}, 1: {RPM: 2}, 2: {RPM: 9}},
+			enabledIdx: []int{0, 1, 2},
+			want:       1,
+		},
+		{
+			name:       "lowest utilization wins",
+			info:       ChannelInfo{MultiKeyRPMLimit: 10},
+			usages:     map[int]ChannelKeyUsage{0: {RPM: 5}, 1: {RPM: 2}, 2: {RPM: 3}},
+			enabledIdx: []int{0, 1, 2},
+			want:       1,
+		},
+		{
+			name:       "tpm utilization counts",
+			info:       ChannelInfo{MultiKeyRPMLimit: 10, MultiKeyTPMLimit: 1000},
+			usages:     map[int]ChannelKeyUsage{0: {RPM: 1, TPM: 800}, 1: {RPM: 3, TPM: 100}},
+			enabledIdx: []int{0, 1},
+			want:       1,
+		},
+		{
+			name:       "per-key limit overrides channel limit",
+			info:       ChannelInfo{MultiKeyRPMLimit: 10, MultiKeyRPMLimits: map[int]int{0: 100}},
+			usages:     map[int]ChannelKeyUsage{0: {RPM: 20}, 1: {RPM: 5}},
+			enabledIdx: []int{0, 1},
+			want:       0,
+		},
+		{
+			name:       "keys near the limit are skipped in favor of unlimited keys",
+			info:       ChannelInfo{MultiKeyRPMLimits: map[int]int{0: 10}},
+			usages:     map[int]ChannelKeyUsage{0: {RPM: 9}, 1: {RPM: 50}},
+			enabledIdx: []int{0, 1},
+			want:       1,
+		},
+		{
+			name:       "limited key with headroom preferred over unlimited key",
+			info:       ChannelInfo{MultiKeyRPMLimits: map[int]int{0: 10}},
+			usages:     map[int]ChannelKeyUsage{0: {RPM: 8}, 1: {RPM: 0}},
+			enabledIdx: []int{0, 1},
+			want:       0,
+		},
+		{
+			name:       "all keys near the limit picks lowest utilization",
+			info:       ChannelInfo{MultiKeyRPMLimit: 10},
+			usages:     map[int]ChannelKeyUsage{0: {RPM: 12}, 1: {RPM: 9}},
+			enabledIdx: []int{0, 1},
+			want:       1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.pickUsageKey(tt.usages, tt.enabledIdx); got != tt.want {
+				t.Fatalf("pickUsageKey = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelKeyUsageCounterClaim(t *testing.T) {
+	usageInfo := &ChannelInfo{MultiKeyMode: constant.MultiKeyModeUsage}
+	tests := []struct {
+		name string
+		pick func(map[int]ChannelKeyUsage, []int) int
+	}{
+		{name: "lru", pick: pickLRUKey},
+		{name: "usage", pick: usageInfo.pickUsageKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &channelKeyUsageCounter{
+				current:  make(map[int]*keyUsageCount),
+				previous: make(map[int]*keyUsageCount),
+				totals:   make(map[int]*ChannelKeyUsage),
+			}
+			now := time.Unix(1_700_000_000, 0)
+			seen := make(map[int]bool)
+			for i := 0; i < 3; i++ {
+				// 每次选择都计入用量，连续选择应依次落到不同的 key 上
+				idx := u.claim([]int{0, 1, 2}, now.Add(time.Duration(i)*time.Millisecond), tt.pick)
+				if seen[idx] {
+					t.Fatalf("claim %d picked key %d again", i, idx)
+				}
+				seen[idx] = true
+			}
+			for idx := range seen {
+				if u.totals[idx].TotalRequests != 1 {
+					t.Fatalf("key %d total requests = %d, want 1", idx, u.totals[idx].TotalRequests)
+				}
+			}
+		})
+	}
+}
+
+func TestGetNextEnabledKeyRecordsUsage(t *testing.T) {
+	modes := []constant.MultiKeyMode{
+		constant.MultiKeyModeRandom,
+		constant.MultiKeyModeWeighted,
+		constant.MultiKeyModeLRU,
+		constant.MultiKeyModeUsage,
+	}
+	for i, mode := range modes {
+		t.Run(string(mode), func(t *testing.T) {
+			channel := &Channel{
+				Id:  -100 - i,
+				Key: "k0\nk1\nk2",
+				ChannelInfo: ChannelInfo{
+					IsMultiKey:   true,
+					MultiKeyMode: mode,
+				},
+			}
+			defer channelKeyUsageCounters.Delete(channel.Id)
+			for n := 0; n < 6; n++ {
+				key, idx, apiErr := channel.GetNextEnabledKey()
+				if apiErr != nil {
+					t.Fatalf("GetNextEnabledKey error: %v", apiErr)
+				}
+				if key != channel.GetKeys()[idx] {
+					t.Fatalf("key %q does not match index %d", key, idx)
+				}
+			}
+			var total int64
+			for _, usage := range GetChannelKeyUsage(channel.Id) {
+				total += usage.TotalRequests
+			}
+			if total != 6 {
+				t.Fatalf("recorded requests = %d, want 6", total)
+			}
+		})
+	}
+}
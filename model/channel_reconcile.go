@@ -0,0 +1,120 @@
+package model
+
+import "gorm.io/gorm"
+
+// 渠道对账：定期记录上游余额快照，将相邻两次快照之间的上游扣减与网关记录的用量比较
+
+// ChannelBalanceSnapshot 渠道余额快照，Balance 为上游余额（美元），UsedQuota 为当时的 Channel.UsedQuota
+type ChannelBalanceSnapshot struct {
+	Id        int     `json:"id"`
+	ChannelId int     `json:"channel_id" gorm:"index:idx_channel_balance_snapshot,priority:1"`
+	Balance   float64 `json:"balance"`
+	UsedQuota int64   `json:"used_quota" gorm:"bigint;default:0"`
+	CreatedAt int64   `json:"created_at" gorm:"bigint;index:idx_channel_balance_snapshot,priority:2"`
+}
+
+// ChannelReconciliation 一个对账周期的结果，金额单位均为美元
+type ChannelReconciliation struct {
+	Id           int     `json:"id"`
+	ChannelId    int     `json:"channel_id" gorm:"index:idx_channel_reconciliation,priority:1"`
+	ChannelName  string  `json:"channel_name" gorm:"type:varchar(128);default:''"`
+	PeriodStart  int64   `json:"period_start" gorm:"bigint"`
+	PeriodEnd    int64   `json:"period_end" gorm:"bigint;index:idx_channel_reconciliation,priority:2"`
+	StartBalance float64 `json:"start_balance"`
+	EndBalance   float64 `json:"end_balance"`
+	// Drawdown 上游余额的扣减额，期间充值导致余额上升时为负数
+	Drawdown float64 `json:"drawdown"`
+	// UsedQuotaDelta 期间 Channel.UsedQuota 的增量
+	UsedQuotaDelta int64 `json:"used_quota_delta" gorm:"bigint;default:0"`
+	// LoggedQuota / LoggedCost 期间消费日志中的扣费额度与上游成本合计
+	LoggedQuota int64 `json:"logged_quota" gorm:"bigint;default:0"`
+	LoggedCost  int64 `json:"logged_cost" gorm:"bigint;default:0"`
+	// Expected 网关认为的上游花费：记录了上游成本的请求取成本，其余请求取扣费额度
+	Expected  float64 `json:"expected"`
+	Drift     float64 `json:"drift"`      // Drawdown - Expected
+	DriftRate float64 `json:"drift_rate"` // Drift / Expected，Expected 为 0 时为 0
+	Skipped   bool    `json:"skipped"`    // 期间余额上升（充值），不参与偏差判断
+	Alerted   bool    `json:"alerted"`
+	CreatedAt int64   `json:"created_at" gorm:"bigint"`
+}
+
+// GetLatestChannelBalanceSnapshot 返回渠道最近一次余额快照，不存在时返回 nil
+func GetLatestChannelBalanceSnapshot(channelId int) (*ChannelBalanceSnapshot, error) {
+	var snapshot ChannelBalanceSnapshot
+	err := DB.Where("channel_id = ?", channelId).Order("id desc").Limit(1).Find(&snapshot).Error
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Id == 0 {
+		return nil, nil
+	}
+	return &snapshot, nil
+}
+
+// ChannelLogSum 渠道消费日志的合计，均为额度单位
+type ChannelLogSum struct {
+	Quota int64
+	Cost  int64
+	// Expected 网关认为的上游花费：记录了上游成本的请求取成本，其余请求以扣费额度近似
+	Expected int64
+}
+
+// SumChannelConsumeLogs 汇总渠道在 (startTimestamp, endTimestamp] 内的消费日志
+func SumChannelConsumeLogs(channelId int, startTimestamp int64, endTimestamp int64) (ChannelLogSum, error) {
+	var result ChannelLogSum
+	err := LOG_DB.Table("logs").
+		Select("coalesce(sum(quota), 0) as quota, coalesce(sum(upstream_cost), 0) as cost, "+
+			"coalesce(sum(case when upstream_cost > 0 then upstream_cost else quota end), 0) as expected").
+		Where("type = ? AND channel_id = ? AND created_at > ? AND created_at <= ?", LogTypeConsume, channelId, startTimestamp, endTimestamp).
+		Scan(&result).Error
+	if err != nil {
+		return ChannelLogSum{}, err
+	}
+	// 原始日志已删除的日期从日志汇总补足，汇总按整天统计
+	if rollupTx, ok := logRollupRangeQuery(startTimestamp, endTimestamp, LogRollupFilter{ChannelId: channelId}); ok {
+		var rollup ChannelLogSum
+		err = rollupTx.Select("coalesce(sum(quota), 0) as quota, coalesce(sum(upstream_cost), 0) as cost, " +
+			"coalesce(sum(upstream_cost + uncosted_quota), 0) as expected").Scan(&rollup).Error
+		if err != nil {
+			return ChannelLogSum{}, err
+		}
+		result.Quota += rollup.Quota
+		result.Cost += rollup.Cost
+		result.Expected += rollup.Expected
+	}
+	return result, nil
+}
+
+// SaveChannelReconciliation 在一个事务内写入新的余额快照与对账结果，reconciliation 为 nil 时只写快照
+func SaveChannelReconciliation(snapshot *ChannelBalanceSnapshot, reconciliation *ChannelReconciliation) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(snapshot).Error; err != nil {
+			return err
+		}
+		if reconciliation == nil {
+			return nil
+		}
+		return tx.Create(reconciliation).Error
+	})
+}
+
+func GetChannelReconciliations(channelId int, startIdx int, num int) (items []*ChannelReconciliation, total int64, err error) {
+	tx := DB.Model(&ChannelReconciliation{})
+	if channelId != 0 {
+		tx = tx.Where("channel_id = ?", channelId)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&items).Error
+	return items, total, err
+}
+
+func GetChannelBalanceSnapshots(channelId int, startIdx int, num int) (snapshots []*ChannelBalanceSnapshot, total int64, err error) {
+	tx := DB.Model(&ChannelBalanceSnapshot{}).Where("channel_id = ?", channelId)
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&snapshots).Error
+	return snapshots, total, err
+}
@@ -0,0 +1,200 @@
+package model
+
+import (
+	"math"
+	"one-api/setting/operation_setting"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 渠道运行时统计：按渠道 + 模型维护一个由固定数量时间桶组成的滑动窗口，
+// 仅保存在本进程内存中，用于动态调整渠道选择权重
+const channelStatsBucketCount = 30
+
+type channelStatsBucket struct {
+	index     int64 // 桶对应的时间片编号，用于判断桶是否已过期
+	requests  int64
+	errors    int64
+	latencyMs int64
+	ttftMs    int64
+	ttftCount int64
+}
+
+type channelModelStats struct {
+	mu      sync.Mutex
+	buckets [channelStatsBucketCount]channelStatsBucket
+}
+
+type channelStatsKey struct {
+	channelId int
+	model     string
+}
+
+var channelStatsMap sync.Map // channelStatsKey -> *channelModelStats
+
+// ChannelModelStats 滑动窗口内的统计快照
+type ChannelModelStats struct {
+	ChannelId    int     `json:"channel_id"`
+	Model        string  `json:"model"`
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	AvgTTFTMs    float64 `json:"avg_ttft_ms"`
+}
+
+func channelStatsBucketSpan() int64 {
+	window := operation_setting.GetChannelSelectionSetting().WindowSeconds
+	if window <= 0 {
+		window = 300
+	}
+	span := int64(window) / channelStatsBucketCount
+	if span <= 0 {
+		span = 1
+	}
+	return span
+}
+
+func getChannelModelStats(channelId int, model string) *channelModelStats {
+	key := channelStatsKey{channelId: channelId, model: model}
+	if v, ok := channelStatsMap.Load(key); ok {
+		return v.(*channelModelStats)
+	}
+	v, _ := channelStatsMap.LoadOrStore(key, &channelModelStats{})
+	return v.(*channelModelStats)
+}
+
+func (s *channelModelStats) record(fn func(bucket *channelStatsBucket)) {
+	index := time.Now().Unix() / channelStatsBucketSpan()
+	s.mu.Lock()
+	bucket := &s.buckets[index%channelStatsBucketCount]
+	if bucket.index != index {
+		*bucket = channelStatsBucket{index: index}
+	}
+	fn(bucket)
+	s.mu.Unlock()
+}
+
+func (s *channelModelStats) snapshot(channelId int, model string) ChannelModelStats {
+	stats := ChannelModelStats{ChannelId: channelId, Model: model}
+	minIndex := time.Now().Unix()/channelStatsBucketSpan() - channelStatsBucketCount + 1
+	var latencyMs, ttftMs, ttftCount int64
+	s.mu.Lock()
+	for _, bucket := range s.buckets {
+		if bucket.index < minIndex {
+			continue
+		}
+		stats.Requests += bucket.requests
+		stats.Errors += bucket.errors
+		latencyMs += bucket.latencyMs
+		ttftMs += bucket.ttftMs
+		ttftCount += bucket.ttftCount
+	}
+	s.mu.Unlock()
+	if stats.Requests > 0 {
+		stats.ErrorRate = float64(stats.Errors) / float64(stats.Requests)
+	}
+	if successes := stats.Requests - stats.Errors; successes > 0 {
+		stats.AvgLatencyMs = float64(latencyMs) / float64(successes)
+	}
+	if ttftCount > 0 {
+		stats.AvgTTFTMs = float64(ttftMs) / float64(ttftCount)
+	}
+	return stats
+}
+
+// RecordChannelSuccess 记录一次成功请求，ttft 为 0 表示未能获取首字时间
+func RecordChannelSuccess(channelId int, model string, ttft time.Duration, latency time.Duration) {
+	if channelId == 0 {
+		return
+	}
+	getChannelModelStats(channelId, model).record(func(bucket *channelStatsBucket) {
+		bucket.requests++
+		bucket.latencyMs += latency.Milliseconds()
+		if ttft > 0 {
+			bucket.ttftMs += ttft.Milliseconds()
+			bucket.ttftCount++
+		}
+	})
+}
+
+func RecordChannelFailure(channelId int, model string) {
+	if channelId == 0 {
+		return
+	}
+	getChannelModelStats(channelId, model).record(func(bucket *channelStatsBucket) {
+		bucket.requests++
+		bucket.errors++
+	})
+}
+
+func GetChannelModelStats(channelId int, model string) ChannelModelStats {
+	v, ok := channelStatsMap.Load(channelStatsKey{channelId: channelId, model: model})
+	if !ok {
+		return ChannelModelStats{ChannelId: channelId, Model: model}
+	}
+	return v.(*channelModelStats).snapshot(channelId, model)
+}
+
+// GetAllChannelModelStats 返回窗口内有请求的所有渠道模型统计，按渠道、模型排序
+func GetAllChannelModelStats() []ChannelModelStats {
+	result := make([]ChannelModelStats, 0)
+	channelStatsMap.Range(func(k, v any) bool {
+		key := k.(channelStatsKey)
+		stats := v.(*channelModelStats).snapshot(key.channelId, key.model)
+		if stats.Requests > 0 {
+			result = append(result, stats)
+		}
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ChannelId != result[j].ChannelId {
+			return result[i].ChannelId < result[j].ChannelId
+		}
+		return result[i].Model < result[j].Model
+	})
+	return result
+}
+
+// latencyScore 优先使用首字延迟，没有流式样本时使用总耗时
+func (s ChannelModelStats) latencyScore() float64 {
+	if s.AvgTTFTMs > 0 {
+		return s.AvgTTFTMs
+	}
+	return s.AvgLatencyMs
+}
+
+// adaptiveChannelWeights 在静态权重基础上按错误率与相对延迟计算动态权重，
+// 样本不足的渠道保持原权重，避免新渠道或冷门模型被饿死
+func adaptiveChannelWeights(channels []*Channel, model string, smoothingFactor int) []float64 {
+	setting := operation_setting.GetChannelSelectionSetting()
+	minFactor := setting.MinWeightFactor
+	if minFactor <= 0 {
+		minFactor = 0.01
+	}
+	allStats := make([]ChannelModelStats, len(channels))
+	bestLatency := 0.0
+	for i, channel := range channels {
+		allStats[i] = GetChannelModelStats(channel.Id, model)
+		if allStats[i].Requests-allStats[i].Errors < int64(setting.MinRequests) {
+			continue
+		}
+		if score := allStats[i].latencyScore(); score > 0 && (bestLatency == 0 || score < bestLatency) {
+			bestLatency = score
+		}
+	}
+	weights := make([]float64, len(channels))
+	for i, channel := range channels {
+		stats := allStats[i]
+		factor := 1.0
+		if stats.Requests >= int64(setting.MinRequests) {
+			factor *= math.Pow(1-stats.ErrorRate, setting.ErrorRatePenalty)
+		}
+		if score := stats.latencyScore(); bestLatency > 0 && score > 0 && stats.Requests-stats.Errors >= int64(setting.MinRequests) {
+			factor *= bestLatency / score
+		}
+		weights[i] = float64(channel.GetWeight()+smoothingFactor) * math.Max(factor, minFactor)
+	}
+	return weights
+}
@@ -0,0 +1,247 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"strconv"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+// 额度批次：每次增加余额都会生成一个批次，记录来源、剩余额度与过期时间。
+// 消费按过期时间从早到晚扣减批次，未过期批次的剩余额度之和即用户余额（User.Quota）中大于 0 的部分
+
+type CreditLot struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	Source    string `json:"source" gorm:"type:varchar(32)"` // 与额度流水的 Reason 一致
+	Amount    int    `json:"amount"`
+	Remaining int    `json:"remaining"`
+	ExpiresAt int64  `json:"expires_at" gorm:"bigint;index;default:0"` // 0 表示永不过期
+	RefType   string `json:"ref_type" gorm:"type:varchar(16);default:''"`
+	RefId     string `json:"ref_id" gorm:"type:varchar(128);default:''"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+// 扣减顺序：先到期的先扣，永不过期的最后扣
+const creditLotDrawOrder = "CASE WHEN expires_at = 0 THEN 1 ELSE 0 END, expires_at, id"
+
+// 退款按扣减的逆序回填
+const creditLotRestoreOrder = "CASE WHEN expires_at = 0 THEN 0 ELSE 1 END, expires_at desc, id desc"
+
+func activeCreditLotsTx(tx *gorm.DB, userId int, now int64) *gorm.DB {
+	return tx.Model(&CreditLot{}).Where("user_id = ? AND (expires_at = 0 OR expires_at > ?)", userId, now)
+}
+
+// applyCreditLotTx 根据一条额度流水更新用户的额度批次，需在更新余额的同一事务内调用。
+// 批次只覆盖余额中大于 0 的部分：余额为负时增加的额度先抵扣欠款，扣减时只扣除变动前余额中大于 0 的部分，
+// 保证未过期批次的剩余额度之和始终等于 max(User.Quota, 0)
+func applyCreditLotTx(tx *gorm.DB, entry *QuotaLedger) error {
+	if entry.Delta == 0 || entry.Reason == LedgerReasonExpire {
+		// 过期由 ExpireCreditLots 直接处理批次
+		return nil
+	}
+	if entry.Delta < 0 {
+		before := entry.BalanceAfter - entry.Delta
+		quota := min(-entry.Delta, max(before, 0))
+		if quota <= 0 {
+			return nil
+		}
+		return drawCreditLotsTx(tx, entry.UserId, quota, entry.CreatedAt)
+	}
+	quota := min(entry.Delta, max(entry.BalanceAfter, 0))
+	if quota <= 0 {
+		return nil
+	}
+	if entry.Reason == LedgerReasonRefund || entry.Reason == LedgerReasonTaskRefund {
+		return restoreCreditLotsTx(tx, entry, quota)
+	}
+	return tx.Create(&CreditLot{
+		UserId:    entry.UserId,
+		Source:    entry.Reason,
+		Amount:    quota,
+		Remaining: quota,
+		ExpiresAt: entry.lotExpiresAt,
+		RefType:   entry.RefType,
+		RefId:     entry.RefId,
+		CreatedAt: entry.CreatedAt,
+	}).Error
+}
+
+// 并发扣减或回填导致条件更新未命中时，重新读取批次后重试的次数
+const creditLotUpdateRetries = 3
+
+// drawCreditLotsTx 按过期顺序扣减批次。读取批次后剩余额度可能已被并发请求扣减，更新时要求剩余额度仍足够，
+// 未命中则重新读取；重试次数用尽仍未扣完时返回错误，由调用方回滚整个事务，避免批次合计与余额不一致
+func drawCreditLotsTx(tx *gorm.DB, userId int, quota int, now int64) error {
+	for attempt := 0; quota > 0; attempt++ {
+		if attempt >= creditLotUpdateRetries {
+			return fmt.Errorf("failed to draw %d quota from credit lots of user %d after %d retries", quota, userId, creditLotUpdateRetries)
+		}
+		var lots []*CreditLot
+		err := activeCreditLotsTx(tx, userId, now).Where("remaining > 0").Order(creditLotDrawOrder).Find(&lots).Error
+		if err != nil {
+			return err
+		}
+		conflict := false
+		for _, lot := range lots {
+			if quota <= 0 {
+				break
+			}
+			take := min(lot.Remaining, quota)
+			result := tx.Model(&CreditLot{}).Where("id = ? AND remaining >= ?", lot.Id, take).
+				Update("remaining", gorm.Expr("remaining - ?", take))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				conflict = true
+				continue
+			}
+			quota -= take
+		}
+		if !conflict {
+			// 批次已全部扣完，只可能出现在启用批次前已存在偏差的情况
+			break
+		}
+	}
+	return nil
+}
+
+// restoreCreditLotsTx 将 quota 回填到已被扣减的未过期批次，无法回填的部分生成一个永不过期的退款批次。
+// 回填同样使用条件更新，保证剩余额度不超过批次金额
+func restoreCreditLotsTx(tx *gorm.DB, entry *QuotaLedger, quota int) error {
+	for attempt := 0; quota > 0 && attempt < creditLotUpdateRetries; attempt++ {
+		var lots []*CreditLot
+		err := activeCreditLotsTx(tx, entry.UserId, entry.CreatedAt).Where("remaining < amount").Order(creditLotRestoreOrder).Find(&lots).Error
+		if err != nil {
+			return err
+		}
+		conflict := false
+		for _, lot := range lots {
+			if quota <= 0 {
+				break
+			}
+			give := min(lot.Amount-lot.Remaining, quota)
+			result := tx.Model(&CreditLot{}).Where("id = ? AND remaining + ? <= amount", lot.Id, give).
+				Update("remaining", gorm.Expr("remaining + ?", give))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				conflict = true
+				continue
+			}
+			quota -= give
+		}
+		if !conflict {
+			break
+		}
+	}
+	if quota <= 0 {
+		return nil
+	}
+	return tx.Create(&CreditLot{
+		UserId:    entry.UserId,
+		Source:    entry.Reason,
+		Amount:    quota,
+		Remaining: quota,
+		RefType:   entry.RefType,
+		RefId:     entry.RefId,
+		CreatedAt: entry.CreatedAt,
+	}).Error
+}
+
+// seedCreditLots 批次表为空时把已有余额作为永不过期的期初批次
+func seedCreditLots() error {
+	var count int64
+	if err := DB.Model(&CreditLot{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	var users []User
+	if err := DB.Select("id", "quota").Where("quota > 0").Find(&users).Error; err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return nil
+	}
+	now := common.GetTimestamp()
+	lots := make([]*CreditLot, 0, len(users))
+	for _, user := range users {
+		lots = append(lots, &CreditLot{
+			UserId:    user.Id,
+			Source:    LedgerReasonOpening,
+			Amount:    user.Quota,
+			Remaining: user.Quota,
+			CreatedAt: now,
+		})
+	}
+	common.SysLog("seeding credit lots from existing balances")
+	return DB.CreateInBatches(&lots, 500).Error
+}
+
+// GetUserCreditLots 返回用户未过期且仍有剩余的批次，按扣减顺序排列
+func GetUserCreditLots(userId int) ([]*CreditLot, error) {
+	var lots []*CreditLot
+	err := activeCreditLotsTx(DB, userId, common.GetTimestamp()).Where("remaining > 0").Order(creditLotDrawOrder).Find(&lots).Error
+	return lots, err
+}
+
+// ExpireCreditLots 清零已过期批次的剩余额度，并从用户余额中扣除
+func ExpireCreditLots() {
+	now := common.GetTimestamp()
+	var lots []*CreditLot
+	err := DB.Where("expires_at > 0 AND expires_at <= ? AND remaining > 0", now).Order("expires_at").Limit(1000).Find(&lots).Error
+	if err != nil {
+		common.SysError("failed to query expired credit lots: " + err.Error())
+		return
+	}
+	for _, lot := range lots {
+		if err := expireCreditLot(lot); err != nil {
+			common.SysError(fmt.Sprintf("failed to expire credit lot %d: %s", lot.Id, err.Error()))
+		}
+	}
+}
+
+func expireCreditLot(lot *CreditLot) error {
+	expired := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var current CreditLot
+		if err := tx.First(&current, lot.Id).Error; err != nil {
+			return err
+		}
+		if current.Remaining <= 0 {
+			return nil
+		}
+		// 以读取到的剩余额度为条件清零，期间被并发扣减或回填时本轮跳过，下一轮重新处理
+		result := tx.Model(&CreditLot{}).Where("id = ? AND remaining = ?", current.Id, current.Remaining).Update("remaining", 0)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		expired = current.Remaining
+		if err := tx.Model(&User{}).Where("id = ?", current.UserId).Update("quota", gorm.Expr("quota - ?", expired)).Error; err != nil {
+			return err
+		}
+		return recordQuotaLedgerTx(tx, current.UserId, -expired, LedgerRef{
+			Reason:  LedgerReasonExpire,
+			RefType: LedgerRefCreditLot,
+			RefId:   strconv.Itoa(current.Id),
+		})
+	})
+	if err != nil || expired == 0 {
+		return err
+	}
+	gopool.Go(func() {
+		if err := cacheDecrUserQuota(lot.UserId, int64(expired)); err != nil {
+			common.SysLog("failed to decrease user quota: " + err.Error())
+		}
+	})
+	return nil
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCreditLotDrawAndRestore(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	type step struct {
+		reason    string
+		delta     int
+		expiresAt int64
+	}
+	tests := []struct {
+		name          string
+		steps         []step
+		wantRemaining []int // 按批次创建顺序
+	}{
+		{
+			name: "draws expiring lot first",
+			steps: []step{
+				{reason: LedgerReasonTopup, delta: 100},
+				{reason: LedgerReasonRedemption, delta: 50, expiresAt: expiresAt},
+				{reason: LedgerReasonConsume, delta: -70},
+			},
+			wantRemaining: []int{80, 0},
+		},
+		{
+			name: "refund restores in reverse draw order",
+			steps: []step{
+				{reason: LedgerReasonTopup, delta: 100},
+				{reason: LedgerReasonRedemption, delta: 50, expiresAt: expiresAt},
+				{reason: LedgerReasonConsume, delta: -70},
+				{reason: LedgerReasonRefund, delta: 30},
+			},
+			wantRemaining: []int{100, 10},
+		},
+		{
+			name: "refund beyond drawn lots creates refund lot",
+			steps: []step{
+				{reason: LedgerReasonTopup, delta: 100},
+				{reason: LedgerReasonConsume, delta: -20},
+				{reason: LedgerReasonRefund, delta: 50},
+			},
+			wantRemaining: []int{100, 30},
+		},
+		{
+			name: "overdraft only draws positive balance",
+			steps: []step{
+				{reason: LedgerReasonTopup, delta: 30},
+				{reason: LedgerReasonConsume, delta: -50},
+			},
+			wantRemaining: []int{0},
+		},
+		{
+			name: "top-up pays off negative balance first",
+			steps: []step{
+				{reason: LedgerReasonTopup, delta: 30},
+				{reason: LedgerReasonConsume, delta: -50},
+				{reason: LedgerReasonTopup, delta: 100},
+				{reason: LedgerReasonConsume, delta: -10},
+			},
+			wantRemaining: []int{0, 70},
+		},
+		{
+			name: "top-up that does not clear debt creates no lot",
+			steps: []step{
+				{reason: LedgerReasonConsume, delta: -50},
+				{reason: LedgerReasonTopup, delta: 20},
+				{reason: LedgerReasonRefund, delta: 10},
+			},
+			wantRemaining: []int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, &User{}, &QuotaLedger{}, &CreditLot{})
+			user := &User{Username: "lot"}
+			if err := DB.Create(user).Error; err != nil {
+				t.Fatal(err)
+			}
+			for i, s := range tt.steps {
+				entry := LedgerRef{Reason: s.reason, ExpiresAt: s.expiresAt}.newEntry(user.Id, s.delta)
+				if err := updateUserQuotaWithLedger(user.Id, s.delta, []*QuotaLedger{entry}); err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				}
+			}
+			var lots []*CreditLot
+			if err := DB.Where("user_id = ?", user.Id).Order("id").Find(&lots).Error; err != nil {
+				t.Fatal(err)
+			}
+			remaining := make([]int, 0, len(lots))
+			sum := 0
+			for _, lot := range lots {
+				remaining = append(remaining, lot.Remaining)
+				sum += lot.Remaining
+			}
+			if !slices.Equal(remaining, tt.wantRemaining) {
+				t.Fatalf("lot remaining = %v, want %v", remaining, tt.wantRemaining)
+			}
+			var got User
+			if err := DB.First(&got, user.Id).Error; err != nil {
+				t.Fatal(err)
+			}
+			if sum != max(got.Quota, 0) {
+				t.Fatalf("lot remaining sum = %d, user quota = %d", sum, got.Quota)
+			}
+		})
+	}
+}
+
+func TestExpireCreditLot(t *testing.T) {
+	setupTestDB(t, &User{}, &QuotaLedger{}, &CreditLot{})
+	user := &User{Username: "lot"}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	entry := LedgerRef{Reason: LedgerReasonRedemption, ExpiresAt: time.Now().Add(time.Hour).Unix()}.newEntry(user.Id, 80)
+	if err := updateUserQuotaWithLedger(user.Id, 80, []*QuotaLedger{entry}); err != nil {
+		t.Fatal(err)
+	}
+	var lot CreditLot
+	if err := DB.Where("user_id = ?", user.Id).First(&lot).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 查询到过期批次后又发生了消费，按最新的剩余额度过期
+	consume := LedgerRef{Reason: LedgerReasonConsume}.newEntry(user.Id, -20)
+	if err := updateUserQuotaWithLedger(user.Id, -20, []*QuotaLedger{consume}); err != nil {
+		t.Fatal(err)
+	}
+	if err := expireCreditLot(&lot); err != nil {
+		t.Fatal(err)
+	}
+	var got User
+	DB.First(&got, user.Id)
+	DB.First(&lot, lot.Id)
+	if lot.Remaining != 0 || got.Quota != 0 {
+		t.Fatalf("after expire remaining = %d quota = %d, want 0 0", lot.Remaining, got.Quota)
+	}
+}
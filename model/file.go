@@ -0,0 +1,87 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+const (
+	FileStatusUploaded  = "uploaded"
+	FileStatusProcessed = "processed"
+	FileStatusError     = "error"
+)
+
+// File 记录通过 /v1/files 上传的文件，内容保存在本地存储中，
+// 若上传时选中了上游渠道，则同时记录上游文件 ID，后续请求复用同一渠道
+type File struct {
+	Id             int            `json:"id"`
+	FileId         string         `json:"file_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId         int            `json:"user_id" gorm:"index"`
+	TokenId        int            `json:"token_id" gorm:"index"`
+	ChannelId      int            `json:"channel_id" gorm:"index"`
+	KeyIndex       int            `json:"key_index" gorm:"default:0"` // 多 Key 渠道上传时使用的 key 下标
+	UpstreamFileId string         `json:"upstream_file_id" gorm:"type:varchar(128);default:''"`
+	Filename       string         `json:"filename" gorm:"type:varchar(255)"`
+	Purpose        string         `json:"purpose" gorm:"type:varchar(32);index"`
+	Bytes          int64          `json:"bytes" gorm:"bigint"`
+	StoragePath    string         `json:"-" gorm:"type:varchar(512)"`
+	Status         string         `json:"status" gorm:"type:varchar(20)"`
+	Quota          int            `json:"quota" gorm:"default:0"`
+	CreatedAt      int64          `json:"created_at" gorm:"bigint;index"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+func (file *File) Insert() error {
+	return DB.Create(file).Error
+}
+
+func (file *File) Update() error {
+	return DB.Save(file).Error
+}
+
+func (file *File) Delete() error {
+	return DB.Delete(file).Error
+}
+
+// GetFileByFileId 不校验归属，仅供内部任务（如批处理）使用
+func GetFileByFileId(fileId string) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id 为空！")
+	}
+	var file File
+	err := DB.Where("file_id = ?", fileId).First(&file).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func GetUserFileByFileId(userId int, fileId string) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id 为空！")
+	}
+	var file File
+	err := DB.Where("user_id = ? and file_id = ?", userId, fileId).First(&file).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// GetUserFiles 按创建时间倒序分页，afterId 为上一页最后一个文件的 file_id
+func GetUserFiles(userId int, purpose string, afterId string, limit int) (files []*File, err error) {
+	tx := DB.Where("user_id = ?", userId)
+	if purpose != "" {
+		tx = tx.Where("purpose = ?", purpose)
+	}
+	if afterId != "" {
+		after, err := GetUserFileByFileId(userId, afterId)
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where("id < ?", after.Id)
+	}
+	err = tx.Order("id desc").Limit(limit).Find(&files).Error
+	return files, err
+}
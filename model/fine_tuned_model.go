@@ -0,0 +1,147 @@
+package model
+
+import (
+	"one-api/common"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FineTunedModel 微调产出的模型，仅在训练所用渠道上为所属用户的分组开放，分发时再校验调用者是否为所属用户
+type FineTunedModel struct {
+	Id          int    `json:"id"`
+	Model       string `json:"model" gorm:"type:varchar(255);uniqueIndex"`
+	BaseModel   string `json:"base_model" gorm:"type:varchar(255)"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Group       string `json:"group" gorm:"type:varchar(64)"`
+	ChannelId   int    `json:"channel_id" gorm:"index"`
+	KeyIndex    int    `json:"key_index" gorm:"default:0"` // 多 Key 渠道训练时使用的 key 下标，删除模型时需使用同一上游账号
+	TaskId      string `json:"task_id" gorm:"type:varchar(64)"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func GetFineTunedModel(modelName string) (*FineTunedModel, error) {
+	var ftModel FineTunedModel
+	err := DB.Where("model = ?", modelName).First(&ftModel).Error
+	if err != nil {
+		return nil, err
+	}
+	return &ftModel, nil
+}
+
+// CanUseFineTunedModel 微调模型只允许训练它的用户调用，非微调模型总是返回 true。
+// 能力按分组注册，同分组的其他用户也能匹配到渠道，因此分发时需单独校验
+func CanUseFineTunedModel(modelName string, userId int) bool {
+	if common.MemoryCacheEnabled {
+		channelSyncLock.RLock()
+		ownerId, ok := fineTunedModelOwners[modelName]
+		channelSyncLock.RUnlock()
+		return !ok || ownerId == userId
+	}
+	var ftModel FineTunedModel
+	if err := DB.Select("user_id").Where("model = ?", modelName).Limit(1).Find(&ftModel).Error; err != nil {
+		common.SysError("failed to check fine-tuned model owner: " + err.Error())
+		return false
+	}
+	return ftModel.UserId == 0 || ftModel.UserId == userId
+}
+
+// FilterFineTunedModels 从模型列表中去掉其他用户的微调模型
+func FilterFineTunedModels(models []string, userId int) []string {
+	hidden := make(map[string]bool)
+	if common.MemoryCacheEnabled {
+		channelSyncLock.RLock()
+		for _, modelName := range models {
+			if ownerId, ok := fineTunedModelOwners[modelName]; ok && ownerId != userId {
+				hidden[modelName] = true
+			}
+		}
+		channelSyncLock.RUnlock()
+	} else if len(models) > 0 {
+		var names []string
+		if err := DB.Model(&FineTunedModel{}).Where("model IN ? AND user_id <> ?", models, userId).Pluck("model", &names).Error; err != nil {
+			common.SysError("failed to filter fine-tuned models: " + err.Error())
+		}
+		for _, name := range names {
+			hidden[name] = true
+		}
+	}
+	if len(hidden) == 0 {
+		return models
+	}
+	filtered := make([]string, 0, len(models)-len(hidden))
+	for _, modelName := range models {
+		if !hidden[modelName] {
+			filtered = append(filtered, modelName)
+		}
+	}
+	return filtered
+}
+
+func getChannelFineTunedModels(db *gorm.DB, channelId int) (ftModels []*FineTunedModel) {
+	db.Where("channel_id = ?", channelId).Find(&ftModels)
+	return ftModels
+}
+
+// fineTunedAbilities 渠道重建能力时附加其上的微调模型
+func (channel *Channel) fineTunedAbilities(db *gorm.DB) []Ability {
+	ftModels := getChannelFineTunedModels(db, channel.Id)
+	abilities := make([]Ability, 0, len(ftModels))
+	for _, ftModel := range ftModels {
+		abilities = append(abilities, channel.newFineTunedAbility(ftModel))
+	}
+	return abilities
+}
+
+func (channel *Channel) newFineTunedAbility(ftModel *FineTunedModel) Ability {
+	channelRatio := channel.ChannelRatio
+	if channelRatio == nil {
+		defaultRatio := 1.0
+		channelRatio = &defaultRatio
+	}
+	return Ability{
+		Group:        ftModel.Group,
+		Model:        ftModel.Model,
+		ChannelId:    channel.Id,
+		Enabled:      channel.Status == common.ChannelStatusEnabled,
+		Priority:     channel.Priority,
+		Weight:       uint(channel.GetWeight()),
+		Tag:          channel.Tag,
+		ChannelRatio: channelRatio,
+	}
+}
+
+// RegisterFineTunedModel 记录微调模型并在原渠道上为所属分组添加能力
+func RegisterFineTunedModel(ftModel *FineTunedModel) error {
+	channel, err := GetChannelById(ftModel.ChannelId, true)
+	if err != nil {
+		return err
+	}
+	ftModel.CreatedTime = common.GetTimestamp()
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(ftModel).Error; err != nil {
+			return err
+		}
+		ability := channel.newFineTunedAbility(ftModel)
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&ability).Error
+	})
+	if err != nil {
+		return err
+	}
+	InitChannelCache()
+	return nil
+}
+
+func DeleteFineTunedModel(ftModel *FineTunedModel) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("model = ? and channel_id = ?", ftModel.Model, ftModel.ChannelId).Delete(&Ability{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(ftModel).Error
+	})
+	if err != nil {
+		return err
+	}
+	InitChannelCache()
+	return nil
+}
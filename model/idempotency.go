@@ -0,0 +1,82 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 幂等记录：未启用 Redis 时保存 Idempotency-Key 对应的首个响应与计费结果
+
+const (
+	IdempotencyStatusProcessing = "processing"
+	IdempotencyStatusCompleted  = "completed"
+)
+
+type IdempotencyRecord struct {
+	Id          int    `json:"id"`
+	Key         string `json:"key" gorm:"type:varchar(64);uniqueIndex"`
+	TokenId     int    `json:"token_id" gorm:"index"`
+	Fingerprint string `json:"fingerprint" gorm:"type:varchar(64)"`      // 请求体摘要，同一幂等键不能用于不同的请求
+	Owner       string `json:"owner" gorm:"type:varchar(64);default:''"` // 占用该幂等键的请求，只有占用者可以完成或释放
+	Status      string `json:"status" gorm:"type:varchar(16)"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type" gorm:"type:varchar(128);default:''"`
+	Body        []byte `json:"body"`
+	BodyOmitted bool   `json:"body_omitted"` // 响应过大或为流式响应时不保存响应体，重试无法回放
+	LogId       int    `json:"log_id"`       // 首个请求的消费日志
+	Quota       int    `json:"quota"`        // 首个请求的计费额度
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+	ExpiresAt   int64  `json:"expires_at" gorm:"bigint;index"`
+}
+
+// AcquireIdempotencyRecord 插入处理中的记录，返回是否成功占用该幂等键；已过期的旧记录会被先清除
+func AcquireIdempotencyRecord(record *IdempotencyRecord, now int64) (bool, error) {
+	if err := DB.Where(commonKeyCol+" = ? AND expires_at <= ?", record.Key, now).Delete(&IdempotencyRecord{}).Error; err != nil {
+		return false, err
+	}
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// GetIdempotencyRecord 不存在或已过期时返回 nil
+func GetIdempotencyRecord(key string, now int64) (*IdempotencyRecord, error) {
+	var record IdempotencyRecord
+	err := DB.Where(commonKeyCol+" = ? AND expires_at > ?", key, now).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// CompleteIdempotencyRecord 保存首个请求的结果，返回记录是否仍由 record.Owner 占用
+func CompleteIdempotencyRecord(record *IdempotencyRecord) (bool, error) {
+	result := DB.Model(&IdempotencyRecord{}).Where(commonKeyCol+" = ? AND owner = ?", record.Key, record.Owner).Updates(map[string]interface{}{
+		"status":       record.Status,
+		"status_code":  record.StatusCode,
+		"content_type": record.ContentType,
+		"body":         record.Body,
+		"body_omitted": record.BodyOmitted,
+		"log_id":       record.LogId,
+		"quota":        record.Quota,
+		"expires_at":   record.ExpiresAt,
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteIdempotencyRecord 只删除仍由 owner 占用的记录
+func DeleteIdempotencyRecord(key string, owner string) error {
+	return DB.Where(commonKeyCol+" = ? AND owner = ?", key, owner).Delete(&IdempotencyRecord{}).Error
+}
+
+func DeleteExpiredIdempotencyRecords(now int64) (int64, error) {
+	result := DB.Where("expires_at <= ?", now).Delete(&IdempotencyRecord{})
+	return result.RowsAffected, result.Error
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/logger"
+	"one-api/metrics"
+	"one-api/tracing"
 	"one-api/types"
 	"os"
 	"strings"
@@ -36,6 +39,8 @@ type Log struct {
 	Group            string `json:"group" gorm:"index"`
 	Ip               string `json:"ip" gorm:"index;default:''"`
 	Other            string `json:"other"`
+	UpstreamCost     int    `json:"upstream_cost" gorm:"default:0"` // 上游成本（额度单位），渠道未配置成本价时为 0
+	OrgId            int    `json:"org_id" gorm:"index;default:0"`  // 使用组织令牌时所属的组织
 }
 
 const (
@@ -45,11 +50,13 @@ const (
 	LogTypeManage
 	LogTypeSystem
 	LogTypeError
+	LogTypeCacheHit // 命中响应缓存，未请求上游
 )
 
 func formatUserLogs(logs []*Log) {
 	for i := range logs {
 		logs[i].ChannelName = ""
+		logs[i].UpstreamCost = 0
 		var otherMap map[string]interface{}
 		otherMap, _ = common.StrToMap(logs[i].Other)
 		if otherMap != nil {
@@ -151,6 +158,27 @@ type RecordConsumeLogParams struct {
 }
 
 func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams) {
+	recordConsumeLog(c, userId, LogTypeConsume, params)
+}
+
+// RecordCacheHitLog 记录命中响应缓存的请求，计费字段与消费日志一致
+func RecordCacheHitLog(c *gin.Context, userId int, params RecordConsumeLogParams) {
+	recordConsumeLog(c, userId, LogTypeCacheHit, params)
+}
+
+func recordConsumeLog(c *gin.Context, userId int, logType int, params RecordConsumeLogParams) {
+	// 记录本次请求的计费结果，供幂等键保存
+	common.SetContextKey(c, constant.ContextKeyConsumeQuota, params.Quota)
+	if logType == LogTypeConsume {
+		channel := metrics.ChannelLabel(params.ChannelId)
+		metrics.RelayTokens.WithLabelValues(params.ModelName, channel, params.Group, "prompt").Add(float64(params.PromptTokens))
+		metrics.RelayTokens.WithLabelValues(params.ModelName, channel, params.Group, "completion").Add(float64(params.CompletionTokens))
+	}
+	tracing.SetAttributes(c,
+		tracing.AttrPromptTokens.Int(params.PromptTokens),
+		tracing.AttrCompletionTokens.Int(params.CompletionTokens),
+		tracing.AttrQuota.Int(params.Quota),
+	)
 	if !common.LogConsumeEnabled {
 		return
 	}
@@ -168,7 +196,7 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		UserId:           userId,
 		Username:         username,
 		CreatedAt:        common.GetTimestamp(),
-		Type:             LogTypeConsume,
+		Type:             logType,
 		Content:          params.Content,
 		PromptTokens:     params.PromptTokens,
 		CompletionTokens: params.CompletionTokens,
@@ -188,9 +216,15 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		}(),
 		Other: otherStr,
 	}
+	if logType == LogTypeConsume {
+		log.UpstreamCost = calculateLogUpstreamCost(params)
+	}
+	log.OrgId = common.GetContextKeyInt(c, constant.ContextKeyTokenOrgId)
 	err := LOG_DB.Create(log).Error
 	if err != nil {
 		logger.LogError(c, "failed to record log: "+err.Error())
+	} else {
+		common.SetContextKey(c, constant.ContextKeyConsumeLogId, log.Id)
 	}
 	if common.DataExportEnabled {
 		gopool.Go(func() {
@@ -199,6 +233,19 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 	}
 }
 
+// calculateLogUpstreamCost 根据渠道成本价计算本次消费的上游成本
+func calculateLogUpstreamCost(params RecordConsumeLogParams) int {
+	usage := ChannelCostUsage{
+		PromptTokens:        params.PromptTokens,
+		CompletionTokens:    params.CompletionTokens,
+		CacheTokens:         getOtherInt(params.Other, "cache_tokens"),
+		CacheCreationTokens: getOtherInt(params.Other, "cache_creation_tokens"),
+	}
+	upstreamModel, _ := params.Other["upstream_model_name"].(string)
+	cost, _ := CalculateUpstreamCost(params.ChannelId, usage, upstreamModel, params.ModelName)
+	return cost
+}
+
 func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, group string) (logs []*Log, total int64, err error) {
 	var tx *gorm.DB
 	if logType == LogTypeUnknown {
@@ -300,6 +347,30 @@ func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int
 	return logs, total, err
 }
 
+// GetOrgLogs 查询使用组织令牌产生的日志，userId 不为 0 时只查询该成员
+func GetOrgLogs(orgId int, userId int, startTimestamp int64, endTimestamp int64, startIdx int, num int) (logs []*Log, total int64, err error) {
+	tx := LOG_DB.Where("logs.org_id = ?", orgId)
+	if userId != 0 {
+		tx = tx.Where("logs.user_id = ?", userId)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", endTimestamp)
+	}
+	err = tx.Model(&Log{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("logs.id desc").Limit(num).Offset(startIdx).Find(&logs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	formatUserLogs(logs)
+	return logs, total, err
+}
+
 func SearchAllLogs(keyword string) (logs []*Log, err error) {
 	err = LOG_DB.Where("type = ? or content LIKE ?", keyword, keyword+"%").Order("id desc").Limit(common.MaxRecentItems).Find(&logs).Error
 	return logs, err
@@ -350,8 +421,9 @@ func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelNa
 		rpmTpmQuery = rpmTpmQuery.Where(logGroupCol+" = ?", group)
 	}
 
-	tx = tx.Where("type = ?", LogTypeConsume)
-	rpmTpmQuery = rpmTpmQuery.Where("type = ?", LogTypeConsume)
+	// 命中缓存的请求同样计费，一并计入统计
+	tx = tx.Where("type IN ?", []int{LogTypeConsume, LogTypeCacheHit})
+	rpmTpmQuery = rpmTpmQuery.Where("type IN ?", []int{LogTypeConsume, LogTypeCacheHit})
 
 	// 只统计最近60秒的rpm和tpm
 	rpmTpmQuery = rpmTpmQuery.Where("created_at >= ?", time.Now().Add(-60*time.Second).Unix())
@@ -359,6 +431,14 @@ func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelNa
 	// 执行查询
 	tx.Scan(&stat)
 	rpmTpmQuery.Scan(&stat)
+	// 已删除的日志从按天汇总数据中补足
+	stat.Quota += int(SumRollupQuota(startTimestamp, endTimestamp, LogRollupFilter{
+		Username:  username,
+		TokenName: tokenName,
+		ModelName: modelName,
+		ChannelId: channel,
+		Group:     group,
+	}))
 
 	return stat
 }
@@ -0,0 +1,212 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 日志按天汇总：消费与错误日志按用户、令牌、渠道、模型、分组汇总到 log_rollups，
+// 删除历史日志前先完成汇总，统计接口对已没有原始日志的日期改读汇总数据。
+// 日期按服务器时区划分。
+
+type LogRollup struct {
+	Id               int    `json:"id"`
+	Day              int64  `json:"day" gorm:"bigint;index:idx_log_rollup_day_user,priority:1"` // 当天零点
+	UserId           int    `json:"user_id" gorm:"index:idx_log_rollup_day_user,priority:2"`
+	Username         string `json:"username" gorm:"index;default:''"`
+	TokenId          int    `json:"token_id" gorm:"index"`
+	TokenName        string `json:"token_name" gorm:"default:''"`
+	ChannelId        int    `json:"channel_id" gorm:"index"`
+	ModelName        string `json:"model_name" gorm:"index;default:''"`
+	Group            string `json:"group" gorm:"column:group_name;default:''"`
+	Quota            int64  `json:"quota"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	RequestCount     int64  `json:"request_count"` // 消费与命中缓存的请求数
+	ErrorCount       int64  `json:"error_count"`
+	TotalUseTime     int64  `json:"total_use_time"` // 请求耗时合计（秒），平均耗时为 TotalUseTime / RequestCount
+	UpstreamCost     int64  `json:"upstream_cost"`
+	CostedCount      int64  `json:"costed_count"` // 记录了上游成本的请求数
+	// UncostedQuota 未记录上游成本的请求的扣费额度合计，对账时以此近似这部分请求的上游花费
+	UncostedQuota int64 `json:"uncosted_quota"`
+}
+
+// LogRollupFilter 汇总数据的筛选条件，零值表示不筛选
+type LogRollupFilter struct {
+	UserId    int
+	Username  string
+	TokenName string
+	TokenId   int
+	ModelName string // 与原始日志统计一致，使用 like 匹配
+	ChannelId int
+	Group     string
+}
+
+var logRollupLock sync.Mutex
+
+func logRollupDayStart(timestamp int64) int64 {
+	t := time.Unix(timestamp, 0)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Unix()
+}
+
+func logRollupNextDay(day int64) int64 {
+	t := time.Unix(day, 0)
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()).Unix()
+}
+
+// AlignLogRollupDay 将时间戳向前取整到当天零点，删除日志时按整天删除，避免某天只有部分日志被汇总
+func AlignLogRollupDay(timestamp int64) int64 {
+	return logRollupDayStart(timestamp)
+}
+
+// RollupLogs 汇总 before 所在日期之前尚未汇总的日志，返回本次汇总的天数
+func RollupLogs(ctx context.Context, before int64) (int, error) {
+	logRollupLock.Lock()
+	defer logRollupLock.Unlock()
+
+	cutoff := logRollupDayStart(before)
+	var lastDay int64
+	if err := LOG_DB.Model(&LogRollup{}).Select("COALESCE(MAX(day), 0)").Scan(&lastDay).Error; err != nil {
+		return 0, err
+	}
+	var next int64
+	if lastDay != 0 {
+		next = logRollupNextDay(lastDay)
+	}
+	days := 0
+	for next < cutoff {
+		if err := ctx.Err(); err != nil {
+			return days, err
+		}
+		// 跳过没有日志的日期
+		var earliest int64
+		err := LOG_DB.Table("logs").Select("COALESCE(MIN(created_at), 0)").
+			Where("created_at >= ? AND created_at < ? AND type IN ?", next, cutoff, logRollupTypes()).
+			Scan(&earliest).Error
+		if err != nil {
+			return days, err
+		}
+		if earliest == 0 {
+			break
+		}
+		day := logRollupDayStart(earliest)
+		if err := rollupLogDay(day); err != nil {
+			return days, err
+		}
+		days++
+		next = logRollupNextDay(day)
+	}
+	return days, nil
+}
+
+func logRollupTypes() []int {
+	return []int{LogTypeConsume, LogTypeCacheHit, LogTypeError}
+}
+
+// rollupLogDay 重新汇总某一天的日志，已有的汇总数据会被替换
+func rollupLogDay(day int64) error {
+	var rows []*LogRollup
+	err := LOG_DB.Table("logs").
+		Select(fmt.Sprintf(`user_id, username, token_id, token_name, channel_id, model_name, %s as group_name,
+			COALESCE(SUM(CASE WHEN type IN (%d, %d) THEN quota ELSE 0 END), 0) as quota,
+			COALESCE(SUM(CASE WHEN type IN (%d, %d) THEN prompt_tokens ELSE 0 END), 0) as prompt_tokens,
+			COALESCE(SUM(CASE WHEN type IN (%d, %d) THEN completion_tokens ELSE 0 END), 0) as completion_tokens,
+			COALESCE(SUM(CASE WHEN type IN (%d, %d) THEN 1 ELSE 0 END), 0) as request_count,
+			COALESCE(SUM(CASE WHEN type = %d THEN 1 ELSE 0 END), 0) as error_count,
+			COALESCE(SUM(CASE WHEN type IN (%d, %d) THEN use_time ELSE 0 END), 0) as total_use_time,
+			COALESCE(SUM(CASE WHEN type IN (%d, %d) THEN upstream_cost ELSE 0 END), 0) as upstream_cost,
+			COALESCE(SUM(CASE WHEN type IN (%d, %d) AND upstream_cost > 0 THEN 1 ELSE 0 END), 0) as costed_count,
+			COALESCE(SUM(CASE WHEN type IN (%d, %d) AND upstream_cost <= 0 THEN quota ELSE 0 END), 0) as uncosted_quota`,
+			logGroupCol,
+			LogTypeConsume, LogTypeCacheHit,
+			LogTypeConsume, LogTypeCacheHit,
+			LogTypeConsume, LogTypeCacheHit,
+			LogTypeConsume, LogTypeCacheHit,
+			LogTypeError,
+			LogTypeConsume, LogTypeCacheHit,
+			LogTypeConsume, LogTypeCacheHit,
+			LogTypeConsume, LogTypeCacheHit,
+			LogTypeConsume, LogTypeCacheHit)).
+		Where("created_at >= ? AND created_at < ? AND type IN ?", day, logRollupNextDay(day), logRollupTypes()).
+		Group("user_id, username, token_id, token_name, channel_id, model_name, " + logGroupCol).
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		row.Id = 0
+		row.Day = day
+	}
+	return LOG_DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("day = ?", day).Delete(&LogRollup{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 100).Error
+	})
+}
+
+// logRollupBoundary 返回最早的原始日志所在日的零点，此前的日期只能从汇总数据读取；没有原始日志时返回当前时间
+func logRollupBoundary() int64 {
+	var earliest int64
+	LOG_DB.Table("logs").Select("COALESCE(MIN(created_at), 0)").Scan(&earliest)
+	if earliest == 0 {
+		return time.Now().Unix()
+	}
+	return logRollupDayStart(earliest)
+}
+
+// logRollupRangeQuery 返回查询范围中原始日志已被删除部分的汇总数据查询，范围内原始日志完整时返回 false。
+// 汇总数据按整天统计，起止时间落在某天中间时包含该天全部数据
+func logRollupRangeQuery(startTimestamp int64, endTimestamp int64, filter LogRollupFilter) (*gorm.DB, bool) {
+	boundary := logRollupBoundary()
+	if startTimestamp != 0 && startTimestamp >= boundary {
+		return nil, false
+	}
+	tx := LOG_DB.Model(&LogRollup{}).Where("day < ?", boundary)
+	if startTimestamp != 0 {
+		tx = tx.Where("day >= ?", logRollupDayStart(startTimestamp))
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("day <= ?", endTimestamp)
+	}
+	if filter.UserId != 0 {
+		tx = tx.Where("user_id = ?", filter.UserId)
+	}
+	if filter.Username != "" {
+		tx = tx.Where("username = ?", filter.Username)
+	}
+	if filter.TokenName != "" {
+		tx = tx.Where("token_name = ?", filter.TokenName)
+	}
+	if filter.TokenId != 0 {
+		tx = tx.Where("token_id = ?", filter.TokenId)
+	}
+	if filter.ModelName != "" {
+		tx = tx.Where("model_name like ?", filter.ModelName)
+	}
+	if filter.ChannelId != 0 {
+		tx = tx.Where("channel_id = ?", filter.ChannelId)
+	}
+	if filter.Group != "" {
+		tx = tx.Where("group_name = ?", filter.Group)
+	}
+	return tx, true
+}
+
+// SumRollupQuota 汇总数据中的消耗额度，用于补足已删除日志的统计
+func SumRollupQuota(startTimestamp int64, endTimestamp int64, filter LogRollupFilter) int64 {
+	tx, ok := logRollupRangeQuery(startTimestamp, endTimestamp, filter)
+	if !ok {
+		return 0
+	}
+	var quota int64
+	tx.Select("COALESCE(SUM(quota), 0)").Scan(&quota)
+	return quota
+}
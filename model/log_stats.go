@@ -1,6 +1,7 @@
 package model
 
 import (
+	"sort"
 	"time"
 )
 
@@ -50,7 +51,33 @@ func GetTokenConsumptionSummary(tokenId int, start, end int64) (*ConsumptionSumm
 		Where("token_id = ? AND type = ? AND created_at >= ? AND created_at <= ?",
 			tokenId, LogTypeConsume, start, end).
 		Scan(&summary).Error
-	return &summary, err
+	if err != nil {
+		return &summary, err
+	}
+	if tx, ok := logRollupRangeQuery(start, end, LogRollupFilter{TokenId: tokenId}); ok {
+		var rollup struct {
+			Quota    int64
+			Requests int64
+			Tokens   int64
+			UseTime  int64
+		}
+		err = tx.Select(`
+			COALESCE(SUM(quota), 0) as quota,
+			COALESCE(SUM(request_count), 0) as requests,
+			COALESCE(SUM(prompt_tokens + completion_tokens), 0) as tokens,
+			COALESCE(SUM(total_use_time), 0) as use_time
+		`).Scan(&rollup).Error
+		if err != nil {
+			return &summary, err
+		}
+		if requests := summary.TotalRequests + rollup.Requests; requests > 0 {
+			summary.AvgLatencyMs = (summary.AvgLatencyMs*float64(summary.TotalRequests) + float64(rollup.UseTime)) / float64(requests)
+		}
+		summary.TotalQuota += rollup.Quota
+		summary.TotalRequests += rollup.Requests
+		summary.TotalTokens += rollup.Tokens
+	}
+	return &summary, nil
 }
 
 // GetTokenConsumptionByModel 按模型分组统计
@@ -73,6 +100,21 @@ func GetTokenConsumptionByModel(tokenId int, start, end int64) ([]ModelConsumpti
 		return nil, err
 	}
 
+	// 已删除的日志从按天汇总数据中补足
+	if tx, ok := logRollupRangeQuery(start, end, LogRollupFilter{TokenId: tokenId}); ok {
+		var rollups []ModelConsumption
+		err = tx.Select(`
+			model_name,
+			COALESCE(SUM(quota), 0) as quota,
+			COALESCE(SUM(request_count), 0) as requests,
+			COALESCE(SUM(prompt_tokens + completion_tokens), 0) as tokens
+		`).Group("model_name").Scan(&rollups).Error
+		if err != nil {
+			return nil, err
+		}
+		results = mergeModelConsumption(results, rollups)
+	}
+
 	// 计算百分比
 	var total int64
 	for _, r := range results {
@@ -87,6 +129,28 @@ func GetTokenConsumptionByModel(tokenId int, start, end int64) ([]ModelConsumpti
 	return results, nil
 }
 
+// mergeModelConsumption 合并原始日志与汇总数据中同一模型的统计，按额度降序排列
+func mergeModelConsumption(results []ModelConsumption, rollups []ModelConsumption) []ModelConsumption {
+	index := make(map[string]int, len(results))
+	for i, r := range results {
+		index[r.ModelName] = i
+	}
+	for _, r := range rollups {
+		if i, ok := index[r.ModelName]; ok {
+			results[i].Quota += r.Quota
+			results[i].Requests += r.Requests
+			results[i].Tokens += r.Tokens
+			continue
+		}
+		index[r.ModelName] = len(results)
+		results = append(results, r)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Quota > results[j].Quota
+	})
+	return results
+}
+
 // GetTokenConsumptionByDay 按日期分组统计
 func GetTokenConsumptionByDay(tokenId int, start, end int64) ([]DailyConsumption, error) {
 	var results []DailyConsumption
@@ -102,7 +166,38 @@ func GetTokenConsumptionByDay(tokenId int, start, end int64) ([]DailyConsumption
 		Group("TO_CHAR(TO_TIMESTAMP(created_at), 'YYYY-MM-DD')").
 		Order("date ASC").
 		Scan(&results).Error
-	return results, err
+	if err != nil {
+		return results, err
+	}
+	// 汇总数据只覆盖最早的原始日志之前的日期，排在原始日志统计结果之前
+	if tx, ok := logRollupRangeQuery(start, end, LogRollupFilter{TokenId: tokenId}); ok {
+		var rollups []struct {
+			Day      int64
+			Quota    int64
+			Requests int64
+			Tokens   int64
+		}
+		err = tx.Select(`
+			day,
+			COALESCE(SUM(quota), 0) as quota,
+			COALESCE(SUM(request_count), 0) as requests,
+			COALESCE(SUM(prompt_tokens + completion_tokens), 0) as tokens
+		`).Group("day").Order("day ASC").Scan(&rollups).Error
+		if err != nil {
+			return results, err
+		}
+		days := make([]DailyConsumption, 0, len(rollups)+len(results))
+		for _, r := range rollups {
+			days = append(days, DailyConsumption{
+				Date:     time.Unix(r.Day, 0).Format("2006-01-02"),
+				Quota:    r.Quota,
+				Requests: r.Requests,
+				Tokens:   r.Tokens,
+			})
+		}
+		results = append(days, results...)
+	}
+	return results, nil
 }
 
 // GetTokenConsumptionByHour 按小时分组统计
@@ -0,0 +1,58 @@
+-- 多 Key 渠道按用量选择 key 并计入一次请求，选择与计数在同一脚本内完成，避免并发请求读到相同用量后选中同一个 key
+-- KEYS[1]: 当前分钟计数
+-- KEYS[2]: 上一分钟计数
+-- KEYS[3]: 累计用量与最近使用时间
+-- ARGV[1]: 选择模式，lru 或 usage
+-- ARGV[2]: 当前毫秒时间戳
+-- ARGV[3]: 上一分钟计数的权重
+-- ARGV[4]: usage 模式下视为接近上限的用量比例
+-- ARGV[5]: 分钟计数过期时间（毫秒）
+-- ARGV[6...]: 每个候选 key 依次为 key index、RPM 上限、TPM 上限，上限为 0 表示不限制
+
+local mode = ARGV[1]
+local now = tonumber(ARGV[2])
+local previousWeight = tonumber(ARGV[3])
+local threshold = tonumber(ARGV[4])
+local ttl = tonumber(ARGV[5])
+
+local function rate(field)
+    local current = tonumber(redis.call('HGET', KEYS[1], field) or '0')
+    local previous = tonumber(redis.call('HGET', KEYS[2], field) or '0')
+    return math.floor(current + previous * previousWeight)
+end
+
+local selected = nil
+local selectedScore = 0
+for i = 6, #ARGV, 3 do
+    local idx = ARGV[i]
+    local score
+    if mode == 'lru' then
+        score = tonumber(redis.call('HGET', KEYS[3], 'l:' .. idx) or '0')
+    else
+        local rpm = rate('r:' .. idx)
+        local rpmLimit = tonumber(ARGV[i + 1])
+        local tpmLimit = tonumber(ARGV[i + 2])
+        score = -1
+        if rpmLimit > 0 then
+            score = math.max(score, rpm / rpmLimit)
+        end
+        if tpmLimit > 0 then
+            score = math.max(score, rate('t:' .. idx) / tpmLimit)
+        end
+        if score < 0 then
+            score = threshold + rpm
+        elseif score >= threshold then
+            score = 1e9 + score
+        end
+    end
+    if selected == nil or score < selectedScore then
+        selected = idx
+        selectedScore = score
+    end
+end
+
+redis.call('HINCRBY', KEYS[1], 'r:' .. selected, 1)
+redis.call('PEXPIRE', KEYS[1], ttl)
+redis.call('HINCRBY', KEYS[3], 'r:' .. selected, 1)
+redis.call('HSET', KEYS[3], 'l:' .. selected, now)
+return tonumber(selected)
@@ -255,6 +255,7 @@ func migrateDB() error {
 		&Redemption{},
 		&Ability{},
 		&Log{},
+		&LogRollup{},
 		&Midjourney{},
 		&TopUp{},
 		&QuotaData{},
@@ -265,11 +266,27 @@ func migrateDB() error {
 		&Setup{},
 		&TwoFA{},
 		&TwoFABackupCode{},
+		&File{},
+		&FineTunedModel{},
+		&Plan{},
+		&Subscription{},
+		&QuotaLedger{},
+		&ChannelBalanceSnapshot{},
+		&ChannelReconciliation{},
+		&CreditLot{},
+		&Statement{},
+		&Organization{},
+		&OrganizationMember{},
+		&IdempotencyRecord{},
+		&PayloadCapture{},
 	)
 	if err != nil {
 		return err
 	}
-	return nil
+	if err := seedQuotaLedgerOpeningBalances(); err != nil {
+		return err
+	}
+	return seedCreditLots()
 }
 
 func migrateDBFast() error {
@@ -287,6 +304,7 @@ func migrateDBFast() error {
 		{&Redemption{}, "Redemption"},
 		{&Ability{}, "Ability"},
 		{&Log{}, "Log"},
+		{&LogRollup{}, "LogRollup"},
 		{&Midjourney{}, "Midjourney"},
 		{&TopUp{}, "TopUp"},
 		{&QuotaData{}, "QuotaData"},
@@ -297,6 +315,19 @@ func migrateDBFast() error {
 		{&Setup{}, "Setup"},
 		{&TwoFA{}, "TwoFA"},
 		{&TwoFABackupCode{}, "TwoFABackupCode"},
+		{&File{}, "File"},
+		{&FineTunedModel{}, "FineTunedModel"},
+		{&Plan{}, "Plan"},
+		{&Subscription{}, "Subscription"},
+		{&QuotaLedger{}, "QuotaLedger"},
+		{&ChannelBalanceSnapshot{}, "ChannelBalanceSnapshot"},
+		{&ChannelReconciliation{}, "ChannelReconciliation"},
+		{&CreditLot{}, "CreditLot"},
+		{&Statement{}, "Statement"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
+		{&IdempotencyRecord{}, "IdempotencyRecord"},
+		{&PayloadCapture{}, "PayloadCapture"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -327,7 +358,7 @@ func migrateDBFast() error {
 
 func migrateLOGDB() error {
 	var err error
-	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
+	if err = LOG_DB.AutoMigrate(&Log{}, &LogRollup{}); err != nil {
 		return err
 	}
 	return nil
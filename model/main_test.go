@@ -0,0 +1,38 @@
+package model
+
+import (
+	"one-api/common"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// 测试不依赖 Redis，状态均保存在内存中
+func TestMain(m *testing.M) {
+	common.RedisEnabled = false
+	os.Exit(m.Run())
+}
+
+// setupTestDB 使用临时 SQLite 文件作为主库与日志库，并迁移给定的表，测试结束后恢复原连接
+func setupTestDB(t *testing.T, models ...interface{}) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")+"?_pragma=busy_timeout(5000)"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	savedDB, savedLogDB, savedUsingSQLite := DB, LOG_DB, common.UsingSQLite
+	DB, LOG_DB, common.UsingSQLite = db, db, true
+	initCol()
+	t.Cleanup(func() {
+		DB, LOG_DB, common.UsingSQLite = savedDB, savedLogDB, savedUsingSQLite
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 利润报表：按渠道、模型、分组或日期汇总消费日志中的收入（实际扣费额度）与上游成本，
+// 原始日志已删除的日期改读日志汇总
+
+const (
+	MarginGroupByChannel = "channel"
+	MarginGroupByModel   = "model"
+	MarginGroupByGroup   = "group"
+	MarginGroupByDay     = "day"
+)
+
+type MarginReportItem struct {
+	Key         string  `json:"key"`
+	ChannelId   int     `json:"channel_id,omitempty"`
+	ChannelName string  `json:"channel_name,omitempty"`
+	Revenue     int64   `json:"revenue"`
+	Cost        int64   `json:"cost"`
+	Margin      int64   `json:"margin"`
+	MarginRate  float64 `json:"margin_rate"` // Margin / Revenue，收入为 0 时为 0
+	Count       int64   `json:"count"`
+	CostedCount int64   `json:"costed_count"` // 记录了上游成本的请求数，用于判断成本价覆盖情况
+}
+
+// GetMarginReport 汇总 [startTimestamp, endTimestamp] 内的收入、成本与利润，按日汇总时使用服务器时区
+func GetMarginReport(groupBy string, startTimestamp int64, endTimestamp int64) ([]*MarginReportItem, error) {
+	var keyExpr, rollupKeyExpr string
+	switch groupBy {
+	case MarginGroupByChannel:
+		keyExpr = "channel_id"
+		rollupKeyExpr = "channel_id"
+	case MarginGroupByModel:
+		keyExpr = "model_name"
+		rollupKeyExpr = "model_name"
+	case MarginGroupByGroup:
+		keyExpr = logGroupCol
+		rollupKeyExpr = "group_name"
+	case MarginGroupByDay:
+		_, offset := time.Now().Zone()
+		keyExpr = fmt.Sprintf("(created_at + %d) - ((created_at + %d) %% 86400) - %d", offset, offset, offset)
+		rollupKeyExpr = "day"
+	default:
+		return nil, errors.New("不支持的汇总维度")
+	}
+
+	var rows []marginReportRow
+	tx := LOG_DB.Table("logs").
+		Select(keyExpr+" as group_key, sum(quota) as revenue, sum(upstream_cost) as cost, count(*) as count, "+
+			"sum(case when upstream_cost > 0 then 1 else 0 end) as costed_count").
+		Where("type IN ?", []int{LogTypeConsume, LogTypeCacheHit})
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	if err := tx.Group("group_key").Order("group_key").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if rollupTx, ok := logRollupRangeQuery(startTimestamp, endTimestamp, LogRollupFilter{}); ok {
+		var rollupRows []marginReportRow
+		err := rollupTx.Select(rollupKeyExpr + " as group_key, sum(quota) as revenue, sum(upstream_cost) as cost, " +
+			"sum(request_count) as count, sum(costed_count) as costed_count").
+			Group("group_key").Scan(&rollupRows).Error
+		if err != nil {
+			return nil, err
+		}
+		rows = mergeMarginReportRows(rows, rollupRows)
+	}
+
+	items := make([]*MarginReportItem, 0, len(rows))
+	channelIds := make([]int, 0)
+	for _, row := range rows {
+		if row.Count == 0 {
+			continue
+		}
+		item := &MarginReportItem{
+			Key:         row.GroupKey,
+			Revenue:     row.Revenue,
+			Cost:        row.Cost,
+			Margin:      row.Revenue - row.Cost,
+			Count:       row.Count,
+			CostedCount: row.CostedCount,
+		}
+		if row.Revenue != 0 {
+			item.MarginRate = float64(item.Margin) / float64(row.Revenue)
+		}
+		if groupBy == MarginGroupByChannel {
+			fmt.Sscanf(row.GroupKey, "%d", &item.ChannelId)
+			channelIds = append(channelIds, item.ChannelId)
+		}
+		items = append(items, item)
+	}
+
+	if len(channelIds) > 0 {
+		var channels []struct {
+			Id   int    `gorm:"column:id"`
+			Name string `gorm:"column:name"`
+		}
+		if err := DB.Table("channels").Select("id, name").Where("id IN ?", channelIds).Find(&channels).Error; err != nil {
+			return nil, err
+		}
+		names := make(map[int]string, len(channels))
+		for _, channel := range channels {
+			names[channel.Id] = channel.Name
+		}
+		for _, item := range items {
+			item.ChannelName = names[item.ChannelId]
+		}
+	}
+	return items, nil
+}
+
+type marginReportRow struct {
+	GroupKey    string
+	Revenue     int64
+	Cost        int64
+	Count       int64
+	CostedCount int64
+}
+
+// mergeMarginReportRows 合并原始日志与日志汇总中相同维度的数据，结果按维度排序
+func mergeMarginReportRows(rows []marginReportRow, rollupRows []marginReportRow) []marginReportRow {
+	index := make(map[string]int, len(rows))
+	for i, row := range rows {
+		index[row.GroupKey] = i
+	}
+	for _, row := range rollupRows {
+		if i, ok := index[row.GroupKey]; ok {
+			rows[i].Revenue += row.Revenue
+			rows[i].Cost += row.Cost
+			rows[i].Count += row.Count
+			rows[i].CostedCount += row.CostedCount
+			continue
+		}
+		index[row.GroupKey] = len(rows)
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].GroupKey < rows[j].GroupKey
+	})
+	return rows
+}
@@ -0,0 +1,403 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strings"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+// 组织：成员共享一个额度池，使用组织令牌的请求从额度池扣费，并受成员各自的消费上限约束
+
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+const (
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+)
+
+type Organization struct {
+	Id        int    `json:"id"`
+	Name      string `json:"name" gorm:"type:varchar(64)"`
+	OwnerId   int    `json:"owner_id" gorm:"index"`
+	Quota     int    `json:"quota" gorm:"default:0"`
+	UsedQuota int    `json:"used_quota" gorm:"default:0"`
+	Status    int    `json:"status" gorm:"default:1"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+type OrganizationMember struct {
+	Id     int    `json:"id"`
+	OrgId  int    `json:"org_id" gorm:"uniqueIndex:idx_org_member,priority:1"`
+	UserId int    `json:"user_id" gorm:"uniqueIndex:idx_org_member,priority:2;index"`
+	Role   string `json:"role" gorm:"type:varchar(16)"`
+	// QuotaLimit 成员的消费上限，0 表示不限制；LimitPeriod 为空时为累计上限，否则按 day/week/month 周期重置
+	QuotaLimit  int    `json:"quota_limit" gorm:"default:0"`
+	LimitPeriod string `json:"limit_period" gorm:"type:varchar(16);default:''"`
+	UsedQuota   int    `json:"used_quota" gorm:"default:0"`
+	PeriodStart int64  `json:"period_start" gorm:"bigint;default:0"` // UsedQuota 所属周期的开始时间
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+
+	Username string `json:"username" gorm:"-:all"`
+}
+
+func IsValidOrgRole(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin || role == OrgRoleMember
+}
+
+// CanManage 所有者与管理员可以管理成员与额度池
+func (member *OrganizationMember) CanManage() bool {
+	return member.Role == OrgRoleOwner || member.Role == OrgRoleAdmin
+}
+
+// ValidateOrgMemberLimit 校验成员消费上限配置
+func ValidateOrgMemberLimit(quotaLimit int, period string) error {
+	if quotaLimit < 0 {
+		return errors.New("成员消费上限不能为负数")
+	}
+	if period != "" && !IsValidPlanPeriod(period) {
+		return errors.New("成员消费上限的重置周期无效")
+	}
+	return nil
+}
+
+// orgMemberPeriodStart 返回当前上限周期的开始时间，累计上限时为 0
+func orgMemberPeriodStart(period string, now time.Time) int64 {
+	if period == "" {
+		return 0
+	}
+	start, _ := TokenBudgetPeriodBounds(period, "", now)
+	return start
+}
+
+// GetUsed 返回成员当前周期已用额度，记录属于以往周期时视为 0
+func (member *OrganizationMember) GetUsed(now time.Time) int {
+	if member.PeriodStart != orgMemberPeriodStart(member.LimitPeriod, now) {
+		return 0
+	}
+	return max(member.UsedQuota, 0)
+}
+
+// GetLimitRemain 返回成员剩余可用额度，未设置上限时返回 -1
+func (member *OrganizationMember) GetLimitRemain(now time.Time) int {
+	if member.QuotaLimit <= 0 {
+		return -1
+	}
+	return max(member.QuotaLimit-member.GetUsed(now), 0)
+}
+
+func validateOrgName(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 64 {
+		return errors.New("组织名称长度必须在1-64之间")
+	}
+	return nil
+}
+
+// CreateOrganization 创建组织，创建者成为所有者
+func CreateOrganization(name string, ownerId int) (*Organization, error) {
+	if err := validateOrgName(name); err != nil {
+		return nil, err
+	}
+	now := common.GetTimestamp()
+	org := &Organization{
+		Name:      strings.TrimSpace(name),
+		OwnerId:   ownerId,
+		Status:    OrganizationStatusEnabled,
+		CreatedAt: now,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrgId:     org.Id,
+			UserId:    ownerId,
+			Role:      OrgRoleOwner,
+			CreatedAt: now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	if id == 0 {
+		return nil, errors.New("组织 id 为空！")
+	}
+	var org Organization
+	if err := DB.First(&org, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("组织不存在")
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (org *Organization) Update() error {
+	if err := validateOrgName(org.Name); err != nil {
+		return err
+	}
+	return DB.Model(org).Select("name", "status").Updates(org).Error
+}
+
+func GetAllOrganizations(keyword string, startIdx int, num int) (orgs []*Organization, total int64, err error) {
+	tx := DB.Model(&Organization{})
+	if keyword != "" {
+		tx = tx.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&orgs).Error
+	return orgs, total, err
+}
+
+// UserOrganization 用户所属组织及其角色
+type UserOrganization struct {
+	Organization
+	Role        string `json:"role"`
+	QuotaLimit  int    `json:"quota_limit"`
+	LimitPeriod string `json:"limit_period"`
+	MemberUsed  int    `json:"member_used"`
+}
+
+func GetUserOrganizations(userId int) ([]*UserOrganization, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("user_id = ?", userId).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*UserOrganization, 0, len(members))
+	now := time.Now()
+	for _, member := range members {
+		org, err := GetOrganizationById(member.OrgId)
+		if err != nil {
+			continue
+		}
+		result = append(result, &UserOrganization{
+			Organization: *org,
+			Role:         member.Role,
+			QuotaLimit:   member.QuotaLimit,
+			LimitPeriod:  member.LimitPeriod,
+			MemberUsed:   member.GetUsed(now),
+		})
+	}
+	return result, nil
+}
+
+func GetUserIdByUsername(username string) (int, error) {
+	var user User
+	if username == "" {
+		return 0, errors.New("用户不存在")
+	}
+	if err := DB.Select("id").Where("username = ?", username).Limit(1).Find(&user).Error; err != nil {
+		return 0, err
+	}
+	if user.Id == 0 {
+		return 0, errors.New("用户不存在")
+	}
+	return user.Id, nil
+}
+
+func GetOrgMember(orgId int, userId int) (*OrganizationMember, error) {
+	var member OrganizationMember
+	err := DB.Where("org_id = ? AND user_id = ?", orgId, userId).Limit(1).Find(&member).Error
+	if err != nil {
+		return nil, err
+	}
+	if member.Id == 0 {
+		return nil, errors.New("不是该组织的成员")
+	}
+	return &member, nil
+}
+
+func GetOrgMembers(orgId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("org_id = ?", orgId).Order("id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, member := range members {
+		member.Username, _ = GetUsernameById(member.UserId, false)
+		member.UsedQuota = member.GetUsed(now)
+	}
+	return members, nil
+}
+
+func AddOrgMember(member *OrganizationMember) error {
+	if !IsValidOrgRole(member.Role) || member.Role == OrgRoleOwner {
+		return errors.New("无效的成员角色")
+	}
+	if err := ValidateOrgMemberLimit(member.QuotaLimit, member.LimitPeriod); err != nil {
+		return err
+	}
+	if _, err := GetOrgMember(member.OrgId, member.UserId); err == nil {
+		return errors.New("该用户已是组织成员")
+	}
+	member.UsedQuota = 0
+	member.PeriodStart = orgMemberPeriodStart(member.LimitPeriod, time.Now())
+	member.CreatedAt = common.GetTimestamp()
+	return DB.Create(member).Error
+}
+
+// UpdateOrgMember 更新成员角色与消费上限，resetUsed 为 true 时清零已用额度
+func UpdateOrgMember(member *OrganizationMember, resetUsed bool) error {
+	if !IsValidOrgRole(member.Role) {
+		return errors.New("无效的成员角色")
+	}
+	if err := ValidateOrgMemberLimit(member.QuotaLimit, member.LimitPeriod); err != nil {
+		return err
+	}
+	fields := []string{"role", "quota_limit", "limit_period"}
+	if resetUsed {
+		member.UsedQuota = 0
+		member.PeriodStart = orgMemberPeriodStart(member.LimitPeriod, time.Now())
+		fields = append(fields, "used_quota", "period_start")
+	}
+	return DB.Model(member).Select(fields).Updates(member).Error
+}
+
+// RemoveOrgMember 移除成员，并禁用其创建的组织令牌
+func RemoveOrgMember(member *OrganizationMember) error {
+	if member.Role == OrgRoleOwner {
+		return errors.New("不能移除组织所有者")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(member).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Token{}).Where("org_id = ? AND user_id = ?", member.OrgId, member.UserId).
+			Update("status", common.TokenStatusDisabled).Error
+	})
+	if err != nil {
+		return err
+	}
+	invalidateOrgMemberTokens(member.OrgId, member.UserId)
+	return nil
+}
+
+// invalidateOrgMemberTokens 清除令牌缓存，使禁用立即生效
+func invalidateOrgMemberTokens(orgId int, userId int) {
+	if !common.RedisEnabled {
+		return
+	}
+	var keys []string
+	if err := DB.Model(&Token{}).Where("org_id = ? AND user_id = ?", orgId, userId).Pluck("key", &keys).Error; err != nil {
+		return
+	}
+	gopool.Go(func() {
+		for _, key := range keys {
+			if err := cacheDeleteToken(key); err != nil {
+				common.SysLog("failed to delete token cache: " + err.Error())
+			}
+		}
+	})
+}
+
+// AdjustOrganizationQuota 管理员调整组织额度池，delta 为负时扣减
+func AdjustOrganizationQuota(orgId int, delta int) error {
+	return DB.Model(&Organization{}).Where("id = ?", orgId).Update("quota", gorm.Expr("quota + ?", delta)).Error
+}
+
+// TransferQuotaToOrganization 将用户个人余额转入组织额度池
+func TransferQuotaToOrganization(userId int, orgId int, quota int) error {
+	if quota <= 0 {
+		return errors.New("转入额度必须大于 0")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 余额检查与扣减在同一条条件更新中完成，避免并发转入时扣成负数
+		result := tx.Model(&User{}).Where("id = ? AND quota >= ?", userId, quota).Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("余额不足")
+		}
+		if err := recordQuotaLedgerTx(tx, userId, -quota, LedgerRef{
+			Reason:  LedgerReasonOrgTransfer,
+			RefType: LedgerRefOrganization,
+			RefId:   fmt.Sprintf("%d", orgId),
+		}); err != nil {
+			return err
+		}
+		return tx.Model(&Organization{}).Where("id = ?", orgId).Update("quota", gorm.Expr("quota + ?", quota)).Error
+	})
+	if err != nil {
+		return err
+	}
+	gopool.Go(func() {
+		if err := cacheDecrUserQuota(userId, int64(quota)); err != nil {
+			common.SysLog("failed to decrease user quota: " + err.Error())
+		}
+	})
+	return nil
+}
+
+// GetOrgAvailableQuota 返回成员通过组织令牌可用的额度：组织余额与成员剩余上限中的较小值
+func GetOrgAvailableQuota(orgId int, userId int) (int, error) {
+	org, err := GetOrganizationById(orgId)
+	if err != nil {
+		return 0, err
+	}
+	if org.Status != OrganizationStatusEnabled {
+		return 0, errors.New("组织已被禁用")
+	}
+	member, err := GetOrgMember(orgId, userId)
+	if err != nil {
+		return 0, err
+	}
+	available := org.Quota
+	if remain := member.GetLimitRemain(time.Now()); remain >= 0 {
+		available = min(available, remain)
+	}
+	return available, nil
+}
+
+// ConsumeOrganizationQuota 从组织额度池扣费并累加成员已用额度，delta 为负时退还
+func ConsumeOrganizationQuota(orgId int, userId int, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Organization{}).Where("id = ?", orgId).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota - ?", delta),
+			"used_quota": gorm.Expr("used_quota + ?", delta),
+		}).Error
+		if err != nil {
+			return err
+		}
+		var member OrganizationMember
+		if err := tx.Where("org_id = ? AND user_id = ?", orgId, userId).Limit(1).Find(&member).Error; err != nil {
+			return err
+		}
+		if member.Id == 0 {
+			// 成员已被移除，只记入组织
+			return nil
+		}
+		start := orgMemberPeriodStart(member.LimitPeriod, time.Now())
+		// 进入新周期时清零
+		err = tx.Model(&OrganizationMember{}).Where("id = ? AND period_start < ?", member.Id, start).Updates(map[string]interface{}{
+			"used_quota":   0,
+			"period_start": start,
+		}).Error
+		if err != nil {
+			return err
+		}
+		expr := gorm.Expr("used_quota + ?", delta)
+		if delta < 0 {
+			expr = gorm.Expr("CASE WHEN used_quota > ? THEN used_quota - ? ELSE 0 END", -delta, -delta)
+		}
+		return tx.Model(&OrganizationMember{}).Where("id = ?", member.Id).Update("used_quota", expr).Error
+	})
+}
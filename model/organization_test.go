@@ -0,0 +1,120 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConsumeOrganizationQuota(t *testing.T) {
+	tests := []struct {
+		name         string
+		member       *OrganizationMember // nil 表示成员已被移除
+		deltas       []int
+		wantOrgQuota int
+		wantOrgUsed  int
+		wantMember   int
+	}{
+		{
+			name:         "consume then refund",
+			member:       &OrganizationMember{},
+			deltas:       []int{100, -30},
+			wantOrgQuota: 930,
+			wantOrgUsed:  170,
+			wantMember:   70,
+		},
+		{
+			name:         "refund beyond member usage clamps to zero",
+			member:       &OrganizationMember{UsedQuota: 20},
+			deltas:       []int{-50},
+			wantOrgQuota: 1050,
+			wantOrgUsed:  50,
+			wantMember:   0,
+		},
+		{
+			name:         "usage from a past period is reset first",
+			member:       &OrganizationMember{LimitPeriod: PlanPeriodDay, UsedQuota: 500, PeriodStart: 1},
+			deltas:       []int{40},
+			wantOrgQuota: 960,
+			wantOrgUsed:  140,
+			wantMember:   40,
+		},
+		{
+			name:         "refund after period reset does not go negative",
+			member:       &OrganizationMember{LimitPeriod: PlanPeriodDay, UsedQuota: 500, PeriodStart: 1},
+			deltas:       []int{-40},
+			wantOrgQuota: 1040,
+			wantOrgUsed:  60,
+			wantMember:   0,
+		},
+		{
+			name:         "removed member only charges the organization",
+			deltas:       []int{25},
+			wantOrgQuota: 975,
+			wantOrgUsed:  125,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, &Organization{}, &OrganizationMember{})
+			org := &Organization{Name: "org", Quota: 1000, UsedQuota: 100, Status: OrganizationStatusEnabled}
+			if err := DB.Create(org).Error; err != nil {
+				t.Fatal(err)
+			}
+			userId := 7
+			if tt.member != nil {
+				tt.member.OrgId = org.Id
+				tt.member.UserId = userId
+				tt.member.Role = OrgRoleMember
+				if err := DB.Create(tt.member).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, delta := range tt.deltas {
+				if err := ConsumeOrganizationQuota(org.Id, userId, delta); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := GetOrganizationById(org.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Quota != tt.wantOrgQuota || got.UsedQuota != tt.wantOrgUsed {
+				t.Fatalf("org quota = %d used = %d, want %d %d", got.Quota, got.UsedQuota, tt.wantOrgQuota, tt.wantOrgUsed)
+			}
+			if tt.member == nil {
+				return
+			}
+			member, err := GetOrgMember(org.Id, userId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if used := member.GetUsed(time.Now()); used != tt.wantMember {
+				t.Fatalf("member used = %d, want %d", used, tt.wantMember)
+			}
+		})
+	}
+}
+
+func TestTransferQuotaToOrganization(t *testing.T) {
+	setupTestDB(t, &User{}, &QuotaLedger{}, &CreditLot{}, &Organization{})
+	user := &User{Username: "transfer", Quota: 100}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	org := &Organization{Name: "org", Status: OrganizationStatusEnabled}
+	if err := DB.Create(org).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := TransferQuotaToOrganization(user.Id, org.Id, 150); err == nil {
+		t.Fatal("transfer beyond balance succeeded")
+	}
+	if err := TransferQuotaToOrganization(user.Id, org.Id, 60); err != nil {
+		t.Fatal(err)
+	}
+	var gotUser User
+	DB.First(&gotUser, user.Id)
+	gotOrg, _ := GetOrganizationById(org.Id)
+	if gotUser.Quota != 40 || gotOrg.Quota != 60 {
+		t.Fatalf("user quota = %d org quota = %d, want 40 60", gotUser.Quota, gotOrg.Quota)
+	}
+}
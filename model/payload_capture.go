@@ -0,0 +1,76 @@
+package model
+
+// 请求内容抓取：按规则采样保存客户端请求、转换后的上游请求与上游响应，用于排查问题
+
+type PayloadCapture struct {
+	Id            int    `json:"id"`
+	LogId         int    `json:"log_id" gorm:"index"` // 对应的消费日志，请求失败时为 0
+	RequestId     string `json:"request_id" gorm:"type:varchar(64);index;default:''"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	TokenId       int    `json:"token_id" gorm:"index"`
+	ChannelId     int    `json:"channel_id" gorm:"index"`
+	ModelName     string `json:"model_name" gorm:"type:varchar(128);default:''"`
+	UpstreamModel string `json:"upstream_model" gorm:"type:varchar(128);default:''"`
+	UpstreamUrl   string `json:"upstream_url" gorm:"type:varchar(512);default:''"` // 不含查询参数
+	IsStream      bool   `json:"is_stream"`
+	StatusCode    int    `json:"status_code"`
+	InboundBody   string `json:"inbound_body" gorm:"type:text"`
+	UpstreamBody  string `json:"upstream_body" gorm:"type:text"`
+	ResponseBody  string `json:"response_body" gorm:"type:text"`
+	ResponseText  string `json:"response_text" gorm:"type:text"` // 流式响应拼接后的文本
+	Truncated     bool   `json:"truncated"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+// PayloadCaptureQuery 列表筛选条件，零值表示不筛选
+type PayloadCaptureQuery struct {
+	LogId     int
+	UserId    int
+	TokenId   int
+	ChannelId int
+	RequestId string
+}
+
+func CreatePayloadCapture(capture *PayloadCapture) error {
+	return DB.Create(capture).Error
+}
+
+// GetPayloadCaptures 列表不返回内容字段，查看内容使用 GetPayloadCaptureById
+func GetPayloadCaptures(query PayloadCaptureQuery, startIdx int, num int) (captures []*PayloadCapture, total int64, err error) {
+	tx := DB.Model(&PayloadCapture{})
+	if query.LogId != 0 {
+		tx = tx.Where("log_id = ?", query.LogId)
+	}
+	if query.UserId != 0 {
+		tx = tx.Where("user_id = ?", query.UserId)
+	}
+	if query.TokenId != 0 {
+		tx = tx.Where("token_id = ?", query.TokenId)
+	}
+	if query.ChannelId != 0 {
+		tx = tx.Where("channel_id = ?", query.ChannelId)
+	}
+	if query.RequestId != "" {
+		tx = tx.Where("request_id = ?", query.RequestId)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Omit("inbound_body", "upstream_body", "response_body", "response_text").
+		Order("id desc").Limit(num).Offset(startIdx).Find(&captures).Error
+	return captures, total, err
+}
+
+func GetPayloadCaptureById(id int) (*PayloadCapture, error) {
+	var capture PayloadCapture
+	err := DB.First(&capture, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &capture, nil
+}
+
+func DeletePayloadCapturesBefore(timestamp int64) (int64, error) {
+	result := DB.Where("created_at < ?", timestamp).Delete(&PayloadCapture{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"strings"
+	"time"
+)
+
+const (
+	PlanPeriodDay   = "day"
+	PlanPeriodWeek  = "week"
+	PlanPeriodMonth = "month"
+)
+
+const (
+	PlanStatusEnabled  = 1
+	PlanStatusDisabled = 2
+)
+
+// Plan 订阅计划：每个周期提供一定额度，订阅期间可升级用户分组
+type Plan struct {
+	Id            int     `json:"id"`
+	Name          string  `json:"name" gorm:"type:varchar(64)"`
+	Description   string  `json:"description" gorm:"type:text"`
+	Price         float64 `json:"price"`                                               // 每周期价格，仅用于展示
+	StripePriceId string  `json:"stripe_price_id" gorm:"type:varchar(128);default:''"` // Stripe 周期性价格 ID，为空则不支持在线订阅
+	Quota         int     `json:"quota"`                                               // 每周期额度
+	ResetPeriod   string  `json:"reset_period" gorm:"type:varchar(16);default:'month'"`
+	Group         string  `json:"group" gorm:"type:varchar(64);default:''"` // 订阅期间升级到的分组，为空则不变
+	AllowedModels string  `json:"allowed_models" gorm:"type:text"`          // 逗号分隔，计划额度仅可用于这些模型，为空不限制
+	Status        int     `json:"status" gorm:"default:1"`
+	CreatedTime   int64   `json:"created_time" gorm:"bigint"`
+}
+
+func IsValidPlanPeriod(period string) bool {
+	return period == PlanPeriodDay || period == PlanPeriodWeek || period == PlanPeriodMonth
+}
+
+// PlanPeriodEnd 返回从 start 开始的一个周期的结束时间
+func PlanPeriodEnd(start int64, period string) int64 {
+	t := time.Unix(start, 0)
+	switch period {
+	case PlanPeriodDay:
+		t = t.AddDate(0, 0, 1)
+	case PlanPeriodWeek:
+		t = t.AddDate(0, 0, 7)
+	default:
+		t = t.AddDate(0, 1, 0)
+	}
+	return t.Unix()
+}
+
+// IsPlanModelAllowed 判断计划额度是否可用于该模型
+func IsPlanModelAllowed(allowedModels string, modelName string) bool {
+	if strings.TrimSpace(allowedModels) == "" {
+		return true
+	}
+	for _, m := range strings.Split(allowedModels, ",") {
+		if strings.TrimSpace(m) == modelName {
+			return true
+		}
+	}
+	return false
+}
+
+func (plan *Plan) Insert() error {
+	plan.CreatedTime = common.GetTimestamp()
+	return DB.Create(plan).Error
+}
+
+func (plan *Plan) Update() error {
+	return DB.Model(plan).Select("name", "description", "price", "stripe_price_id", "quota", "reset_period",
+		"group", "allowed_models", "status").Updates(plan).Error
+}
+
+func (plan *Plan) Delete() error {
+	return DB.Delete(plan).Error
+}
+
+func GetPlanById(id int) (*Plan, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	var plan Plan
+	err := DB.First(&plan, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func GetAllPlans() ([]*Plan, error) {
+	var plans []*Plan
+	err := DB.Order("id asc").Find(&plans).Error
+	return plans, err
+}
+
+func GetEnabledPlans() ([]*Plan, error) {
+	var plans []*Plan
+	err := DB.Where("status = ?", PlanStatusEnabled).Order("id asc").Find(&plans).Error
+	return plans, err
+}
@@ -0,0 +1,225 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+// 额度流水：用户余额（User.Quota）的每一次变动都追加一条记录，只增不改
+
+const (
+	LedgerReasonOpening      = "opening_balance" // 启用流水前已有的余额
+	LedgerReasonRegister     = "register"
+	LedgerReasonInviteReward = "invite_reward"
+	LedgerReasonConsume      = "consume"
+	LedgerReasonRefund       = "refund"
+	LedgerReasonTaskRefund   = "task_refund"
+	LedgerReasonTopup        = "topup"
+	LedgerReasonRedemption   = "redemption"
+	LedgerReasonAffTransfer  = "aff_transfer"
+	LedgerReasonAdminAdjust  = "admin_adjust"
+	LedgerReasonExpire       = "expire" // 额度批次过期
+	LedgerReasonOrgTransfer  = "org_transfer"
+)
+
+const (
+	LedgerRefRequest      = "request"
+	LedgerRefTask         = "task"
+	LedgerRefTradeNo      = "trade_no"
+	LedgerRefRedemption   = "redemption"
+	LedgerRefCreditLot    = "credit_lot"
+	LedgerRefOrganization = "organization"
+)
+
+type QuotaLedger struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index:idx_quota_ledger_user_id,priority:1"`
+	TokenId      int    `json:"token_id" gorm:"default:0"`
+	Delta        int    `json:"delta"`
+	BalanceAfter int    `json:"balance_after"`
+	Reason       string `json:"reason" gorm:"type:varchar(32);index"`
+	RefType      string `json:"ref_type" gorm:"type:varchar(16);default:''"`
+	RefId        string `json:"ref_id" gorm:"type:varchar(128);default:''"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index:idx_quota_ledger_user_id,priority:2"`
+
+	lotExpiresAt int64 // 生成额度批次时使用的过期时间，不落库
+}
+
+// LedgerRef 描述一次余额变动的原因与关联对象
+type LedgerRef struct {
+	Reason  string
+	TokenId int
+	RefType string
+	RefId   string
+	// ExpiresAt 增加余额时生成的额度批次的过期时间，0 表示永不过期
+	ExpiresAt int64
+}
+
+func (ref LedgerRef) newEntry(userId int, delta int) *QuotaLedger {
+	return &QuotaLedger{
+		UserId:       userId,
+		TokenId:      ref.TokenId,
+		Delta:        delta,
+		Reason:       ref.Reason,
+		RefType:      ref.RefType,
+		RefId:        ref.RefId,
+		CreatedAt:    common.GetTimestamp(),
+		lotExpiresAt: ref.ExpiresAt,
+	}
+}
+
+// 批量更新模式下暂存的流水，与 BatchUpdateTypeUserQuota 共用一把锁
+var pendingQuotaLedgers = make(map[int][]*QuotaLedger)
+
+func addUserQuotaRecord(userId int, delta int, ref LedgerRef) {
+	batchUpdateLocks[BatchUpdateTypeUserQuota].Lock()
+	defer batchUpdateLocks[BatchUpdateTypeUserQuota].Unlock()
+	batchUpdateStores[BatchUpdateTypeUserQuota][userId] += delta
+	pendingQuotaLedgers[userId] = append(pendingQuotaLedgers[userId], ref.newEntry(userId, delta))
+}
+
+// takePendingQuotaLedgers 调用方需持有 BatchUpdateTypeUserQuota 的锁
+func takePendingQuotaLedgers() map[int][]*QuotaLedger {
+	ledgers := pendingQuotaLedgers
+	pendingQuotaLedgers = make(map[int][]*QuotaLedger)
+	return ledgers
+}
+
+// recordQuotaLedgerTx 在余额已更新的事务内追加流水，BalanceAfter 取更新后的余额
+func recordQuotaLedgerTx(tx *gorm.DB, userId int, delta int, ref LedgerRef) error {
+	if delta == 0 {
+		return nil
+	}
+	return insertQuotaLedgersTx(tx, userId, []*QuotaLedger{ref.newEntry(userId, delta)})
+}
+
+// insertQuotaLedgersTx 按顺序写入同一用户的多条流水，最后一条的 BalanceAfter 为当前余额
+func insertQuotaLedgersTx(tx *gorm.DB, userId int, entries []*QuotaLedger) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	var balance int
+	if err := tx.Model(&User{}).Where("id = ?", userId).Select("quota").Scan(&balance).Error; err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entries[i].BalanceAfter = balance
+		balance -= entries[i].Delta
+	}
+	if err := tx.Create(&entries).Error; err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := applyCreditLotTx(tx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateUserQuotaWithLedger 在一个事务内更新余额并记录流水
+func updateUserQuotaWithLedger(userId int, delta int, entries []*QuotaLedger) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", delta)).Error
+		if err != nil {
+			return err
+		}
+		return insertQuotaLedgersTx(tx, userId, entries)
+	})
+}
+
+// seedQuotaLedgerOpeningBalances 流水表为空时为已有余额的用户写入期初记录，保证对账起点一致
+func seedQuotaLedgerOpeningBalances() error {
+	var count int64
+	if err := DB.Model(&QuotaLedger{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	var users []User
+	if err := DB.Select("id", "quota").Where("quota <> 0").Find(&users).Error; err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return nil
+	}
+	now := common.GetTimestamp()
+	entries := make([]*QuotaLedger, 0, len(users))
+	for _, user := range users {
+		entries = append(entries, &QuotaLedger{
+			UserId:       user.Id,
+			Delta:        user.Quota,
+			BalanceAfter: user.Quota,
+			Reason:       LedgerReasonOpening,
+			CreatedAt:    now,
+		})
+	}
+	common.SysLog("seeding quota ledger opening balances")
+	return DB.CreateInBatches(&entries, 500).Error
+}
+
+func GetUserQuotaLedgers(userId int, startIdx int, num int) (ledgers []*QuotaLedger, total int64, err error) {
+	if userId == 0 {
+		return nil, 0, errors.New("user id 为空！")
+	}
+	tx := DB.Model(&QuotaLedger{}).Where("user_id = ?", userId)
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&ledgers).Error
+	return ledgers, total, err
+}
+
+// QuotaDrift 用户余额与流水合计不一致的情况
+type QuotaDrift struct {
+	UserId      int    `json:"user_id"`
+	Username    string `json:"username"`
+	Quota       int    `json:"quota"`
+	LedgerQuota int    `json:"ledger_quota"`
+	Drift       int    `json:"drift"` // Quota - LedgerQuota
+}
+
+// ReconcileQuotaLedger 对比用户余额与流水合计，userId 为 0 时检查全部用户。
+// 批量更新模式下尚未落库的变动两边都未计入，不影响结果
+func ReconcileQuotaLedger(userId int) (checked int, drifts []QuotaDrift, err error) {
+	var users []User
+	query := DB.Select("id", "username", "quota")
+	if userId != 0 {
+		query = query.Where("id = ?", userId)
+	}
+	if err = query.Find(&users).Error; err != nil {
+		return 0, nil, err
+	}
+	var sums []struct {
+		UserId int
+		Total  int
+	}
+	sumQuery := DB.Model(&QuotaLedger{}).Select("user_id, sum(delta) as total").Group("user_id")
+	if userId != 0 {
+		sumQuery = sumQuery.Where("user_id = ?", userId)
+	}
+	if err = sumQuery.Scan(&sums).Error; err != nil {
+		return 0, nil, err
+	}
+	ledgerTotals := make(map[int]int, len(sums))
+	for _, s := range sums {
+		ledgerTotals[s.UserId] = s.Total
+	}
+	drifts = make([]QuotaDrift, 0)
+	for _, user := range users {
+		ledgerQuota := ledgerTotals[user.Id]
+		if user.Quota != ledgerQuota {
+			drifts = append(drifts, QuotaDrift{
+				UserId:      user.Id,
+				Username:    user.Username,
+				Quota:       user.Quota,
+				LedgerQuota: ledgerQuota,
+				Drift:       user.Quota - ledgerQuota,
+			})
+		}
+	}
+	return len(users), drifts, nil
+}
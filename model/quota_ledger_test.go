@@ -0,0 +1,75 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestInsertQuotaLedgersBalanceAfter(t *testing.T) {
+	tests := []struct {
+		name         string
+		startQuota   int
+		deltas       []int
+		wantBalances []int
+	}{
+		{
+			name:         "single top-up",
+			startQuota:   100,
+			deltas:       []int{50},
+			wantBalances: []int{150},
+		},
+		{
+			name:         "entries applied in order",
+			startQuota:   100,
+			deltas:       []int{-30, 20, -10},
+			wantBalances: []int{70, 90, 80},
+		},
+		{
+			name:         "balance may go negative",
+			startQuota:   10,
+			deltas:       []int{-30},
+			wantBalances: []int{-20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, &User{}, &QuotaLedger{}, &CreditLot{})
+			user := &User{Username: "ledger", Quota: tt.startQuota}
+			if err := DB.Create(user).Error; err != nil {
+				t.Fatal(err)
+			}
+			total := 0
+			entries := make([]*QuotaLedger, 0, len(tt.deltas))
+			for _, delta := range tt.deltas {
+				total += delta
+				entries = append(entries, LedgerRef{Reason: LedgerReasonAdminAdjust}.newEntry(user.Id, delta))
+			}
+			if err := updateUserQuotaWithLedger(user.Id, total, entries); err != nil {
+				t.Fatal(err)
+			}
+			var ledgers []*QuotaLedger
+			if err := DB.Where("user_id = ?", user.Id).Order("id").Find(&ledgers).Error; err != nil {
+				t.Fatal(err)
+			}
+			if len(ledgers) != len(tt.wantBalances) {
+				t.Fatalf("got %d ledger entries, want %d", len(ledgers), len(tt.wantBalances))
+			}
+			for i, ledger := range ledgers {
+				if ledger.BalanceAfter != tt.wantBalances[i] {
+					t.Fatalf("entry %d balance after = %d, want %d", i, ledger.BalanceAfter, tt.wantBalances[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRecordQuotaLedgerSkipsZeroDelta(t *testing.T) {
+	setupTestDB(t, &User{}, &QuotaLedger{}, &CreditLot{})
+	if err := recordQuotaLedgerTx(DB, 1, 0, LedgerRef{Reason: LedgerReasonConsume}); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	DB.Model(&QuotaLedger{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("zero delta wrote %d ledger entries", count)
+	}
+}
@@ -0,0 +1,308 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 对账单：按用户、账期汇总消费、退款与充值，生成后不再修改
+
+type Statement struct {
+	Id               int     `json:"id"`
+	UserId           int     `json:"user_id" gorm:"uniqueIndex:idx_statement_user_period,priority:1"`
+	Username         string  `json:"username" gorm:"type:varchar(64);default:''"`
+	PeriodStart      int64   `json:"period_start" gorm:"bigint;uniqueIndex:idx_statement_user_period,priority:2"`
+	PeriodEnd        int64   `json:"period_end" gorm:"bigint"`
+	RequestCount     int64   `json:"request_count"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	UsageQuota       int64   `json:"usage_quota"`
+	RefundQuota      int64   `json:"refund_quota"`
+	TopupQuota       int64   `json:"topup_quota"`
+	TopupMoney       float64 `json:"topup_money"`
+	LineItems        string  `json:"line_items" gorm:"type:text"`
+	Topups           string  `json:"topups" gorm:"type:text"`
+	Credits          string  `json:"credits" gorm:"type:text"`
+	// 生成时的货币显示设置，保证之后修改设置不影响已生成的对账单
+	QuotaPerUnit      float64 `json:"quota_per_unit"`
+	ExchangeRate      float64 `json:"exchange_rate"`
+	DisplayInCurrency bool    `json:"display_in_currency"`
+	CreatedAt         int64   `json:"created_at" gorm:"bigint"`
+}
+
+// StatementLineItem 按模型与分组汇总的消费明细
+type StatementLineItem struct {
+	ModelName        string `json:"model_name"`
+	Group            string `json:"group" gorm:"column:group_name"`
+	RequestCount     int64  `json:"request_count"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+	Quota            int64  `json:"quota"`
+}
+
+type StatementTopup struct {
+	TradeNo      string  `json:"trade_no"`
+	CompleteTime int64   `json:"complete_time"`
+	Money        float64 `json:"money"`
+	Quota        int64   `json:"quota"`
+}
+
+// StatementCredit 充值以外的余额变动（兑换码、赠送、管理员调整、退款等），按原因汇总
+type StatementCredit struct {
+	Reason string `json:"reason"`
+	Quota  int64  `json:"quota"`
+}
+
+func (statement *Statement) GetLineItems() []StatementLineItem {
+	items := make([]StatementLineItem, 0)
+	_ = common.UnmarshalJsonStr(statement.LineItems, &items)
+	return items
+}
+
+func (statement *Statement) GetTopups() []StatementTopup {
+	topups := make([]StatementTopup, 0)
+	_ = common.UnmarshalJsonStr(statement.Topups, &topups)
+	return topups
+}
+
+func (statement *Statement) GetCredits() []StatementCredit {
+	credits := make([]StatementCredit, 0)
+	_ = common.UnmarshalJsonStr(statement.Credits, &credits)
+	return credits
+}
+
+// StatementMonthBounds 解析 YYYY-MM 格式的账期，按服务器时区返回起止时间
+func StatementMonthBounds(period string) (int64, int64, error) {
+	start, err := time.ParseInLocation("2006-01", period, time.Local)
+	if err != nil {
+		return 0, 0, errors.New("账期格式应为 YYYY-MM")
+	}
+	return start.Unix(), start.AddDate(0, 1, 0).Unix(), nil
+}
+
+// GenerateStatement 生成用户在 [periodStart, periodEnd) 内的对账单，已存在时直接返回已有的对账单
+func GenerateStatement(userId int, periodStart int64, periodEnd int64) (*Statement, error) {
+	if periodEnd <= periodStart {
+		return nil, errors.New("账期无效")
+	}
+	if periodEnd > common.GetTimestamp() {
+		return nil, errors.New("账期尚未结束")
+	}
+	var existing Statement
+	err := DB.Where("user_id = ? AND period_start = ?", userId, periodStart).Limit(1).Find(&existing).Error
+	if err != nil {
+		return nil, err
+	}
+	if existing.Id != 0 {
+		return &existing, nil
+	}
+	username, err := GetUsernameById(userId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{
+		UserId:            userId,
+		Username:          username,
+		PeriodStart:       periodStart,
+		PeriodEnd:         periodEnd,
+		QuotaPerUnit:      common.QuotaPerUnit,
+		ExchangeRate:      operation_setting.USDExchangeRate,
+		DisplayInCurrency: common.DisplayInCurrencyEnabled,
+		CreatedAt:         common.GetTimestamp(),
+	}
+
+	lineItems, err := getStatementLineItems(userId, username, periodStart, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range lineItems {
+		statement.RequestCount += item.RequestCount
+		statement.PromptTokens += item.PromptTokens
+		statement.CompletionTokens += item.CompletionTokens
+		statement.TotalTokens += item.TotalTokens
+		statement.UsageQuota += item.Quota
+	}
+
+	var topups []StatementTopup
+	err = DB.Table("top_ups AS t").
+		Select("t.trade_no, t.complete_time, t.money, coalesce(l.delta, 0) as quota").
+		Joins("LEFT JOIN quota_ledgers AS l ON l.ref_type = ? AND l.ref_id = t.trade_no AND l.reason = ?", LedgerRefTradeNo, LedgerReasonTopup).
+		Where("t.user_id = ? AND t.status = ? AND t.complete_time >= ? AND t.complete_time < ?", userId, common.TopUpStatusSuccess, periodStart, periodEnd).
+		Order("t.complete_time").Scan(&topups).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, topup := range topups {
+		statement.TopupQuota += topup.Quota
+		statement.TopupMoney += topup.Money
+	}
+
+	// 请求结算时的多退少补已体现在消费日志中，这里只统计失败任务的退款与其他余额变动
+	var credits []StatementCredit
+	err = DB.Model(&QuotaLedger{}).Select("reason, sum(delta) as quota").
+		Where("user_id = ? AND created_at >= ? AND created_at < ? AND reason NOT IN ?", userId, periodStart, periodEnd,
+			[]string{LedgerReasonConsume, LedgerReasonRefund, LedgerReasonTopup, LedgerReasonOpening}).
+		Group("reason").Order("reason").Scan(&credits).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, credit := range credits {
+		if credit.Reason == LedgerReasonTaskRefund {
+			statement.RefundQuota += credit.Quota
+		}
+	}
+
+	statement.LineItems = marshalStatementPart(lineItems)
+	statement.Topups = marshalStatementPart(topups)
+	statement.Credits = marshalStatementPart(credits)
+	if err := DB.Create(statement).Error; err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+// getStatementLineItems 优先使用消费日志，原始日志已删除的日期从日志汇总补足；
+// 未开启消费日志时退回到按模型汇总的 QuotaData
+func getStatementLineItems(userId int, username string, periodStart int64, periodEnd int64) ([]StatementLineItem, error) {
+	var items []StatementLineItem
+	err := LOG_DB.Table("logs").
+		Select("model_name, "+logGroupCol+" as group_name, count(*) as request_count, sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens, sum(quota) as quota").
+		Where("user_id = ? AND type IN ? AND created_at >= ? AND created_at < ?", userId, []int{LogTypeConsume, LogTypeCacheHit}, periodStart, periodEnd).
+		Group("model_name, " + logGroupCol).Order("model_name").Scan(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	// 账期结束时间不含在内，汇总按整天统计
+	if rollupTx, ok := logRollupRangeQuery(periodStart, periodEnd-1, LogRollupFilter{UserId: userId}); ok {
+		var rollupItems []StatementLineItem
+		err = rollupTx.Select("model_name, group_name, sum(request_count) as request_count, sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens, sum(quota) as quota").
+			Where("request_count > 0").
+			Group("model_name, group_name").Scan(&rollupItems).Error
+		if err != nil {
+			return nil, err
+		}
+		items = mergeStatementLineItems(items, rollupItems)
+	}
+	if len(items) > 0 {
+		for i := range items {
+			items[i].TotalTokens = items[i].PromptTokens + items[i].CompletionTokens
+		}
+		return items, nil
+	}
+	err = DB.Model(&QuotaData{}).
+		Select("model_name, sum(count) as request_count, sum(token_used) as total_tokens, sum(quota) as quota").
+		Where("username = ? AND created_at >= ? AND created_at < ?", username, periodStart, periodEnd).
+		Group("model_name").Order("model_name").Scan(&items).Error
+	return items, err
+}
+
+func mergeStatementLineItems(items []StatementLineItem, rollupItems []StatementLineItem) []StatementLineItem {
+	if len(rollupItems) == 0 {
+		return items
+	}
+	index := make(map[string]int, len(items))
+	for i, item := range items {
+		index[item.ModelName+"\x00"+item.Group] = i
+	}
+	for _, item := range rollupItems {
+		key := item.ModelName + "\x00" + item.Group
+		if i, ok := index[key]; ok {
+			items[i].RequestCount += item.RequestCount
+			items[i].PromptTokens += item.PromptTokens
+			items[i].CompletionTokens += item.CompletionTokens
+			items[i].Quota += item.Quota
+			continue
+		}
+		index[key] = len(items)
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].ModelName < items[j].ModelName
+	})
+	return items
+}
+
+func marshalStatementPart(v any) string {
+	data, err := common.Marshal(v)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to marshal statement: %v", err))
+		return "[]"
+	}
+	return string(data)
+}
+
+// GenerateStatementsForPeriod 为账期内有消费或充值的用户生成对账单，返回新生成与已存在的数量
+func GenerateStatementsForPeriod(periodStart int64, periodEnd int64) (int, error) {
+	var userIds []int
+	err := LOG_DB.Table("logs").Distinct("user_id").
+		Where("type IN ? AND created_at >= ? AND created_at < ?", []int{LogTypeConsume, LogTypeCacheHit}, periodStart, periodEnd).
+		Pluck("user_id", &userIds).Error
+	if err != nil {
+		return 0, err
+	}
+	if rollupTx, ok := logRollupRangeQuery(periodStart, periodEnd-1, LogRollupFilter{}); ok {
+		var rollupUserIds []int
+		if err = rollupTx.Where("request_count > 0").Distinct("user_id").Pluck("user_id", &rollupUserIds).Error; err != nil {
+			return 0, err
+		}
+		userIds = append(userIds, rollupUserIds...)
+	}
+	var topupUserIds []int
+	err = DB.Model(&TopUp{}).Distinct("user_id").
+		Where("status = ? AND complete_time >= ? AND complete_time < ?", common.TopUpStatusSuccess, periodStart, periodEnd).
+		Pluck("user_id", &topupUserIds).Error
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[int]bool)
+	count := 0
+	for _, userId := range append(userIds, topupUserIds...) {
+		if userId == 0 || seen[userId] {
+			continue
+		}
+		seen[userId] = true
+		if _, err := GenerateStatement(userId, periodStart, periodEnd); err != nil {
+			common.SysError(fmt.Sprintf("failed to generate statement for user %d: %s", userId, err.Error()))
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func GetStatements(userId int, startIdx int, num int) (statements []*Statement, total int64, err error) {
+	tx := DB.Model(&Statement{}).Omit("line_items", "topups", "credits")
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("period_start desc, id desc").Limit(num).Offset(startIdx).Find(&statements).Error
+	return statements, total, err
+}
+
+// GetStatementById userId 不为 0 时只返回该用户的对账单
+func GetStatementById(id int, userId int) (*Statement, error) {
+	var statement Statement
+	tx := DB.Where("id = ?", id)
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if err := tx.First(&statement).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("对账单不存在")
+		}
+		return nil, err
+	}
+	return &statement, nil
+}
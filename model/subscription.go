@@ -0,0 +1,407 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SubscriptionStatusPending   = "pending"
+	SubscriptionStatusActive    = "active"
+	SubscriptionStatusCancelled = "cancelled" // 已取消续订，到期前仍可使用
+	SubscriptionStatusExpired   = "expired"
+)
+
+const (
+	SubscriptionSourceStripe = "stripe"
+	SubscriptionSourceManual = "manual"
+)
+
+// Subscription 用户的计划订阅。激活时复制计划的额度、周期、分组与模型限制，之后修改计划不影响已有订阅。
+// 计划额度每个周期重置，扣费时优先于预付费余额使用
+type Subscription struct {
+	Id                   int    `json:"id"`
+	UserId               int    `json:"user_id" gorm:"index"`
+	PlanId               int    `json:"plan_id" gorm:"index"`
+	PlanName             string `json:"plan_name" gorm:"type:varchar(64)"`
+	Status               string `json:"status" gorm:"type:varchar(16);index"`
+	Source               string `json:"source" gorm:"type:varchar(16)"`
+	TradeNo              string `json:"trade_no" gorm:"type:varchar(255);index"`
+	StripeSubscriptionId string `json:"stripe_subscription_id" gorm:"type:varchar(128);index"`
+	LastInvoiceId        string `json:"-" gorm:"type:varchar(128);default:''"` // 最近处理的 Stripe 账单，避免 Webhook 重复投递导致重复续订
+	PlanQuota            int    `json:"plan_quota"`
+	QuotaRemaining       int    `json:"quota_remaining"`
+	ResetPeriod          string `json:"reset_period" gorm:"type:varchar(16)"`
+	Group                string `json:"group" gorm:"type:varchar(64);default:''"`
+	PreviousGroup        string `json:"previous_group" gorm:"type:varchar(64);default:''"` // 升级分组前的分组，到期后恢复
+	AllowedModels        string `json:"allowed_models" gorm:"type:text"`
+	PeriodStart          int64  `json:"period_start" gorm:"bigint"`
+	PeriodEnd            int64  `json:"period_end" gorm:"bigint"`
+	ExpiresAt            int64  `json:"expires_at" gorm:"bigint;index"`
+	CreatedTime          int64  `json:"created_time" gorm:"bigint"`
+}
+
+func formatSubscriptionTime(timestamp int64) string {
+	return time.Unix(timestamp, 0).Format("2006-01-02 15:04:05")
+}
+
+func (sub *Subscription) Insert() error {
+	sub.CreatedTime = common.GetTimestamp()
+	return DB.Create(sub).Error
+}
+
+func (sub *Subscription) Update() error {
+	return DB.Save(sub).Error
+}
+
+func GetSubscriptionById(id int) (*Subscription, error) {
+	var sub Subscription
+	err := DB.First(&sub, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func GetSubscriptionByTradeNo(tradeNo string) (*Subscription, error) {
+	var sub Subscription
+	err := DB.First(&sub, "trade_no = ?", tradeNo).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func GetSubscriptionByStripeId(stripeSubscriptionId string) (*Subscription, error) {
+	var sub Subscription
+	err := DB.Where("stripe_subscription_id = ?", stripeSubscriptionId).Order("id desc").First(&sub).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// GetActiveSubscription 返回用户当前生效的订阅，没有时返回 nil
+func GetActiveSubscription(userId int) (*Subscription, error) {
+	var subs []*Subscription
+	err := DB.Where("user_id = ? and status in ? and expires_at > ?", userId,
+		[]string{SubscriptionStatusActive, SubscriptionStatusCancelled}, common.GetTimestamp()).
+		Order("id desc").Limit(1).Find(&subs).Error
+	if err != nil || len(subs) == 0 {
+		return nil, err
+	}
+	return subs[0], nil
+}
+
+func GetUserSubscriptions(userId int) ([]*Subscription, error) {
+	var subs []*Subscription
+	err := DB.Where("user_id = ? and status <> ?", userId, SubscriptionStatusPending).Order("id desc").Find(&subs).Error
+	return subs, err
+}
+
+func GetAllSubscriptions(userId int, status string, startIdx int, num int) (subs []*Subscription, total int64, err error) {
+	tx := DB.Model(&Subscription{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&subs).Error
+	return subs, total, err
+}
+
+// NewSubscriptionFromPlan 根据计划创建未激活的订阅
+func NewSubscriptionFromPlan(userId int, plan *Plan, source string) *Subscription {
+	return &Subscription{
+		UserId:        userId,
+		PlanId:        plan.Id,
+		PlanName:      plan.Name,
+		Status:        SubscriptionStatusPending,
+		Source:        source,
+		PlanQuota:     plan.Quota,
+		ResetPeriod:   plan.ResetPeriod,
+		Group:         plan.Group,
+		AllowedModels: plan.AllowedModels,
+	}
+}
+
+// SetUserStripeCustomer 记录用户的 Stripe 客户 ID，后续订阅复用
+func SetUserStripeCustomer(userId int, customerId string) error {
+	if customerId == "" {
+		return nil
+	}
+	return DB.Model(&User{}).Where("id = ?", userId).Update("stripe_customer", customerId).Error
+}
+
+func setUserGroup(tx *gorm.DB, userId int, group string) error {
+	err := tx.Model(&User{}).Where("id = ?", userId).Update("group", group).Error
+	if err != nil {
+		return err
+	}
+	if err := updateUserGroupCache(userId, group); err != nil {
+		common.SysLog("failed to update user group cache: " + err.Error())
+	}
+	return nil
+}
+
+// ActivateSubscription 激活订阅 periods 个周期，用户已有的其他订阅会被替换
+func ActivateSubscription(sub *Subscription, periods int) error {
+	if periods <= 0 {
+		periods = 1
+	}
+	now := common.GetTimestamp()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var others []*Subscription
+		err := tx.Where("user_id = ? and id <> ? and status in ?", sub.UserId, sub.Id,
+			[]string{SubscriptionStatusActive, SubscriptionStatusCancelled}).Find(&others).Error
+		if err != nil {
+			return err
+		}
+		previousGroup := ""
+		for _, other := range others {
+			if previousGroup == "" {
+				previousGroup = other.PreviousGroup
+			}
+			other.Status = SubscriptionStatusExpired
+			other.ExpiresAt = now
+			if err := tx.Save(other).Error; err != nil {
+				return err
+			}
+		}
+		if previousGroup == "" {
+			var user User
+			if err := tx.Select("id", "group").First(&user, "id = ?", sub.UserId).Error; err != nil {
+				return err
+			}
+			previousGroup = user.Group
+		}
+		sub.PreviousGroup = previousGroup
+		sub.Status = SubscriptionStatusActive
+		sub.PeriodStart = now
+		sub.PeriodEnd = PlanPeriodEnd(now, sub.ResetPeriod)
+		sub.QuotaRemaining = sub.PlanQuota
+		sub.ExpiresAt = now
+		for i := 0; i < periods; i++ {
+			sub.ExpiresAt = PlanPeriodEnd(sub.ExpiresAt, sub.ResetPeriod)
+		}
+		if err := tx.Save(sub).Error; err != nil {
+			return err
+		}
+		targetGroup := previousGroup
+		if sub.Group != "" {
+			targetGroup = sub.Group
+		}
+		return setUserGroup(tx, sub.UserId, targetGroup)
+	})
+	if err != nil {
+		return err
+	}
+	RecordLog(sub.UserId, LogTypeTopup, fmt.Sprintf("订阅计划 %s 已生效，每周期额度 %s，有效期至 %s", sub.PlanName,
+		logger.FormatQuota(sub.PlanQuota), formatSubscriptionTime(sub.ExpiresAt)))
+	return nil
+}
+
+// RenewSubscription 续订一个周期，并从当前时间重新开始额度周期；已到期的订阅重新激活
+func RenewSubscription(sub *Subscription) error {
+	if sub.Status == SubscriptionStatusExpired || sub.Status == SubscriptionStatusPending {
+		return ActivateSubscription(sub, 1)
+	}
+	now := common.GetTimestamp()
+	start := max(sub.ExpiresAt, now)
+	sub.ExpiresAt = PlanPeriodEnd(start, sub.ResetPeriod)
+	sub.Status = SubscriptionStatusActive
+	sub.PeriodStart = now
+	sub.PeriodEnd = PlanPeriodEnd(now, sub.ResetPeriod)
+	sub.QuotaRemaining = sub.PlanQuota
+	if err := sub.Update(); err != nil {
+		return err
+	}
+	RecordLog(sub.UserId, LogTypeTopup, fmt.Sprintf("订阅计划 %s 已续订，有效期至 %s", sub.PlanName, formatSubscriptionTime(sub.ExpiresAt)))
+	return nil
+}
+
+// ExpireSubscription 使订阅失效，并在用户分组仍为计划分组时恢复原分组
+func ExpireSubscription(sub *Subscription) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		sub.Status = SubscriptionStatusExpired
+		sub.QuotaRemaining = 0
+		if err := tx.Save(sub).Error; err != nil {
+			return err
+		}
+		if sub.Group == "" || sub.PreviousGroup == "" {
+			return nil
+		}
+		var user User
+		if err := tx.Select("id", "group").First(&user, "id = ?", sub.UserId).Error; err != nil {
+			return err
+		}
+		if user.Group != sub.Group {
+			return nil
+		}
+		return setUserGroup(tx, sub.UserId, sub.PreviousGroup)
+	})
+	if err != nil {
+		return err
+	}
+	RecordLog(sub.UserId, LogTypeSystem, fmt.Sprintf("订阅计划 %s 已到期", sub.PlanName))
+	return nil
+}
+
+// resetSubscriptionPeriod 周期结束后重置计划额度，以 period_end 作为条件避免重复重置
+func resetSubscriptionPeriod(sub *Subscription, now int64) error {
+	periodStart := sub.PeriodEnd
+	periodEnd := PlanPeriodEnd(periodStart, sub.ResetPeriod)
+	for periodEnd <= now {
+		periodStart = periodEnd
+		periodEnd = PlanPeriodEnd(periodStart, sub.ResetPeriod)
+	}
+	result := DB.Model(&Subscription{}).Where("id = ? and period_end = ?", sub.Id, sub.PeriodEnd).Updates(map[string]interface{}{
+		"period_start":    periodStart,
+		"period_end":      periodEnd,
+		"quota_remaining": sub.PlanQuota,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		sub.PeriodStart = periodStart
+		sub.PeriodEnd = periodEnd
+		sub.QuotaRemaining = sub.PlanQuota
+		return nil
+	}
+	// 已被其他请求重置
+	return DB.First(sub, "id = ?", sub.Id).Error
+}
+
+// getUsableSubscription 返回可用于该模型的生效订阅，必要时先重置额度周期
+func getUsableSubscription(userId int, modelName string) (*Subscription, error) {
+	sub, err := GetActiveSubscription(userId)
+	if err != nil || sub == nil {
+		return nil, err
+	}
+	if !IsPlanModelAllowed(sub.AllowedModels, modelName) {
+		return nil, nil
+	}
+	if now := common.GetTimestamp(); sub.PeriodEnd <= now {
+		if err := resetSubscriptionPeriod(sub, now); err != nil {
+			return nil, err
+		}
+	}
+	return sub, nil
+}
+
+// GetSubscriptionQuota 返回用户当前可用于该模型的计划额度
+func GetSubscriptionQuota(userId int, modelName string) (int, error) {
+	sub, err := getUsableSubscription(userId, modelName)
+	if err != nil || sub == nil {
+		return 0, err
+	}
+	return max(sub.QuotaRemaining, 0), nil
+}
+
+// ConsumeSubscriptionQuota 从计划额度中扣除至多 quota，返回实际扣除的额度
+func ConsumeSubscriptionQuota(userId int, modelName string, quota int) (int, error) {
+	if quota <= 0 {
+		return 0, nil
+	}
+	sub, err := getUsableSubscription(userId, modelName)
+	if err != nil || sub == nil {
+		return 0, err
+	}
+	// 并发扣除时以剩余额度作为条件，失败则重新读取后重试
+	for i := 0; i < 3; i++ {
+		used := min(quota, sub.QuotaRemaining)
+		if used <= 0 {
+			return 0, nil
+		}
+		result := DB.Model(&Subscription{}).Where("id = ? and quota_remaining = ?", sub.Id, sub.QuotaRemaining).
+			Update("quota_remaining", gorm.Expr("quota_remaining - ?", used))
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		if result.RowsAffected > 0 {
+			return used, nil
+		}
+		if err := DB.First(sub, "id = ?", sub.Id).Error; err != nil {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("subscription %d quota changed concurrently, retries exhausted", sub.Id)
+}
+
+// RefundSubscriptionQuota 将至多 quota 退回计划额度（不超过每周期额度），返回实际退回的额度
+func RefundSubscriptionQuota(userId int, modelName string, quota int) (int, error) {
+	if quota <= 0 {
+		return 0, nil
+	}
+	sub, err := getUsableSubscription(userId, modelName)
+	if err != nil || sub == nil {
+		return 0, err
+	}
+	for i := 0; i < 3; i++ {
+		refunded := min(quota, sub.PlanQuota-sub.QuotaRemaining)
+		if refunded <= 0 {
+			return 0, nil
+		}
+		result := DB.Model(&Subscription{}).Where("id = ? and quota_remaining = ?", sub.Id, sub.QuotaRemaining).
+			Update("quota_remaining", gorm.Expr("quota_remaining + ?", refunded))
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		if result.RowsAffected > 0 {
+			return refunded, nil
+		}
+		if err := DB.First(sub, "id = ?", sub.Id).Error; err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
+
+// ProcessSubscriptions 重置已结束周期的计划额度，并使已到期的订阅失效
+func ProcessSubscriptions() {
+	now := common.GetTimestamp()
+	var expired []*Subscription
+	err := DB.Where("status in ? and expires_at <= ?", []string{SubscriptionStatusActive, SubscriptionStatusCancelled}, now).
+		Find(&expired).Error
+	if err != nil {
+		common.SysError("failed to query expired subscriptions: " + err.Error())
+	}
+	for _, sub := range expired {
+		if err := ExpireSubscription(sub); err != nil {
+			common.SysError(fmt.Sprintf("failed to expire subscription %d: %s", sub.Id, err.Error()))
+		}
+	}
+
+	var due []*Subscription
+	err = DB.Where("status in ? and period_end <= ? and expires_at > ?", []string{SubscriptionStatusActive, SubscriptionStatusCancelled}, now, now).
+		Find(&due).Error
+	if err != nil {
+		common.SysError("failed to query subscriptions to reset: " + err.Error())
+		return
+	}
+	for _, sub := range due {
+		if err := resetSubscriptionPeriod(sub, now); err != nil {
+			common.SysError(fmt.Sprintf("failed to reset subscription %d: %s", sub.Id, err.Error()))
+		}
+	}
+}
+
+// CancelSubscription 取消续订，订阅在到期前仍然有效
+func CancelSubscription(sub *Subscription) error {
+	if sub.Status != SubscriptionStatusActive {
+		return errors.New("订阅状态错误")
+	}
+	sub.Status = SubscriptionStatusCancelled
+	return sub.Update()
+}
@@ -175,13 +175,39 @@ func GetAllUnFinishSyncTasks(limit int) []*Task {
 	var tasks []*Task
 	var err error
 	// get all tasks progress is not 100%
-	err = DB.Where("progress != ?", "100%").Limit(limit).Order("id").Find(&tasks).Error
+	// 批处理任务由批处理 worker 自行推进，无需轮询上游
+	err = DB.Where("progress != ? and platform != ?", "100%", constant.TaskPlatformBatch).Limit(limit).Order("id").Find(&tasks).Error
 	if err != nil {
 		return nil
 	}
 	return tasks
 }
 
+// CountPendingTasksByPlatform 统计各平台未完成的任务数，Midjourney 任务计入 mj 平台
+func CountPendingTasksByPlatform() (map[string]int64, error) {
+	var rows []struct {
+		Platform string
+		Count    int64
+	}
+	err := DB.Model(&Task{}).Select("platform, count(*) as count").
+		Where("progress != ? AND status NOT IN ?", "100%", []TaskStatus{TaskStatusSuccess, TaskStatusFailure}).
+		Group("platform").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows)+1)
+	for _, row := range rows {
+		counts[row.Platform] += row.Count
+	}
+	var mjCount int64
+	err = DB.Model(&Midjourney{}).Where("progress != ? AND status NOT IN ?", "100%", []string{TaskStatusSuccess, TaskStatusFailure}).Count(&mjCount).Error
+	if err != nil {
+		return nil, err
+	}
+	counts[string(constant.TaskPlatformMidjourney)] += mjCount
+	return counts, nil
+}
+
 func GetByOnlyTaskId(taskId string) (*Task, bool, error) {
 	if taskId == "" {
 		return nil, false, nil
@@ -259,6 +285,15 @@ func TaskBulkUpdateByTaskIds(taskIDs []int64, params map[string]any) error {
 		Updates(params).Error
 }
 
+// UpdateTaskIfStatus 仅当当前状态属于 fromStatus 时更新，返回是否更新成功
+func UpdateTaskIfStatus(id int64, fromStatus []TaskStatus, params map[string]any) (bool, error) {
+	result := DB.Model(&Task{}).Where("id = ? and status in ?", id, fromStatus).Updates(params)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func TaskBulkUpdateByID(ids []int64, params map[string]any) error {
 	if len(ids) == 0 {
 		return nil
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"one-api/constant"
+	"one-api/dto"
+)
+
+// 批处理、微调任务使用的额外状态，取消请求与 worker 的进度写入通过 status 列做条件更新，避免互相覆盖
+const (
+	TaskStatusCancelling TaskStatus = "CANCELLING"
+	TaskStatusCancelled  TaskStatus = "CANCELLED"
+	TaskStatusExpired    TaskStatus = "EXPIRED"
+)
+
+// OpenAI batch 状态
+const (
+	BatchStatusValidating = "validating"
+	BatchStatusFailed     = "failed"
+	BatchStatusInProgress = "in_progress"
+	BatchStatusFinalizing = "finalizing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusExpired    = "expired"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCancelled  = "cancelled"
+)
+
+// BatchData 保存在 Task.Data 中的批处理信息
+type BatchData struct {
+	Endpoint         string                 `json:"endpoint"`
+	InputFileId      string                 `json:"input_file_id"`
+	OutputFileId     string                 `json:"output_file_id,omitempty"`
+	ErrorFileId      string                 `json:"error_file_id,omitempty"`
+	CompletionWindow string                 `json:"completion_window"`
+	Status           string                 `json:"status"`
+	TokenId          int                    `json:"token_id"`
+	ClientIp         string                 `json:"client_ip,omitempty"` // 创建批处理时的客户端 IP，用于通过令牌 IP 白名单校验
+	Metadata         map[string]string      `json:"metadata,omitempty"`
+	RequestCounts    dto.BatchRequestCounts `json:"request_counts"`
+	Errors           []dto.BatchError       `json:"errors,omitempty"`
+	InProgressAt     int64                  `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64                  `json:"expires_at,omitempty"`
+	FinalizingAt     int64                  `json:"finalizing_at,omitempty"`
+	CompletedAt      int64                  `json:"completed_at,omitempty"`
+	FailedAt         int64                  `json:"failed_at,omitempty"`
+	ExpiredAt        int64                  `json:"expired_at,omitempty"`
+	CancellingAt     int64                  `json:"cancelling_at,omitempty"`
+	CancelledAt      int64                  `json:"cancelled_at,omitempty"`
+}
+
+func (t *Task) GetBatchData() *BatchData {
+	data := &BatchData{}
+	_ = t.GetData(data)
+	return data
+}
+
+// GetUserBatchTasks 按创建时间倒序分页，afterId 为上一页最后一个批处理的 id
+func GetUserBatchTasks(userId int, afterId string, limit int) (tasks []*Task, err error) {
+	tx := DB.Where("user_id = ? and platform = ?", userId, constant.TaskPlatformBatch)
+	if afterId != "" {
+		after, exist, err := GetByTaskId(userId, afterId)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			tx = tx.Where("id < ?", after.ID)
+		}
+	}
+	err = tx.Order("id desc").Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
+// GetUnfinishedBatchTasks 获取待调度、执行中以及取消中的批处理
+func GetUnfinishedBatchTasks() (tasks []*Task, err error) {
+	err = DB.Where("platform = ? and status in ?", constant.TaskPlatformBatch,
+		[]TaskStatus{TaskStatusQueued, TaskStatusInProgress, TaskStatusCancelling}).
+		Order("id").Find(&tasks).Error
+	return tasks, err
+}
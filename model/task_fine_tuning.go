@@ -0,0 +1,42 @@
+package model
+
+import (
+	"encoding/json"
+	"one-api/constant"
+)
+
+// FineTuningData 保存在 Task.Data 中的微调任务信息，Job 为上游最近一次返回的任务对象
+type FineTuningData struct {
+	Job              json.RawMessage `json:"job"`
+	BaseModel        string          `json:"base_model"`
+	Group            string          `json:"group"`
+	KeyIndex         int             `json:"key_index"`
+	TokenId          int             `json:"token_id"`
+	TokenName        string          `json:"token_name"`
+	OrgId            int             `json:"org_id,omitempty"`
+	TrainingFileId   string          `json:"training_file_id"`
+	ValidationFileId string          `json:"validation_file_id,omitempty"`
+	PreConsumedQuota int             `json:"pre_consumed_quota,omitempty"` // 创建任务时预扣的估算费用
+}
+
+func (t *Task) GetFineTuningData() *FineTuningData {
+	data := &FineTuningData{}
+	_ = t.GetData(data)
+	return data
+}
+
+// GetUserFineTuningTasks 按创建时间倒序分页，afterId 为上一页最后一个任务的 id
+func GetUserFineTuningTasks(userId int, afterId string, limit int) (tasks []*Task, err error) {
+	tx := DB.Where("user_id = ? and platform = ?", userId, constant.TaskPlatformFineTuning)
+	if afterId != "" {
+		after, exist, err := GetByTaskId(userId, afterId)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			tx = tx.Where("id < ?", after.ID)
+		}
+	}
+	err = tx.Order("id desc").Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"one-api/common"
+	"one-api/metrics"
 	"strings"
 
 	"github.com/bytedance/gopkg/util/gopool"
@@ -26,7 +27,18 @@ type Token struct {
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
-	ExternalUserId     string         `json:"external_user_id" gorm:"index;type:varchar(64)"` // 外部用户ID（如Supabase用户UUID）
+	ExternalUserId     string         `json:"external_user_id" gorm:"index;type:varchar(64)"`     // 外部用户ID（如Supabase用户UUID）
+	RateLimitRPM       int            `json:"rate_limit_rpm" gorm:"default:0"`                    // 每分钟请求数上限，0 表示不限制
+	RateLimitTPM       int            `json:"rate_limit_tpm" gorm:"default:0"`                    // 每分钟 token 数上限（按实际用量统计），0 表示不限制
+	MaxConcurrency     int            `json:"max_concurrency" gorm:"default:0"`                   // 最大并发请求数，0 表示不限制
+	ModelFallbacks     string         `json:"model_fallbacks" gorm:"type:text"`                   // 模型降级链 JSON：模型 -> 备选模型列表，优先于分组与全局配置
+	ResponseCache      string         `json:"response_cache" gorm:"type:varchar(16);default:''"`  // 响应缓存：enabled/semantic/disabled，为空时跟随分组配置
+	BudgetQuota        int            `json:"budget_quota" gorm:"default:0"`                      // 周期预算额度，0 表示不限制
+	BudgetPeriod       string         `json:"budget_period" gorm:"type:varchar(16);default:''"`   // 预算重置周期：day/week/month
+	BudgetTimezone     string         `json:"budget_timezone" gorm:"type:varchar(64);default:''"` // 预算周期所用时区，为空时使用服务器时区
+	BudgetUsed         int            `json:"budget_used" gorm:"default:0"`                       // 当前周期已用预算
+	BudgetPeriodStart  int64          `json:"budget_period_start" gorm:"bigint;default:0"`        // BudgetUsed 所属周期的开始时间
+	OrgId              int            `json:"org_id" gorm:"index;default:0"`                      // 组织令牌从组织额度池扣费，0 表示个人令牌
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -156,6 +168,7 @@ func GetTokenByKey(key string, fromDB bool) (token *Token, err error) {
 	if !fromDB && common.RedisEnabled {
 		// Try Redis first
 		token, err := cacheGetTokenByKey(key)
+		metrics.RecordCache(metrics.CacheToken, err == nil)
 		if err == nil {
 			return token, nil
 		}
@@ -173,19 +186,38 @@ func (token *Token) Insert() error {
 }
 
 // Update Make sure your token's fields is completed, because this will update non-zero values
-func (token *Token) Update() (err error) {
+// 已用预算由 IncreaseTokenBudgetUsed 实时累加，这里不写入；预算周期划分变化时使用 UpdateAndResetBudget
+func (token *Token) Update() error {
+	return token.update(false)
+}
+
+// UpdateAndResetBudget 更新令牌并清零已用预算，用于预算周期或时区变化后重新开始计算
+func (token *Token) UpdateAndResetBudget() error {
+	return token.update(true)
+}
+
+func (token *Token) update(resetBudget bool) (err error) {
+	columns := []string{"name", "status", "expired_time", "remain_quota", "unlimited_quota",
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "rate_limit_rpm", "rate_limit_tpm", "max_concurrency", "model_fallbacks", "response_cache",
+		"budget_quota", "budget_period", "budget_timezone"}
+	if resetBudget {
+		token.BudgetUsed = 0
+		token.BudgetPeriodStart = 0
+		columns = append(columns, "budget_used", "budget_period_start")
+	}
 	defer func() {
 		if shouldUpdateRedis(true, err) {
 			gopool.Go(func() {
-				err := cacheSetToken(*token)
+				// 缓存中的已用预算由请求实时累加，用编辑前读到的值覆盖会丢失这期间的消耗，
+				// 因此删除缓存，由下次读取时从数据库重新加载
+				err := cacheDeleteToken(token.Key)
 				if err != nil {
 					common.SysLog("failed to update token cache: " + err.Error())
 				}
 			})
 		}
 	}()
-	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group").Updates(token).Error
+	err = DB.Model(token).Select(columns).Updates(token).Error
 	return err
 }
 
@@ -382,3 +414,13 @@ func GetTokensByExternalUserId(externalUserId string) ([]*Token, error) {
 	err := DB.Where("external_user_id = ?", externalUserId).Find(&tokens).Error
 	return tokens, err
 }
+
+// GetModelFallbacks 解析令牌上配置的模型降级链
+func (token *Token) GetModelFallbacks() (map[string][]string, error) {
+	fallbacks := make(map[string][]string)
+	if strings.TrimSpace(token.ModelFallbacks) == "" {
+		return fallbacks, nil
+	}
+	err := common.Unmarshal([]byte(token.ModelFallbacks), &fallbacks)
+	return fallbacks, err
+}
@@ -0,0 +1,156 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 令牌周期预算：每个自然日/周/月（按令牌时区计算）最多消耗 BudgetQuota，
+// 周期切换时在 Redis 与数据库中惰性清零已用预算
+
+var budgetLocations sync.Map
+
+// GetBudgetLocation 解析预算时区，为空时使用服务器本地时区
+func GetBudgetLocation(timezone string) (*time.Location, error) {
+	timezone = strings.TrimSpace(timezone)
+	if timezone == "" {
+		return time.Local, nil
+	}
+	if loc, ok := budgetLocations.Load(timezone); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	budgetLocations.Store(timezone, loc)
+	return loc, nil
+}
+
+// ValidateTokenBudget 校验令牌预算配置
+func ValidateTokenBudget(quota int, period string, timezone string) error {
+	if quota < 0 {
+		return errors.New("周期预算不能为负数")
+	}
+	if quota == 0 {
+		return nil
+	}
+	if !IsValidPlanPeriod(period) {
+		return errors.New("周期预算的重置周期无效")
+	}
+	if _, err := GetBudgetLocation(timezone); err != nil {
+		return fmt.Errorf("周期预算的时区无效: %s", timezone)
+	}
+	return nil
+}
+
+// TokenBudgetPeriodBounds 返回 now 所在预算周期的起止时间，周从周一开始
+func TokenBudgetPeriodBounds(period string, timezone string, now time.Time) (int64, int64) {
+	loc, err := GetBudgetLocation(timezone)
+	if err != nil {
+		loc = time.Local
+	}
+	t := now.In(loc)
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	var end time.Time
+	switch period {
+	case PlanPeriodWeek:
+		start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+		end = start.AddDate(0, 0, 7)
+	case PlanPeriodMonth:
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		end = start.AddDate(0, 1, 0)
+	default:
+		end = start.AddDate(0, 0, 1)
+	}
+	return start.Unix(), end.Unix()
+}
+
+func (token *Token) HasBudget() bool {
+	return token.BudgetQuota > 0 && IsValidPlanPeriod(token.BudgetPeriod)
+}
+
+// GetBudgetUsed 返回当前周期已用预算，记录属于以往周期时视为 0
+func (token *Token) GetBudgetUsed(now time.Time) int {
+	if !token.HasBudget() {
+		return 0
+	}
+	start, _ := TokenBudgetPeriodBounds(token.BudgetPeriod, token.BudgetTimezone, now)
+	if token.BudgetPeriodStart != start {
+		return 0
+	}
+	return max(token.BudgetUsed, 0)
+}
+
+// GetBudgetRemain 返回当前周期剩余预算
+func (token *Token) GetBudgetRemain(now time.Time) int {
+	return max(token.BudgetQuota-token.GetBudgetUsed(now), 0)
+}
+
+// tokenBudgetScript 周期切换时先清零再累加，退款不会使已用预算小于 0
+const tokenBudgetScript = `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local start = tonumber(redis.call('HGET', KEYS[1], 'BudgetPeriodStart') or '0') or 0
+local current = tonumber(ARGV[1])
+if start > current then
+	return 0
+end
+if start < current then
+	redis.call('HSET', KEYS[1], 'BudgetPeriodStart', ARGV[1], 'BudgetUsed', 0)
+end
+local used = redis.call('HINCRBY', KEYS[1], 'BudgetUsed', ARGV[2])
+if used < 0 then
+	redis.call('HSET', KEYS[1], 'BudgetUsed', 0)
+end
+return 1
+`
+
+var tokenBudgetRedisScript = redis.NewScript(tokenBudgetScript)
+
+func cacheIncrTokenBudget(key string, periodStart int64, delta int) error {
+	hmacKey := common.GenerateHMAC(key)
+	return tokenBudgetRedisScript.Run(context.Background(), common.RDB, []string{fmt.Sprintf("token:%s", hmacKey)}, periodStart, delta).Err()
+}
+
+// IncreaseTokenBudgetUsed 累加（delta 为负时退还）令牌当前周期的已用预算。
+// 预算需要立即生效，不走批量更新
+func IncreaseTokenBudgetUsed(id int, key string, period string, timezone string, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	start, _ := TokenBudgetPeriodBounds(period, timezone, time.Now())
+	if common.RedisEnabled {
+		gopool.Go(func() {
+			if err := cacheIncrTokenBudget(key, start, delta); err != nil {
+				common.SysLog("failed to update token budget cache: " + err.Error())
+			}
+		})
+	}
+	// 进入新周期时清零，条件更新保证并发下只清零一次
+	err := DB.Model(&Token{}).Where("id = ? AND budget_period_start < ?", id, start).Updates(map[string]interface{}{
+		"budget_used":         0,
+		"budget_period_start": start,
+	}).Error
+	if err != nil {
+		return err
+	}
+	var expr clause.Expr
+	if delta > 0 {
+		expr = gorm.Expr("budget_used + ?", delta)
+	} else {
+		expr = gorm.Expr("CASE WHEN budget_used > ? THEN budget_used - ? ELSE 0 END", -delta, -delta)
+	}
+	return DB.Model(&Token{}).Where("id = ? AND budget_period_start = ?", id, start).Update("budget_used", expr).Error
+}
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBudgetPeriodBounds(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("time zone data unavailable")
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data unavailable")
+	}
+	tests := []struct {
+		name      string
+		period    string
+		timezone  string
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			name:      "day in UTC",
+			period:    PlanPeriodDay,
+			timezone:  "UTC",
+			now:       time.Date(2024, 3, 15, 13, 45, 0, 0, time.UTC),
+			wantStart: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "day follows token time zone",
+			period:    PlanPeriodDay,
+			timezone:  "Asia/Shanghai",
+			now:       time.Date(2024, 3, 15, 20, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2024, 3, 16, 0, 0, 0, 0, shanghai),
+			wantEnd:   time.Date(2024, 3, 17, 0, 0, 0, 0, shanghai),
+		},
+		{
+			name:      "week starts on monday",
+			period:    PlanPeriodWeek,
+			timezone:  "UTC",
+			now:       time.Date(2024, 3, 17, 23, 0, 0, 0, time.UTC), // 周日
+			wantStart: time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "week on monday itself",
+			period:    PlanPeriodWeek,
+			timezone:  "UTC",
+			now:       time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "month crosses year end",
+			period:    PlanPeriodMonth,
+			timezone:  "UTC",
+			now:       time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "month in leap february",
+			period:    PlanPeriodMonth,
+			timezone:  "UTC",
+			now:       time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "day across daylight saving change is 23 hours",
+			period:    PlanPeriodDay,
+			timezone:  "America/New_York",
+			now:       time.Date(2024, 3, 10, 12, 0, 0, 0, newYork),
+			wantStart: time.Date(2024, 3, 10, 0, 0, 0, 0, newYork),
+			wantEnd:   time.Date(2024, 3, 11, 0, 0, 0, 0, newYork),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := TokenBudgetPeriodBounds(tt.period, tt.timezone, tt.now)
+			if start != tt.wantStart.Unix() || end != tt.wantEnd.Unix() {
+				t.Fatalf("bounds = [%s, %s), want [%s, %s)",
+					time.Unix(start, 0).UTC(), time.Unix(end, 0).UTC(), tt.wantStart.UTC(), tt.wantEnd.UTC())
+			}
+		})
+	}
+}
+
+func TestTokenUpdateKeepsBudgetUsed(t *testing.T) {
+	setupTestDB(t, &Token{})
+	token := &Token{Key: "budget-test", Name: "old", Status: 1, BudgetQuota: 1000, BudgetPeriod: PlanPeriodDay}
+	if err := DB.Create(token).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 编辑前读取的令牌副本
+	edited, err := GetTokenById(token.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 编辑期间请求累加了已用预算
+	if err := IncreaseTokenBudgetUsed(token.Id, token.Key, PlanPeriodDay, "", 300); err != nil {
+		t.Fatal(err)
+	}
+	edited.Name = "new"
+	if err := edited.Update(); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := GetTokenById(token.Id)
+	if got.Name != "new" || got.GetBudgetUsed(time.Now()) != 300 {
+		t.Fatalf("after Update name = %q budget used = %d, want new 300", got.Name, got.GetBudgetUsed(time.Now()))
+	}
+
+	got.BudgetPeriod = PlanPeriodWeek
+	if err := got.UpdateAndResetBudget(); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = GetTokenById(token.Id)
+	if got.BudgetUsed != 0 || got.BudgetPeriodStart != 0 {
+		t.Fatalf("after reset budget used = %d period start = %d, want 0 0", got.BudgetUsed, got.BudgetPeriodStart)
+	}
+}
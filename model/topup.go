@@ -83,7 +83,11 @@ func Recharge(referenceId string, customerId string) (err error) {
 		}
 
 		quota = topUp.Money * common.QuotaPerUnit
-		err = tx.Model(&User{}).Where("id = ?", topUp.UserId).Updates(map[string]interface{}{"stripe_customer": customerId, "quota": gorm.Expr("quota + ?", quota)}).Error
+		err = tx.Model(&User{}).Where("id = ?", topUp.UserId).Updates(map[string]interface{}{"stripe_customer": customerId, "quota": gorm.Expr("quota + ?", int(quota))}).Error
+		if err != nil {
+			return err
+		}
+		err = recordQuotaLedgerTx(tx, topUp.UserId, int(quota), LedgerRef{Reason: LedgerReasonTopup, RefType: LedgerRefTradeNo, RefId: topUp.TradeNo})
 		if err != nil {
 			return err
 		}
@@ -125,3 +125,75 @@ func GetAllQuotaDates(startTime int64, endTime int64, username string) (quotaDat
 	err = DB.Table("quota_data").Select("model_name, sum(count) as count, sum(quota) as quota, sum(token_used) as token_used, created_at").Where("created_at >= ? and created_at <= ?", startTime, endTime).Group("model_name, created_at").Find(&quotaDatas).Error
 	return quotaDatas, err
 }
+
+type monthlyTokenUsageCache struct {
+	since     int64
+	tokens    int64
+	expiresAt int64
+}
+
+var (
+	monthlyTokenUsageCaches    sync.Map
+	monthlyTokenUsageSweepLock sync.Mutex
+	monthlyTokenUsageLastSweep int64
+)
+
+// GetUserMonthlyTokenUsage 返回用户自 since 起累计使用的 token 数，结果缓存一分钟。
+// 开启消费日志时从日志（含已删除日期的日志汇总）统计，否则使用数据看板（quota_data），两者都未开启时为 0
+func GetUserMonthlyTokenUsage(userId int, since int64) (int64, error) {
+	now := common.GetTimestamp()
+	if cached, ok := monthlyTokenUsageCaches.Load(userId); ok {
+		c := cached.(*monthlyTokenUsageCache)
+		if c.since == since && c.expiresAt > now {
+			return c.tokens, nil
+		}
+	}
+	var tokens int64
+	var err error
+	if common.LogConsumeEnabled {
+		tokens, err = sumUserLogTokens(userId, since)
+	} else {
+		err = DB.Table("quota_data").Select("COALESCE(sum(token_used), 0)").Where("user_id = ? and created_at >= ?", userId, since).Scan(&tokens).Error
+	}
+	if err != nil {
+		return 0, err
+	}
+	monthlyTokenUsageCaches.Store(userId, &monthlyTokenUsageCache{since: since, tokens: tokens, expiresAt: now + 60})
+	sweepMonthlyTokenUsageCaches(now)
+	return tokens, nil
+}
+
+func sumUserLogTokens(userId int, since int64) (int64, error) {
+	var tokens int64
+	err := LOG_DB.Table("logs").Select("COALESCE(sum(prompt_tokens + completion_tokens), 0)").
+		Where("user_id = ? AND type IN ? AND created_at >= ?", userId, []int{LogTypeConsume, LogTypeCacheHit}, since).
+		Scan(&tokens).Error
+	if err != nil {
+		return 0, err
+	}
+	if tx, ok := logRollupRangeQuery(since, 0, LogRollupFilter{UserId: userId}); ok {
+		var rollupTokens int64
+		if err := tx.Select("COALESCE(sum(prompt_tokens + completion_tokens), 0)").Scan(&rollupTokens).Error; err != nil {
+			return 0, err
+		}
+		tokens += rollupTokens
+	}
+	return tokens, nil
+}
+
+// sweepMonthlyTokenUsageCaches 每分钟最多清理一次已过期的缓存，避免不再请求的用户一直占用内存
+func sweepMonthlyTokenUsageCaches(now int64) {
+	monthlyTokenUsageSweepLock.Lock()
+	if now-monthlyTokenUsageLastSweep < 60 {
+		monthlyTokenUsageSweepLock.Unlock()
+		return
+	}
+	monthlyTokenUsageLastSweep = now
+	monthlyTokenUsageSweepLock.Unlock()
+	monthlyTokenUsageCaches.Range(func(key, value any) bool {
+		if value.(*monthlyTokenUsageCache).expiresAt <= now {
+			monthlyTokenUsageCaches.Delete(key)
+		}
+		return true
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"one-api/common"
 	"one-api/dto"
 	"one-api/logger"
+	"one-api/setting/operation_setting"
 	"strconv"
 	"strings"
 
@@ -366,6 +367,9 @@ func (user *User) TransferAffQuotaToQuota(quota int) error {
 	if err := tx.Save(user).Error; err != nil {
 		return err
 	}
+	if err := recordQuotaLedgerTx(tx, user.Id, quota, LedgerRef{Reason: LedgerReasonAffTransfer}); err != nil {
+		return err
+	}
 
 	// 提交事务
 	return tx.Commit().Error
@@ -390,9 +394,17 @@ func (user *User) Insert(inviterId int) error {
 		user.SetSetting(defaultSetting)
 	}
 
-	result := DB.Create(user)
-	if result.Error != nil {
-		return result.Error
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return recordQuotaLedgerTx(tx, user.Id, user.Quota, LedgerRef{
+			Reason:    LedgerReasonRegister,
+			ExpiresAt: operation_setting.CreditExpiresAt(common.GetTimestamp(), operation_setting.GetCreditLotSetting().RegisterValidDays),
+		})
+	})
+	if err != nil {
+		return err
 	}
 
 	// 用户创建成功后，根据角色初始化边栏配置
@@ -415,7 +427,10 @@ func (user *User) Insert(inviterId int) error {
 	}
 	if inviterId != 0 {
 		if common.QuotaForInvitee > 0 {
-			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true)
+			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true, LedgerRef{
+				Reason:    LedgerReasonInviteReward,
+				ExpiresAt: operation_setting.CreditExpiresAt(common.GetTimestamp(), operation_setting.GetCreditLotSetting().InviteValidDays),
+			})
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", logger.LogQuota(common.QuotaForInvitee)))
 		}
 		if common.QuotaForInviter > 0 {
@@ -467,7 +482,14 @@ func (user *User) Edit(updatePassword bool) error {
 	}
 
 	DB.First(&user, user.Id)
-	if err = DB.Model(user).Updates(updates).Error; err != nil {
+	quotaDelta := newUser.Quota - user.Quota
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		return recordQuotaLedgerTx(tx, user.Id, quotaDelta, LedgerRef{Reason: LedgerReasonAdminAdjust})
+	})
+	if err != nil {
 		return err
 	}
 
@@ -749,7 +771,7 @@ func GetUserSetting(id int, fromDB bool) (settingMap dto.UserSetting, err error)
 	return userBase.GetSetting(), nil
 }
 
-func IncreaseUserQuota(id int, quota int, db bool) (err error) {
+func IncreaseUserQuota(id int, quota int, db bool, ref LedgerRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
@@ -760,21 +782,13 @@ func IncreaseUserQuota(id int, quota int, db bool) (err error) {
 		}
 	})
 	if !db && common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeUserQuota, id, quota)
+		addUserQuotaRecord(id, quota, ref)
 		return nil
 	}
-	return increaseUserQuota(id, quota)
+	return updateUserQuotaWithLedger(id, quota, []*QuotaLedger{ref.newEntry(id, quota)})
 }
 
-func increaseUserQuota(id int, quota int) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", quota)).Error
-	if err != nil {
-		return err
-	}
-	return err
-}
-
-func DecreaseUserQuota(id int, quota int) (err error) {
+func DecreaseUserQuota(id int, quota int, ref LedgerRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
@@ -785,28 +799,20 @@ func DecreaseUserQuota(id int, quota int) (err error) {
 		}
 	})
 	if common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeUserQuota, id, -quota)
+		addUserQuotaRecord(id, -quota, ref)
 		return nil
 	}
-	return decreaseUserQuota(id, quota)
-}
-
-func decreaseUserQuota(id int, quota int) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota - ?", quota)).Error
-	if err != nil {
-		return err
-	}
-	return err
+	return updateUserQuotaWithLedger(id, -quota, []*QuotaLedger{ref.newEntry(id, -quota)})
 }
 
-func DeltaUpdateUserQuota(id int, delta int) (err error) {
+func DeltaUpdateUserQuota(id int, delta int, ref LedgerRef) (err error) {
 	if delta == 0 {
 		return nil
 	}
 	if delta > 0 {
-		return IncreaseUserQuota(id, delta, false)
+		return IncreaseUserQuota(id, delta, false, ref)
 	} else {
-		return DecreaseUserQuota(id, -delta)
+		return DecreaseUserQuota(id, -delta, ref)
 	}
 }
 
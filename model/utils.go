@@ -70,12 +70,16 @@ func batchUpdate() {
 		batchUpdateLocks[i].Lock()
 		store := batchUpdateStores[i]
 		batchUpdateStores[i] = make(map[int]int)
+		var ledgers map[int][]*QuotaLedger
+		if i == BatchUpdateTypeUserQuota {
+			ledgers = takePendingQuotaLedgers()
+		}
 		batchUpdateLocks[i].Unlock()
 		// TODO: maybe we can combine updates with same key?
 		for key, value := range store {
 			switch i {
 			case BatchUpdateTypeUserQuota:
-				err := increaseUserQuota(key, value)
+				err := updateUserQuotaWithLedger(key, value, ledgers[key])
 				if err != nil {
 					common.SysLog("failed to batch update user quota: " + err.Error())
 				}
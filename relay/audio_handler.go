@@ -9,6 +9,7 @@ import (
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
 	"one-api/service"
+	"one-api/tracing"
 	"one-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -60,7 +61,9 @@ func AudioHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 		}
 	}
 
+	_, responseSpan := tracing.StartSpan(c, "DoResponse")
 	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	responseSpan.End()
 	if newAPIError != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
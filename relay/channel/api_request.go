@@ -7,12 +7,14 @@ import (
 	"io"
 	"net/http"
 	common2 "one-api/common"
+	constant2 "one-api/constant"
 	"one-api/logger"
 	"one-api/relay/common"
 	"one-api/relay/constant"
 	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting/operation_setting"
+	"one-api/tracing"
 	"one-api/types"
 	"sync"
 	"time"
@@ -20,6 +22,7 @@ import (
 	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func SetupApiRequestHeader(info *common.RelayInfo, c *gin.Context, req *http.Header) {
@@ -242,6 +245,10 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 	} else {
 		client = service.GetHttpClient()
 	}
+	// 对冲请求落败时通过该上下文中断上游请求
+	if hedgeCtx, ok := common2.GetContextKeyType[context.Context](c, constant2.ContextKeyHedgeContext); ok {
+		req = req.WithContext(hedgeCtx)
+	}
 
 	var stopPinger context.CancelFunc
 	if info.IsStream {
@@ -263,13 +270,27 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		}
 	}
 
+	// 上游请求 span 只覆盖到收到响应头，响应体的读取与转换由各 handler 单独记录
+	spanCtx, span := tracing.StartSpan(c, "upstream request",
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", req.URL.Host),
+		tracing.AttrChannelId.Int(info.ChannelId),
+		tracing.AttrModel.String(info.UpstreamModelName),
+	)
+	defer span.End()
+	tracing.InjectUpstream(spanCtx, req.Header)
+	payloadCapture := service.CaptureUpstreamRequest(c, info, req)
+
 	resp, err := client.Do(req)
 	if err != nil {
+		tracing.SetError(span, err.Error())
 		return nil, types.NewError(err, types.ErrorCodeDoRequestFailed, types.ErrOptionWithHideErrMsg("upstream error: do request failed"))
 	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 	if resp == nil {
 		return nil, errors.New("resp is nil")
 	}
+	payloadCapture.CaptureResponse(resp)
 
 	_ = req.Body.Close()
 	_ = c.Request.Body.Close()
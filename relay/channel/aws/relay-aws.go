@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/dto"
+	"one-api/logger"
 	"one-api/relay/channel/claude"
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
@@ -211,10 +212,10 @@ func awsStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 				return respErr, nil
 			}
 		case *bedrockruntimeTypes.UnknownUnionMember:
-			fmt.Println("unknown tag:", v.Tag)
+			logger.LogWarn(c, "unknown bedrock stream tag: "+v.Tag)
 			return types.NewError(errors.New("unknown response type"), types.ErrorCodeInvalidRequest), nil
 		default:
-			fmt.Println("union is nil or unknown type")
+			logger.LogWarn(c, "bedrock stream union is nil or unknown type")
 			return types.NewError(errors.New("nil or unknown response type"), types.ErrorCodeInvalidRequest), nil
 		}
 	}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/dto"
+	"one-api/logger"
 	"one-api/model"
 	relaycommon "one-api/relay/common"
 	"one-api/service"
@@ -85,8 +86,7 @@ func (a *Adaptor) DoRequest(c *gin.Context, baseURL string, channelKey string) (
 	}
 
 	// 调试信息
-	fmt.Printf("[DEBUG Bltcy] Method: %s, targetURL: %s, bodyLen: %d\n",
-		c.Request.Method, targetURL, len(requestBody))
+	logger.LogDebug(c, fmt.Sprintf("[Bltcy] Method: %s, targetURL: %s, bodyLen: %d", c.Request.Method, targetURL, len(requestBody)))
 
 	// 创建请求
 	req, err := http.NewRequest(c.Request.Method, targetURL, bytes.NewReader(requestBody))
@@ -100,10 +100,10 @@ func (a *Adaptor) DoRequest(c *gin.Context, baseURL string, channelKey string) (
 	var timeout time.Duration
 	if c.Request.Method == "GET" {
 		timeout = time.Second * 120 // GET 请求 120 秒超时
-		fmt.Printf("[DEBUG Bltcy] Using GET request timeout: %v\n", timeout)
+		logger.LogDebug(c, fmt.Sprintf("[Bltcy] Using GET request timeout: %v", timeout))
 	} else {
 		timeout = time.Second * 900 // POST/PUT 请求 900 秒超时，支持大文件上传
-		fmt.Printf("[DEBUG Bltcy] Using POST/PUT request timeout: %v\n", timeout)
+		logger.LogDebug(c, fmt.Sprintf("[Bltcy] Using POST/PUT request timeout: %v", timeout))
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
@@ -141,14 +141,12 @@ func (a *Adaptor) DoRequest(c *gin.Context, baseURL string, channelKey string) (
 	if err != nil {
 		cancel()
 		// 记录详细错误信息，包括目标 URL 和错误类型
-		fmt.Printf("[ERROR Bltcy] Request failed: method=%s, url=%s, error=%v\n",
-			c.Request.Method, targetURL, err)
+		logger.LogError(c, fmt.Sprintf("[Bltcy] Request failed: method=%s, url=%s, error=%v", c.Request.Method, targetURL, err))
 		return nil, nil, fmt.Errorf("failed to send request to legacy gateway: %w", err)
 	}
 
 	// 记录响应状态码
-	fmt.Printf("[DEBUG Bltcy] Response received: status=%d, method=%s, url=%s\n",
-		resp.StatusCode, c.Request.Method, targetURL)
+	logger.LogDebug(c, fmt.Sprintf("[Bltcy] Response received: status=%d, method=%s, url=%s", resp.StatusCode, c.Request.Method, targetURL))
 
 	return resp, cancel, nil
 }
@@ -241,7 +239,7 @@ func RelayBltcy(c *gin.Context) {
 				cancel()
 			}
 			if attempt < maxRetries {
-				fmt.Printf("[DEBUG Bltcy] Request failed (attempt %d/%d), retrying in 2s: %s\n", attempt, maxRetries, err.Error())
+				logger.LogDebug(c, fmt.Sprintf("[Bltcy] Request failed (attempt %d/%d), retrying in 2s: %s", attempt, maxRetries, err.Error()))
 				time.Sleep(2 * time.Second)
 				continue
 			}
@@ -254,12 +252,11 @@ func RelayBltcy(c *gin.Context) {
 		}
 
 		// 🆕 添加详细日志，追踪状态码和重试条件
-		fmt.Printf("[DEBUG Bltcy] Response status: %d, isGetRequest: %v, attempt: %d, maxRetries: %d\n",
-			resp.StatusCode, isGetRequest, attempt, maxRetries)
+		logger.LogDebug(c, fmt.Sprintf("[Bltcy] Response status: %d, isGetRequest: %v, attempt: %d, maxRetries: %d", resp.StatusCode, isGetRequest, attempt, maxRetries))
 
 		// GET 请求：如果遇到 5xx 错误且可以重试，则重试
 		if isGetRequest && resp.StatusCode >= 500 && attempt < maxRetries {
-			fmt.Printf("[DEBUG Bltcy] GET request returned %d (attempt %d/%d), retrying in 2s\n", resp.StatusCode, attempt, maxRetries)
+			logger.LogDebug(c, fmt.Sprintf("[Bltcy] GET request returned %d (attempt %d/%d), retrying in 2s", resp.StatusCode, attempt, maxRetries))
 			resp.Body.Close()
 			if cancel != nil {
 				cancel()
@@ -282,8 +279,7 @@ func RelayBltcy(c *gin.Context) {
 				strings.Contains(errStr, "timeout")
 
 			if isGetRequest && isTimeoutError && attempt < maxRetries {
-				fmt.Printf("[WARN Bltcy] Response read timeout (attempt %d/%d), retrying in 2s: %s\n",
-					attempt, maxRetries, errStr)
+				logger.LogWarn(c, fmt.Sprintf("[Bltcy] Response read timeout (attempt %d/%d), retrying in 2s: %s", attempt, maxRetries, errStr))
 				resp.Body.Close()
 				if cancel != nil {
 					cancel()
@@ -295,7 +291,7 @@ func RelayBltcy(c *gin.Context) {
 
 			// 如果不能重试或已达最大重试次数，返回错误
 			errMsg := fmt.Sprintf("处理响应失败: %s", err.Error())
-			fmt.Printf("[ERROR Bltcy] DoResponse failed after %d attempts: %s\n", attempt, errMsg)
+			logger.LogError(c, fmt.Sprintf("[Bltcy] DoResponse failed after %d attempts: %s", attempt, errMsg))
 			c.JSON(http.StatusInternalServerError, dto.TaskError{
 				Code:       "response_processing_failed",
 				Message:    errMsg,
@@ -307,12 +303,11 @@ func RelayBltcy(c *gin.Context) {
 		// 请求和响应读取都成功，跳出循环
 		break
 	}
-	fmt.Printf("[DEBUG Bltcy] DoResponse success, body size: %d bytes\n", len(responseBody))
+	logger.LogDebug(c, fmt.Sprintf("[Bltcy] DoResponse success, body size: %d bytes", len(responseBody)))
 
 	// 🆕 如果 POST 请求收到 5xx 错误，记录详细日志
 	if !isGetRequest && resp.StatusCode >= 500 {
-		fmt.Printf("[WARN Bltcy] POST/PUT request returned 5xx error: status=%d, body=%s\n",
-			resp.StatusCode, string(responseBody))
+		logger.LogWarn(c, fmt.Sprintf("[Bltcy] POST/PUT request returned 5xx error: status=%d, body=%s", resp.StatusCode, string(responseBody)))
 	}
 
 	// 🆕 判断是否为轮询请求（不计费）
@@ -322,22 +317,19 @@ func RelayBltcy(c *gin.Context) {
 		(c.Request.Method == "POST" && strings.Contains(c.Request.URL.Path, "/feed"))
 
 	// 🆕 添加详细调试日志
-	fmt.Printf("[DEBUG Bltcy Billing Check] Method: %s, Path: %s, isGetRequest: %v, contains /feed: %v, isPollingRequest: %v\n",
-		c.Request.Method, c.Request.URL.Path, isGetRequest,
-		strings.Contains(c.Request.URL.Path, "/feed"), isPollingRequest)
+	logger.LogDebug(c, fmt.Sprintf("[Bltcy Billing Check] Method: %s, Path: %s, isGetRequest: %v, contains /feed: %v, isPollingRequest: %v", c.Request.Method, c.Request.URL.Path, isGetRequest, strings.Contains(c.Request.URL.Path, "/feed"), isPollingRequest))
 
     if isPollingRequest {
 		requestType := "GET"
 		if !isGetRequest {
 			requestType = "POST /feed (polling)"
 		}
-		fmt.Printf("[DEBUG Bltcy] %s request completed with status %d (no billing)\n", requestType, resp.StatusCode)
+		logger.LogDebug(c, fmt.Sprintf("[Bltcy] %s request completed with status %d (no billing)", requestType, resp.StatusCode))
 
 		// 🆕 如果上游返回 5xx 错误，记录详细日志但直接返回原始响应
 		// 让客户端知道真实的错误状态，而不是掩盖它
 		if resp.StatusCode >= 500 {
-			fmt.Printf("[WARN Bltcy] Upstream returned 5xx error: %d, body: %s\n",
-				resp.StatusCode, string(responseBody))
+			logger.LogWarn(c, fmt.Sprintf("[Bltcy] Upstream returned 5xx error: %d, body: %s", resp.StatusCode, string(responseBody)))
 			// 不再转换为 202，直接返回真实状态码和错误信息
 		}
 
@@ -383,9 +375,9 @@ func RelayBltcy(c *gin.Context) {
                 relayInfo := &relaycommon.RelayInfo{UserId: userId, TokenId: tokenId, UsingGroup: group}
                 relayInfo.ChannelMeta = &relaycommon.ChannelMeta{ChannelId: channelId}
                 if err := service.PostConsumeQuota(relayInfo, -refundQuota, 0, false); err != nil {
-                    common.SysLog(fmt.Sprintf("[Bltcy Refund] 轮询检测到退款事件，返还失败: %v", err))
+                    logger.LogError(c, fmt.Sprintf("[Bltcy Refund] 轮询检测到退款事件，返还失败: %v", err))
                 } else {
-                    common.SysLog(fmt.Sprintf("[Bltcy Refund] 轮询检测到退款事件，已返还配额 %d (model=%s)", refundQuota, billingName))
+                    logger.LogInfo(c, fmt.Sprintf("[Bltcy Refund] 轮询检测到退款事件，已返还配额 %d (model=%s)", refundQuota, billingName))
                 }
             }
         }
@@ -421,7 +413,7 @@ func RelayBltcy(c *gin.Context) {
 
 	// 🆕 获取具体的模型名（如 "gen4_turbo", "kling-v1-6"）
 	billingModelName := c.GetString("billing_model_name")
-	fmt.Printf("[DEBUG Bltcy] serviceName: %s, billingModelName: %s\n", serviceName, billingModelName)
+	logger.LogDebug(c, fmt.Sprintf("[Bltcy] serviceName: %s, billingModelName: %s", serviceName, billingModelName))
 
 	// 如果上下文中没有 billing_model_name，从原始请求体提取
 	if billingModelName == "" {
@@ -432,10 +424,10 @@ func RelayBltcy(c *gin.Context) {
 					// 支持 model 和 model_name 字段
 					if model, ok := reqBody["model"].(string); ok && model != "" {
 						billingModelName = model
-						fmt.Printf("[DEBUG Bltcy] Extracted model from original body: %s\n", model)
+						logger.LogDebug(c, fmt.Sprintf("[Bltcy] Extracted model from original body: %s", model))
 					} else if modelName, ok := reqBody["model_name"].(string); ok && modelName != "" {
 						billingModelName = modelName
-						fmt.Printf("[DEBUG Bltcy] Extracted model_name from original body: %s\n", modelName)
+						logger.LogDebug(c, fmt.Sprintf("[Bltcy] Extracted model_name from original body: %s", modelName))
 					}
 				}
 			}
@@ -445,7 +437,7 @@ func RelayBltcy(c *gin.Context) {
 	if billingModelName == "" {
 		// 如果还是没有具体模型名，使用服务名
 		billingModelName = serviceName
-		fmt.Printf("[DEBUG Bltcy] billing_model_name is empty, fallback to serviceName: %s\n", serviceName)
+		logger.LogDebug(c, fmt.Sprintf("[Bltcy] billing_model_name is empty, fallback to serviceName: %s", serviceName))
 	}
 
 	// 🆕 查询模型价格，计算实际配额
@@ -464,13 +456,11 @@ func RelayBltcy(c *gin.Context) {
 		modelPrice = price
 		actualQuota = int(price * common.QuotaPerUnit * groupRatio * channelRatio)
 		priceSource = "price"
-		fmt.Printf("[DEBUG Bltcy Billing] Model: %s, Price: $%.4f, GroupRatio: %.2f, ChannelRatio: %.2f, Quota: %d\n",
-			billingModelName, price, groupRatio, channelRatio, actualQuota)
+		logger.LogDebug(c, fmt.Sprintf("[Bltcy Billing] Model: %s, Price: $%.4f, GroupRatio: %.2f, ChannelRatio: %.2f, Quota: %d", billingModelName, price, groupRatio, channelRatio, actualQuota))
 	} else {
 		// 如果没有配置价格，使用基础配额（也需要应用倍率）
 		actualQuota = int(float64(baseQuota) * groupRatio * channelRatio)
-		fmt.Printf("[DEBUG Bltcy Billing] Model: %s, Using base quota: %d, GroupRatio: %.2f, ChannelRatio: %.2f, Final: %d\n",
-			billingModelName, baseQuota, groupRatio, channelRatio, actualQuota)
+		logger.LogDebug(c, fmt.Sprintf("[Bltcy Billing] Model: %s, Using base quota: %d, GroupRatio: %.2f, ChannelRatio: %.2f, Final: %d", billingModelName, baseQuota, groupRatio, channelRatio, actualQuota))
 	}
 
     // 计费（在发送响应之前完成）
@@ -490,7 +480,7 @@ func RelayBltcy(c *gin.Context) {
 			true,
 		)
 		if err != nil {
-			common.SysLog(fmt.Sprintf("计费失败: %s", err.Error()))
+			logger.LogError(c, fmt.Sprintf("计费失败: %s", err.Error()))
 		}
 
 		// 🆕 记录消费日志，使用具体模型名
@@ -552,9 +542,9 @@ func RelayBltcy(c *gin.Context) {
             relayInfo.ChannelMeta = &relaycommon.ChannelMeta{ChannelId: channelId}
             // 返还等额配额
             if err := service.PostConsumeQuota(relayInfo, -actualQuota, 0, false); err != nil {
-                common.SysLog(fmt.Sprintf("[Bltcy Refund] 返还配额失败: %v", err))
+                logger.LogError(c, fmt.Sprintf("[Bltcy Refund] 返还配额失败: %v", err))
             } else {
-                common.SysLog(fmt.Sprintf("[Bltcy Refund] 检测到上游退款事件，已返还配额 %d (model=%s)", actualQuota, billingModelName))
+                logger.LogInfo(c, fmt.Sprintf("[Bltcy Refund] 检测到上游退款事件，已返还配额 %d (model=%s)", actualQuota, billingModelName))
             }
         }
 
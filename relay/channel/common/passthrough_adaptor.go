@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/dto"
+	"one-api/logger"
 	"one-api/model"
 	relaycommon "one-api/relay/common"
 	"one-api/service"
@@ -163,7 +164,7 @@ func RelayPassthrough(c *gin.Context, serviceName string) {
 		resp, err = adaptor.DoRequest(c, channelId, channelKey, baseURL)
 		if err != nil {
 			if attempt < maxRetries {
-				fmt.Printf("[DEBUG %s Passthrough] GET request failed (attempt %d/%d), retrying in 1s: %s\n", serviceName, attempt, maxRetries, err.Error())
+				logger.LogDebug(c, fmt.Sprintf("[%s Passthrough] GET request failed (attempt %d/%d), retrying in 1s: %s", serviceName, attempt, maxRetries, err.Error()))
 				time.Sleep(1 * time.Second)
 				continue
 			}
@@ -177,7 +178,7 @@ func RelayPassthrough(c *gin.Context, serviceName string) {
 
 		// GET 请求：如果遇到 5xx 错误且可以重试，则重试
 		if isGetRequest && resp.StatusCode >= 500 && attempt < maxRetries {
-			fmt.Printf("[DEBUG %s Passthrough] GET request returned %d (attempt %d/%d), retrying in 1s\n", serviceName, resp.StatusCode, attempt, maxRetries)
+			logger.LogDebug(c, fmt.Sprintf("[%s Passthrough] GET request returned %d (attempt %d/%d), retrying in 1s", serviceName, resp.StatusCode, attempt, maxRetries))
 			resp.Body.Close()
 			time.Sleep(1 * time.Second)
 			continue
@@ -204,12 +205,12 @@ func RelayPassthrough(c *gin.Context, serviceName string) {
 
 	// 🆕 GET 请求（查询状态）不计费，直接返回响应
 	if isGetRequest {
-		fmt.Printf("[DEBUG %s Passthrough] GET request completed with status %d, skipping billing\n", serviceName, statusCode)
+		logger.LogDebug(c, fmt.Sprintf("[%s Passthrough] GET request completed with status %d, skipping billing", serviceName, statusCode))
 
 		// 🆕 如果上游返回 5xx 错误，转换为 202 Accepted（任务处理中）
 		finalStatusCode := statusCode
 		if statusCode >= 500 {
-			fmt.Printf("[DEBUG %s Passthrough] Converting upstream 5xx error to 202 Accepted\n", serviceName)
+			logger.LogDebug(c, fmt.Sprintf("[%s Passthrough] Converting upstream 5xx error to 202 Accepted", serviceName))
 			finalStatusCode = http.StatusAccepted
 			// 返回友好的 JSON 响应
 			c.JSON(finalStatusCode, gin.H{
@@ -241,7 +242,7 @@ func RelayPassthrough(c *gin.Context, serviceName string) {
 			true,
 		)
 		if err != nil {
-			common.SysLog(fmt.Sprintf("error consuming quota: %s", err.Error()))
+			logger.LogInfo(c, fmt.Sprintf("error consuming quota: %s", err.Error()))
 		}
 
 		// 记录消费日志（使用参数化的服务名）
@@ -6,8 +6,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"one-api/common"
 	"one-api/dto"
+	"one-api/logger"
 	"one-api/relay/channel"
 	relaycommon "one-api/relay/common"
 	"one-api/types"
@@ -53,20 +53,20 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 	// 🔧 对于工作流请求，先过滤掉空值参数（防止透传模式下的问题）
 	// 这个过滤会直接修改request对象，确保即使在透传模式下也能过滤
 	if request.WorkflowId != "" && request.WorkflowParameters != nil {
-		filterEmptyWorkflowParameters(request)
+		filterEmptyWorkflowParameters(c, request)
 	}
 
 	// 🆕 方案A：将工作流 ID 作为模型名称，以使用系统按次计费机制
 	if request.WorkflowId != "" {
 		// 将工作流 ID 设置为模型名称，这样可以在价格配置中为每个工作流单独定价
 		info.OriginModelName = request.WorkflowId
-		common.SysLog(fmt.Sprintf("[WorkflowModel] 工作流ID作为模型名称: %s", request.WorkflowId))
+		logger.LogInfo(c, fmt.Sprintf("[WorkflowModel] 工作流ID作为模型名称: %s", request.WorkflowId))
 	}
 
 	// Check if this is an async workflow request
 	// 只有明确指定 model="coze-workflow-async" 时才使用异步执行
 	if request.Model == ModelWorkflowAsync {
-		common.SysLog(fmt.Sprintf("[Async] Detected async workflow request: model=%s, workflow_id=%s, stream=%v",
+		logger.LogInfo(c, fmt.Sprintf("[Async] Detected async workflow request: model=%s, workflow_id=%s, stream=%v",
 			request.Model, request.WorkflowId, request.Stream))
 		// 标记为异步请求，在 DoRequest 中处理
 		c.Set("is_async_workflow", true)
@@ -94,11 +94,11 @@ func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dt
 
 // DoRequest implements channel.Adaptor.
 func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
-	common.SysLog(fmt.Sprintf("DoRequest called with OriginModelName: %s", info.OriginModelName))
+	logger.LogInfo(c, fmt.Sprintf("DoRequest called with OriginModelName: %s", info.OriginModelName))
 
 	// Check if this is an async workflow request
 	if isAsync, _ := c.Get("is_async_workflow"); isAsync == true {
-		common.SysLog("[Async] Processing async workflow request in DoRequest")
+		logger.LogInfo(c, "[Async] Processing async workflow request in DoRequest")
 		requestVal, _ := c.Get("async_workflow_request")
 		request, ok := requestVal.(*dto.GeneralOpenAIRequest)
 		if !ok {
@@ -129,7 +129,7 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 	// Check if this is a sync workflow request
 	// 检查原始请求中是否有workflow_id
 	if req, ok := info.Request.(*dto.GeneralOpenAIRequest); ok && req.WorkflowId != "" {
-		common.SysLog("Processing as Coze workflow request")
+		logger.LogInfo(c, "Processing as Coze workflow request")
 		return channel.DoApiRequest(a, c, info, requestBody)
 	}
 
@@ -174,13 +174,13 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
 	// Check if async response was already sent
 	if responseSent, _ := c.Get("async_response_sent"); responseSent == true {
-		common.SysLog("[Async] Response already sent, skipping DoResponse")
+		logger.LogInfo(c, "[Async] Response already sent, skipping DoResponse")
 		// Return empty usage to avoid panic in quota consumption
 		return &dto.Usage{}, nil
 	}
 
 	// Check if this is a workflow request by checking the original request
-	common.SysLog(fmt.Sprintf("DoResponse called with OriginModelName: %s", info.OriginModelName))
+	logger.LogInfo(c, fmt.Sprintf("DoResponse called with OriginModelName: %s", info.OriginModelName))
 	if req, ok := info.Request.(*dto.GeneralOpenAIRequest); ok && req.WorkflowId != "" {
 		if info.IsStream {
 			usage, err = cozeWorkflowStreamHandler(c, info, resp)
@@ -228,8 +228,9 @@ func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
 	// 这样确保在所有模式（包括透传模式）下都能过滤
 	if req, ok := info.Request.(*dto.GeneralOpenAIRequest); ok {
 		if req.WorkflowId != "" && req.WorkflowParameters != nil {
-			filterEmptyWorkflowParameters(req)
-			common.SysLog("[Init] Coze工作流请求参数过滤完成")
+			ctx := info.LogContext()
+			filterEmptyWorkflowParameters(ctx, req)
+			logger.LogInfo(ctx, "[Init] Coze工作流请求参数过滤完成")
 		}
 	}
 }
@@ -238,12 +239,12 @@ func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
 func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *relaycommon.RelayInfo) error {
 	channel.SetupApiRequestHeader(info, c, req)
 
-	common.SysLog(fmt.Sprintf("[OAuth Debug] ChannelOtherSettings: %+v", info.ChannelOtherSettings))
-	common.SysLog(fmt.Sprintf("[OAuth Debug] CozeAuthType 原始值: '%s'", info.ChannelOtherSettings.CozeAuthType))
+	logger.LogInfo(c, fmt.Sprintf("[OAuth Debug] ChannelOtherSettings: %+v", info.ChannelOtherSettings))
+	logger.LogInfo(c, fmt.Sprintf("[OAuth Debug] CozeAuthType 原始值: '%s'", info.ChannelOtherSettings.CozeAuthType))
 	authType := info.ChannelOtherSettings.CozeAuthType
 	if authType == "" {
 		authType = "pat"
-		common.SysLog("[OAuth Debug] authType 为空，使用默认值 'pat'")
+		logger.LogInfo(c, "[OAuth Debug] authType 为空，使用默认值 'pat'")
 	}
 
 	var token string
@@ -258,14 +259,14 @@ func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *rel
 		if err != nil {
 			return fmt.Errorf("failed to get OAuth access token: %w", err)
 		}
-		common.SysLog(fmt.Sprintf("[OAuth Debug] 准备使用 OAuth token (前20字符): %s...", token[:min(20, len(token))]))
+		logger.LogInfo(c, fmt.Sprintf("[OAuth Debug] 准备使用 OAuth token (前20字符): %s...", token[:min(20, len(token))]))
 	} else {
 		token = info.ApiKey
-		common.SysLog("[OAuth Debug] 使用 PAT token 模式")
+		logger.LogInfo(c, "[OAuth Debug] 使用 PAT token 模式")
 	}
 
 	authHeader := "Bearer " + token
-	common.SysLog(fmt.Sprintf("[OAuth Debug] 设置 Authorization 头 (前30字符): %s...", authHeader[:min(30, len(authHeader))]))
+	logger.LogInfo(c, fmt.Sprintf("[OAuth Debug] 设置 Authorization 头 (前30字符): %s...", authHeader[:min(30, len(authHeader))]))
 	req.Set("Authorization", authHeader)
 	return nil
 }
@@ -2,6 +2,7 @@ package coze
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"one-api/common"
 	"one-api/constant"
 	"one-api/dto"
+	"one-api/logger"
 	"one-api/model"
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
@@ -80,25 +82,25 @@ func handleAsyncWorkflowRequest(c *gin.Context, info *relaycommon.RelayInfo, req
 		return nil, fmt.Errorf("failed to create async task: %w", err)
 	}
 
-	common.SysLog(fmt.Sprintf("[Async] Created local task %s for workflow %s", localExecuteId, request.WorkflowId))
+	logger.LogInfo(c, fmt.Sprintf("[Async] Created local task %s for workflow %s", localExecuteId, request.WorkflowId))
 
 	// 🔧 渠道预热验证: 确保渠道信息完整,避免503错误
 	// 1. 预先初始化ChannelRatio
 	if info.PriceData.GroupRatioInfo.ChannelRatio == 0 {
 		channelRatio := model.GetChannelRatio(info.UsingGroup, "coze-workflow-async", info.ChannelId)
 		info.PriceData.GroupRatioInfo.ChannelRatio = channelRatio
-		common.SysLog(fmt.Sprintf("[Async] 预初始化渠道倍率: channel_id=%d, group=%s, ratio=%.2f",
+		logger.LogInfo(c, fmt.Sprintf("[Async] 预初始化渠道倍率: channel_id=%d, group=%s, ratio=%.2f",
 			info.ChannelId, info.UsingGroup, channelRatio))
 	}
 
 	// 2. 验证渠道可用性
 	if info.ChannelId == 0 || info.ChannelBaseUrl == "" || info.ApiKey == "" {
-		common.SysLog(fmt.Sprintf("[Async] 警告: 渠道信息不完整! channel_id=%d, base_url=%s, api_key_len=%d",
+		logger.LogInfo(c, fmt.Sprintf("[Async] 警告: 渠道信息不完整! channel_id=%d, base_url=%s, api_key_len=%d",
 			info.ChannelId, info.ChannelBaseUrl, len(info.ApiKey)))
 		return nil, fmt.Errorf("渠道信息不完整,无法启动异步任务")
 	}
 
-	common.SysLog(fmt.Sprintf("[Async] 渠道预热完成: channel_id=%d, type=%d, base_url=%s",
+	logger.LogInfo(c, fmt.Sprintf("[Async] 渠道预热完成: channel_id=%d, type=%d, base_url=%s",
 		info.ChannelId, info.ChannelType, info.ChannelBaseUrl))
 
 	// 启动后台goroutine调用Coze官方异步接口
@@ -119,17 +121,18 @@ func handleAsyncWorkflowRequest(c *gin.Context, info *relaycommon.RelayInfo, req
 
 // executeWorkflowInBackground 在后台执行工作流
 func executeWorkflowInBackground(executeId string, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) {
+	ctx := info.LogContext()
 	defer func() {
 		if r := recover(); r != nil {
-			common.SysLog(fmt.Sprintf("[Async] Panic in background execution: %v", r))
+			logger.LogError(ctx, fmt.Sprintf("[Async] Panic in background execution: %v", r))
 			updateTaskStatus(executeId, model.TaskStatusFailure, fmt.Sprintf("执行异常: %v", r), "", nil, info, nil)
 		}
 	}()
 
-	common.SysLog(fmt.Sprintf("[Async] Starting background execution for task %s", executeId))
+	logger.LogInfo(ctx, fmt.Sprintf("[Async] Starting background execution for task %s", executeId))
 
 	// 更新任务状态为进行中
-	updateTaskProgress(executeId, model.TaskStatusInProgress, "0%")
+	updateTaskProgress(ctx, executeId, model.TaskStatusInProgress, "0%")
 
 	// 构造流式请求
 	streamRequest := *request
@@ -195,30 +198,30 @@ func executeWorkflowInBackground(executeId string, info *relaycommon.RelayInfo,
 		}
 	}
 
-	common.SysLog(fmt.Sprintf("[Async] 发送HTTP请求到: %s", requestURL))
+	logger.LogInfo(ctx, fmt.Sprintf("[Async] 发送HTTP请求到: %s", requestURL))
 
 	resp, err := client.Do(req)
 	if err != nil {
-		common.SysLog(fmt.Sprintf("[Async] HTTP请求失败: %v", err))
+		logger.LogError(ctx, fmt.Sprintf("[Async] HTTP请求失败: %v", err))
 		updateTaskStatus(executeId, model.TaskStatusFailure, fmt.Sprintf("请求执行失败: %v", err), "", nil, info, nil)
 		return
 	}
 	defer resp.Body.Close()
 
-	common.SysLog(fmt.Sprintf("[Async] 收到HTTP响应: status=%d", resp.StatusCode))
+	logger.LogInfo(ctx, fmt.Sprintf("[Async] 收到HTTP响应: status=%d", resp.StatusCode))
 
 	// 检查HTTP状态码
 	if resp.StatusCode != 200 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		errorMsg := fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(bodyBytes))
-		common.SysLog(fmt.Sprintf("[Async] HTTP错误: %s", errorMsg))
+		logger.LogError(ctx, fmt.Sprintf("[Async] HTTP错误: %s", errorMsg))
 		updateTaskStatus(executeId, model.TaskStatusFailure, errorMsg, "", nil, info, nil)
 		return
 	}
 
 	// 处理流式响应
 	// 注意：异步工作流可能需要很长时间，不应受到 STREAMING_TIMEOUT 限制
-	common.SysLog(fmt.Sprintf("[Async] 开始处理SSE流式响应"))
+	logger.LogInfo(ctx, fmt.Sprintf("[Async] 开始处理SSE流式响应"))
 	scanner := bufio.NewScanner(resp.Body)
 	scanner.Split(bufio.ScanLines)
 	// 设置更大的缓冲区以处理长时间流式传输
@@ -238,12 +241,12 @@ func executeWorkflowInBackground(executeId string, info *relaycommon.RelayInfo,
 		lineCount++
 
 		if lineCount%100 == 0 {
-			common.SysLog(fmt.Sprintf("[Async] 已处理%d行SSE数据", lineCount))
+			logger.LogInfo(ctx, fmt.Sprintf("[Async] 已处理%d行SSE数据", lineCount))
 		}
 
 		if strings.HasPrefix(line, "event:") {
 			currentEvent = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
-			common.SysLog(fmt.Sprintf("[Async] SSE事件类型: %s", currentEvent))
+			logger.LogInfo(ctx, fmt.Sprintf("[Async] SSE事件类型: %s", currentEvent))
 			continue
 		}
 
@@ -253,7 +256,7 @@ func executeWorkflowInBackground(executeId string, info *relaycommon.RelayInfo,
 		}
 
 		if line == "" && currentEvent != "" && currentData != "" {
-			common.SysLog(fmt.Sprintf("[Async] 处理SSE事件: %s (数据长度: %d)", currentEvent, len(currentData)))
+			logger.LogInfo(ctx, fmt.Sprintf("[Async] 处理SSE事件: %s (数据长度: %d)", currentEvent, len(currentData)))
 			// 处理事件
 			switch currentEvent {
 			case "Message":
@@ -267,7 +270,7 @@ func executeWorkflowInBackground(executeId string, info *relaycommon.RelayInfo,
 						if lastProgress > 90 {
 							lastProgress = 90
 						}
-						updateTaskProgress(executeId, model.TaskStatusInProgress, fmt.Sprintf("%d%%", lastProgress))
+						updateTaskProgress(ctx, executeId, model.TaskStatusInProgress, fmt.Sprintf("%d%%", lastProgress))
 					}
 
 					// 提取 usage
@@ -289,25 +292,25 @@ func executeWorkflowInBackground(executeId string, info *relaycommon.RelayInfo,
 
 						// 数据合理性校验：修复 Coze API 返回的异常 completion_tokens
 						if usage.CompletionTokens > usage.TotalTokens || usage.CompletionTokens < 0 {
-							common.SysLog(fmt.Sprintf("[Async] WARNING: 检测到异常 completion_tokens=%d (total=%d, prompt=%d), 自动修正",
+							logger.LogWarn(ctx, fmt.Sprintf("[Async] WARNING: 检测到异常 completion_tokens=%d (total=%d, prompt=%d), 自动修正",
 								usage.CompletionTokens, usage.TotalTokens, usage.PromptTokens))
 							usage.CompletionTokens = usage.TotalTokens - usage.PromptTokens
 							if usage.CompletionTokens < 0 {
 								usage.CompletionTokens = 0
 							}
-							common.SysLog(fmt.Sprintf("[Async] 修正后: completion_tokens=%d", usage.CompletionTokens))
+							logger.LogInfo(ctx, fmt.Sprintf("[Async] 修正后: completion_tokens=%d", usage.CompletionTokens))
 						}
 
 						// 记录 usage 变化（用于诊断）
 						if oldTotal > 0 {
 							// 检测异常：usage 不应该减少
 							if usage.TotalTokens < oldTotal {
-								common.SysLog(fmt.Sprintf("[Async] WARNING: usage 发生减少！旧值: %d, 新值: %d", oldTotal, usage.TotalTokens))
+								logger.LogWarn(ctx, fmt.Sprintf("[Async] WARNING: usage 发生减少！旧值: %d, 新值: %d", oldTotal, usage.TotalTokens))
 							}
-							common.SysLog(fmt.Sprintf("[Async] Usage 更新: Prompt %d→%d, Completion %d→%d, Total %d→%d",
+							logger.LogInfo(ctx, fmt.Sprintf("[Async] Usage 更新: Prompt %d→%d, Completion %d→%d, Total %d→%d",
 								oldPrompt, usage.PromptTokens, oldCompletion, usage.CompletionTokens, oldTotal, usage.TotalTokens))
 						} else {
-							common.SysLog(fmt.Sprintf("[Async] 首次提取 usage from Message: Prompt=%d, Completion=%d, Total=%d",
+							logger.LogInfo(ctx, fmt.Sprintf("[Async] 首次提取 usage from Message: Prompt=%d, Completion=%d, Total=%d",
 								usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens))
 						}
 					}
@@ -320,13 +323,13 @@ func executeWorkflowInBackground(executeId string, info *relaycommon.RelayInfo,
 					if upstreamExecuteId == "" {
 						if val, ok := doneData["execute_id"].(string); ok && val != "" {
 							upstreamExecuteId = val
-							common.SysLog(fmt.Sprintf("[Async] Done事件获取Coze execute_id: %s", upstreamExecuteId))
+							logger.LogInfo(ctx, fmt.Sprintf("[Async] Done事件获取Coze execute_id: %s", upstreamExecuteId))
 						}
 					}
 					if debugUrl == "" {
 						if val, ok := doneData["debug_url"].(string); ok && val != "" {
 							debugUrl = val
-							common.SysLog(fmt.Sprintf("[Async] Done事件获取Coze debug_url: %s", debugUrl))
+							logger.LogInfo(ctx, fmt.Sprintf("[Async] Done事件获取Coze debug_url: %s", debugUrl))
 						}
 					}
 					// 从 Done 事件提取 usage（如果 Message 中没有）
@@ -344,16 +347,16 @@ func executeWorkflowInBackground(executeId string, info *relaycommon.RelayInfo,
 
 							// 数据合理性校验：修复 Coze API 返回的异常 completion_tokens
 							if usage.CompletionTokens > usage.TotalTokens || usage.CompletionTokens < 0 {
-								common.SysLog(fmt.Sprintf("[Async] WARNING: Done事件检测到异常 completion_tokens=%d (total=%d, prompt=%d), 自动修正",
+								logger.LogWarn(ctx, fmt.Sprintf("[Async] WARNING: Done事件检测到异常 completion_tokens=%d (total=%d, prompt=%d), 自动修正",
 									usage.CompletionTokens, usage.TotalTokens, usage.PromptTokens))
 								usage.CompletionTokens = usage.TotalTokens - usage.PromptTokens
 								if usage.CompletionTokens < 0 {
 									usage.CompletionTokens = 0
 								}
-								common.SysLog(fmt.Sprintf("[Async] 修正后: completion_tokens=%d", usage.CompletionTokens))
+								logger.LogInfo(ctx, fmt.Sprintf("[Async] 修正后: completion_tokens=%d", usage.CompletionTokens))
 							}
 
-							common.SysLog(fmt.Sprintf("[Async] 从Done事件提取 usage: Prompt=%d, Completion=%d, Total=%d",
+							logger.LogInfo(ctx, fmt.Sprintf("[Async] 从Done事件提取 usage: Prompt=%d, Completion=%d, Total=%d",
 								usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens))
 						}
 					}
@@ -385,22 +388,22 @@ func executeWorkflowInBackground(executeId string, info *relaycommon.RelayInfo,
 					usage.CompletionTokens = textTokens + videoTokens
 					usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 
-					common.SysLog(fmt.Sprintf("[Async] 检测到%d个视频，重新计算合理计费", videoCount))
-					common.SysLog(fmt.Sprintf("[Async] 文本tokens=%d, 视频tokens=%d(%d*%d)",
+					logger.LogInfo(ctx, fmt.Sprintf("[Async] 检测到%d个视频，重新计算合理计费", videoCount))
+					logger.LogInfo(ctx, fmt.Sprintf("[Async] 文本tokens=%d, 视频tokens=%d(%d*%d)",
 						textTokens, videoTokens, videoCount, REASONABLE_TOKENS_PER_VIDEO))
-					common.SysLog(fmt.Sprintf("[Async] CompletionTokens修正: %d → %d",
+					logger.LogInfo(ctx, fmt.Sprintf("[Async] CompletionTokens修正: %d → %d",
 						oldCompletionTokens, usage.CompletionTokens))
-					common.SysLog(fmt.Sprintf("[Async] TotalTokens修正: %d → %d",
+					logger.LogInfo(ctx, fmt.Sprintf("[Async] TotalTokens修正: %d → %d",
 						oldCompletionTokens+usage.PromptTokens, usage.TotalTokens))
 				}
 
-				common.SysLog(fmt.Sprintf("[Async] 最终计费 usage: Prompt=%d, Completion=%d, Total=%d",
+				logger.LogInfo(ctx, fmt.Sprintf("[Async] 最终计费 usage: Prompt=%d, Completion=%d, Total=%d",
 					usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens))
 				updateTaskStatus(executeId, model.TaskStatusSuccess, "", outputText, &usage, info, map[string]interface{}{
 					"coze_execute_id": upstreamExecuteId,
 					"debug_url":       debugUrl,
 				})
-				common.SysLog(fmt.Sprintf("[Async] Task %s completed successfully", executeId))
+				logger.LogInfo(ctx, fmt.Sprintf("[Async] Task %s completed successfully", executeId))
 				return
 
 			case "Error":
@@ -413,29 +416,29 @@ func executeWorkflowInBackground(executeId string, info *relaycommon.RelayInfo,
 					if upstreamExecuteId == "" {
 						if val, ok := errorData["execute_id"].(string); ok && val != "" {
 							upstreamExecuteId = val
-							common.SysLog(fmt.Sprintf("[Async] Error事件获取Coze execute_id: %s", upstreamExecuteId))
+							logger.LogInfo(ctx, fmt.Sprintf("[Async] Error事件获取Coze execute_id: %s", upstreamExecuteId))
 						}
 					}
 					if debugUrl == "" {
 						if val, ok := errorData["debug_url"].(string); ok && val != "" {
 							debugUrl = val
-							common.SysLog(fmt.Sprintf("[Async] Error事件获取Coze debug_url: %s", debugUrl))
+							logger.LogInfo(ctx, fmt.Sprintf("[Async] Error事件获取Coze debug_url: %s", debugUrl))
 						}
 					}
 					// 即使失败也记录usage（如果有的话）
-					common.SysLog(fmt.Sprintf("[Async] Error occurred, usage: PromptTokens=%d, CompletionTokens=%d, TotalTokens=%d",
+					logger.LogError(ctx, fmt.Sprintf("[Async] Error occurred, usage: PromptTokens=%d, CompletionTokens=%d, TotalTokens=%d",
 						usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens))
 					updateTaskStatus(executeId, model.TaskStatusFailure, errorMsg, "", &usage, info, map[string]interface{}{
 						"coze_execute_id": upstreamExecuteId,
 						"debug_url":       debugUrl,
 					})
-					common.SysLog(fmt.Sprintf("[Async] Task %s failed: %s", executeId, errorMsg))
+					logger.LogError(ctx, fmt.Sprintf("[Async] Task %s failed: %s", executeId, errorMsg))
 					return
 				}
 
 			case "PING":
 				// 记录PING事件的数据内容,可能包含进度信息
-				common.SysLog(fmt.Sprintf("[Async] PING数据: %s", currentData))
+				logger.LogInfo(ctx, fmt.Sprintf("[Async] PING数据: %s", currentData))
 			}
 
 			currentEvent = ""
@@ -457,7 +460,7 @@ func executeWorkflowInBackground(executeId string, info *relaycommon.RelayInfo,
 			"coze_execute_id": upstreamExecuteId,
 			"debug_url":       debugUrl,
 		})
-		common.SysLog(fmt.Sprintf("[Async] Task %s completed (no Done event)", executeId))
+		logger.LogInfo(ctx, fmt.Sprintf("[Async] Task %s completed (no Done event)", executeId))
 	} else {
 		updateTaskStatus(executeId, model.TaskStatusFailure, "未收到任何输出", "", &usage, info, map[string]interface{}{
 			"coze_execute_id": upstreamExecuteId,
@@ -467,10 +470,10 @@ func executeWorkflowInBackground(executeId string, info *relaycommon.RelayInfo,
 }
 
 // updateTaskProgress 更新任务进度
-func updateTaskProgress(executeId string, status model.TaskStatus, progress string) {
+func updateTaskProgress(ctx context.Context, executeId string, status model.TaskStatus, progress string) {
 	task, exist, err := model.GetByOnlyTaskId(executeId)
 	if err != nil || !exist {
-		common.SysLog(fmt.Sprintf("[Async] Failed to get task %s: %v", executeId, err))
+		logger.LogError(ctx, fmt.Sprintf("[Async] Failed to get task %s: %v", executeId, err))
 		return
 	}
 
@@ -484,15 +487,16 @@ func updateTaskProgress(executeId string, status model.TaskStatus, progress stri
 
 	err = task.Update()
 	if err != nil {
-		common.SysLog(fmt.Sprintf("[Async] Failed to update task %s: %v", executeId, err))
+		logger.LogError(ctx, fmt.Sprintf("[Async] Failed to update task %s: %v", executeId, err))
 	}
 }
 
 // updateTaskStatus 更新任务最终状态并记录quota消耗
 func updateTaskStatus(executeId string, status model.TaskStatus, failReason string, output string, usage *dto.Usage, info *relaycommon.RelayInfo, extra map[string]interface{}) {
+	ctx := info.LogContext()
 	task, exist, err := model.GetByOnlyTaskId(executeId)
 	if err != nil || !exist {
-		common.SysLog(fmt.Sprintf("[Async] Failed to get task %s: %v", executeId, err))
+		logger.LogError(ctx, fmt.Sprintf("[Async] Failed to get task %s: %v", executeId, err))
 		return
 	}
 
@@ -536,14 +540,14 @@ func updateTaskStatus(executeId string, status model.TaskStatus, failReason stri
 		// 从 abilities 表查询渠道倍率（使用 coze-workflow-async 作为模型名称）
 		channelRatio := model.GetChannelRatio(info.UsingGroup, "coze-workflow-async", info.ChannelId)
 		info.PriceData.GroupRatioInfo.ChannelRatio = channelRatio
-		common.SysLog(fmt.Sprintf("[Async] 初始化渠道倍率: channel_id=%d, group=%s, ratio=%.2f",
+		logger.LogInfo(ctx, fmt.Sprintf("[Async] 初始化渠道倍率: channel_id=%d, group=%s, ratio=%.2f",
 			info.ChannelId, info.UsingGroup, channelRatio))
 	}
 
 	// 2. 查询工作流定价
 	var workflowPricePerCall int
 	if workflowId != "" {
-		workflowPricePerCall = GetWorkflowPricePerCall(workflowId, info.ChannelId)
+		workflowPricePerCall = GetWorkflowPricePerCall(ctx, workflowId, info.ChannelId)
 	}
 
 	// 3. 计算 quota
@@ -556,7 +560,7 @@ func updateTaskStatus(executeId string, status model.TaskStatus, failReason stri
 			quota = 1 // 确保至少扣1个quota
 		}
 
-		common.SysLog(fmt.Sprintf("[Async] 工作流按次计费: workflow=%s, 基础价格=%d quota/次, 分组倍率=%.2f, 渠道倍率=%.2f, 最终quota=%d",
+		logger.LogInfo(ctx, fmt.Sprintf("[Async] 工作流按次计费: workflow=%s, 基础价格=%d quota/次, 分组倍率=%.2f, 渠道倍率=%.2f, 最终quota=%d",
 			workflowId, workflowPricePerCall, info.PriceData.GroupRatioInfo.GroupRatio, info.PriceData.GroupRatioInfo.ChannelRatio, quota))
 
 	} else if usage != nil && usage.TotalTokens > 0 {
@@ -568,10 +572,10 @@ func updateTaskStatus(executeId string, status model.TaskStatus, failReason stri
 			quota = 1
 		}
 
-		common.SysLog(fmt.Sprintf("[Async] Token计费（未配置工作流定价）: tokens=%d, 倍率=%.2f, quota=%d",
+		logger.LogInfo(ctx, fmt.Sprintf("[Async] Token计费（未配置工作流定价）: tokens=%d, 倍率=%.2f, quota=%d",
 			usage.TotalTokens, ratio, quota))
 	} else {
-		common.SysLog("[Async] WARNING: 无法计算quota（无定价且无token usage）")
+		logger.LogWarn(ctx, "[Async] WARNING: 无法计算quota（无定价且无token usage）")
 	}
 
 	task.Quota = quota
@@ -600,7 +604,7 @@ func updateTaskStatus(executeId string, status model.TaskStatus, failReason stri
 
 	err = task.Update()
 	if err != nil {
-		common.SysLog(fmt.Sprintf("[Async] Failed to update task status %s: %v", executeId, err))
+		logger.LogError(ctx, fmt.Sprintf("[Async] Failed to update task status %s: %v", executeId, err))
 		return
 	}
 
@@ -613,20 +617,21 @@ func updateTaskStatus(executeId string, status model.TaskStatus, failReason stri
 		// 扣除quota（异步任务没有预扣费，所以quotaDelta就是quota）
 		err = service.PostConsumeQuota(info, quota, 0, true)
 		if err != nil {
-			common.SysLog(fmt.Sprintf("[Async] Failed to consume quota: %v", err))
+			logger.LogError(ctx, fmt.Sprintf("[Async] Failed to consume quota: %v", err))
 		} else {
-			common.SysLog(fmt.Sprintf("[Async] Successfully consumed quota: %d for task %s", quota, executeId))
+			logger.LogInfo(ctx, fmt.Sprintf("[Async] Successfully consumed quota: %d for task %s", quota, executeId))
 		}
 
 		// 创建日志记录以正确记录token消耗
 		recordAsyncConsumeLog(task, info, usage, quota, false, "")
 	} else if status == model.TaskStatusFailure {
-		common.SysLog(fmt.Sprintf("[Async] Task failed, not consuming quota: %s", failReason))
+		logger.LogInfo(ctx, fmt.Sprintf("[Async] Task failed, not consuming quota: %s", failReason))
 	}
 }
 
 // recordAsyncConsumeLog 为异步任务创建日志记录
 func recordAsyncConsumeLog(task *model.Task, info *relaycommon.RelayInfo, usage *dto.Usage, quota int, isFailed bool, failReason string) {
+	ctx := info.LogContext()
 	if !common.LogConsumeEnabled {
 		return
 	}
@@ -635,7 +640,7 @@ func recordAsyncConsumeLog(task *model.Task, info *relaycommon.RelayInfo, usage
 	username, _ := model.GetUsernameById(info.UserId, false)
 	token, err := model.GetTokenById(info.TokenId)
 	if err != nil {
-		common.SysLog(fmt.Sprintf("[Async] Failed to get token info: %v", err))
+		logger.LogError(ctx, fmt.Sprintf("[Async] Failed to get token info: %v", err))
 		return
 	}
 	tokenName := token.Name
@@ -698,16 +703,16 @@ func recordAsyncConsumeLog(task *model.Task, info *relaycommon.RelayInfo, usage
 
 	err = model.LOG_DB.Create(log).Error
 	if err != nil {
-		common.SysLog(fmt.Sprintf("[Async] Failed to create log: %v", err))
+		logger.LogError(ctx, fmt.Sprintf("[Async] Failed to create log: %v", err))
 	} else {
-		common.SysLog(fmt.Sprintf("[Async] Successfully created log for task %s with %d tokens", task.TaskID, usage.TotalTokens))
+		logger.LogInfo(ctx, fmt.Sprintf("[Async] Successfully created log for task %s with %d tokens", task.TaskID, usage.TotalTokens))
 	}
 
 	// 记录到数据看板 quota_data 表
 	if common.DataExportEnabled {
 		gopool.Go(func() {
 			model.LogQuotaData(info.UserId, username, info.OriginModelName, quota, task.FinishTime, usage.PromptTokens+usage.CompletionTokens)
-			common.SysLog(fmt.Sprintf("[Async] Logged quota data for task %s: quota=%d, tokens=%d", task.TaskID, quota, usage.PromptTokens+usage.CompletionTokens))
+			logger.LogInfo(ctx, fmt.Sprintf("[Async] Logged quota data for task %s: quota=%d, tokens=%d", task.TaskID, quota, usage.PromptTokens+usage.CompletionTokens))
 		})
 	}
 }
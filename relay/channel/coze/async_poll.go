@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"one-api/common"
 	"one-api/dto"
+	"one-api/logger"
 	"one-api/model"
 	relaycommon "one-api/relay/common"
 	"one-api/service"
@@ -50,17 +50,18 @@ type CozeUsageInfo struct {
 
 // executeWorkflowAsync 使用Coze官方异步接口执行工作流 (is_async=true)
 func executeWorkflowAsync(localExecuteId string, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) {
+	ctx := info.LogContext()
 	defer func() {
 		if r := recover(); r != nil {
-			common.SysLog(fmt.Sprintf("[AsyncOfficial] Panic in async execution: %v", r))
+			logger.LogError(ctx, fmt.Sprintf("[AsyncOfficial] Panic in async execution: %v", r))
 			updateTaskStatus(localExecuteId, model.TaskStatusFailure, fmt.Sprintf("执行异常: %v", r), "", nil, info, nil)
 		}
 	}()
 
-	common.SysLog(fmt.Sprintf("[AsyncOfficial] Starting official async execution for task %s", localExecuteId))
+	logger.LogInfo(ctx, fmt.Sprintf("[AsyncOfficial] Starting official async execution for task %s", localExecuteId))
 
 	// 更新任务状态为进行中
-	updateTaskProgress(localExecuteId, model.TaskStatusInProgress, "提交中")
+	updateTaskProgress(ctx, localExecuteId, model.TaskStatusInProgress, "提交中")
 
 	// 构造 Coze 异步工作流请求 - 关键是设置 is_async=true
 	cozeRequest := convertCozeWorkflowRequest(nil, *request)
@@ -75,7 +76,7 @@ func executeWorkflowAsync(localExecuteId string, info *relaycommon.RelayInfo, re
 
 	// 强制设置 is_async=true (官方异步参数)
 	requestMap["is_async"] = true
-	common.SysLog("[AsyncOfficial] Set is_async=true for official Coze async API")
+	logger.LogInfo(ctx, "[AsyncOfficial] Set is_async=true for official Coze async API")
 
 	requestBody, err := json.Marshal(requestMap)
 	if err != nil {
@@ -130,11 +131,11 @@ func executeWorkflowAsync(localExecuteId string, info *relaycommon.RelayInfo, re
 		client = service.GetHttpClient()
 	}
 
-	common.SysLog(fmt.Sprintf("[AsyncOfficial] 发送异步执行请求到: %s", requestURL))
+	logger.LogInfo(ctx, fmt.Sprintf("[AsyncOfficial] 发送异步执行请求到: %s", requestURL))
 
 	resp, err := client.Do(req)
 	if err != nil {
-		common.SysLog(fmt.Sprintf("[AsyncOfficial] HTTP请求失败: %v", err))
+		logger.LogError(ctx, fmt.Sprintf("[AsyncOfficial] HTTP请求失败: %v", err))
 		updateTaskStatus(localExecuteId, model.TaskStatusFailure, fmt.Sprintf("请求执行失败: %v", err), "", nil, info, nil)
 		return
 	}
@@ -147,7 +148,7 @@ func executeWorkflowAsync(localExecuteId string, info *relaycommon.RelayInfo, re
 		return
 	}
 
-	common.SysLog(fmt.Sprintf("[AsyncOfficial] 收到异步提交响应: status=%d, body=%s", resp.StatusCode, string(bodyBytes)))
+	logger.LogInfo(ctx, fmt.Sprintf("[AsyncOfficial] 收到异步提交响应: status=%d, body=%s", resp.StatusCode, string(bodyBytes)))
 
 	// 解析响应
 	var asyncResp CozeAsyncRunResponse
@@ -172,7 +173,7 @@ func executeWorkflowAsync(localExecuteId string, info *relaycommon.RelayInfo, re
 	cozeExecuteId := asyncResp.ExecuteId
 	debugUrl := asyncResp.DebugUrl
 
-	common.SysLog(fmt.Sprintf("[AsyncOfficial] 异步任务已提交, Coze execute_id=%s, debug_url=%s",
+	logger.LogInfo(ctx, fmt.Sprintf("[AsyncOfficial] 异步任务已提交, Coze execute_id=%s, debug_url=%s",
 		cozeExecuteId, debugUrl))
 
 	// 保存 Coze execute_id 到任务数据中
@@ -190,7 +191,7 @@ func executeWorkflowAsync(localExecuteId string, info *relaycommon.RelayInfo, re
 	}
 
 	// 更新进度
-	updateTaskProgress(localExecuteId, model.TaskStatusInProgress, "执行中")
+	updateTaskProgress(ctx, localExecuteId, model.TaskStatusInProgress, "执行中")
 
 	// 开始轮询查询结果
 	pollAsyncResult(localExecuteId, cozeExecuteId, debugUrl, info, request)
@@ -198,7 +199,8 @@ func executeWorkflowAsync(localExecuteId string, info *relaycommon.RelayInfo, re
 
 // pollAsyncResult 轮询查询Coze异步执行结果
 func pollAsyncResult(localExecuteId, cozeExecuteId, debugUrl string, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) {
-	common.SysLog(fmt.Sprintf("[AsyncOfficial] 开始轮询任务结果, coze_execute_id=%s", cozeExecuteId))
+	ctx := info.LogContext()
+	logger.LogInfo(ctx, fmt.Sprintf("[AsyncOfficial] 开始轮询任务结果, coze_execute_id=%s", cozeExecuteId))
 
 	// 轮询配置
 	maxAttempts := 2880              // 最多轮询次数 (24小时 = 2880 * 30秒)
@@ -215,12 +217,12 @@ func pollAsyncResult(localExecuteId, cozeExecuteId, debugUrl string, info *relay
 			time.Sleep(pollInterval)
 		}
 
-		common.SysLog(fmt.Sprintf("[AsyncOfficial] 第%d次轮询 (coze_execute_id=%s)", attempt+1, cozeExecuteId))
+		logger.LogInfo(ctx, fmt.Sprintf("[AsyncOfficial] 第%d次轮询 (coze_execute_id=%s)", attempt+1, cozeExecuteId))
 
 		// 查询结果
 		history, err := queryAsyncWorkflowResult(cozeExecuteId, info, request)
 		if err != nil {
-			common.SysLog(fmt.Sprintf("[AsyncOfficial] 查询失败: %v", err))
+			logger.LogError(ctx, fmt.Sprintf("[AsyncOfficial] 查询失败: %v", err))
 
 			// 如果是网络错误或临时错误,继续轮询
 			if attempt < maxAttempts-1 {
@@ -247,15 +249,15 @@ func pollAsyncResult(localExecuteId, cozeExecuteId, debugUrl string, info *relay
 				progress = 95 // 不超过95%,留5%给最终完成
 			}
 
-			common.SysLog(fmt.Sprintf("[AsyncOfficial] 任务仍在执行中 (进度约%d%%)", progress))
-			updateTaskProgress(localExecuteId, model.TaskStatusInProgress,
+			logger.LogInfo(ctx, fmt.Sprintf("[AsyncOfficial] 任务仍在执行中 (进度约%d%%)", progress))
+			updateTaskProgress(ctx, localExecuteId, model.TaskStatusInProgress,
 				fmt.Sprintf("%d%%", progress))
 			continue
 		}
 
 		// 检查是否成功
 		if history.ExecuteStatus == "Success" {
-			common.SysLog(fmt.Sprintf("[AsyncOfficial] 任务成功完成, output长度=%d", len(history.Output)))
+			logger.LogInfo(ctx, fmt.Sprintf("[AsyncOfficial] 任务成功完成, output长度=%d", len(history.Output)))
 
 			// 转换 usage
 			var usage *dto.Usage
@@ -265,7 +267,7 @@ func pollAsyncResult(localExecuteId, cozeExecuteId, debugUrl string, info *relay
 					CompletionTokens: history.Usage.OutputCount,
 					TotalTokens:      history.Usage.TokenCount,
 				}
-				common.SysLog(fmt.Sprintf("[AsyncOfficial] Usage: Prompt=%d, Completion=%d, Total=%d",
+				logger.LogInfo(ctx, fmt.Sprintf("[AsyncOfficial] Usage: Prompt=%d, Completion=%d, Total=%d",
 					usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens))
 			}
 
@@ -283,7 +285,7 @@ func pollAsyncResult(localExecuteId, cozeExecuteId, debugUrl string, info *relay
 			if errorMsg == "" {
 				errorMsg = fmt.Sprintf("工作流执行失败 (error_code=%s)", history.ErrorCode)
 			}
-			common.SysLog(fmt.Sprintf("[AsyncOfficial] 任务失败: %s", errorMsg))
+			logger.LogError(ctx, fmt.Sprintf("[AsyncOfficial] 任务失败: %s", errorMsg))
 
 			// 转换 usage (即使失败也记录)
 			var usage *dto.Usage
@@ -311,15 +313,15 @@ func pollAsyncResult(localExecuteId, cozeExecuteId, debugUrl string, info *relay
 				progress = 95
 			}
 
-			common.SysLog(fmt.Sprintf("[AsyncOfficial] 任务仍在运行中 (进度约%d%%)", progress))
-			updateTaskProgress(localExecuteId, model.TaskStatusInProgress,
+			logger.LogInfo(ctx, fmt.Sprintf("[AsyncOfficial] 任务仍在运行中 (进度约%d%%)", progress))
+			updateTaskProgress(ctx, localExecuteId, model.TaskStatusInProgress,
 				fmt.Sprintf("%d%%", progress))
 			continue
 		}
 	}
 
 	// 超时
-	common.SysLog(fmt.Sprintf("[AsyncOfficial] 轮询超时, 已尝试%d次", maxAttempts))
+	logger.LogWarn(ctx, fmt.Sprintf("[AsyncOfficial] 轮询超时, 已尝试%d次", maxAttempts))
 	updateTaskStatus(localExecuteId, model.TaskStatusFailure,
 		"查询结果超时(24小时)", "", nil, info, map[string]interface{}{
 			"coze_execute_id": cozeExecuteId,
@@ -331,6 +333,7 @@ func pollAsyncResult(localExecuteId, cozeExecuteId, debugUrl string, info *relay
 // 返回nil表示任务仍在执行中
 // 官方接口: GET /v1/workflows/:workflow_id/run_histories/:execute_id
 func queryAsyncWorkflowResult(cozeExecuteId string, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (*WorkflowExecuteHistory, error) {
+	ctx := info.LogContext()
 	// 获取 workflow_id
 	workflowId := request.WorkflowId
 	if workflowId == "" {
@@ -394,7 +397,7 @@ func queryAsyncWorkflowResult(cozeExecuteId string, info *relaycommon.RelayInfo,
 		return nil, fmt.Errorf("读取查询响应失败: %w", err)
 	}
 
-	common.SysLog(fmt.Sprintf("[AsyncOfficial] 查询响应: status=%d, body=%s", resp.StatusCode, string(bodyBytes)))
+	logger.LogInfo(ctx, fmt.Sprintf("[AsyncOfficial] 查询响应: status=%d, body=%s", resp.StatusCode, string(bodyBytes)))
 
 	// HTTP 404 可能表示任务不存在或已过期
 	if resp.StatusCode == 404 {
@@ -419,14 +422,14 @@ func queryAsyncWorkflowResult(cozeExecuteId string, info *relaycommon.RelayInfo,
 
 	// 检查是否有数据
 	if len(queryResp.Data) == 0 {
-		common.SysLog("[AsyncOfficial] 查询响应中没有数据,任务可能仍在执行中")
+		logger.LogInfo(ctx, "[AsyncOfficial] 查询响应中没有数据,任务可能仍在执行中")
 		return nil, nil // 返回nil表示任务仍在执行中
 	}
 
 	// 返回第一个执行历史记录
 	history := &queryResp.Data[0]
 
-	common.SysLog(fmt.Sprintf("[AsyncOfficial] 查询到任务状态: %s, execute_id=%s",
+	logger.LogInfo(ctx, fmt.Sprintf("[AsyncOfficial] 查询到任务状态: %s, execute_id=%s",
 		history.ExecuteStatus, history.ExecuteId))
 
 	// 如果状态为 Running,返回 nil 表示仍在执行中
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"one-api/common"
+	"one-api/logger"
 	relaycommon "one-api/relay/common"
 	"one-api/service"
 	"os"
@@ -116,6 +117,7 @@ func IsCozeOAuthConfig(key string) bool {
 }
 
 func GetCozeAccessToken(info *relaycommon.RelayInfo, oauthConfig *CozeOAuthConfig) (string, error) {
+	ctx := info.LogContext()
 	var cacheKey string
 	if info.ChannelIsMultiKey {
 		cacheKey = fmt.Sprintf("coze-oauth-token-%d-%d", info.ChannelId, info.ChannelMultiKeyIndex)
@@ -125,27 +127,27 @@ func GetCozeAccessToken(info *relaycommon.RelayInfo, oauthConfig *CozeOAuthConfi
 
 	val, err := cozeOAuthCache.Get(cacheKey)
 	if err == nil {
-		common.SysLog(fmt.Sprintf("[OAuth Debug] 使用缓存的 token (前20字符): %s...", val.(string)[:min(20, len(val.(string)))]))
+		logger.LogDebug(ctx, fmt.Sprintf("[OAuth Debug] 使用缓存的 token (前20字符): %s...", val.(string)[:min(20, len(val.(string)))]))
 		return val.(string), nil
 	}
 
-	common.SysLog("[OAuth Debug] 缓存未命中，开始生成新 token")
+	logger.LogDebug(ctx, "[OAuth Debug] 缓存未命中，开始生成新 token")
 	signedJWT, err := createCozeSignedJWT(oauthConfig)
 	if err != nil {
 		return "", fmt.Errorf("failed to create signed JWT: %w", err)
 	}
-	common.SysLog(fmt.Sprintf("[OAuth Debug] JWT 签名成功 (前50字符): %s...", signedJWT[:min(50, len(signedJWT))]))
+	logger.LogDebug(ctx, fmt.Sprintf("[OAuth Debug] JWT 签名成功 (前50字符): %s...", signedJWT[:min(50, len(signedJWT))]))
 
 	newToken, err := exchangeJWTForCozeAccessToken(signedJWT, oauthConfig, info)
 	if err != nil {
 		return "", fmt.Errorf("failed to exchange JWT for access token: %w", err)
 	}
-	common.SysLog(fmt.Sprintf("[OAuth Debug] Access token 获取成功 (前20字符): %s...", newToken[:min(20, len(newToken))]))
+	logger.LogDebug(ctx, fmt.Sprintf("[OAuth Debug] Access token 获取成功 (前20字符): %s...", newToken[:min(20, len(newToken))]))
 
 	success := cozeOAuthCache.SetDefault(cacheKey, newToken)
 	if !success {
 		// 即使缓存设置失败，也返回token，只记录错误
-		common.SysLog("设置OAuth token缓存失败")
+		logger.LogError(ctx, "设置OAuth token缓存失败")
 	}
 
 	return newToken, nil
@@ -204,6 +206,7 @@ func createCozeSignedJWT(config *CozeOAuthConfig) (string, error) {
 }
 
 func exchangeJWTForCozeAccessToken(signedJWT string, config *CozeOAuthConfig, info *relaycommon.RelayInfo) (string, error) {
+	ctx := info.LogContext()
 	tokenURL := fmt.Sprintf("%s/api/permission/oauth2/token", info.ChannelBaseUrl)
 
 	// 构造 scopes (必须是 JSON 数组格式)
@@ -246,7 +249,7 @@ func exchangeJWTForCozeAccessToken(signedJWT string, config *CozeOAuthConfig, in
 		client = service.GetHttpClient()
 	}
 
-	common.SysLog(fmt.Sprintf("[OAuth Debug] 向 %s 发送 token 交换请求 (scopes=%s)", tokenURL, string(scopesJSON)))
+	logger.LogDebug(ctx, fmt.Sprintf("[OAuth Debug] 向 %s 发送 token 交换请求 (scopes=%s)", tokenURL, string(scopesJSON)))
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to request token: %w", err)
@@ -258,7 +261,7 @@ func exchangeJWTForCozeAccessToken(signedJWT string, config *CozeOAuthConfig, in
 		return "", fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	common.SysLog(fmt.Sprintf("[OAuth Debug] Token 交换响应状态: %d, 响应体: %s", resp.StatusCode, string(body)))
+	logger.LogDebug(ctx, fmt.Sprintf("[OAuth Debug] Token 交换响应状态: %d, 响应体: %s", resp.StatusCode, string(body)))
 
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, string(body))
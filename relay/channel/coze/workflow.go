@@ -2,6 +2,7 @@ package coze
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/dto"
+	"one-api/logger"
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
 	"one-api/service"
@@ -21,7 +23,7 @@ import (
 
 // filterEmptyWorkflowParameters 过滤掉工作流参数中的空值并进行参数名称映射
 // 直接修改request对象，确保即使在透传模式下也能过滤和映射
-func filterEmptyWorkflowParameters(request *dto.GeneralOpenAIRequest) {
+func filterEmptyWorkflowParameters(ctx context.Context, request *dto.GeneralOpenAIRequest) {
 	if request.WorkflowParameters == nil {
 		return
 	}
@@ -31,27 +33,27 @@ func filterEmptyWorkflowParameters(request *dto.GeneralOpenAIRequest) {
 	for key, value := range request.WorkflowParameters {
 		// 过滤掉空字符串、nil、空数组等无效值
 		if value == nil {
-			common.SysLog(fmt.Sprintf("[前置参数过滤] 跳过 nil 参数: %s", key))
+			logger.LogInfo(ctx, fmt.Sprintf("[前置参数过滤] 跳过 nil 参数: %s", key))
 			continue
 		}
 
 		// 检查字符串类型的空值
 		if str, ok := value.(string); ok {
 			if str == "" {
-				common.SysLog(fmt.Sprintf("[前置参数过滤] 跳过空字符串参数: %s", key))
+				logger.LogInfo(ctx, fmt.Sprintf("[前置参数过滤] 跳过空字符串参数: %s", key))
 				continue
 			}
 		}
 
 		// 检查空数组
 		if arr, ok := value.([]interface{}); ok && len(arr) == 0 {
-			common.SysLog(fmt.Sprintf("[前置参数过滤] 跳过空数组参数: %s", key))
+			logger.LogInfo(ctx, fmt.Sprintf("[前置参数过滤] 跳过空数组参数: %s", key))
 			continue
 		}
 
 		// 检查空map
 		if m, ok := value.(map[string]interface{}); ok && len(m) == 0 {
-			common.SysLog(fmt.Sprintf("[前置参数过滤] 跳过空map参数: %s", key))
+			logger.LogInfo(ctx, fmt.Sprintf("[前置参数过滤] 跳过空map参数: %s", key))
 			continue
 		}
 
@@ -66,7 +68,7 @@ func filterEmptyWorkflowParameters(request *dto.GeneralOpenAIRequest) {
 	request.WorkflowParameters = filtered
 
 	if originalCount != len(filtered) {
-		common.SysLog(fmt.Sprintf("[前置参数过滤] 过滤前: %d 个, 过滤后: %d 个参数",
+		logger.LogInfo(ctx, fmt.Sprintf("[前置参数过滤] 过滤前: %d 个, 过滤后: %d 个参数",
 			originalCount, len(filtered)))
 	}
 }
@@ -78,7 +80,7 @@ func convertRelativePathToFullURL(c *gin.Context, value string) string {
 	if strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "//") {
 		// 如果 Context 为 nil (后台执行场景)，跳过转换
 		if c == nil {
-			common.SysLog(fmt.Sprintf("[URL转换] Context为nil，跳过相对路径转换: %s", value))
+			logger.LogInfo(c, fmt.Sprintf("[URL转换] Context为nil，跳过相对路径转换: %s", value))
 			return value
 		}
 
@@ -95,7 +97,7 @@ func convertRelativePathToFullURL(c *gin.Context, value string) string {
 		}
 
 		fullURL := fmt.Sprintf("%s://%s%s", scheme, host, value)
-		common.SysLog(fmt.Sprintf("[URL转换] 相对路径转完整URL: %s -> %s", value, fullURL))
+		logger.LogInfo(c, fmt.Sprintf("[URL转换] 相对路径转完整URL: %s -> %s", value, fullURL))
 		return fullURL
 	}
 	return value
@@ -127,14 +129,14 @@ func convertCozeWorkflowRequest(c *gin.Context, request dto.GeneralOpenAIRequest
 	for key, value := range parameters {
 		// 过滤掉空字符串、nil、空数组等无效值
 		if value == nil {
-			common.SysLog(fmt.Sprintf("[参数过滤] 跳过 nil 参数: %s", key))
+			logger.LogInfo(c, fmt.Sprintf("[参数过滤] 跳过 nil 参数: %s", key))
 			continue
 		}
 
 		// 检查字符串类型的空值和相对路径转换
 		if str, ok := value.(string); ok {
 			if str == "" {
-				common.SysLog(fmt.Sprintf("[参数过滤] 跳过空字符串参数: %s", key))
+				logger.LogInfo(c, fmt.Sprintf("[参数过滤] 跳过空字符串参数: %s", key))
 				continue
 			}
 			// 🆕 转换相对路径为完整 URL(针对图片等资源)
@@ -145,13 +147,13 @@ func convertCozeWorkflowRequest(c *gin.Context, request dto.GeneralOpenAIRequest
 
 		// 检查空数组
 		if arr, ok := value.([]interface{}); ok && len(arr) == 0 {
-			common.SysLog(fmt.Sprintf("[参数过滤] 跳过空数组参数: %s", key))
+			logger.LogInfo(c, fmt.Sprintf("[参数过滤] 跳过空数组参数: %s", key))
 			continue
 		}
 
 		// 检查空map
 		if m, ok := value.(map[string]interface{}); ok && len(m) == 0 {
-			common.SysLog(fmt.Sprintf("[参数过滤] 跳过空map参数: %s", key))
+			logger.LogInfo(c, fmt.Sprintf("[参数过滤] 跳过空map参数: %s", key))
 			continue
 		}
 
@@ -166,13 +168,13 @@ func convertCozeWorkflowRequest(c *gin.Context, request dto.GeneralOpenAIRequest
 
 	// 添加调试日志
 	requestJson, _ := json.Marshal(workflowRequest)
-	common.SysLog(fmt.Sprintf("[透传模式] 发送给Coze的工作流请求: %s", string(requestJson)))
+	logger.LogInfo(c, fmt.Sprintf("[透传模式] 发送给Coze的工作流请求: %s", string(requestJson)))
 
 	return workflowRequest
 }
 
 func cozeWorkflowHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
-	common.SysLog("=== cozeWorkflowHandler called ===")
+	logger.LogInfo(c, "=== cozeWorkflowHandler called ===")
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
@@ -180,7 +182,7 @@ func cozeWorkflowHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 	service.CloseResponseBodyGracefully(resp)
 
 	// 添加调试日志
-	common.SysLog(fmt.Sprintf("Coze工作流响应: %s", string(responseBody)))
+	logger.LogInfo(c, fmt.Sprintf("Coze工作流响应: %s", string(responseBody)))
 
 	var response dto.TextResponse
 	response.Model = info.UpstreamModelName
@@ -198,7 +200,7 @@ func cozeWorkflowHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 	var workflowResponse CozeWorkflowResponse
 	err = json.Unmarshal(responseBody, &workflowResponse)
 	if err != nil {
-		common.SysLog(fmt.Sprintf("解析CozeWorkflowResponse失败: %s", err.Error()))
+		logger.LogError(c, fmt.Sprintf("解析CozeWorkflowResponse失败: %s", err.Error()))
 		// 如果解析失败，创建一个默认响应
 		response.Choices = []dto.OpenAITextResponseChoice{
 			{
@@ -223,7 +225,7 @@ func cozeWorkflowHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 			if errorMsg == "" {
 				errorMsg = "Workflow execution failed"
 			}
-			common.SysLog(fmt.Sprintf("Coze工作流执行失败: code=%d, msg=%s", workflowResponse.Code, errorMsg))
+			logger.LogError(c, fmt.Sprintf("Coze工作流执行失败: code=%d, msg=%s", workflowResponse.Code, errorMsg))
 			return nil, types.NewError(errors.New(errorMsg), types.ErrorCodeBadResponse)
 		}
 
@@ -268,14 +270,14 @@ func cozeWorkflowHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 		usage.PromptTokens = workflowResponse.Data[0].Usage.InputCount
 		usage.CompletionTokens = workflowResponse.Data[0].Usage.OutputCount
 		usage.TotalTokens = workflowResponse.Data[0].Usage.TokenCount
-		common.SysLog(fmt.Sprintf("从Coze响应解析到Token: input=%d, output=%d, total=%d",
+		logger.LogInfo(c, fmt.Sprintf("从Coze响应解析到Token: input=%d, output=%d, total=%d",
 			usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens))
 	} else {
 		// 如果没有usage信息，设置默认值用于测试
 		usage.PromptTokens = 10
 		usage.CompletionTokens = 20
 		usage.TotalTokens = 30
-		common.SysLog("Coze响应中没有usage信息，使用默认Token统计值")
+		logger.LogInfo(c, "Coze响应中没有usage信息，使用默认Token统计值")
 	}
 
 	response.Usage = *usage
@@ -293,7 +295,7 @@ func cozeWorkflowHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 }
 
 func cozeWorkflowStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
-	common.SysLog("=== cozeWorkflowStreamHandler (标准SSE格式) ===")
+	logger.LogInfo(c, "=== cozeWorkflowStreamHandler (标准SSE格式) ===")
 	scanner := bufio.NewScanner(resp.Body)
 	scanner.Split(bufio.ScanLines)
 	helper.SetEventStreamHeaders(c)
@@ -310,11 +312,11 @@ func cozeWorkflowStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		common.SysLog(fmt.Sprintf("[SSE] 原始行: %s", line))
+		logger.LogInfo(c, fmt.Sprintf("[SSE] 原始行: %s", line))
 
 		if strings.HasPrefix(line, "event:") {
 			currentEvent = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
-			common.SysLog(fmt.Sprintf("[SSE] 事件类型: %s", currentEvent))
+			logger.LogInfo(c, fmt.Sprintf("[SSE] 事件类型: %s", currentEvent))
 			continue
 		}
 
@@ -324,7 +326,7 @@ func cozeWorkflowStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 		}
 
 		if line == "" && currentEvent != "" && currentData != "" {
-			common.SysLog(fmt.Sprintf("[SSE] 处理事件 %s", currentEvent))
+			logger.LogInfo(c, fmt.Sprintf("[SSE] 处理事件 %s", currentEvent))
 
 			switch currentEvent {
 			case "Message":
@@ -335,13 +337,13 @@ func cozeWorkflowStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 					if executeId == "" {
 						if val, ok := messageData["execute_id"].(string); ok && val != "" {
 							executeId = val
-							common.SysLog(fmt.Sprintf("[SSE] 捕获execute_id: %s", executeId))
+							logger.LogInfo(c, fmt.Sprintf("[SSE] 捕获execute_id: %s", executeId))
 						}
 					}
 					if debugUrl == "" {
 						if val, ok := messageData["debug_url"].(string); ok && val != "" {
 							debugUrl = val
-							common.SysLog(fmt.Sprintf("[SSE] 捕获debug_url: %s", debugUrl))
+							logger.LogInfo(c, fmt.Sprintf("[SSE] 捕获debug_url: %s", debugUrl))
 						}
 					}
 
@@ -367,25 +369,25 @@ func cozeWorkflowStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 
 						// 数据合理性校验：修复 Coze API 返回的异常 completion_tokens
 						if usage.CompletionTokens > usage.TotalTokens || usage.CompletionTokens < 0 {
-							common.SysLog(fmt.Sprintf("[SSE] WARNING: 检测到异常 completion_tokens=%d (total=%d, prompt=%d), 自动修正",
+							logger.LogWarn(c, fmt.Sprintf("[SSE] WARNING: 检测到异常 completion_tokens=%d (total=%d, prompt=%d), 自动修正",
 								usage.CompletionTokens, usage.TotalTokens, usage.PromptTokens))
 							usage.CompletionTokens = usage.TotalTokens - usage.PromptTokens
 							if usage.CompletionTokens < 0 {
 								usage.CompletionTokens = 0
 							}
-							common.SysLog(fmt.Sprintf("[SSE] 修正后: completion_tokens=%d", usage.CompletionTokens))
+							logger.LogInfo(c, fmt.Sprintf("[SSE] 修正后: completion_tokens=%d", usage.CompletionTokens))
 						}
 
 						// 记录 usage 变化（用于诊断）
 						if oldTotal > 0 {
 							// 检测异常：usage 不应该减少
 							if usage.TotalTokens < oldTotal {
-								common.SysLog(fmt.Sprintf("[SSE] WARNING: usage 发生减少！旧值: %d, 新值: %d", oldTotal, usage.TotalTokens))
+								logger.LogWarn(c, fmt.Sprintf("[SSE] WARNING: usage 发生减少！旧值: %d, 新值: %d", oldTotal, usage.TotalTokens))
 							}
-							common.SysLog(fmt.Sprintf("[SSE] Usage 更新: Prompt %d→%d, Completion %d→%d, Total %d→%d",
+							logger.LogInfo(c, fmt.Sprintf("[SSE] Usage 更新: Prompt %d→%d, Completion %d→%d, Total %d→%d",
 								oldPrompt, usage.PromptTokens, oldCompletion, usage.CompletionTokens, oldTotal, usage.TotalTokens))
 						} else {
-							common.SysLog(fmt.Sprintf("[SSE] 首次从Message提取Token: input=%d, output=%d, total=%d",
+							logger.LogInfo(c, fmt.Sprintf("[SSE] 首次从Message提取Token: input=%d, output=%d, total=%d",
 								usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens))
 						}
 					}
@@ -414,13 +416,13 @@ func cozeWorkflowStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 
 						helper.ObjectData(c, streamResponse)
 						if len(content) > 50 {
-							common.SysLog(fmt.Sprintf("[SSE] 转发内容: %s...", content[:50]))
+							logger.LogInfo(c, fmt.Sprintf("[SSE] 转发内容: %s...", content[:50]))
 						} else {
-							common.SysLog(fmt.Sprintf("[SSE] 转发内容: %s", content))
+							logger.LogInfo(c, fmt.Sprintf("[SSE] 转发内容: %s", content))
 						}
 					}
 				} else {
-					common.SysLog(fmt.Sprintf("[SSE] 解析Message失败: %s", err.Error()))
+					logger.LogError(c, fmt.Sprintf("[SSE] 解析Message失败: %s", err.Error()))
 				}
 
 			case "Done":
@@ -429,13 +431,13 @@ func cozeWorkflowStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 					if executeId == "" {
 						if val, ok := doneData["execute_id"].(string); ok && val != "" {
 							executeId = val
-							common.SysLog(fmt.Sprintf("[SSE] Done事件捕获execute_id: %s", executeId))
+							logger.LogInfo(c, fmt.Sprintf("[SSE] Done事件捕获execute_id: %s", executeId))
 						}
 					}
 					if debugUrl == "" {
 						if val, ok := doneData["debug_url"].(string); ok && val != "" {
 							debugUrl = val
-							common.SysLog(fmt.Sprintf("[SSE] Done事件捕获debug_url: %s", debugUrl))
+							logger.LogInfo(c, fmt.Sprintf("[SSE] Done事件捕获debug_url: %s", debugUrl))
 						}
 					}
 					if usage.TotalTokens == 0 {
@@ -452,21 +454,21 @@ func cozeWorkflowStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 
 							// 数据合理性校验：修复 Coze API 返回的异常 completion_tokens
 							if usage.CompletionTokens > usage.TotalTokens || usage.CompletionTokens < 0 {
-								common.SysLog(fmt.Sprintf("[SSE] WARNING: Done事件检测到异常 completion_tokens=%d (total=%d, prompt=%d), 自动修正",
+								logger.LogWarn(c, fmt.Sprintf("[SSE] WARNING: Done事件检测到异常 completion_tokens=%d (total=%d, prompt=%d), 自动修正",
 									usage.CompletionTokens, usage.TotalTokens, usage.PromptTokens))
 								usage.CompletionTokens = usage.TotalTokens - usage.PromptTokens
 								if usage.CompletionTokens < 0 {
 									usage.CompletionTokens = 0
 								}
-								common.SysLog(fmt.Sprintf("[SSE] 修正后: completion_tokens=%d", usage.CompletionTokens))
+								logger.LogInfo(c, fmt.Sprintf("[SSE] 修正后: completion_tokens=%d", usage.CompletionTokens))
 							}
 
-							common.SysLog(fmt.Sprintf("[SSE] 从Done提取Token: input=%d, output=%d, total=%d",
+							logger.LogInfo(c, fmt.Sprintf("[SSE] 从Done提取Token: input=%d, output=%d, total=%d",
 								usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens))
 						}
 					}
 				} else {
-					common.SysLog(fmt.Sprintf("[SSE] 解析Done事件失败: %s", err.Error()))
+					logger.LogError(c, fmt.Sprintf("[SSE] 解析Done事件失败: %s", err.Error()))
 				}
 
 				// 修复：Coze API返回的output_count对视频的计费过高（49,000/视频）
@@ -494,17 +496,17 @@ func cozeWorkflowStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 					usage.CompletionTokens = textTokens + videoTokens
 					usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 
-					common.SysLog(fmt.Sprintf("[SSE] 检测到%d个视频，重新计算合理计费", videoCount))
-					common.SysLog(fmt.Sprintf("[SSE] 文本tokens=%d, 视频tokens=%d(%d*%d)",
+					logger.LogInfo(c, fmt.Sprintf("[SSE] 检测到%d个视频，重新计算合理计费", videoCount))
+					logger.LogInfo(c, fmt.Sprintf("[SSE] 文本tokens=%d, 视频tokens=%d(%d*%d)",
 						textTokens, videoTokens, videoCount, REASONABLE_TOKENS_PER_VIDEO))
-					common.SysLog(fmt.Sprintf("[SSE] CompletionTokens修正: %d → %d",
+					logger.LogInfo(c, fmt.Sprintf("[SSE] CompletionTokens修正: %d → %d",
 						oldCompletionTokens, usage.CompletionTokens))
-					common.SysLog(fmt.Sprintf("[SSE] TotalTokens修正: %d → %d",
+					logger.LogInfo(c, fmt.Sprintf("[SSE] TotalTokens修正: %d → %d",
 						oldCompletionTokens+usage.PromptTokens, usage.TotalTokens))
 				}
 
 				// 记录最终 usage
-				common.SysLog(fmt.Sprintf("[SSE] 最终计费 usage: Prompt=%d, Completion=%d, Total=%d",
+				logger.LogInfo(c, fmt.Sprintf("[SSE] 最终计费 usage: Prompt=%d, Completion=%d, Total=%d",
 					usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens))
 
 				finishReason := "stop"
@@ -527,23 +529,23 @@ func cozeWorkflowStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 					if debugUrl == "" {
 						if val, ok := errorData["debug_url"].(string); ok && val != "" {
 							debugUrl = val
-							common.SysLog(fmt.Sprintf("[SSE] Error事件捕获debug_url: %s", debugUrl))
+							logger.LogInfo(c, fmt.Sprintf("[SSE] Error事件捕获debug_url: %s", debugUrl))
 						}
 					}
 					if executeId == "" {
 						if val, ok := errorData["execute_id"].(string); ok && val != "" {
 							executeId = val
-							common.SysLog(fmt.Sprintf("[SSE] Error事件捕获execute_id: %s", executeId))
+							logger.LogInfo(c, fmt.Sprintf("[SSE] Error事件捕获execute_id: %s", executeId))
 						}
 					}
 					return nil, types.NewError(errors.New(errorMsg), types.ErrorCodeBadResponse)
 				}
 
 			case "Interrupt":
-				common.SysLog("[SSE] 收到Interrupt事件")
+				logger.LogInfo(c, "[SSE] 收到Interrupt事件")
 
 			default:
-				common.SysLog(fmt.Sprintf("[SSE] 未知事件类型: %s", currentEvent))
+				logger.LogInfo(c, fmt.Sprintf("[SSE] 未知事件类型: %s", currentEvent))
 			}
 
 			currentEvent = ""
@@ -558,7 +560,7 @@ func cozeWorkflowStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 	helper.Done(c)
 	service.CloseResponseBodyGracefully(resp)
 
-	common.SysLog(fmt.Sprintf("[SSE] 最终返回usage: input=%d, output=%d, total=%d",
+	logger.LogInfo(c, fmt.Sprintf("[SSE] 最终返回usage: input=%d, output=%d, total=%d",
 		usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens))
 	return usage, nil
 }
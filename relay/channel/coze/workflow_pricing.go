@@ -1,8 +1,10 @@
 package coze
 
 import (
+	"context"
 	"fmt"
 	"one-api/common"
+	"one-api/logger"
 	"one-api/model"
 	"one-api/setting/ratio_setting"
 )
@@ -25,12 +27,12 @@ import (
 //   - 此函数不会抛出错误，查询失败时静默返回 0
 //   - 保证向后兼容，不影响现有的 token 计费逻辑
 //   - 优先使用 ModelPrice 便于在前端 UI 中统一管理
-func GetWorkflowPricePerCall(workflowId string, channelId int) int {
-	common.SysLog(fmt.Sprintf("[WorkflowPricing] ===== 开始查询工作流定价 ====="))
-	common.SysLog(fmt.Sprintf("[WorkflowPricing] 输入参数: workflow_id=%s, channel_id=%d", workflowId, channelId))
+func GetWorkflowPricePerCall(ctx context.Context, workflowId string, channelId int) int {
+	logger.LogInfo(ctx, fmt.Sprintf("[WorkflowPricing] ===== 开始查询工作流定价 ====="))
+	logger.LogInfo(ctx, fmt.Sprintf("[WorkflowPricing] 输入参数: workflow_id=%s, channel_id=%d", workflowId, channelId))
 
 	if workflowId == "" {
-		common.SysLog("[WorkflowPricing] workflow_id 为空，返回0（使用token计费）")
+		logger.LogInfo(ctx, "[WorkflowPricing] workflow_id 为空，返回0（使用token计费）")
 		return 0
 	}
 
@@ -39,12 +41,12 @@ func GetWorkflowPricePerCall(workflowId string, channelId int) int {
 	if hasPrice && modelPrice > 0 {
 		// 转换为 quota: price(元) * QuotaPerUnit(500000)
 		quota := int(modelPrice * common.QuotaPerUnit)
-		common.SysLog(fmt.Sprintf("[WorkflowPricing] ✅ 从 ModelPrice 读取定价: workflow=%s, price=%.2f元, quota=%d",
+		logger.LogInfo(ctx, fmt.Sprintf("[WorkflowPricing] ✅ 从 ModelPrice 读取定价: workflow=%s, price=%.2f元, quota=%d",
 			workflowId, modelPrice, quota))
 		return quota
 	}
 
-	common.SysLog(fmt.Sprintf("[WorkflowPricing] ModelPrice 中未配置，尝试从 abilities 表读取"))
+	logger.LogInfo(ctx, fmt.Sprintf("[WorkflowPricing] ModelPrice 中未配置，尝试从 abilities 表读取"))
 
 	// 🔄 回退到 abilities 表查询（向后兼容）
 	var workflowPrice *int
@@ -54,7 +56,7 @@ func GetWorkflowPricePerCall(workflowId string, channelId int) int {
 	model.DB.Model(&model.Ability{}).
 		Where("model = ? AND channel_id = ?", workflowId, channelId).
 		Count(&count)
-	common.SysLog(fmt.Sprintf("[WorkflowPricing] abilities 表中匹配的记录数: %d", count))
+	logger.LogInfo(ctx, fmt.Sprintf("[WorkflowPricing] abilities 表中匹配的记录数: %d", count))
 
 	err := model.DB.Model(&model.Ability{}).
 		Select("workflow_price").
@@ -63,24 +65,24 @@ func GetWorkflowPricePerCall(workflowId string, channelId int) int {
 
 	if err != nil {
 		// 查询失败，静默降级到 token 计费
-		common.SysLog(fmt.Sprintf("[WorkflowPricing] abilities 表查询失败: workflow=%s, channel=%d, err=%v",
+		logger.LogError(ctx, fmt.Sprintf("[WorkflowPricing] abilities 表查询失败: workflow=%s, channel=%d, err=%v",
 			workflowId, channelId, err))
 		return 0
 	}
 
-	common.SysLog(fmt.Sprintf("[WorkflowPricing] abilities 表查询成功，workflowPrice指针: %v", workflowPrice))
+	logger.LogInfo(ctx, fmt.Sprintf("[WorkflowPricing] abilities 表查询成功，workflowPrice指针: %v", workflowPrice))
 	if workflowPrice != nil {
-		common.SysLog(fmt.Sprintf("[WorkflowPricing] workflowPrice值: %d", *workflowPrice))
+		logger.LogInfo(ctx, fmt.Sprintf("[WorkflowPricing] workflowPrice值: %d", *workflowPrice))
 	}
 
 	if workflowPrice == nil || *workflowPrice <= 0 {
 		// 未配置定价或价格为 0，使用 token 计费
-		common.SysLog(fmt.Sprintf("[WorkflowPricing] ❌ 工作流未配置定价，使用token计费: workflow=%s, channel=%d",
+		logger.LogInfo(ctx, fmt.Sprintf("[WorkflowPricing] ❌ 工作流未配置定价，使用token计费: workflow=%s, channel=%d",
 			workflowId, channelId))
 		return 0
 	}
 
-	common.SysLog(fmt.Sprintf("[WorkflowPricing] ✅ 从 abilities 表读取定价: workflow=%s, channel=%d, price=%d quota/次",
+	logger.LogInfo(ctx, fmt.Sprintf("[WorkflowPricing] ✅ 从 abilities 表读取定价: workflow=%s, channel=%d, price=%d quota/次",
 		workflowId, channelId, *workflowPrice))
 
 	return *workflowPrice
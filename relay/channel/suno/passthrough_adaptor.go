@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/dto"
+	"one-api/logger"
 	"one-api/model"
 	relaycommon "one-api/relay/common"
 	"one-api/service"
@@ -200,7 +201,7 @@ func RelayPassthrough(c *gin.Context) {
 			true,
 		)
 		if err != nil {
-			common.SysLog(fmt.Sprintf("error consuming quota: %s", err.Error()))
+			logger.LogInfo(c, fmt.Sprintf("error consuming quota: %s", err.Error()))
 		}
 
 		// 记录消费日志
@@ -11,6 +11,7 @@ import (
 
 	"one-api/constant"
 	"one-api/dto"
+	"one-api/logger"
 	"one-api/model"
 	"one-api/relay/channel"
 	relaycommon "one-api/relay/common"
@@ -92,7 +93,7 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, _ *relaycommon.RelayInfo)
 	}
 	req := v.(relaycommon.TaskSubmitReq)
 
-	body, err := a.convertToRequestPayload(&req)
+	body, err := a.convertToRequestPayload(c, &req)
 	if err != nil {
 		return nil, err
 	}
@@ -107,7 +108,7 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, _ *relaycommon.RelayInfo)
 	}
 
 	// 🆕 调试日志：输出发送给 Vidu API 的完整请求体
-	fmt.Printf("[DEBUG Vidu] Request body sent to Vidu API: %s\n", string(data))
+	logger.LogDebug(c, fmt.Sprintf("[Vidu] Request body sent to Vidu API: %s", string(data)))
 
 	return bytes.NewReader(data), nil
 }
@@ -218,9 +219,9 @@ func (a *TaskAdaptor) GetChannelName() string {
 // helpers
 // ============================
 
-func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq) (*requestPayload, error) {
+func (a *TaskAdaptor) convertToRequestPayload(c *gin.Context, req *relaycommon.TaskSubmitReq) (*requestPayload, error) {
 	// 🆕 从 size 或 metadata 中获取 aspect_ratio
-	aspectRatio := a.getAspectRatio(req)
+	aspectRatio := a.getAspectRatio(c, req)
 
 	// 🆕 获取分辨率配置（优先使用前端传递的 resolution 字段）
 	resolution := req.Resolution
@@ -232,8 +233,8 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq) (*
 	}
 
 	// 🆕 调试日志：输出原始请求信息
-	fmt.Printf("[DEBUG Vidu] Original request - Size: %s, Resolution: %s, Metadata: %+v\n", req.Size, req.Resolution, req.Metadata)
-	fmt.Printf("[DEBUG Vidu] Converted aspect_ratio: %s, resolution: %s\n", aspectRatio, resolution)
+	logger.LogDebug(c, fmt.Sprintf("[Vidu] Original request - Size: %s, Resolution: %s, Metadata: %+v", req.Size, req.Resolution, req.Metadata))
+	logger.LogDebug(c, fmt.Sprintf("[Vidu] Converted aspect_ratio: %s, resolution: %s", aspectRatio, resolution))
 
 	r := requestPayload{
 		Model:             defaultString(req.Model, "viduq1"),
@@ -258,7 +259,7 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq) (*
 	}
 
 	// 🆕 调试日志：输出最终发送的参数
-	fmt.Printf("[DEBUG Vidu] Final payload - aspect_ratio: %s, resolution: %s\n", r.AspectRatio, r.Resolution)
+	logger.LogDebug(c, fmt.Sprintf("[Vidu] Final payload - aspect_ratio: %s, resolution: %s", r.AspectRatio, r.Resolution))
 
 	return &r, nil
 }
@@ -278,7 +279,7 @@ func defaultInt(value, defaultValue int) int {
 }
 
 // 🆕 getAspectRatio 将 aspect_ratio 或 size 转换为 Vidu 支持的格式
-func (a *TaskAdaptor) getAspectRatio(req *relaycommon.TaskSubmitReq) string {
+func (a *TaskAdaptor) getAspectRatio(c *gin.Context, req *relaycommon.TaskSubmitReq) string {
 	// 🆕 最优先：使用前端直接传递的 aspect_ratio 字段
 	if req.AspectRatio != "" {
 		// 验证是否为支持的值
@@ -287,7 +288,7 @@ func (a *TaskAdaptor) getAspectRatio(req *relaycommon.TaskSubmitReq) string {
 			return req.AspectRatio
 		default:
 			// 如果是无效值，记录日志并继续
-			fmt.Printf("[WARN Vidu] Invalid aspect_ratio: %s, will try other sources\n", req.AspectRatio)
+			logger.LogWarn(c, fmt.Sprintf("[Vidu] Invalid aspect_ratio: %s, will try other sources", req.AspectRatio))
 		}
 	}
 
@@ -12,6 +12,7 @@ import (
 	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting/model_setting"
+	"one-api/tracing"
 	"one-api/types"
 	"strings"
 
@@ -144,7 +145,9 @@ func ClaudeHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 		}
 	}
 
+	_, responseSpan := tracing.StartSpan(c, "DoResponse")
 	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	responseSpan.End()
 	//log.Printf("usage: %v", usage)
 	if newAPIError != nil {
 		// reset status code 重置状态码
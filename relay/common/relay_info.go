@@ -1,11 +1,13 @@
 package common
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"one-api/common"
 	"one-api/constant"
 	"one-api/dto"
+	"one-api/logger"
 	relayconstant "one-api/relay/constant"
 	"one-api/types"
 	"strings"
@@ -84,6 +86,10 @@ type RelayInfo struct {
 	//SendLastReasoningResponse bool
 	IsStream               bool
 	IsGeminiBatchEmbedding bool
+	TokenBudgetPeriod      string // 令牌周期预算的重置周期，未设置预算时为空
+	TokenBudgetTimezone    string
+	RequestId              string
+	OrgId                  int // 使用组织令牌时从该组织的额度池扣费
 	IsPlayground           bool
 	UsePrice               bool
 	RelayMode              int
@@ -392,6 +398,11 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		TokenKey:       common.GetContextKeyString(c, constant.ContextKeyTokenKey),
 		TokenUnlimited: common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
 
+		TokenBudgetPeriod:   common.GetContextKeyString(c, constant.ContextKeyTokenBudgetPeriod),
+		TokenBudgetTimezone: common.GetContextKeyString(c, constant.ContextKeyTokenBudgetTimezone),
+		RequestId:           c.GetString(common.RequestIdKey),
+		OrgId:               common.GetContextKeyInt(c, constant.ContextKeyTokenOrgId),
+
 		isFirstResponse: true,
 		RelayMode:       relayconstant.Path2RelayMode(c.Request.URL.Path),
 		RequestURLPath:  c.Request.URL.String(),
@@ -409,10 +420,10 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 	if billingModel, exists := c.Get("billing_model_name"); exists {
 		if billingModelStr, ok := billingModel.(string); ok && billingModelStr != "" {
 			info.BillingModelName = billingModelStr
-			fmt.Printf("[DEBUG genBaseRelayInfo] Set BillingModelName=%q from context\n", billingModelStr)
+			logger.LogDebug(c, fmt.Sprintf("[genBaseRelayInfo] Set BillingModelName=%q from context", billingModelStr))
 		}
 	} else {
-		fmt.Printf("[DEBUG genBaseRelayInfo] No billing_model_name in context\n")
+		logger.LogDebug(c, "[genBaseRelayInfo] No billing_model_name in context")
 	}
 
 	if info.RelayMode == relayconstant.RelayModeUnknown {
@@ -483,6 +494,23 @@ func (info *RelayInfo) HasSendResponse() bool {
 	return info.FirstResponseTime.After(info.StartTime)
 }
 
+// LogContext 返回携带本次请求关联字段的上下文，供没有 gin.Context 的异步任务记录日志
+func (info *RelayInfo) LogContext() context.Context {
+	if info == nil {
+		return context.Background()
+	}
+	values := map[string]any{
+		common.RequestIdKey:                      info.RequestId,
+		string(constant.ContextKeyUserId):        info.UserId,
+		string(constant.ContextKeyTokenId):       info.TokenId,
+		string(constant.ContextKeyOriginalModel): info.OriginModelName,
+	}
+	if info.ChannelMeta != nil {
+		values[string(constant.ContextKeyChannelId)] = info.ChannelId
+	}
+	return logger.WithValues(context.Background(), values)
+}
+
 type TaskRelayInfo struct {
 	Action       string
 	OriginTaskID string
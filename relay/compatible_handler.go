@@ -15,6 +15,7 @@ import (
 	"one-api/service"
 	"one-api/setting/model_setting"
 	"one-api/setting/operation_setting"
+	"one-api/tracing"
 	"one-api/types"
 	"strings"
 	"time"
@@ -167,7 +168,9 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		}
 	}
 
+	_, responseSpan := tracing.StartSpan(c, "DoResponse")
 	usage, newApiErr := adaptor.DoResponse(c, httpResp, info)
+	responseSpan.End()
 	if newApiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newApiErr, statusCodeMappingStr)
@@ -183,6 +186,13 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 }
 
 func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent string) {
+	// 对冲请求中落败的一方不计费，保留用量用于记录其上游成本
+	if claim, ok := common.GetContextKeyType[func() bool](ctx, constant.ContextKeyHedgeClaim); ok && !claim() {
+		if usage != nil {
+			common.SetContextKey(ctx, constant.ContextKeyResponseUsage, usage)
+		}
+		return
+	}
 	if usage == nil {
 		usage = &dto.Usage{
 			PromptTokens:     relayInfo.PromptTokens,
@@ -190,7 +200,10 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 			TotalTokens:      relayInfo.PromptTokens,
 		}
 		extraContent += "（可能是请求出错）"
+	} else {
+		common.SetContextKey(ctx, constant.ContextKeyResponseUsage, usage)
 	}
+	service.RecordUsageTokens(ctx, relayInfo, usage.PromptTokens+usage.CompletionTokens)
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
 	cacheTokens := usage.PromptTokensDetails.CachedTokens
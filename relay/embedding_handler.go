@@ -10,6 +10,7 @@ import (
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
 	"one-api/service"
+	"one-api/tracing"
 	"one-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -65,7 +66,9 @@ func EmbeddingHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		}
 	}
 
+	_, responseSpan := tracing.StartSpan(c, "DoResponse")
 	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	responseSpan.End()
 	if newAPIError != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
@@ -0,0 +1,131 @@
+package relay
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/service"
+	"strings"
+)
+
+// IsFileRelaySupported 目前仅 OpenAI 兼容渠道支持 Files API 透传
+func IsFileRelaySupported(channel *model.Channel) bool {
+	if channel == nil {
+		return false
+	}
+	apiType, _ := common.ChannelType2APIType(channel.Type)
+	return apiType == constant.APITypeOpenAI
+}
+
+// GetChannelKeyByIndex 多 Key 渠道按下标取 key，保证文件相关请求始终落在上传时的同一个上游账号
+func GetChannelKeyByIndex(channel *model.Channel, index int) string {
+	if !channel.ChannelInfo.IsMultiKey {
+		return channel.Key
+	}
+	keys := channel.GetKeys()
+	if index < 0 || index >= len(keys) {
+		return channel.Key
+	}
+	return keys[index]
+}
+
+func doChannelFileRequest(channel *model.Channel, key string, method string, path string, body io.Reader, contentType string) (*http.Response, error) {
+	baseURL := strings.TrimSuffix(channel.GetBaseURL(), "/")
+	if baseURL == "" {
+		baseURL = constant.ChannelBaseURLs[channel.Type]
+	}
+	req, err := http.NewRequest(method, baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("new request failed: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if channel.OpenAIOrganization != nil && *channel.OpenAIOrganization != "" {
+		req.Header.Set("OpenAI-Organization", *channel.OpenAIOrganization)
+	}
+	for k, v := range channel.GetHeaderOverride() {
+		if str, ok := v.(string); ok {
+			req.Header.Set(k, str)
+		}
+	}
+	client := service.GetHttpClient()
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// UploadFileToChannel 以 multipart 形式将文件上传至渠道的 /v1/files
+func UploadFileToChannel(channel *model.Channel, key string, filename string, purpose string, content io.Reader) (*dto.OpenAIFile, error) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		err := writer.WriteField("purpose", purpose)
+		if err == nil {
+			var part io.Writer
+			part, err = writer.CreateFormFile("file", filename)
+			if err == nil {
+				_, err = io.Copy(part, content)
+			}
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	resp, err := doChannelFileRequest(channel, key, http.MethodPost, "/v1/files", pr, writer.FormDataContentType())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream status code %d: %s", resp.StatusCode, string(respBody))
+	}
+	var file dto.OpenAIFile
+	if err = common.Unmarshal(respBody, &file); err != nil {
+		return nil, err
+	}
+	if file.Id == "" {
+		return nil, fmt.Errorf("upstream returned empty file id: %s", string(respBody))
+	}
+	return &file, nil
+}
+
+func DeleteFileFromChannel(channel *model.Channel, key string, upstreamFileId string) error {
+	resp, err := doChannelFileRequest(channel, key, http.MethodDelete, "/v1/files/"+upstreamFileId, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("upstream status code %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// FetchFileContentFromChannel 调用方负责关闭返回的 Body
+func FetchFileContentFromChannel(channel *model.Channel, key string, upstreamFileId string) (*http.Response, error) {
+	resp, err := doChannelFileRequest(channel, key, http.MethodGet, "/v1/files/"+upstreamFileId+"/content", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("upstream status code %d: %s", resp.StatusCode, string(body))
+	}
+	return resp, nil
+}
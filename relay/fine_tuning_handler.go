@@ -0,0 +1,27 @@
+package relay
+
+import (
+	"bytes"
+	"io"
+	"one-api/model"
+)
+
+// DoFineTuningRequest 向 OpenAI 兼容渠道透传微调相关请求，返回上游状态码与响应体
+func DoFineTuningRequest(channel *model.Channel, key string, method string, path string, body []byte) (int, []byte, error) {
+	var reader io.Reader
+	contentType := ""
+	if body != nil {
+		reader = bytes.NewReader(body)
+		contentType = "application/json"
+	}
+	resp, err := doChannelFileRequest(channel, key, method, path, reader, contentType)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, respBody, nil
+}
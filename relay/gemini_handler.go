@@ -14,6 +14,7 @@ import (
 	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting/model_setting"
+	"one-api/tracing"
 	"one-api/types"
 	"strings"
 
@@ -186,7 +187,9 @@ func GeminiHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 		}
 	}
 
+	_, responseSpan := tracing.StartSpan(c, "DoResponse")
 	usage, openaiErr := adaptor.DoResponse(c, resp.(*http.Response), info)
+	responseSpan.End()
 	if openaiErr != nil {
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
 		return openaiErr
@@ -282,7 +285,9 @@ func GeminiEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo) (newAPI
 		}
 	}
 
+	_, responseSpan := tracing.StartSpan(c, "DoResponse")
 	usage, openaiErr := adaptor.DoResponse(c, resp.(*http.Response), info)
+	responseSpan.End()
 	if openaiErr != nil {
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
 		return openaiErr
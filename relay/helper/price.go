@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"one-api/common"
 	"one-api/constant"
+	"one-api/logger"
 	"one-api/model"
 	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 	"one-api/types"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -73,6 +76,7 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	}
 
 	groupRatioInfo := HandleGroupRatio(c, info)
+	priceRule := applyPriceRule(c, info, &groupRatioInfo)
 
 	var preConsumedQuota int
 	var modelRatio float64
@@ -126,6 +130,7 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		AudioCompletionRatio:   audioCompletionRatio,
 		CacheCreationRatio:     cacheCreationRatio,
 		ShouldPreConsumedQuota: preConsumedQuota,
+		PriceRule:              priceRule,
 	}
 
 	if common.DebugEnabled {
@@ -135,6 +140,39 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	return priceData, nil
 }
 
+// applyPriceRule 按定价规则的月度用量阶梯与时段调整分组倍率，月度用量取自消费日志或数据看板统计
+func applyPriceRule(c *gin.Context, info *relaycommon.RelayInfo, groupRatioInfo *types.GroupRatioInfo) *types.PriceRuleInfo {
+	rule := operation_setting.MatchPriceRule(info.OriginModelName, info.UsingGroup)
+	if rule == nil {
+		return nil
+	}
+	now := time.Now().In(operation_setting.GetPriceRuleLocation())
+	ruleInfo := &types.PriceRuleInfo{Rule: rule.Name, Ratio: 1}
+	if len(rule.Tiers) > 0 {
+		monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Unix()
+		monthlyTokens, err := model.GetUserMonthlyTokenUsage(info.UserId, monthStart)
+		if err != nil {
+			logger.LogError(c, fmt.Sprintf("failed to get monthly token usage for user %d: %s", info.UserId, err.Error()))
+		}
+		ruleInfo.MonthlyTokens = monthlyTokens
+		if tier := rule.MatchTier(monthlyTokens); tier != nil {
+			ruleInfo.Tier = tier.Name
+			ruleInfo.TierRatio = tier.Ratio
+			ruleInfo.Ratio *= tier.Ratio
+		}
+	}
+	if window := rule.MatchTimeWindow(now); window != nil {
+		ruleInfo.TimeWindow = window.Name
+		ruleInfo.WindowRatio = window.Ratio
+		ruleInfo.Ratio *= window.Ratio
+	}
+	if ruleInfo.Tier == "" && ruleInfo.TimeWindow == "" {
+		return nil
+	}
+	groupRatioInfo.GroupRatio *= ruleInfo.Ratio
+	return ruleInfo
+}
+
 // ModelPriceHelperPerCall 按次计费的 PriceHelper (MJ、Task)
 func ModelPriceHelperPerCall(c *gin.Context, info *relaycommon.RelayInfo) types.PerCallPriceData {
 	groupRatioInfo := HandleGroupRatio(c, info)
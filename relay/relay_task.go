@@ -10,6 +10,7 @@ import (
 	"one-api/common"
 	"one-api/constant"
 	"one-api/dto"
+	"one-api/logger"
 	"one-api/model"
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
@@ -94,8 +95,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 	}
 
 	// 🔍 调试日志
-	fmt.Printf("[DEBUG BILLING] BillingModelName=%q, OriginModelName=%q, Final modelName=%q\n",
-		info.BillingModelName, info.OriginModelName, modelName)
+	logger.LogDebug(c, fmt.Sprintf("[BILLING] BillingModelName=%q, OriginModelName=%q, Final modelName=%q", info.BillingModelName, info.OriginModelName, modelName))
 
 	// 预扣费用计算
 	var quota int
@@ -115,16 +115,16 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 		modelPrice, success := ratio_setting.GetModelPrice(modelName, true)
 
 		// 🔍 调试日志
-		fmt.Printf("[DEBUG PRICE] GetModelPrice(%q) = %f, success=%t\n", modelName, modelPrice, success)
+		logger.LogDebug(c, fmt.Sprintf("[PRICE] GetModelPrice(%q) = %f, success=%t", modelName, modelPrice, success))
 
 		if !success {
 			defaultPrice, ok := ratio_setting.GetDefaultModelRatioMap()[modelName]
 			if !ok {
 				modelPrice = 0.1
-				fmt.Printf("[DEBUG PRICE] Using fallback price 0.1\n")
+				logger.LogDebug(c, "[PRICE] Using fallback price 0.1")
 			} else {
 				modelPrice = defaultPrice
-				fmt.Printf("[DEBUG PRICE] Using default price %f\n", defaultPrice)
+				logger.LogDebug(c, fmt.Sprintf("[PRICE] Using default price %f", defaultPrice))
 			}
 		}
 
@@ -135,8 +135,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 		channelRatio := model.GetChannelRatio(info.UsingGroup, modelName, info.ChannelId)
 
 		// 🔍 调试日志
-		fmt.Printf("[DEBUG RATIO] modelPrice=%f, groupRatio=%f, channelRatio=%f\n",
-			modelPrice, groupRatio, channelRatio)
+		logger.LogDebug(c, fmt.Sprintf("[RATIO] modelPrice=%f, groupRatio=%f, channelRatio=%f", modelPrice, groupRatio, channelRatio))
 
 		var ratio float64
 		if hasUserGroupRatio {
@@ -147,7 +146,13 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 		quota = int(ratio * common.QuotaPerUnit)
 
 		// 🔍 调试日志
-		fmt.Printf("[DEBUG QUOTA] final ratio=%f, quota=%d\n", ratio, quota)
+		logger.LogDebug(c, fmt.Sprintf("[QUOTA] final ratio=%f, quota=%d", ratio, quota))
+	}
+
+	// 异步任务失败时退款到用户个人余额，暂不支持组织令牌
+	if info.OrgId != 0 {
+		taskErr = service.TaskErrorWrapperLocal(errors.New("organization tokens are not supported for async tasks"), "org_token_not_supported", http.StatusBadRequest)
+		return
 	}
 
 	// 验证用户额度
@@ -214,7 +219,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 
 			err := service.PostConsumeQuota(info, quota, 0, true)
 			if err != nil {
-				common.SysLog("error consuming token remain quota: " + err.Error())
+				logger.LogError(c, "error consuming token remain quota: "+err.Error())
 			}
 
 			// Vidu Credits 按量计费：跳过预扣逻辑，等待实际 credits 返回后再计费
@@ -289,7 +294,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 			quota = int(float64(actualCredits) * viduCreditPrice * finalRatio * channelRatio * common.QuotaPerUnit)
 
 			// 扣费
-			err = model.DecreaseUserQuota(info.UserId, quota)
+			err = model.DecreaseUserQuota(info.UserId, quota, model.LedgerRef{Reason: model.LedgerReasonConsume, TokenId: info.TokenId, RefType: model.LedgerRefTask, RefId: taskID})
 			if err != nil {
 				taskErr = service.TaskErrorWrapper(err, "insufficient_user_quota", http.StatusForbidden)
 				return
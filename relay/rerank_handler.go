@@ -11,6 +11,7 @@ import (
 	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting/model_setting"
+	"one-api/tracing"
 	"one-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -88,7 +89,9 @@ func RerankHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 		}
 	}
 
+	_, responseSpan := tracing.StartSpan(c, "DoResponse")
 	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	responseSpan.End()
 	if newAPIError != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
@@ -0,0 +1,403 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/shopspring/decimal"
+)
+
+// 对话补全响应缓存：按规范化后的请求计算哈希，命中时直接回放已缓存的响应，不再请求上游。
+// 精确匹配未命中时可按语义相似度匹配（见 response_cache_semantic.go），流式请求以 SSE chunk 列表形式缓存并回放
+
+const responseCacheKeyPrefix = "resp_cache:"
+
+type ResponseCacheEntry struct {
+	Model     string            `json:"model"`
+	IsStream  bool              `json:"is_stream"`
+	Body      json.RawMessage   `json:"body,omitempty"`   // 非流式响应体
+	Chunks    []json.RawMessage `json:"chunks,omitempty"` // 流式响应的 data chunk，不含 [DONE]
+	Usage     dto.Usage         `json:"usage"`
+	CreatedAt int64             `json:"created_at"`
+}
+
+var (
+	responseCacheMemory = newMemoryCache[[]byte]()
+	responseCacheLock   sync.Mutex
+)
+
+// responseCacheRequest 参与缓存键计算的请求字段，user 等不影响输出的字段不参与
+type responseCacheRequest struct {
+	UserId              int                    `json:"user_id,omitempty"`
+	Model               string                 `json:"model"`
+	Messages            []dto.Message          `json:"messages"`
+	Stream              bool                   `json:"stream"`
+	StreamOptions       *dto.StreamOptions     `json:"stream_options,omitempty"`
+	MaxTokens           uint                   `json:"max_tokens,omitempty"`
+	MaxCompletionTokens uint                   `json:"max_completion_tokens,omitempty"`
+	ReasoningEffort     string                 `json:"reasoning_effort,omitempty"`
+	Temperature         *float64               `json:"temperature,omitempty"`
+	TopP                float64                `json:"top_p,omitempty"`
+	TopK                int                    `json:"top_k,omitempty"`
+	Stop                any                    `json:"stop,omitempty"`
+	N                   int                    `json:"n,omitempty"`
+	FrequencyPenalty    float64                `json:"frequency_penalty,omitempty"`
+	PresencePenalty     float64                `json:"presence_penalty,omitempty"`
+	ResponseFormat      *dto.ResponseFormat    `json:"response_format,omitempty"`
+	Seed                float64                `json:"seed,omitempty"`
+	Functions           json.RawMessage        `json:"functions,omitempty"`
+	Tools               []dto.ToolCallRequest  `json:"tools,omitempty"`
+	ToolChoice          any                    `json:"tool_choice,omitempty"`
+	ParallelToolCalls   *bool                  `json:"parallel_tool_calls,omitempty"`
+	LogProbs            bool                   `json:"logprobs,omitempty"`
+	TopLogProbs         int                    `json:"top_logprobs,omitempty"`
+	Modalities          json.RawMessage        `json:"modalities,omitempty"`
+	Audio               json.RawMessage        `json:"audio,omitempty"`
+	Reasoning           json.RawMessage        `json:"reasoning,omitempty"`
+	Thinking            json.RawMessage        `json:"thinking,omitempty"`
+	ExtraBody           json.RawMessage        `json:"extra_body,omitempty"`
+	WorkflowParameters  map[string]interface{} `json:"workflow_parameters,omitempty"`
+	WorkflowId          string                 `json:"workflow_id,omitempty"`
+}
+
+// isResponseCacheBypassed 请求头 X-Cache-Bypass: true 或 Cache-Control: no-cache/no-store 时跳过缓存
+func isResponseCacheBypassed(c *gin.Context) bool {
+	if bypass := strings.ToLower(c.GetHeader("X-Cache-Bypass")); bypass == "true" || bypass == "1" {
+		return true
+	}
+	cacheControl := strings.ToLower(c.GetHeader("Cache-Control"))
+	return strings.Contains(cacheControl, "no-cache") || strings.Contains(cacheControl, "no-store")
+}
+
+// ResponseCacheKey 返回请求的缓存键，请求不可缓存或未启用缓存时返回空字符串
+func ResponseCacheKey(c *gin.Context, info *relaycommon.RelayInfo, request dto.Request) string {
+	if info.RelayMode != relayconstant.RelayModeChatCompletions {
+		return ""
+	}
+	textRequest, ok := request.(*dto.GeneralOpenAIRequest)
+	if !ok {
+		return ""
+	}
+	tokenMode := common.GetContextKeyString(c, constant.ContextKeyTokenResponseCache)
+	if !operation_setting.IsResponseCacheEnabled(info.UsingGroup, tokenMode) || isResponseCacheBypassed(c) {
+		return ""
+	}
+	// 分发阶段已降级到其他模型时不使用缓存
+	if common.GetContextKeyString(c, constant.ContextKeyFallbackFromModel) != "" {
+		return ""
+	}
+	normalized := responseCacheRequest{
+		Model:               textRequest.Model,
+		Messages:            textRequest.Messages,
+		Stream:              textRequest.Stream,
+		StreamOptions:       textRequest.StreamOptions,
+		MaxTokens:           textRequest.MaxTokens,
+		MaxCompletionTokens: textRequest.MaxCompletionTokens,
+		ReasoningEffort:     textRequest.ReasoningEffort,
+		Temperature:         textRequest.Temperature,
+		TopP:                textRequest.TopP,
+		TopK:                textRequest.TopK,
+		Stop:                textRequest.Stop,
+		N:                   textRequest.N,
+		FrequencyPenalty:    textRequest.FrequencyPenalty,
+		PresencePenalty:     textRequest.PresencePenalty,
+		ResponseFormat:      textRequest.ResponseFormat,
+		Seed:                textRequest.Seed,
+		Functions:           textRequest.Functions,
+		Tools:               textRequest.Tools,
+		ToolChoice:          textRequest.ToolChoice,
+		ParallelToolCalls:   textRequest.ParallelTooCalls,
+		LogProbs:            textRequest.LogProbs,
+		TopLogProbs:         textRequest.TopLogProbs,
+		Modalities:          textRequest.Modalities,
+		Audio:               textRequest.Audio,
+		Reasoning:           textRequest.Reasoning,
+		Thinking:            textRequest.THINKING,
+		ExtraBody:           textRequest.ExtraBody,
+		WorkflowParameters:  textRequest.WorkflowParameters,
+		WorkflowId:          textRequest.WorkflowId,
+	}
+	if !operation_setting.GetResponseCacheSetting().ShareAcrossUsers {
+		normalized.UserId = info.UserId
+	}
+	if threshold, ok := operation_setting.SemanticCacheThreshold(info.UsingGroup, tokenMode); ok {
+		if query := newSemanticCacheQuery(info.UsingGroup, threshold, normalized); query != nil {
+			common.SetContextKey(c, constant.ContextKeyResponseCacheSemantic, query)
+		}
+	}
+	// encoding/json 对 map 键排序，序列化结果稳定
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(data)
+	return responseCacheKeyPrefix + hex.EncodeToString(hash[:])
+}
+
+func getResponseCache(key string) (*ResponseCacheEntry, error) {
+	var data []byte
+	if common.RedisEnabled {
+		value, err := common.RedisGet(key)
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		data = []byte(value)
+	} else {
+		responseCacheLock.Lock()
+		item, ok := responseCacheMemory.get(key, time.Now())
+		responseCacheLock.Unlock()
+		if !ok {
+			return nil, nil
+		}
+		data = item
+	}
+	var entry ResponseCacheEntry
+	if err := common.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func setResponseCache(key string, entry *ResponseCacheEntry) error {
+	cacheSetting := operation_setting.GetResponseCacheSetting()
+	data, err := common.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if cacheSetting.MaxEntryBytes > 0 && len(data) > cacheSetting.MaxEntryBytes {
+		return nil
+	}
+	ttl := time.Duration(cacheSetting.TTLSeconds) * time.Second
+	if common.RedisEnabled {
+		return common.RedisSet(key, string(data), ttl)
+	}
+	responseCacheLock.Lock()
+	defer responseCacheLock.Unlock()
+	now := time.Now()
+	responseCacheMemory.set(key, data, now.Add(ttl), cacheSetting.MaxMemoryEntries, now)
+	return nil
+}
+
+// ServeCachedResponse 命中缓存时回放响应并按 billing_ratio 计费，返回是否已命中
+func ServeCachedResponse(c *gin.Context, info *relaycommon.RelayInfo, key string) bool {
+	entry, err := getResponseCache(key)
+	if err != nil {
+		logger.LogError(c, "failed to get response cache: "+err.Error())
+		return false
+	}
+	// similarity 为 1 表示精确匹配
+	similarity := 1.0
+	if entry == nil {
+		entry, similarity = getSemanticResponseCache(c, info)
+	}
+	if entry == nil || entry.IsStream != info.IsStream {
+		return false
+	}
+	c.Header("X-Cache", "HIT")
+	if similarity < 1 {
+		c.Header("X-Cache-Match", "semantic")
+	}
+	if entry.IsStream {
+		helper.SetEventStreamHeaders(c)
+		for _, chunk := range entry.Chunks {
+			if err := helper.ObjectData(c, chunk); err != nil {
+				logger.LogError(c, "failed to replay cached chunk: "+err.Error())
+				break
+			}
+		}
+		helper.Done(c)
+	} else {
+		c.Data(http.StatusOK, "application/json", entry.Body)
+	}
+	info.SetFirstResponseTime()
+	postCachedResponseQuota(c, info, entry, similarity)
+	return true
+}
+
+// cachedResponseQuota 按缓存的用量计算正常费用，再乘以 billing_ratio
+func cachedResponseQuota(info *relaycommon.RelayInfo, usage dto.Usage) int {
+	priceData := info.PriceData
+	dGroupRatio := decimal.NewFromFloat(priceData.GroupRatioInfo.GroupRatio)
+	var dQuota decimal.Decimal
+	if priceData.UsePrice {
+		dQuota = decimal.NewFromFloat(priceData.ModelPrice).Mul(decimal.NewFromFloat(common.QuotaPerUnit)).Mul(dGroupRatio)
+	} else {
+		dTokens := decimal.NewFromInt(int64(usage.PromptTokens)).
+			Add(decimal.NewFromInt(int64(usage.CompletionTokens)).Mul(decimal.NewFromFloat(priceData.CompletionRatio)))
+		dQuota = dTokens.Mul(decimal.NewFromFloat(priceData.ModelRatio)).Mul(dGroupRatio)
+	}
+	billingRatio := operation_setting.GetResponseCacheSetting().BillingRatio
+	return int(dQuota.Mul(decimal.NewFromFloat(billingRatio)).Round(0).IntPart())
+}
+
+func postCachedResponseQuota(c *gin.Context, info *relaycommon.RelayInfo, entry *ResponseCacheEntry, similarity float64) {
+	quota := cachedResponseQuota(info, entry.Usage)
+	if quota > 0 {
+		model.UpdateUserUsedQuotaAndRequestCount(info.UserId, quota)
+	}
+	quotaDelta := quota - info.FinalPreConsumedQuota
+	if quotaDelta != 0 {
+		if err := service.PostConsumeQuota(info, quotaDelta, info.FinalPreConsumedQuota, true); err != nil {
+			logger.LogError(c, "error consuming token remain quota: "+err.Error())
+		}
+	}
+
+	other := make(map[string]interface{})
+	other["model_ratio"] = info.PriceData.ModelRatio
+	other["group_ratio"] = info.PriceData.GroupRatioInfo.GroupRatio
+	other["completion_ratio"] = info.PriceData.CompletionRatio
+	other["model_price"] = info.PriceData.ModelPrice
+	other["cache_hit"] = true
+	other["cache_billing_ratio"] = operation_setting.GetResponseCacheSetting().BillingRatio
+	other["cache_created_at"] = entry.CreatedAt
+	if similarity < 1 {
+		other["cache_similarity"] = similarity
+	}
+	model.RecordCacheHitLog(c, info.UserId, model.RecordConsumeLogParams{
+		PromptTokens:     entry.Usage.PromptTokens,
+		CompletionTokens: entry.Usage.CompletionTokens,
+		ModelName:        info.OriginModelName,
+		TokenName:        c.GetString("token_name"),
+		Quota:            quota,
+		Content:          "命中响应缓存",
+		TokenId:          info.TokenId,
+		UseTimeSeconds:   int(time.Now().Unix() - info.StartTime.Unix()),
+		IsStream:         info.IsStream,
+		Group:            info.UsingGroup,
+		Other:            other,
+	})
+}
+
+// ResponseCapture 包装 ResponseWriter，在写出响应的同时保留一份副本用于写入缓存
+type ResponseCapture struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+// NewResponseCapture 替换 c.Writer 以捕获响应内容
+func NewResponseCapture(c *gin.Context) *ResponseCapture {
+	capture := &ResponseCapture{
+		ResponseWriter: c.Writer,
+		limit:          operation_setting.GetResponseCacheSetting().MaxEntryBytes,
+	}
+	c.Writer = capture
+	c.Header("X-Cache", "MISS")
+	return capture
+}
+
+func (w *ResponseCapture) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.limit > 0 && w.buf.Len()+len(data) > w.limit {
+		w.overflow = true
+		w.buf.Reset()
+		return
+	}
+	w.buf.Write(data)
+}
+
+func (w *ResponseCapture) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *ResponseCapture) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Reset 每次重试前清空已捕获的内容
+func (w *ResponseCapture) Reset() {
+	w.buf.Reset()
+	w.overflow = false
+}
+
+// parseSSEChunks 从捕获的 SSE 输出中提取 data chunk
+func parseSSEChunks(data []byte) ([]json.RawMessage, bool) {
+	chunks := make([]json.RawMessage, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "[DONE]" {
+			continue
+		}
+		if !json.Valid([]byte(payload)) {
+			return nil, false
+		}
+		chunks = append(chunks, json.RawMessage(payload))
+	}
+	if scanner.Err() != nil || len(chunks) == 0 {
+		return nil, false
+	}
+	return chunks, true
+}
+
+// StoreCachedResponse 请求成功后将捕获的响应写入缓存
+func StoreCachedResponse(c *gin.Context, info *relaycommon.RelayInfo, key string, capture *ResponseCapture) {
+	if capture.overflow || capture.Status() != http.StatusOK {
+		return
+	}
+	if common.GetContextKeyString(c, constant.ContextKeyFallbackFromModel) != "" {
+		return
+	}
+	usage, ok := common.GetContextKeyType[*dto.Usage](c, constant.ContextKeyResponseUsage)
+	if !ok || usage == nil || usage.CompletionTokens == 0 {
+		return
+	}
+	entry := &ResponseCacheEntry{
+		Model:     info.OriginModelName,
+		IsStream:  info.IsStream,
+		Usage:     *usage,
+		CreatedAt: common.GetTimestamp(),
+	}
+	data := capture.buf.Bytes()
+	if info.IsStream {
+		chunks, ok := parseSSEChunks(data)
+		if !ok {
+			return
+		}
+		entry.Chunks = chunks
+	} else {
+		if !json.Valid(data) {
+			return
+		}
+		entry.Body = append(json.RawMessage(nil), data...)
+	}
+	if err := setResponseCache(key, entry); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to set response cache: %s", err.Error()))
+		return
+	}
+	storeSemanticCandidate(c, key)
+}
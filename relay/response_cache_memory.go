@@ -0,0 +1,68 @@
+package relay
+
+import (
+	"container/list"
+	"time"
+)
+
+// memoryCache 未启用 Redis 时使用的内存缓存：读取到过期条目时删除，写入超过容量时淘汰最久未访问的条目。
+// 非并发安全，调用方需持有对应的锁
+type memoryCache[V any] struct {
+	items map[string]*list.Element
+	order *list.List // 最近访问的条目在前
+}
+
+type memoryCacheEntry[V any] struct {
+	key      string
+	value    V
+	expireAt time.Time
+}
+
+func newMemoryCache[V any]() *memoryCache[V] {
+	return &memoryCache[V]{
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+func (m *memoryCache[V]) get(key string, now time.Time) (V, bool) {
+	var zero V
+	element, ok := m.items[key]
+	if !ok {
+		return zero, false
+	}
+	entry := element.Value.(*memoryCacheEntry[V])
+	if now.After(entry.expireAt) {
+		m.remove(element)
+		return zero, false
+	}
+	m.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// set 写入条目，maxEntries 大于 0 时先淘汰已过期的尾部条目，仍超出容量时淘汰最久未访问的条目
+func (m *memoryCache[V]) set(key string, value V, expireAt time.Time, maxEntries int, now time.Time) {
+	if element, ok := m.items[key]; ok {
+		entry := element.Value.(*memoryCacheEntry[V])
+		entry.value = value
+		entry.expireAt = expireAt
+		m.order.MoveToFront(element)
+		return
+	}
+	m.items[key] = m.order.PushFront(&memoryCacheEntry[V]{key: key, value: value, expireAt: expireAt})
+	if maxEntries <= 0 {
+		return
+	}
+	for back := m.order.Back(); back != nil && len(m.items) > maxEntries; back = m.order.Back() {
+		m.remove(back)
+	}
+	// 顺带清理尾部已过期的条目，避免长期不被访问的过期条目占用内存
+	for back := m.order.Back(); back != nil && now.After(back.Value.(*memoryCacheEntry[V]).expireAt); back = m.order.Back() {
+		m.remove(back)
+	}
+}
+
+func (m *memoryCache[V]) remove(element *list.Element) {
+	m.order.Remove(element)
+	delete(m.items, element.Value.(*memoryCacheEntry[V]).key)
+}
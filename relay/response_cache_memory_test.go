@@ -0,0 +1,94 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	later := now.Add(time.Hour)
+	type op struct {
+		set      bool
+		key      string
+		expireAt time.Time
+		at       time.Time
+		wantHit  bool
+	}
+	tests := []struct {
+		name       string
+		maxEntries int
+		ops        []op
+	}{
+		{
+			name:       "evicts least recently used when full",
+			maxEntries: 2,
+			ops: []op{
+				{set: true, key: "a", expireAt: later, at: now},
+				{set: true, key: "b", expireAt: later, at: now},
+				{key: "a", at: now, wantHit: true},
+				{set: true, key: "c", expireAt: later, at: now},
+				{key: "b", at: now, wantHit: false},
+				{key: "a", at: now, wantHit: true},
+				{key: "c", at: now, wantHit: true},
+			},
+		},
+		{
+			name:       "expired entries are not returned",
+			maxEntries: 2,
+			ops: []op{
+				{set: true, key: "a", expireAt: now.Add(time.Minute), at: now},
+				{key: "a", at: now.Add(2 * time.Minute), wantHit: false},
+			},
+		},
+		{
+			name:       "expired tail entries are cleaned on write",
+			maxEntries: 3,
+			ops: []op{
+				{set: true, key: "a", expireAt: now.Add(time.Minute), at: now},
+				{set: true, key: "b", expireAt: later, at: now.Add(2 * time.Minute)},
+				{key: "a", at: now, wantHit: false},
+			},
+		},
+		{
+			name:       "overwrite refreshes recency",
+			maxEntries: 2,
+			ops: []op{
+				{set: true, key: "a", expireAt: later, at: now},
+				{set: true, key: "b", expireAt: later, at: now},
+				{set: true, key: "a", expireAt: later, at: now},
+				{set: true, key: "c", expireAt: later, at: now},
+				{key: "a", at: now, wantHit: true},
+				{key: "b", at: now, wantHit: false},
+			},
+		},
+		{
+			name:       "no limit keeps everything",
+			maxEntries: 0,
+			ops: []op{
+				{set: true, key: "a", expireAt: later, at: now},
+				{set: true, key: "b", expireAt: later, at: now},
+				{set: true, key: "c", expireAt: later, at: now},
+				{key: "a", at: now, wantHit: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newMemoryCache[string]()
+			for i, o := range tt.ops {
+				if o.set {
+					cache.set(o.key, o.key, o.expireAt, tt.maxEntries, o.at)
+					if tt.maxEntries > 0 && len(cache.items) > tt.maxEntries {
+						t.Fatalf("op %d: %d entries exceed limit %d", i, len(cache.items), tt.maxEntries)
+					}
+					continue
+				}
+				value, ok := cache.get(o.key, o.at)
+				if ok != o.wantHit || (ok && value != o.key) {
+					t.Fatalf("op %d: get(%s) = %q, %v, want hit %v", i, o.key, value, ok, o.wantHit)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/setting/ratio_setting"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// 响应缓存的语义匹配：精确匹配未命中时，对除 system 以外的消息计算向量，与参数完全相同
+// （模型、采样参数、工具、system 消息等）的已缓存请求比较余弦相似度，超过阈值即视为命中。
+// 向量通过请求分组下 EmbeddingModel 的 OpenAI 兼容渠道计算，按向量模型价格与分组倍率向用户计费并单独记录消费日志；
+// 含图片等非文本内容或工具调用的请求只做精确匹配
+
+const (
+	semanticCacheKeyPrefix     = "resp_cache_sem:"
+	semanticCacheMaxTextLength = 8000
+	semanticEmbeddingTimeout   = 5 * time.Second
+)
+
+type semanticCacheQuery struct {
+	Scope     string
+	Text      string
+	Group     string
+	Threshold float64
+	Vector    []float32 // 查询时计算，未命中时随缓存一并写入
+}
+
+// semanticCacheCandidate 一条已缓存请求的向量，Key 指向精确匹配的缓存条目
+type semanticCacheCandidate struct {
+	Key    string    `json:"key"`
+	Vector []float32 `json:"vector"`
+}
+
+// semanticEmbedding 一次向量计算的结果，用于计费
+type semanticEmbedding struct {
+	Vector    []float32
+	ChannelId int
+	Model     string
+	Usage     dto.Usage
+}
+
+var (
+	semanticCacheMemory = newMemoryCache[[]semanticCacheCandidate]()
+	semanticCacheLock   sync.Mutex
+)
+
+// newSemanticCacheQuery system 消息与其余参数一起决定匹配范围，其余消息的文本用于计算向量
+func newSemanticCacheQuery(group string, threshold float64, normalized responseCacheRequest) *semanticCacheQuery {
+	var scopeMessages []dto.Message
+	var text strings.Builder
+	for i := range normalized.Messages {
+		message := &normalized.Messages[i]
+		if message.Role == "tool" || message.ToolCallId != "" || len(message.ToolCalls) > 0 {
+			return nil
+		}
+		if !message.IsStringContent() && hasNonTextContent(message) {
+			return nil
+		}
+		if message.Role == "system" || message.Role == "developer" {
+			scopeMessages = append(scopeMessages, *message)
+			continue
+		}
+		text.WriteString(message.Role)
+		text.WriteString(": ")
+		text.WriteString(message.StringContent())
+		text.WriteString("\n")
+	}
+	if text.Len() == 0 || len([]rune(text.String())) > semanticCacheMaxTextLength {
+		return nil
+	}
+	normalized.Messages = scopeMessages
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return nil
+	}
+	hash := sha256.Sum256(data)
+	return &semanticCacheQuery{
+		Scope:     semanticCacheKeyPrefix + hex.EncodeToString(hash[:]),
+		Text:      text.String(),
+		Group:     group,
+		Threshold: threshold,
+	}
+}
+
+func hasNonTextContent(message *dto.Message) bool {
+	for _, content := range message.ParseContent() {
+		if content.Type != dto.ContentTypeText {
+			return true
+		}
+	}
+	return false
+}
+
+func cosineSimilarity(a []float32, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// requestSemanticEmbedding 通过分组下的向量模型渠道计算文本向量
+func requestSemanticEmbedding(c *gin.Context, group string, text string) (*semanticEmbedding, error) {
+	modelName := operation_setting.GetResponseCacheSetting().EmbeddingModel
+	channel, _, err := model.CacheGetRandomSatisfiedChannel(c, group, modelName, 0)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, fmt.Errorf("分组 %s 下模型 %s 无可用渠道", group, modelName)
+	}
+	if apiType, _ := common.ChannelType2APIType(channel.Type); apiType != constant.APITypeOpenAI {
+		return nil, fmt.Errorf("渠道 #%d 不支持向量接口", channel.Id)
+	}
+	key, _, apiErr := channel.GetNextEnabledKey()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	body, err := common.Marshal(dto.EmbeddingRequest{Model: modelName, Input: text})
+	if err != nil {
+		return nil, err
+	}
+	baseURL := strings.TrimSuffix(channel.GetBaseURL(), "/")
+	if baseURL == "" {
+		baseURL = constant.ChannelBaseURLs[channel.Type]
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), semanticEmbeddingTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/v1/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Content-Type", "application/json")
+	if channel.OpenAIOrganization != nil && *channel.OpenAIOrganization != "" {
+		req.Header.Set("OpenAI-Organization", *channel.OpenAIOrganization)
+	}
+	client := service.GetHttpClient()
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream status code %d: %s", resp.StatusCode, string(respBody))
+	}
+	var embeddingResponse dto.OpenAIEmbeddingResponse
+	if err := common.Unmarshal(respBody, &embeddingResponse); err != nil {
+		return nil, err
+	}
+	if len(embeddingResponse.Data) == 0 || len(embeddingResponse.Data[0].Embedding) == 0 {
+		return nil, errors.New("upstream returned empty embedding")
+	}
+	embedding := &semanticEmbedding{
+		Vector:    make([]float32, len(embeddingResponse.Data[0].Embedding)),
+		ChannelId: channel.Id,
+		Model:     modelName,
+		Usage:     embeddingResponse.Usage,
+	}
+	for i, value := range embeddingResponse.Data[0].Embedding {
+		embedding.Vector[i] = float32(value)
+	}
+	if embedding.Usage.PromptTokens == 0 {
+		embedding.Usage.PromptTokens = service.CountTextToken(text, modelName)
+	}
+	return embedding, nil
+}
+
+// semanticEmbeddingQuota 按向量模型的价格或倍率与请求分组倍率计算向量计算的费用
+func semanticEmbeddingQuota(info *relaycommon.RelayInfo, embedding *semanticEmbedding) int {
+	dGroupRatio := decimal.NewFromFloat(info.PriceData.GroupRatioInfo.GroupRatio)
+	if modelPrice, ok := ratio_setting.GetModelPrice(embedding.Model, false); ok {
+		return int(decimal.NewFromFloat(modelPrice).Mul(decimal.NewFromFloat(common.QuotaPerUnit)).Mul(dGroupRatio).Round(0).IntPart())
+	}
+	modelRatio, _, _ := ratio_setting.GetModelRatio(embedding.Model)
+	return int(decimal.NewFromInt(int64(embedding.Usage.PromptTokens)).Mul(decimal.NewFromFloat(modelRatio)).Mul(dGroupRatio).Round(0).IntPart())
+}
+
+// postSemanticEmbeddingQuota 向量计算与对话请求分开计费，扣费走正常的消费流程并记录一条向量模型的消费日志
+func postSemanticEmbeddingQuota(c *gin.Context, info *relaycommon.RelayInfo, embedding *semanticEmbedding) {
+	quota := semanticEmbeddingQuota(info, embedding)
+	if quota > 0 {
+		if err := service.PostConsumeQuota(info, quota, 0, false); err != nil {
+			logger.LogError(c, "error consuming response cache embedding quota: "+err.Error())
+			return
+		}
+		model.UpdateUserUsedQuotaAndRequestCount(info.UserId, quota)
+		model.UpdateChannelUsedQuota(embedding.ChannelId, quota)
+	}
+	other := make(map[string]interface{})
+	other["group_ratio"] = info.PriceData.GroupRatioInfo.GroupRatio
+	other["response_cache_embedding"] = true
+	model.RecordConsumeLog(c, info.UserId, model.RecordConsumeLogParams{
+		ChannelId:      embedding.ChannelId,
+		PromptTokens:   embedding.Usage.PromptTokens,
+		ModelName:      embedding.Model,
+		TokenName:      c.GetString("token_name"),
+		Quota:          quota,
+		Content:        "响应缓存语义匹配向量计算",
+		TokenId:        info.TokenId,
+		UseTimeSeconds: int(time.Now().Unix() - info.StartTime.Unix()),
+		Group:          info.UsingGroup,
+		Other:          other,
+	})
+}
+
+func getSemanticCandidates(scope string) ([]semanticCacheCandidate, error) {
+	if common.RedisEnabled {
+		values, err := common.RDB.LRange(context.Background(), scope, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		candidates := make([]semanticCacheCandidate, 0, len(values))
+		for _, value := range values {
+			var candidate semanticCacheCandidate
+			if err := common.UnmarshalJsonStr(value, &candidate); err != nil {
+				continue
+			}
+			candidates = append(candidates, candidate)
+		}
+		return candidates, nil
+	}
+	semanticCacheLock.Lock()
+	defer semanticCacheLock.Unlock()
+	candidates, _ := semanticCacheMemory.get(scope, time.Now())
+	return candidates, nil
+}
+
+// addSemanticCandidate 追加向量并只保留最新的 SemanticMaxCandidates 条，有效期与缓存条目一致
+func addSemanticCandidate(scope string, candidate semanticCacheCandidate) error {
+	cacheSetting := operation_setting.GetResponseCacheSetting()
+	maxCandidates := cacheSetting.SemanticMaxCandidates
+	ttl := time.Duration(cacheSetting.TTLSeconds) * time.Second
+	if common.RedisEnabled {
+		data, err := common.Marshal(candidate)
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		pipe := common.RDB.TxPipeline()
+		pipe.RPush(ctx, scope, data)
+		if maxCandidates > 0 {
+			pipe.LTrim(ctx, scope, int64(-maxCandidates), -1)
+		}
+		pipe.Expire(ctx, scope, ttl)
+		_, err = pipe.Exec(ctx)
+		return err
+	}
+	semanticCacheLock.Lock()
+	defer semanticCacheLock.Unlock()
+	now := time.Now()
+	existing, _ := semanticCacheMemory.get(scope, now)
+	// 复制后追加，避免与读取方共享底层数组
+	candidates := make([]semanticCacheCandidate, 0, len(existing)+1)
+	candidates = append(candidates, existing...)
+	candidates = append(candidates, candidate)
+	if maxCandidates > 0 && len(candidates) > maxCandidates {
+		candidates = candidates[len(candidates)-maxCandidates:]
+	}
+	semanticCacheMemory.set(scope, candidates, now.Add(ttl), cacheSetting.MaxMemoryEntries, now)
+	return nil
+}
+
+// getSemanticResponseCache 返回相似度最高且超过阈值的缓存条目及其相似度，未启用语义匹配或未命中时返回 nil
+func getSemanticResponseCache(c *gin.Context, info *relaycommon.RelayInfo) (*ResponseCacheEntry, float64) {
+	query, ok := common.GetContextKeyType[*semanticCacheQuery](c, constant.ContextKeyResponseCacheSemantic)
+	if !ok || query == nil {
+		return nil, 0
+	}
+	embedding, err := requestSemanticEmbedding(c, query.Group, query.Text)
+	if err != nil {
+		logger.LogWarn(c, "failed to get response cache embedding: "+err.Error())
+		return nil, 0
+	}
+	postSemanticEmbeddingQuota(c, info, embedding)
+	vector := embedding.Vector
+	query.Vector = vector
+	candidates, err := getSemanticCandidates(query.Scope)
+	if err != nil {
+		logger.LogError(c, "failed to get semantic cache candidates: "+err.Error())
+		return nil, 0
+	}
+	var best *semanticCacheCandidate
+	bestSimilarity := 0.0
+	for i := range candidates {
+		similarity := cosineSimilarity(vector, candidates[i].Vector)
+		if similarity >= query.Threshold && similarity > bestSimilarity {
+			best = &candidates[i]
+			bestSimilarity = similarity
+		}
+	}
+	if best == nil {
+		return nil, 0
+	}
+	entry, err := getResponseCache(best.Key)
+	if err != nil {
+		logger.LogError(c, "failed to get response cache: "+err.Error())
+		return nil, 0
+	}
+	if entry == nil {
+		return nil, 0
+	}
+	return entry, bestSimilarity
+}
+
+// storeSemanticCandidate 缓存写入后记录请求向量，查询阶段未能计算向量时跳过
+func storeSemanticCandidate(c *gin.Context, key string) {
+	query, ok := common.GetContextKeyType[*semanticCacheQuery](c, constant.ContextKeyResponseCacheSemantic)
+	if !ok || query == nil || len(query.Vector) == 0 {
+		return
+	}
+	if err := addSemanticCandidate(query.Scope, semanticCacheCandidate{Key: key, Vector: query.Vector}); err != nil {
+		logger.LogError(c, "failed to add semantic cache candidate: "+err.Error())
+	}
+}
@@ -114,12 +114,17 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/search", controller.SearchChannels)
 			channelRoute.GET("/models", controller.ChannelListModels)
 			channelRoute.GET("/models_enabled", controller.EnabledListModels)
+			channelRoute.GET("/stats", controller.GetChannelStats)
+			channelRoute.GET("/breaker/:id", controller.GetChannelBreaker)
+			channelRoute.DELETE("/breaker/:id", controller.ResetChannelBreaker)
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.POST("/:id/key", middleware.CriticalRateLimit(), middleware.DisableCache(), controller.GetChannelKey)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
 			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
+			channelRoute.GET("/reconciliation", controller.GetChannelReconciliations)
+			channelRoute.GET("/balance_snapshots/:id", controller.GetChannelBalanceSnapshots)
 			channelRoute.POST("/", controller.AddChannel)
 			channelRoute.PUT("/", controller.UpdateChannel)
 			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
@@ -181,15 +186,62 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.DELETE("/invalid", controller.DeleteInvalidRedemption)
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
+		planRoute := apiRouter.Group("/plan")
+		planRoute.Use(middleware.AdminAuth())
+		{
+			planRoute.GET("/", controller.GetAllPlans)
+			planRoute.POST("/", controller.AddPlan)
+			planRoute.PUT("/", controller.UpdatePlan)
+			planRoute.DELETE("/:id", controller.DeletePlan)
+		}
+		subscriptionRoute := apiRouter.Group("/subscription")
+		subscriptionRoute.GET("/plans", middleware.UserAuth(), controller.GetSubscriptionPlans)
+		subscriptionRoute.GET("/self", middleware.UserAuth(), controller.GetSelfSubscription)
+		subscriptionRoute.POST("/self/cancel", middleware.UserAuth(), controller.CancelSelfSubscription)
+		subscriptionRoute.POST("/stripe/pay", middleware.UserAuth(), middleware.CriticalRateLimit(), controller.RequestStripeSubscription)
+		subscriptionRoute.GET("/", middleware.AdminAuth(), controller.GetAllSubscriptions)
+		subscriptionRoute.POST("/", middleware.AdminAuth(), controller.GrantSubscription)
+		subscriptionRoute.DELETE("/:id", middleware.AdminAuth(), controller.RevokeSubscription)
+		ledgerRoute := apiRouter.Group("/ledger")
+		ledgerRoute.GET("/self", middleware.UserAuth(), controller.GetSelfQuotaLedgers)
+		ledgerRoute.GET("/", middleware.AdminAuth(), controller.GetUserQuotaLedgers)
+		ledgerRoute.GET("/reconcile", middleware.AdminAuth(), controller.ReconcileQuotaLedger)
+		orgRoute := apiRouter.Group("/org")
+		orgRoute.GET("/self", middleware.UserAuth(), controller.GetSelfOrganizations)
+		orgRoute.POST("/self", middleware.UserAuth(), controller.CreateOrganization)
+		orgRoute.GET("/self/:id/members", middleware.UserAuth(), controller.GetOrganizationMembers)
+		orgRoute.POST("/self/:id/members", middleware.UserAuth(), controller.AddOrganizationMember)
+		orgRoute.PUT("/self/:id/members", middleware.UserAuth(), controller.UpdateOrganizationMember)
+		orgRoute.DELETE("/self/:id/members/:user_id", middleware.UserAuth(), controller.RemoveOrganizationMember)
+		orgRoute.POST("/self/:id/transfer", middleware.UserAuth(), controller.TransferQuotaToOrganization)
+		orgRoute.GET("/self/:id/logs", middleware.UserAuth(), controller.GetOrganizationLogs)
+		orgRoute.GET("/", middleware.AdminAuth(), controller.GetAllOrganizations)
+		orgRoute.PUT("/", middleware.AdminAuth(), controller.UpdateOrganization)
+		orgRoute.POST("/:id/quota", middleware.AdminAuth(), controller.AdjustOrganizationQuota)
+		statementRoute := apiRouter.Group("/statement")
+		statementRoute.GET("/self", middleware.UserAuth(), controller.GetSelfStatements)
+		statementRoute.POST("/self", middleware.UserAuth(), controller.GenerateSelfStatement)
+		statementRoute.GET("/self/:id/download", middleware.UserAuth(), controller.DownloadSelfStatement)
+		statementRoute.GET("/", middleware.AdminAuth(), controller.GetAllStatements)
+		statementRoute.POST("/", middleware.AdminAuth(), controller.GenerateStatements)
+		statementRoute.GET("/:id/download", middleware.AdminAuth(), controller.DownloadStatement)
 		logRoute := apiRouter.Group("/log")
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
 		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRoute.GET("/margin", middleware.AdminAuth(), controller.GetMarginReport)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
 		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
 
+		payloadCaptureRoute := apiRouter.Group("/payload_capture")
+		payloadCaptureRoute.Use(middleware.AdminAuth())
+		{
+			payloadCaptureRoute.GET("/", controller.GetPayloadCaptures)
+			payloadCaptureRoute.GET("/:id", controller.GetPayloadCapture)
+		}
+
 		dataRoute := apiRouter.Group("/data")
 		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
 		dataRoute.GET("/self", middleware.UserAuth(), controller.GetUserQuotaDates)
@@ -0,0 +1,18 @@
+package router
+
+import (
+	"one-api/constant"
+	"one-api/metrics"
+	"one-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetMetricsRouter 仅在设置 METRICS_TOKEN 时于主端口暴露 /metrics；
+// 独立端口（METRICS_PORT）由 main 启动，不经过令牌校验
+func SetMetricsRouter(router *gin.Engine) {
+	if constant.MetricsToken == "" {
+		return
+	}
+	router.GET("/metrics", middleware.MetricsAuth(), gin.WrapH(metrics.Handler()))
+}
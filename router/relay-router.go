@@ -37,6 +37,8 @@ func SetRelayRouter(router *gin.Engine) {
 				controller.RetrieveModel(c, constant.ChannelTypeOpenAI)
 			}
 		})
+
+		modelsRouter.DELETE("/:model", controller.DeleteFineTunedModel)
 	}
 
 	geminiRouter := router.Group("/v1beta/models")
@@ -73,11 +75,29 @@ func SetRelayRouter(router *gin.Engine) {
 
 		// Workflow execution query route
 		relayV1Router.GET("/workflows/executions/:execute_id", controller.GetWorkflowExecution)
+
+		// files routes（不经过 Distribute，上传时按 file_setting.upstream_model 选择渠道）
+		relayV1Router.POST("/files", controller.UploadFile)
+		relayV1Router.GET("/files", controller.ListFiles)
+		relayV1Router.GET("/files/:id", controller.RetrieveFile)
+		relayV1Router.DELETE("/files/:id", controller.DeleteFile)
+		relayV1Router.GET("/files/:id/content", controller.GetFileContent)
+		// batches routes（不经过 Distribute，每行请求由批处理 worker 单独分发渠道）
+		relayV1Router.POST("/batches", controller.CreateBatch)
+		relayV1Router.GET("/batches", controller.ListBatches)
+		relayV1Router.GET("/batches/:id", controller.RetrieveBatch)
+		relayV1Router.POST("/batches/:id/cancel", controller.CancelBatch)
+		// fine-tuning routes（不经过 Distribute，创建时按基础模型或训练文件所在渠道选择）
+		relayV1Router.POST("/fine_tuning/jobs", controller.CreateFineTuningJob)
+		relayV1Router.GET("/fine_tuning/jobs", controller.ListFineTuningJobs)
+		relayV1Router.GET("/fine_tuning/jobs/:id", controller.RetrieveFineTuningJob)
+		relayV1Router.POST("/fine_tuning/jobs/:id/cancel", controller.CancelFineTuningJob)
+		relayV1Router.GET("/fine_tuning/jobs/:id/events", controller.ListFineTuningEvents)
 	}
 	{
 		//http router
 		httpRouter := relayV1Router.Group("")
-		httpRouter.Use(middleware.Distribute())
+		httpRouter.Use(middleware.Idempotency(), middleware.Distribute())
 
 		// claude related routes
 		httpRouter.POST("/messages", func(c *gin.Context) {
@@ -144,17 +164,6 @@ func SetRelayRouter(router *gin.Engine) {
 
 		// not implemented
 		httpRouter.POST("/images/variations", controller.RelayNotImplemented)
-		httpRouter.GET("/files", controller.RelayNotImplemented)
-		httpRouter.POST("/files", controller.RelayNotImplemented)
-		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id/content", controller.RelayNotImplemented)
-		httpRouter.POST("/fine-tunes", controller.RelayNotImplemented)
-		httpRouter.GET("/fine-tunes", controller.RelayNotImplemented)
-		httpRouter.GET("/fine-tunes/:id", controller.RelayNotImplemented)
-		httpRouter.POST("/fine-tunes/:id/cancel", controller.RelayNotImplemented)
-		httpRouter.GET("/fine-tunes/:id/events", controller.RelayNotImplemented)
-		httpRouter.DELETE("/models/:model", controller.RelayNotImplemented)
 	}
 
 	relayMjRouter := router.Group("/mj")
@@ -170,7 +179,7 @@ func SetRelayRouter(router *gin.Engine) {
 	// 中间件顺序：TokenAuth -> Distribute -> AudioRequestConvert
 	// Distribute根据原始model字段选择渠道，AudioRequestConvert转换为Suno格式
 	relayAudioRouter := router.Group("/v1")
-	relayAudioRouter.Use(middleware.TokenAuth(), middleware.Distribute()) // 先认证和分发
+	relayAudioRouter.Use(middleware.TokenAuth(), middleware.Idempotency(), middleware.Distribute()) // 先认证和分发
 	relayAudioRouter.Use(middleware.AudioRequestConvert()) // 后格式转换
 	{
 		relayAudioRouter.POST("/audio/generations", controller.RelayTask)
@@ -181,7 +190,7 @@ func SetRelayRouter(router *gin.Engine) {
 	// 用于支持应用端直接发送 POST /generate 请求
 	// 注意：platform 和 relay_mode 由 Distribute 中间件自动设置
 	directGenerateRouter := router.Group("")
-	directGenerateRouter.Use(middleware.TokenAuth(), middleware.Distribute())
+	directGenerateRouter.Use(middleware.TokenAuth(), middleware.Idempotency(), middleware.Distribute())
 	{
 		directGenerateRouter.POST("/generate", func(c *gin.Context) {
 			c.Params = append(c.Params, gin.Param{Key: "action", Value: "music"})
@@ -196,7 +205,7 @@ func SetRelayRouter(router *gin.Engine) {
 	}
 
 	relaySunoRouter := router.Group("/suno")
-	relaySunoRouter.Use(middleware.TokenAuth(), middleware.Distribute())
+	relaySunoRouter.Use(middleware.TokenAuth(), middleware.Idempotency(), middleware.Distribute())
 	{
 		// 任务模式端点（保持向后兼容）
 		relaySunoRouter.POST("/submit/:action", controller.RelayTask)
@@ -216,7 +225,7 @@ func SetRelayRouter(router *gin.Engine) {
 	relayGeminiRouter := router.Group("/v1beta")
 	relayGeminiRouter.Use(middleware.TokenAuth())
 	relayGeminiRouter.Use(middleware.ModelRequestRateLimit())
-	relayGeminiRouter.Use(middleware.Distribute())
+	relayGeminiRouter.Use(middleware.Idempotency(), middleware.Distribute())
 	{
 		// Gemini API 路径格式: /v1beta/models/{model_name}:{action}
 		relayGeminiRouter.POST("/models/*path", func(c *gin.Context) {
@@ -258,7 +267,7 @@ func SetRelayRouter(router *gin.Engine) {
 
 	// Tripo3D 任务路由：支持提交与查询
 	relayTripoRouter := router.Group("/tripo")
-	relayTripoRouter.Use(middleware.TokenAuth(), middleware.Distribute())
+	relayTripoRouter.Use(middleware.TokenAuth(), middleware.Idempotency(), middleware.Distribute())
 	{
 		relayTripoRouter.POST("/task", controller.RelayTask)
 		relayTripoRouter.GET("/task/:task_id", controller.RelayTask)
@@ -267,7 +276,7 @@ func SetRelayRouter(router *gin.Engine) {
 
 func registerMjRouterGroup(relayMjRouter *gin.RouterGroup) {
 	relayMjRouter.GET("/image/:id", relay.RelayMidjourneyImage)
-	relayMjRouter.Use(middleware.TokenAuth(), middleware.Distribute())
+	relayMjRouter.Use(middleware.TokenAuth(), middleware.Idempotency(), middleware.Distribute())
 	{
 		relayMjRouter.POST("/submit/action", controller.RelayMidjourney)
 		relayMjRouter.POST("/submit/shorten", controller.RelayMidjourney)
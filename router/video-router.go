@@ -9,7 +9,7 @@ import (
 
 func SetVideoRouter(router *gin.Engine) {
 	videoV1Router := router.Group("/v1")
-	videoV1Router.Use(middleware.TokenAuth(), middleware.Distribute())
+	videoV1Router.Use(middleware.TokenAuth(), middleware.Idempotency(), middleware.Distribute())
 	{
 		videoV1Router.POST("/video/generations", controller.RelayTask)
 		videoV1Router.GET("/video/generations/:task_id", controller.RelayTask)
@@ -21,14 +21,14 @@ func SetVideoRouter(router *gin.Engine) {
 	// 支持参数: model, prompt, size, input_reference (文件), seconds, watermark 等
     soraVideosRouter := router.Group("/v1")
     // Place SoraVideosAdapter before TokenAuth/Distribute to preserve body and extract model
-    soraVideosRouter.Use(middleware.SoraVideosAdapter(), middleware.TokenAuth(), middleware.Distribute())
+    soraVideosRouter.Use(middleware.SoraVideosAdapter(), middleware.TokenAuth(), middleware.Idempotency(), middleware.Distribute())
 	{
 		soraVideosRouter.POST("/videos", controller.RelayBltcy)
 		soraVideosRouter.GET("/videos/:id", controller.RelayBltcy)
 	}
 
 	klingV1Router := router.Group("/kling/v1")
-	klingV1Router.Use(middleware.KlingRequestConvert(), middleware.TokenAuth(), middleware.Distribute())
+	klingV1Router.Use(middleware.KlingRequestConvert(), middleware.TokenAuth(), middleware.Idempotency(), middleware.Distribute())
 	{
 		klingV1Router.POST("/videos/text2video", controller.RelayTask)
 		klingV1Router.POST("/videos/image2video", controller.RelayTask)
@@ -38,7 +38,7 @@ func SetVideoRouter(router *gin.Engine) {
 
 	// Jimeng official API routes - direct mapping to official API format
 	jimengOfficialGroup := router.Group("jimeng")
-	jimengOfficialGroup.Use(middleware.JimengRequestConvert(), middleware.TokenAuth(), middleware.Distribute())
+	jimengOfficialGroup.Use(middleware.JimengRequestConvert(), middleware.TokenAuth(), middleware.Idempotency(), middleware.Distribute())
 	{
 		// Maps to: /?Action=CVSync2AsyncSubmitTask&Version=2022-08-31 and /?Action=CVSync2AsyncGetResult&Version=2022-08-31
 		jimengOfficialGroup.POST("/", controller.RelayTask)
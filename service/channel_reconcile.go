@@ -0,0 +1,112 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"time"
+)
+
+// ReconcileChannelBalance 记录渠道最新余额快照，并将与上一次快照之间的上游扣减和网关用量对账，偏差过大时通知管理员
+func ReconcileChannelBalance(channel *model.Channel, balance float64) {
+	setting := operation_setting.GetChannelReconcileSetting()
+	if !setting.Enabled {
+		return
+	}
+	balance, ok := channelBalanceUsd(channel.Type, balance)
+	if !ok {
+		return
+	}
+	now := common.GetTimestamp()
+	snapshot := &model.ChannelBalanceSnapshot{
+		ChannelId: channel.Id,
+		Balance:   balance,
+		UsedQuota: channel.UsedQuota,
+		CreatedAt: now,
+	}
+	previous, err := model.GetLatestChannelBalanceSnapshot(channel.Id)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to get channel balance snapshot: channel_id=%d, error=%v", channel.Id, err))
+		return
+	}
+	if previous == nil {
+		if err := model.SaveChannelReconciliation(snapshot, nil); err != nil {
+			common.SysLog(fmt.Sprintf("failed to save channel balance snapshot: channel_id=%d, error=%v", channel.Id, err))
+		}
+		return
+	}
+
+	logged, err := model.SumChannelConsumeLogs(channel.Id, previous.CreatedAt, now)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to sum channel logs: channel_id=%d, error=%v", channel.Id, err))
+		return
+	}
+	reconciliation := &model.ChannelReconciliation{
+		ChannelId:      channel.Id,
+		ChannelName:    channel.Name,
+		PeriodStart:    previous.CreatedAt,
+		PeriodEnd:      now,
+		StartBalance:   previous.Balance,
+		EndBalance:     balance,
+		Drawdown:       previous.Balance - balance,
+		UsedQuotaDelta: channel.UsedQuota - previous.UsedQuota,
+		LoggedQuota:    logged.Quota,
+		LoggedCost:     logged.Cost,
+		Expected:       float64(logged.Expected) / common.QuotaPerUnit,
+		CreatedAt:      now,
+	}
+	reconciliation.Drift = reconciliation.Drawdown - reconciliation.Expected
+	if reconciliation.Expected > 0 {
+		reconciliation.DriftRate = reconciliation.Drift / reconciliation.Expected
+	}
+	// 余额上升说明期间有充值，无法计算扣减
+	reconciliation.Skipped = reconciliation.Drawdown < 0
+	if !reconciliation.Skipped && exceedsDriftThreshold(reconciliation, setting) {
+		reconciliation.Alerted = true
+	}
+
+	if err := model.SaveChannelReconciliation(snapshot, reconciliation); err != nil {
+		common.SysLog(fmt.Sprintf("failed to save channel reconciliation: channel_id=%d, error=%v", channel.Id, err))
+		return
+	}
+	if reconciliation.Alerted {
+		subject := fmt.Sprintf("渠道「%s」（#%d）上游扣费与网关用量不一致", channel.Name, channel.Id)
+		content := fmt.Sprintf("渠道「%s」（#%d）在 %s 至 %s 期间上游余额减少 $%.4f，网关记录的用量为 $%.4f，偏差 $%.4f（%.1f%%），请检查密钥是否泄露或模型定价是否有误",
+			channel.Name, channel.Id, time.Unix(previous.CreatedAt, 0).Format("2006-01-02 15:04:05"), time.Unix(now, 0).Format("2006-01-02 15:04:05"),
+			reconciliation.Drawdown, reconciliation.Expected, reconciliation.Drift, reconciliation.DriftRate*100)
+		NotifyRootUser(fmt.Sprintf("channel_reconcile_%d", channel.Id), subject, content)
+	}
+}
+
+// channelBalanceUsd 将上游余额查询结果换算为美元，与网关用量比较；余额单位未知的渠道不参与对账
+func channelBalanceUsd(channelType int, balance float64) (float64, bool) {
+	switch channelType {
+	case constant.ChannelTypeDeepSeek, constant.ChannelTypeSiliconFlow:
+		// 余额以人民币计
+		if operation_setting.USDExchangeRate <= 0 {
+			return 0, false
+		}
+		return balance / operation_setting.USDExchangeRate, true
+	case constant.ChannelTypeOpenAI, constant.ChannelTypeCustom, constant.ChannelTypeOpenRouter, constant.ChannelTypeMoonshot:
+		// OpenAI 兼容的账单接口与 OpenRouter 以美元计，Moonshot 查询时已换算为美元
+		return balance, true
+	default:
+		// AIProxy 为平台积分，其余渠道的余额单位未确认，不参与对账
+		return 0, false
+	}
+}
+
+func exceedsDriftThreshold(reconciliation *model.ChannelReconciliation, setting *operation_setting.ChannelReconcileSetting) bool {
+	drift := math.Abs(reconciliation.Drift)
+	if drift < setting.MinDriftUsd {
+		return false
+	}
+	// 网关无用量但上游有扣减，视为超出阈值
+	if reconciliation.Expected <= 0 {
+		return drift > 0
+	}
+	return math.Abs(reconciliation.DriftRate)*100 > setting.DriftThresholdPercent
+}
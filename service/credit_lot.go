@@ -0,0 +1,19 @@
+package service
+
+import (
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"time"
+)
+
+// StartCreditLotWorker 定期清理已过期的额度批次
+func StartCreditLotWorker() {
+	for {
+		model.ExpireCreditLots()
+		interval := operation_setting.GetCreditLotSetting().CheckIntervalSeconds
+		if interval <= 0 {
+			interval = 300
+		}
+		time.Sleep(time.Duration(interval) * time.Second)
+	}
+}
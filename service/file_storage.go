@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"one-api/constant"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileStorage 文件内容存储后端，默认使用本地文件系统
+type FileStorage interface {
+	Save(name string, r io.Reader) (int64, error)
+	Open(name string) (io.ReadCloser, error)
+	Delete(name string) error
+}
+
+type localFileStorage struct {
+	root string
+}
+
+var (
+	fileStorage     FileStorage
+	fileStorageOnce sync.Once
+)
+
+// GetFileStorage 返回全局文件存储，首次调用时按 FILE_STORAGE_DIR 初始化
+func GetFileStorage() FileStorage {
+	fileStorageOnce.Do(func() {
+		fileStorage = &localFileStorage{root: constant.FileStorageDir}
+	})
+	return fileStorage
+}
+
+// SetFileStorage 替换文件存储后端（例如对象存储）
+func SetFileStorage(storage FileStorage) {
+	fileStorageOnce.Do(func() {})
+	fileStorage = storage
+}
+
+func (s *localFileStorage) path(name string) (string, error) {
+	cleaned := filepath.Clean("/" + name)
+	if strings.Contains(cleaned, "..") {
+		return "", errors.New("invalid file name")
+	}
+	return filepath.Join(s.root, cleaned), nil
+}
+
+func (s *localFileStorage) Save(name string, r io.Reader) (int64, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return 0, err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	closeErr := f.Close()
+	if err != nil {
+		_ = os.Remove(p)
+		return 0, err
+	}
+	return n, closeErr
+}
+
+func (s *localFileStorage) Open(name string) (io.ReadCloser, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (s *localFileStorage) Delete(name string) error {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
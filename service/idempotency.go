@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 幂等键存储：启用 Redis 时使用 Redis，否则使用数据库，保证多节点部署下同样生效
+
+const idempotencyRedisKeyPrefix = "idempotency:"
+
+//go:embed lua/release_idempotency_key.lua
+var releaseIdempotencyKeyScriptSource string
+
+//go:embed lua/complete_idempotency_key.lua
+var completeIdempotencyKeyScriptSource string
+
+var (
+	releaseIdempotencyKeyScript  = redis.NewScript(releaseIdempotencyKeyScriptSource)
+	completeIdempotencyKeyScript = redis.NewScript(completeIdempotencyKeyScriptSource)
+)
+
+// errIdempotencyKeyLost 首个请求处理超过占用时长，幂等键已过期或被其他请求重新占用
+var errIdempotencyKeyLost = errors.New("idempotency key is no longer held by this request")
+
+// AcquireIdempotencyKey 尝试占用幂等键，成功时记录处于处理中状态直到完成或超时。
+// 每次占用生成新的占用者标识，完成与释放时据此确认记录仍属于本次请求
+func AcquireIdempotencyKey(record *model.IdempotencyRecord) (bool, error) {
+	lockTimeout := operation_setting.GetIdempotencySetting().LockTimeoutSeconds
+	if lockTimeout <= 0 {
+		lockTimeout = 600
+	}
+	now := common.GetTimestamp()
+	record.Owner = common.GetUUID()
+	record.Status = model.IdempotencyStatusProcessing
+	record.CreatedAt = now
+	record.ExpiresAt = now + int64(lockTimeout)
+	if common.RedisEnabled {
+		data, err := common.Marshal(record)
+		if err != nil {
+			return false, err
+		}
+		return common.RDB.SetNX(context.Background(), idempotencyRedisKeyPrefix+record.Key, string(data), time.Duration(lockTimeout)*time.Second).Result()
+	}
+	return model.AcquireIdempotencyRecord(record, now)
+}
+
+// GetIdempotencyRecord 不存在或已过期时返回 nil
+func GetIdempotencyRecord(key string) (*model.IdempotencyRecord, error) {
+	if common.RedisEnabled {
+		value, err := common.RedisGet(idempotencyRedisKeyPrefix + key)
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		var record model.IdempotencyRecord
+		if err := common.UnmarshalJsonStr(value, &record); err != nil {
+			return nil, err
+		}
+		return &record, nil
+	}
+	return model.GetIdempotencyRecord(key, common.GetTimestamp())
+}
+
+// CompleteIdempotencyKey 保存首个请求的响应与计费结果，在 TTLSeconds 内供重试回放
+func CompleteIdempotencyKey(record *model.IdempotencyRecord) error {
+	ttl := operation_setting.GetIdempotencySetting().TTLSeconds
+	if ttl <= 0 {
+		return ReleaseIdempotencyKey(record)
+	}
+	record.Status = model.IdempotencyStatusCompleted
+	record.ExpiresAt = common.GetTimestamp() + int64(ttl)
+	var completed bool
+	if common.RedisEnabled {
+		data, err := common.Marshal(record)
+		if err != nil {
+			return err
+		}
+		keys := []string{idempotencyRedisKeyPrefix + record.Key}
+		result, err := completeIdempotencyKeyScript.Run(context.Background(), common.RDB, keys, record.Owner, string(data), ttl*1000).Int()
+		if err != nil {
+			return err
+		}
+		completed = result == 1
+	} else {
+		var err error
+		if completed, err = model.CompleteIdempotencyRecord(record); err != nil {
+			return err
+		}
+	}
+	if !completed {
+		return errIdempotencyKeyLost
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey 请求失败时释放幂等键，允许客户端重试；幂等键已被其他请求占用时不做处理
+func ReleaseIdempotencyKey(record *model.IdempotencyRecord) error {
+	if common.RedisEnabled {
+		keys := []string{idempotencyRedisKeyPrefix + record.Key}
+		return releaseIdempotencyKeyScript.Run(context.Background(), common.RDB, keys, record.Owner).Err()
+	}
+	return model.DeleteIdempotencyRecord(record.Key, record.Owner)
+}
+
+// StartIdempotencyCleanupWorker 定期清理数据库中已过期的幂等记录，Redis 依赖自身的过期机制
+func StartIdempotencyCleanupWorker() {
+	for {
+		time.Sleep(time.Hour)
+		if common.RedisEnabled {
+			continue
+		}
+		count, err := model.DeleteExpiredIdempotencyRecords(common.GetTimestamp())
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to delete expired idempotency records: %s", err.Error()))
+			continue
+		}
+		if count > 0 {
+			common.SysLog(fmt.Sprintf("deleted %d expired idempotency records", count))
+		}
+	}
+}
@@ -35,6 +35,21 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		other["is_system_prompt_overwritten"] = true
 	}
 
+	if batchId := common.GetContextKeyString(ctx, constant.ContextKeyBatchId); batchId != "" {
+		other["batch_id"] = batchId
+		if discountRatio, ok := common.GetContextKeyType[float64](ctx, constant.ContextKeyBatchDiscountRatio); ok {
+			other["batch_discount_ratio"] = discountRatio
+		}
+	}
+
+	if relayInfo.PriceData.PriceRule != nil {
+		other["price_rule"] = relayInfo.PriceData.PriceRule
+	}
+
+	if fallbackFrom := common.GetContextKeyString(ctx, constant.ContextKeyFallbackFromModel); fallbackFrom != "" {
+		other["fallback_from_model"] = fallbackFrom
+	}
+
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
 	isMultiKey := common.GetContextKeyBool(ctx, constant.ContextKeyChannelIsMultiKey)
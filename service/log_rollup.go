@@ -0,0 +1,22 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"time"
+)
+
+// StartLogRollupWorker 定期将已结束日期的日志汇总到 log_rollups，删除历史日志后报表仍可使用
+func StartLogRollupWorker() {
+	for {
+		days, err := model.RollupLogs(context.Background(), common.GetTimestamp())
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to rollup logs: %s", err.Error()))
+		} else if days > 0 {
+			common.SysLog(fmt.Sprintf("rolled up logs of %d days", days))
+		}
+		time.Sleep(time.Hour)
+	}
+}
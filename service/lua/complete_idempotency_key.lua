@@ -0,0 +1,16 @@
+-- 幂等键仍由本次请求占用时才保存结果，避免占用超时后覆盖其他请求新占用的记录
+-- KEYS[1]: 幂等键
+-- ARGV[1]: 占用者标识
+-- ARGV[2]: 完成后的记录
+-- ARGV[3]: 保存时长（毫秒）
+
+local value = redis.call('GET', KEYS[1])
+if not value then
+    return 0
+end
+local ok, record = pcall(cjson.decode, value)
+if not ok or record.owner ~= ARGV[1] then
+    return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
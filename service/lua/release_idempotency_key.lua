@@ -0,0 +1,13 @@
+-- 幂等键仍由本次请求占用时才删除，避免占用超时后误删其他请求新占用的记录
+-- KEYS[1]: 幂等键
+-- ARGV[1]: 占用者标识
+
+local value = redis.call('GET', KEYS[1])
+if not value then
+    return 0
+end
+local ok, record = pcall(cjson.decode, value)
+if not ok or record.owner ~= ARGV[1] then
+    return 0
+end
+return redis.call('DEL', KEYS[1])
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"one-api/metrics"
+	"one-api/model"
+	"sync"
+	"time"
+)
+
+// pendingTaskCacheDuration 采集间隔内复用统计结果，避免频繁采集时反复查询数据库
+const pendingTaskCacheDuration = 10 * time.Second
+
+var (
+	pendingTaskLock     sync.Mutex
+	pendingTaskCounts   map[string]int64
+	pendingTaskCachedAt time.Time
+)
+
+func countPendingTasks() (map[string]int64, error) {
+	pendingTaskLock.Lock()
+	defer pendingTaskLock.Unlock()
+	if pendingTaskCounts != nil && time.Since(pendingTaskCachedAt) < pendingTaskCacheDuration {
+		return pendingTaskCounts, nil
+	}
+	counts, err := model.CountPendingTasksByPlatform()
+	if err != nil {
+		return nil, err
+	}
+	pendingTaskCounts = counts
+	pendingTaskCachedAt = time.Now()
+	return counts, nil
+}
+
+func init() {
+	metrics.SetPendingTaskCounter(countPendingTasks)
+}
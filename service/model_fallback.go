@@ -0,0 +1,42 @@
+package service
+
+import (
+	"one-api/common"
+	"one-api/constant"
+	"one-api/setting/operation_setting"
+	"one-api/setting/ratio_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetModelFallbacks 返回模型的降级链，优先使用令牌配置，其次分组与全局配置；
+// 已剔除令牌无权访问的模型与原模型本身
+func GetModelFallbacks(c *gin.Context, group string, modelName string) []string {
+	var fallbacks []string
+	if tokenFallbacks, ok := common.GetContextKeyType[map[string][]string](c, constant.ContextKeyTokenModelFallbacks); ok {
+		fallbacks = tokenFallbacks[modelName]
+	}
+	if len(fallbacks) == 0 {
+		fallbacks = operation_setting.GetGroupModelFallbacks(group, modelName)
+	}
+	result := make([]string, 0, len(fallbacks))
+	for _, fallback := range fallbacks {
+		if fallback == "" || fallback == modelName || !tokenModelAllowed(c, fallback) {
+			continue
+		}
+		result = append(result, fallback)
+	}
+	return result
+}
+
+func tokenModelAllowed(c *gin.Context, modelName string) bool {
+	if !common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		return true
+	}
+	tokenModelLimit, ok := common.GetContextKeyType[map[string]bool](c, constant.ContextKeyTokenModelLimit)
+	if !ok {
+		return false
+	}
+	_, ok = tokenModelLimit[ratio_setting.FormatMatchingModelName(modelName)]
+	return ok
+}
@@ -0,0 +1,463 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// 请求内容抓取：命中规则并被采样的请求会保存客户端请求体、经过模型映射、参数覆盖与格式转换后的上游请求体，
+// 以及上游响应（流式响应额外拼接出完整文本），与消费日志关联。只保存请求体，不保存请求头。
+
+const (
+	redactedPlaceholder = "[REDACTED]"
+	// MySQL TEXT 字段上限为 65535 字节
+	payloadCaptureMaxBodyBytes = 60000
+	// 流式响应中单行的最大长度，超出时丢弃该行
+	payloadCaptureMaxLineBytes = 1 << 20
+)
+
+type payloadCaptureState struct {
+	// 每个请求只取一次随机数，重试到其他渠道时按同一个随机数判断是否采样
+	roll    float64
+	attempt *PayloadCaptureAttempt
+}
+
+// PayloadCaptureAttempt 一次上游请求的抓取内容，重试时被新的请求替换
+type PayloadCaptureAttempt struct {
+	mu            sync.Mutex
+	channelId     int
+	upstreamModel string
+	upstreamUrl   string
+	isStream      bool
+	statusCode    int
+	upstreamBody  []byte
+	response      bytes.Buffer
+	responseLimit int
+	truncated     bool
+	assembler     *streamTextAssembler
+}
+
+// BeginPayloadCapture 在请求开始时调用，未开启抓取时不做任何处理
+func BeginPayloadCapture(c *gin.Context) {
+	captureSetting := operation_setting.GetPayloadCaptureSetting()
+	if !captureSetting.Enabled || len(captureSetting.Rules) == 0 {
+		return
+	}
+	common.SetContextKey(c, constant.ContextKeyPayloadCapture, &payloadCaptureState{roll: rand.Float64()})
+}
+
+// CaptureUpstreamRequest 发送上游请求前调用，命中规则时保存上游请求体并返回本次抓取，否则返回 nil
+func CaptureUpstreamRequest(c *gin.Context, info *relaycommon.RelayInfo, req *http.Request) *PayloadCaptureAttempt {
+	state, ok := common.GetContextKeyType[*payloadCaptureState](c, constant.ContextKeyPayloadCapture)
+	if !ok || state == nil {
+		return nil
+	}
+	// 对冲请求在多个渠道上并发执行，无法确定最终使用的结果，不抓取
+	if _, hedged := c.Get(string(constant.ContextKeyHedgeContext)); hedged {
+		return nil
+	}
+	state.attempt = nil
+	captureSetting := operation_setting.GetPayloadCaptureSetting()
+	if state.roll >= captureSetting.SampleRateFor(info.UserId, info.TokenId, info.ChannelId) {
+		return nil
+	}
+	limit := payloadCaptureBodyLimit(captureSetting)
+	attempt := &PayloadCaptureAttempt{
+		channelId:     info.ChannelId,
+		upstreamModel: info.UpstreamModelName,
+		upstreamUrl:   req.URL.Scheme + "://" + req.URL.Host + req.URL.Path,
+		isStream:      info.IsStream,
+		// 先完整保留，脱敏后再截断，避免截断后的 JSON 无法解析
+		responseLimit: limit * 4,
+	}
+	if info.IsStream {
+		attempt.assembler = &streamTextAssembler{limit: limit}
+	}
+	if isMultipartContentType(req.Header.Get("Content-Type")) {
+		attempt.upstreamBody = []byte("[multipart/form-data omitted]")
+	} else if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		attempt.upstreamBody = body
+	}
+	state.attempt = attempt
+	return attempt
+}
+
+// CaptureResponse 包装上游响应体，在 handler 读取的同时保存一份副本
+func (a *PayloadCaptureAttempt) CaptureResponse(resp *http.Response) {
+	if a == nil || resp == nil {
+		return
+	}
+	a.mu.Lock()
+	a.statusCode = resp.StatusCode
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		// 上游对流式请求返回错误时响应不是 SSE
+		a.assembler = nil
+	}
+	a.mu.Unlock()
+	if resp.Body != nil {
+		resp.Body = &payloadCaptureReader{ReadCloser: resp.Body, attempt: a}
+	}
+}
+
+func (a *PayloadCaptureAttempt) write(data []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if remain := a.responseLimit - a.response.Len(); remain > 0 {
+		if len(data) > remain {
+			a.response.Write(data[:remain])
+			a.truncated = true
+		} else {
+			a.response.Write(data)
+		}
+	} else {
+		a.truncated = true
+	}
+	if a.assembler != nil {
+		a.assembler.write(data)
+	}
+}
+
+type payloadCaptureReader struct {
+	io.ReadCloser
+	attempt *PayloadCaptureAttempt
+}
+
+func (r *payloadCaptureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.attempt.write(p[:n])
+	}
+	return n, err
+}
+
+// FinishPayloadCapture 请求结束时调用，将最后一次上游请求的抓取内容脱敏后写入数据库
+func FinishPayloadCapture(c *gin.Context, info *relaycommon.RelayInfo) {
+	state, ok := common.GetContextKeyType[*payloadCaptureState](c, constant.ContextKeyPayloadCapture)
+	if !ok || state == nil || state.attempt == nil {
+		return
+	}
+	attempt := state.attempt
+	captureSetting := operation_setting.GetPayloadCaptureSetting()
+	limit := payloadCaptureBodyLimit(captureSetting)
+	redactor := getPayloadRedactor(captureSetting)
+
+	var inbound []byte
+	if isMultipartContentType(c.Request.Header.Get("Content-Type")) {
+		inbound = []byte("[multipart/form-data omitted]")
+	} else {
+		inbound, _ = common.GetRequestBody(c)
+	}
+
+	attempt.mu.Lock()
+	response := append([]byte(nil), attempt.response.Bytes()...)
+	truncated := attempt.truncated
+	isSSE := attempt.assembler != nil
+	responseText := ""
+	if attempt.assembler != nil {
+		responseText = attempt.assembler.text.String()
+		truncated = truncated || attempt.assembler.truncated
+	}
+	statusCode := attempt.statusCode
+	attempt.mu.Unlock()
+
+	capture := &model.PayloadCapture{
+		LogId:         common.GetContextKeyInt(c, constant.ContextKeyConsumeLogId),
+		RequestId:     c.GetString(common.RequestIdKey),
+		UserId:        info.UserId,
+		TokenId:       info.TokenId,
+		ChannelId:     attempt.channelId,
+		ModelName:     info.OriginModelName,
+		UpstreamModel: attempt.upstreamModel,
+		UpstreamUrl:   attempt.upstreamUrl,
+		IsStream:      attempt.isStream,
+		StatusCode:    statusCode,
+		CreatedAt:     common.GetTimestamp(),
+	}
+	var cut bool
+	capture.InboundBody, cut = truncatePayload(redactor.redact(inbound, false), limit)
+	truncated = truncated || cut
+	capture.UpstreamBody, cut = truncatePayload(redactor.redact(attempt.upstreamBody, false), limit)
+	truncated = truncated || cut
+	capture.ResponseBody, cut = truncatePayload(redactor.redact(response, isSSE), limit)
+	truncated = truncated || cut
+	capture.ResponseText, _ = truncatePayload(redactor.redactText(responseText), limit)
+	capture.Truncated = truncated
+
+	gopool.Go(func() {
+		if err := model.CreatePayloadCapture(capture); err != nil {
+			common.SysError("failed to save payload capture: " + err.Error())
+		}
+	})
+}
+
+func payloadCaptureBodyLimit(captureSetting *operation_setting.PayloadCaptureSetting) int {
+	limit := captureSetting.MaxBodyBytes
+	if limit <= 0 || limit > payloadCaptureMaxBodyBytes {
+		limit = payloadCaptureMaxBodyBytes
+	}
+	return limit
+}
+
+func isMultipartContentType(contentType string) bool {
+	return strings.HasPrefix(strings.ToLower(contentType), "multipart/")
+}
+
+// truncatePayload 按字节截断，不截断在 UTF-8 字符中间
+func truncatePayload(text string, limit int) (string, bool) {
+	if len(text) <= limit {
+		return text, false
+	}
+	end := limit
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end], true
+}
+
+// streamTextAssembler 从 SSE 响应中拼接出模型输出的文本，兼容 OpenAI、Claude、Gemini 与 Responses 格式
+type streamTextAssembler struct {
+	pending   []byte
+	text      strings.Builder
+	limit     int
+	truncated bool
+}
+
+type captureStreamChunk struct {
+	Type    string          `json:"type"`
+	Delta   json.RawMessage `json:"delta"`
+	Choices []struct {
+		Text  string `json:"text"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+}
+
+func (s *streamTextAssembler) write(data []byte) {
+	s.pending = append(s.pending, data...)
+	consumed := 0
+	for {
+		idx := bytes.IndexByte(s.pending[consumed:], '\n')
+		if idx < 0 {
+			break
+		}
+		s.line(s.pending[consumed : consumed+idx])
+		consumed += idx + 1
+	}
+	if consumed > 0 {
+		s.pending = append([]byte(nil), s.pending[consumed:]...)
+	}
+	if len(s.pending) > payloadCaptureMaxLineBytes {
+		s.pending = nil
+	}
+}
+
+func (s *streamTextAssembler) line(line []byte) {
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return
+	}
+	payload := bytes.TrimSpace(line[len("data:"):])
+	if len(payload) == 0 || string(payload) == "[DONE]" {
+		return
+	}
+	var chunk captureStreamChunk
+	if err := common.Unmarshal(payload, &chunk); err != nil {
+		return
+	}
+	for _, choice := range chunk.Choices {
+		s.append(choice.Delta.Content)
+		s.append(choice.Text)
+	}
+	for _, candidate := range chunk.Candidates {
+		for _, part := range candidate.Content.Parts {
+			s.append(part.Text)
+		}
+	}
+	if len(chunk.Delta) > 0 {
+		if chunk.Delta[0] == '"' {
+			// Responses API: response.output_text.delta
+			var delta string
+			if strings.HasSuffix(chunk.Type, "output_text.delta") && common.Unmarshal(chunk.Delta, &delta) == nil {
+				s.append(delta)
+			}
+		} else {
+			// Claude: content_block_delta
+			var delta struct {
+				Text string `json:"text"`
+			}
+			if common.Unmarshal(chunk.Delta, &delta) == nil {
+				s.append(delta.Text)
+			}
+		}
+	}
+}
+
+func (s *streamTextAssembler) append(text string) {
+	if text == "" || s.truncated {
+		return
+	}
+	if s.text.Len()+len(text) > s.limit {
+		s.truncated = true
+		return
+	}
+	s.text.WriteString(text)
+}
+
+// payloadRedactor 按字段名与正则脱敏，配置不变时复用已编译的正则
+type payloadRedactor struct {
+	configKey string
+	fields    map[string]struct{}
+	// 内容不是合法 JSON（如已截断）时按 "字段": "值" 的形式匹配
+	fieldPattern *regexp.Regexp
+	patterns     []*regexp.Regexp
+}
+
+var (
+	payloadRedactorCache *payloadRedactor
+	payloadRedactorLock  sync.Mutex
+)
+
+func getPayloadRedactor(captureSetting *operation_setting.PayloadCaptureSetting) *payloadRedactor {
+	configKey := strings.Join(captureSetting.RedactFields, "\x00") + "\x01" + strings.Join(captureSetting.RedactPatterns, "\x00")
+	payloadRedactorLock.Lock()
+	defer payloadRedactorLock.Unlock()
+	if payloadRedactorCache != nil && payloadRedactorCache.configKey == configKey {
+		return payloadRedactorCache
+	}
+	redactor := &payloadRedactor{
+		configKey: configKey,
+		fields:    make(map[string]struct{}),
+	}
+	quoted := make([]string, 0, len(captureSetting.RedactFields))
+	for _, field := range captureSetting.RedactFields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		redactor.fields[field] = struct{}{}
+		quoted = append(quoted, regexp.QuoteMeta(field))
+	}
+	if len(quoted) > 0 {
+		redactor.fieldPattern = regexp.MustCompile(`(?i)"(` + strings.Join(quoted, "|") + `)"\s*:\s*"(?:[^"\\]|\\.)*"?`)
+	}
+	for _, pattern := range captureSetting.RedactPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			common.SysError(fmt.Sprintf("invalid payload capture redact pattern %q: %s", pattern, err.Error()))
+			continue
+		}
+		redactor.patterns = append(redactor.patterns, re)
+	}
+	payloadRedactorCache = redactor
+	return redactor
+}
+
+// redact 脱敏请求或响应体，SSE 响应逐行处理
+func (r *payloadRedactor) redact(body []byte, isSSE bool) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if !isSSE {
+		return r.redactText(r.redactJSON(body))
+	}
+	lines := strings.Split(string(body), "\n")
+	for i, line := range lines {
+		if payload, ok := strings.CutPrefix(line, "data:"); ok {
+			lines[i] = "data: " + r.redactJSON([]byte(strings.TrimSpace(payload)))
+		}
+	}
+	return r.redactText(strings.Join(lines, "\n"))
+}
+
+func (r *payloadRedactor) redactJSON(body []byte) string {
+	if len(r.fields) == 0 {
+		return string(body)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err == nil && !decoder.More() {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(r.redactValue(value)); err == nil {
+			return strings.TrimSuffix(buf.String(), "\n")
+		}
+	}
+	return r.fieldPattern.ReplaceAllString(string(body), `"$1": "`+redactedPlaceholder+`"`)
+}
+
+func (r *payloadRedactor) redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if _, ok := r.fields[strings.ToLower(key)]; ok {
+				v[key] = redactedPlaceholder
+			} else {
+				v[key] = r.redactValue(item)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = r.redactValue(item)
+		}
+	}
+	return value
+}
+
+func (r *payloadRedactor) redactText(text string) string {
+	for _, re := range r.patterns {
+		text = re.ReplaceAllString(text, redactedPlaceholder)
+	}
+	return text
+}
+
+// StartPayloadCaptureCleanupWorker 定期删除超过保留天数的抓取记录
+func StartPayloadCaptureCleanupWorker() {
+	for {
+		time.Sleep(time.Hour)
+		retentionDays := operation_setting.GetPayloadCaptureSetting().RetentionDays
+		if retentionDays <= 0 {
+			continue
+		}
+		count, err := model.DeletePayloadCapturesBefore(time.Now().AddDate(0, 0, -retentionDays).Unix())
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to delete expired payload captures: %s", err.Error()))
+			continue
+		}
+		if count > 0 {
+			common.SysLog(fmt.Sprintf("deleted %d expired payload captures", count))
+		}
+	}
+}
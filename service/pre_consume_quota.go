@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/logger"
+	"one-api/metrics"
 	"one-api/model"
 	relaycommon "one-api/relay/common"
 	"one-api/types"
@@ -15,6 +16,7 @@ import (
 
 func ReturnPreConsumedQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo) {
 	if relayInfo.FinalPreConsumedQuota != 0 {
+		metrics.QuotaRefunded.WithLabelValues(metrics.RefundPreConsume).Add(float64(relayInfo.FinalPreConsumedQuota))
 		logger.LogInfo(c, fmt.Sprintf("用户 %d 请求失败, 返还预扣费额度 %s", relayInfo.UserId, logger.FormatQuota(relayInfo.FinalPreConsumedQuota)))
 		gopool.Go(func() {
 			relayInfoCopy := *relayInfo
@@ -30,10 +32,15 @@ func ReturnPreConsumedQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo) {
 // PreConsumeQuota checks if the user has enough quota to pre-consume.
 // It returns the pre-consumed quota if successful, or an error if not.
 func PreConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommon.RelayInfo) *types.NewAPIError {
+	if relayInfo.OrgId != 0 {
+		return preConsumeOrgQuota(c, preConsumedQuota, relayInfo)
+	}
 	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
 	if err != nil {
 		return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
 	}
+	// 计划额度优先于预付费余额使用，一并计入可用额度
+	userQuota += GetUserPlanQuota(relayInfo.UserId, relayInfo.OriginModelName)
 	if userQuota <= 0 {
 		return types.NewErrorWithStatusCode(fmt.Errorf("用户额度不足, 剩余额度: %s", logger.FormatQuota(userQuota)), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 	}
@@ -44,7 +51,8 @@ func PreConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 	trustQuota := common.GetTrustQuota()
 
 	relayInfo.UserQuota = userQuota
-	if userQuota > trustQuota {
+	// 设置了周期预算的令牌需要逐次预扣以校验预算，不走信任逻辑
+	if userQuota > trustQuota && relayInfo.TokenBudgetPeriod == "" {
 		// 用户额度充足，判断令牌额度是否充足
 		if !relayInfo.TokenUnlimited {
 			// 非无限令牌，判断令牌额度是否充足
@@ -67,12 +75,39 @@ func PreConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 		if err != nil {
 			return types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 		}
-		err = model.DecreaseUserQuota(relayInfo.UserId, preConsumedQuota)
+		err = DecreaseUserQuotaWithPlan(relayInfo.UserId, relayInfo.OriginModelName, preConsumedQuota, relayLedgerRef(relayInfo, model.LedgerReasonConsume))
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
 		}
+		metrics.QuotaPreConsumed.Add(float64(preConsumedQuota))
 		logger.LogInfo(c, fmt.Sprintf("用户 %d 预扣费 %s, 预扣费后剩余额度: %s", relayInfo.UserId, logger.FormatQuota(preConsumedQuota), logger.FormatQuota(userQuota-preConsumedQuota)))
 	}
 	relayInfo.FinalPreConsumedQuota = preConsumedQuota
 	return nil
 }
+
+// preConsumeOrgQuota 组织令牌从组织额度池预扣费，不走信任逻辑，每次都预扣以校验成员消费上限
+func preConsumeOrgQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommon.RelayInfo) *types.NewAPIError {
+	orgQuota, err := model.GetOrgAvailableQuota(relayInfo.OrgId, relayInfo.UserId)
+	if err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+	}
+	if orgQuota <= 0 || orgQuota < preConsumedQuota {
+		return types.NewErrorWithStatusCode(fmt.Errorf("组织额度不足, 剩余可用额度: %s, 需要预扣费额度: %s", logger.FormatQuota(orgQuota), logger.FormatQuota(preConsumedQuota)), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+	}
+	relayInfo.UserQuota = orgQuota
+	if preConsumedQuota > 0 {
+		err := PreConsumeTokenQuota(relayInfo, preConsumedQuota)
+		if err != nil {
+			return types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
+		err = model.ConsumeOrganizationQuota(relayInfo.OrgId, relayInfo.UserId, preConsumedQuota)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+		}
+		metrics.QuotaPreConsumed.Add(float64(preConsumedQuota))
+		logger.LogInfo(c, fmt.Sprintf("用户 %d 使用组织 %d 令牌预扣费 %s, 预扣费后组织可用额度: %s", relayInfo.UserId, relayInfo.OrgId, logger.FormatQuota(preConsumedQuota), logger.FormatQuota(orgQuota-preConsumedQuota)))
+	}
+	relayInfo.FinalPreConsumedQuota = preConsumedQuota
+	return nil
+}
@@ -3,7 +3,6 @@ package service
 import (
 	"errors"
 	"fmt"
-	"log"
 	"math"
 	"one-api/common"
 	"one-api/constant"
@@ -110,7 +109,7 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	autoGroup, exists := ctx.Get("auto_group")
 	if exists {
 		groupRatio = ratio_setting.GetGroupRatio(autoGroup.(string))
-		log.Printf("final group ratio: %f", groupRatio)
+		logger.LogDebug(ctx, fmt.Sprintf("final group ratio: %f", groupRatio))
 		relayInfo.UsingGroup = autoGroup.(string)
 	}
 
@@ -153,8 +152,16 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	return nil
 }
 
+// recordChannelKeyTokens 多 Key 渠道记录本次请求所用 key 消耗的 token 数
+func recordChannelKeyTokens(relayInfo *relaycommon.RelayInfo, tokens int) {
+	if relayInfo.ChannelIsMultiKey {
+		model.RecordChannelKeyTokens(relayInfo.ChannelId, relayInfo.ChannelMultiKeyIndex, tokens)
+	}
+}
+
 func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelName string,
 	usage *dto.RealtimeUsage, extraContent string) {
+	RecordUsageTokens(ctx, relayInfo, usage.TotalTokens)
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.InputTokenDetails.TextTokens
@@ -235,6 +242,7 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 }
 
 func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage) {
+	RecordUsageTokens(ctx, relayInfo, usage.PromptTokens+usage.CompletionTokens)
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
@@ -361,6 +369,7 @@ func CalcOpenRouterCacheCreateTokens(usage dto.Usage, priceData types.PriceData)
 }
 
 func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent string) {
+	RecordUsageTokens(ctx, relayInfo, usage.PromptTokens+usage.CompletionTokens)
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.PromptTokensDetails.TextTokens
@@ -480,19 +489,47 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	if !relayInfo.TokenUnlimited && token.RemainQuota < quota {
 		return fmt.Errorf("token quota is not enough, token remain quota: %s, need quota: %s", logger.FormatQuota(token.RemainQuota), logger.FormatQuota(quota))
 	}
+	// 周期预算对无限额度令牌同样生效
+	if token.HasBudget() {
+		now := time.Now()
+		if budgetRemain := token.GetBudgetRemain(now); budgetRemain <= 0 || budgetRemain < quota {
+			_, resetAt := model.TokenBudgetPeriodBounds(token.BudgetPeriod, token.BudgetTimezone, now)
+			return fmt.Errorf("token budget for the current period is not enough, budget remain: %s, need quota: %s, resets at %s",
+				logger.FormatQuota(budgetRemain), logger.FormatQuota(quota), time.Unix(resetAt, 0).Format(time.RFC3339))
+		}
+	}
 	err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota)
 	if err != nil {
 		return err
 	}
+	if relayInfo.TokenBudgetPeriod != "" {
+		err = model.IncreaseTokenBudgetUsed(relayInfo.TokenId, relayInfo.TokenKey, relayInfo.TokenBudgetPeriod, relayInfo.TokenBudgetTimezone, quota)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// relayLedgerRef 生成中转请求的额度流水关联信息，以请求 ID 关联消费日志
+func relayLedgerRef(relayInfo *relaycommon.RelayInfo, reason string) model.LedgerRef {
+	ref := model.LedgerRef{Reason: reason, TokenId: relayInfo.TokenId}
+	if relayInfo.RequestId != "" {
+		ref.RefType = model.LedgerRefRequest
+		ref.RefId = relayInfo.RequestId
+	}
+	return ref
+}
+
 func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool) (err error) {
 
-	if quota > 0 {
-		err = model.DecreaseUserQuota(relayInfo.UserId, quota)
+	if relayInfo.OrgId != 0 {
+		// 组织令牌从组织额度池扣费，不动用成员个人余额
+		err = model.ConsumeOrganizationQuota(relayInfo.OrgId, relayInfo.UserId, quota)
+	} else if quota > 0 {
+		err = DecreaseUserQuotaWithPlan(relayInfo.UserId, relayInfo.OriginModelName, quota, relayLedgerRef(relayInfo, model.LedgerReasonConsume))
 	} else {
-		err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false)
+		err = IncreaseUserQuotaWithPlan(relayInfo.UserId, relayInfo.OriginModelName, -quota, relayLedgerRef(relayInfo, model.LedgerReasonRefund))
 	}
 	if err != nil {
 		return err
@@ -507,9 +544,15 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		if err != nil {
 			return err
 		}
+		if relayInfo.TokenBudgetPeriod != "" {
+			err = model.IncreaseTokenBudgetUsed(relayInfo.TokenId, relayInfo.TokenKey, relayInfo.TokenBudgetPeriod, relayInfo.TokenBudgetTimezone, quota)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
-	if sendEmail {
+	if sendEmail && relayInfo.OrgId == 0 {
 		if (quota + preConsumedQuota) != 0 {
 			checkAndSendQuotaNotify(relayInfo, quota, preConsumedQuota)
 		}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StatementCurrencyUSD   = "USD"
+	StatementCurrencyCNY   = "CNY"
+	StatementCurrencyQuota = "QUOTA"
+)
+
+// StatementCurrency 解析下载对账单时使用的货币，未指定时沿用生成对账单时的显示设置
+func StatementCurrency(statement *model.Statement, currency string) string {
+	switch strings.ToUpper(currency) {
+	case StatementCurrencyUSD:
+		return StatementCurrencyUSD
+	case StatementCurrencyCNY:
+		return StatementCurrencyCNY
+	case StatementCurrencyQuota:
+		return StatementCurrencyQuota
+	}
+	if statement.DisplayInCurrency {
+		return StatementCurrencyUSD
+	}
+	return StatementCurrencyQuota
+}
+
+// formatStatementAmount 按对账单生成时的 QuotaPerUnit 与汇率换算额度
+func formatStatementAmount(statement *model.Statement, currency string, quota int64) string {
+	if currency == StatementCurrencyQuota || statement.QuotaPerUnit <= 0 {
+		return strconv.FormatInt(quota, 10)
+	}
+	amount := float64(quota) / statement.QuotaPerUnit
+	if currency == StatementCurrencyCNY {
+		amount *= statement.ExchangeRate
+	}
+	return strconv.FormatFloat(amount, 'f', 6, 64)
+}
+
+func formatStatementTime(timestamp int64) string {
+	return time.Unix(timestamp, 0).Format("2006-01-02 15:04:05")
+}
+
+// StatementFileName 下载文件名，例如 statement-12-2026-09.csv
+func StatementFileName(statement *model.Statement, ext string) string {
+	return fmt.Sprintf("statement-%d-%s.%s", statement.UserId, time.Unix(statement.PeriodStart, 0).Format("2006-01"), ext)
+}
+
+func RenderStatementCSV(statement *model.Statement, currency string) ([]byte, error) {
+	currency = StatementCurrency(statement, currency)
+	amount := func(quota int64) string {
+		return formatStatementAmount(statement, currency, quota)
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	rows := [][]string{
+		{"Statement", strconv.Itoa(statement.Id)},
+		{"User", fmt.Sprintf("%s (#%d)", statement.Username, statement.UserId)},
+		{"Period", formatStatementTime(statement.PeriodStart), formatStatementTime(statement.PeriodEnd)},
+		{"Currency", currency},
+		{},
+		{"Model", "Group", "Requests", "Prompt Tokens", "Completion Tokens", "Total Tokens", "Quota", "Amount"},
+	}
+	for _, item := range statement.GetLineItems() {
+		rows = append(rows, []string{
+			item.ModelName, item.Group,
+			strconv.FormatInt(item.RequestCount, 10),
+			strconv.FormatInt(item.PromptTokens, 10),
+			strconv.FormatInt(item.CompletionTokens, 10),
+			strconv.FormatInt(item.TotalTokens, 10),
+			strconv.FormatInt(item.Quota, 10),
+			amount(item.Quota),
+		})
+	}
+	rows = append(rows, []string{}, []string{"Top-up", "Completed At", "Paid", "Quota", "Amount"})
+	for _, topup := range statement.GetTopups() {
+		rows = append(rows, []string{
+			topup.TradeNo,
+			formatStatementTime(topup.CompleteTime),
+			strconv.FormatFloat(topup.Money, 'f', 2, 64),
+			strconv.FormatInt(topup.Quota, 10),
+			amount(topup.Quota),
+		})
+	}
+	rows = append(rows, []string{}, []string{"Adjustment", "Quota", "Amount"})
+	for _, credit := range statement.GetCredits() {
+		rows = append(rows, []string{credit.Reason, strconv.FormatInt(credit.Quota, 10), amount(credit.Quota)})
+	}
+	rows = append(rows,
+		[]string{},
+		[]string{"Summary", "Quota", "Amount"},
+		[]string{"Usage", strconv.FormatInt(statement.UsageQuota, 10), amount(statement.UsageQuota)},
+		[]string{"Refunds", strconv.FormatInt(statement.RefundQuota, 10), amount(statement.RefundQuota)},
+		[]string{"Net Usage", strconv.FormatInt(statement.UsageQuota-statement.RefundQuota, 10), amount(statement.UsageQuota - statement.RefundQuota)},
+		[]string{"Top-ups", strconv.FormatInt(statement.TopupQuota, 10), amount(statement.TopupQuota)},
+	)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var statementHTMLTemplate = template.Must(template.New("statement").Funcs(template.FuncMap{
+	"time": formatStatementTime,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Statement #{{.Statement.Id}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", sans-serif; margin: 32px; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 24px; }
+th, td { border: 1px solid #ccc; padding: 6px 8px; text-align: left; }
+td.num, th.num { text-align: right; }
+h1 { margin-bottom: 4px; }
+.meta { color: #666; margin-bottom: 24px; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>Statement #{{.Statement.Id}}</h1>
+<div class="meta">
+{{.Statement.Username}} (#{{.Statement.UserId}}) · {{time .Statement.PeriodStart}} – {{time .Statement.PeriodEnd}} · {{.Currency}}
+</div>
+<h2>Usage</h2>
+<table>
+<tr><th>Model</th><th>Group</th><th class="num">Requests</th><th class="num">Prompt Tokens</th><th class="num">Completion Tokens</th><th class="num">Total Tokens</th><th class="num">Amount</th></tr>
+{{range .LineItems}}<tr><td>{{.ModelName}}</td><td>{{.Group}}</td><td class="num">{{.RequestCount}}</td><td class="num">{{.PromptTokens}}</td><td class="num">{{.CompletionTokens}}</td><td class="num">{{.TotalTokens}}</td><td class="num">{{.Amount}}</td></tr>
+{{end}}</table>
+{{if .Topups}}<h2>Top-ups</h2>
+<table>
+<tr><th>Trade No</th><th>Completed At</th><th class="num">Paid</th><th class="num">Amount</th></tr>
+{{range .Topups}}<tr><td>{{.TradeNo}}</td><td>{{time .CompleteTime}}</td><td class="num">{{printf "%.2f" .Money}}</td><td class="num">{{.Amount}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Credits}}<h2>Adjustments</h2>
+<table>
+<tr><th>Reason</th><th class="num">Amount</th></tr>
+{{range .Credits}}<tr><td>{{.Reason}}</td><td class="num">{{.Amount}}</td></tr>
+{{end}}</table>
+{{end}}<h2>Summary</h2>
+<table>
+<tr><td>Usage</td><td class="num">{{.Usage}}</td></tr>
+<tr><td>Refunds</td><td class="num">{{.Refunds}}</td></tr>
+<tr><td>Net Usage</td><td class="num">{{.NetUsage}}</td></tr>
+<tr><td>Top-ups</td><td class="num">{{.TopupTotal}}</td></tr>
+</table>
+<div class="meta">Generated at {{time .Statement.CreatedAt}}</div>
+</body>
+</html>
+`))
+
+func RenderStatementHTML(statement *model.Statement, currency string) ([]byte, error) {
+	currency = StatementCurrency(statement, currency)
+	amount := func(quota int64) string {
+		return formatStatementAmount(statement, currency, quota)
+	}
+	type lineItem struct {
+		model.StatementLineItem
+		Amount string
+	}
+	type topup struct {
+		model.StatementTopup
+		Amount string
+	}
+	type credit struct {
+		model.StatementCredit
+		Amount string
+	}
+	data := struct {
+		Statement  *model.Statement
+		Currency   string
+		LineItems  []lineItem
+		Topups     []topup
+		Credits    []credit
+		Usage      string
+		Refunds    string
+		NetUsage   string
+		TopupTotal string
+	}{
+		Statement:  statement,
+		Currency:   currency,
+		Usage:      amount(statement.UsageQuota),
+		Refunds:    amount(statement.RefundQuota),
+		NetUsage:   amount(statement.UsageQuota - statement.RefundQuota),
+		TopupTotal: amount(statement.TopupQuota),
+	}
+	for _, item := range statement.GetLineItems() {
+		data.LineItems = append(data.LineItems, lineItem{item, amount(item.Quota)})
+	}
+	for _, t := range statement.GetTopups() {
+		data.Topups = append(data.Topups, topup{t, amount(t.Quota)})
+	}
+	for _, c := range statement.GetCredits() {
+		data.Credits = append(data.Credits, credit{c, amount(c.Quota)})
+	}
+	var buf bytes.Buffer
+	if err := statementHTMLTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"time"
+)
+
+// 订阅计划额度：扣费时优先使用计划额度，不足部分扣除预付费余额；退还时优先退回计划额度
+
+// GetUserPlanQuota 返回用户可用于该模型的计划额度，未启用订阅或查询失败时返回 0
+func GetUserPlanQuota(userId int, modelName string) int {
+	if !operation_setting.GetSubscriptionSetting().Enabled {
+		return 0
+	}
+	quota, err := model.GetSubscriptionQuota(userId, modelName)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to get subscription quota for user %d: %s", userId, err.Error()))
+		return 0
+	}
+	return quota
+}
+
+// DecreaseUserQuotaWithPlan 优先从计划额度扣除，剩余部分扣除预付费余额
+func DecreaseUserQuotaWithPlan(userId int, modelName string, quota int, ref model.LedgerRef) error {
+	planUsed := 0
+	if operation_setting.GetSubscriptionSetting().Enabled {
+		// 计划额度扣除失败时直接返回错误，不能转而扣除预付费余额
+		used, err := model.ConsumeSubscriptionQuota(userId, modelName, quota)
+		if err != nil {
+			return fmt.Errorf("failed to consume subscription quota for user %d: %w", userId, err)
+		}
+		planUsed = used
+	}
+	if quota-planUsed > 0 {
+		return model.DecreaseUserQuota(userId, quota-planUsed, ref)
+	}
+	return nil
+}
+
+// IncreaseUserQuotaWithPlan 退还额度时优先退回本周期已使用的计划额度，剩余部分退回预付费余额
+func IncreaseUserQuotaWithPlan(userId int, modelName string, quota int, ref model.LedgerRef) error {
+	planRefunded := 0
+	if operation_setting.GetSubscriptionSetting().Enabled {
+		refunded, err := model.RefundSubscriptionQuota(userId, modelName, quota)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to refund subscription quota for user %d: %s", userId, err.Error()))
+		}
+		planRefunded = refunded
+	}
+	if quota-planRefunded > 0 {
+		return model.IncreaseUserQuota(userId, quota-planRefunded, false, ref)
+	}
+	return nil
+}
+
+// StartSubscriptionWorker 定期重置计划额度周期，并使到期订阅失效、恢复用户分组
+func StartSubscriptionWorker() {
+	for {
+		if operation_setting.GetSubscriptionSetting().Enabled {
+			model.ProcessSubscriptions()
+		}
+		interval := operation_setting.GetSubscriptionSetting().CheckIntervalSeconds
+		if interval <= 0 {
+			interval = 60
+		}
+		time.Sleep(time.Duration(interval) * time.Second)
+	}
+}
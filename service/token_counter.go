@@ -8,11 +8,11 @@ import (
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
-	"log"
 	"math"
 	"one-api/common"
 	"one-api/constant"
 	"one-api/dto"
+	"one-api/logger"
 	relaycommon "one-api/relay/common"
 	"one-api/types"
 	"strings"
@@ -78,7 +78,7 @@ func getTokenNum(tokenEncoder tokenizer.Codec, text string) int {
 	return tkm
 }
 
-func getImageToken(fileMeta *types.FileMeta, model string, stream bool) (int, error) {
+func getImageToken(c *gin.Context, fileMeta *types.FileMeta, model string, stream bool) (int, error) {
 	if fileMeta == nil {
 		return 0, fmt.Errorf("image_url_is_nil")
 	}
@@ -185,7 +185,7 @@ func getImageToken(fileMeta *types.FileMeta, model string, stream bool) (int, er
 
 	width := config.Width
 	height := config.Height
-	log.Printf("format: %s, width: %d, height: %d", format, width, height)
+	logger.LogDebug(c, fmt.Sprintf("format: %s, width: %d, height: %d", format, width, height))
 
 	if isPatchBased {
 		// 32x32 patch-based calculation with 1536 cap and model multiplier
@@ -246,7 +246,7 @@ func getImageToken(fileMeta *types.FileMeta, model string, stream bool) (int, er
 	tiles := tilesW * tilesH
 
 	if common.DebugEnabled {
-		log.Printf("scaled to: %dx%d, tiles: %d", finalW, finalH, tiles)
+		logger.LogDebug(c, fmt.Sprintf("scaled to: %dx%d, tiles: %d", finalW, finalH, tiles))
 	}
 
 	return tiles*tileTokens + baseTokens, nil
@@ -339,7 +339,7 @@ func CountRequestToken(c *gin.Context, meta *types.TokenCountMeta, info *relayco
 			if info.RelayFormat == types.RelayFormatGemini {
 				tkm += 256
 			} else {
-				token, err := getImageToken(file, model, info.IsStream)
+				token, err := getImageToken(c, file, model, info.IsStream)
 				if err != nil {
 					return 0, fmt.Errorf("error counting image token, media index[%d], original data[%s], err: %v", i, file.OriginData, err)
 				}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"one-api/common/limiter"
+	"one-api/constant"
+	relaycommon "one-api/relay/common"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenRateLimitWindow 令牌 RPM/TPM 限制的统计窗口
+const TokenRateLimitWindow = time.Minute
+
+func TokenRPMLimitKey(tokenId int) string {
+	return fmt.Sprintf("tokenRateLimit:rpm:%d", tokenId)
+}
+
+func TokenTPMLimitKey(tokenId int) string {
+	return fmt.Sprintf("tokenRateLimit:tpm:%d", tokenId)
+}
+
+func TokenConcurrencyKey(tokenId int) string {
+	return fmt.Sprintf("tokenRateLimit:concurrency:%d", tokenId)
+}
+
+// RecordUsageTokens 请求结束后按实际用量累计令牌 TPM 与多 Key 渠道的 key 用量
+func RecordUsageTokens(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, tokens int) {
+	recordChannelKeyTokens(relayInfo, tokens)
+	if tokens <= 0 || common.GetContextKeyInt(ctx, constant.ContextKeyTokenRateLimitTPM) <= 0 {
+		return
+	}
+	_, err := limiter.WindowIncr(context.Background(), TokenTPMLimitKey(relayInfo.TokenId), int64(tokens), 0, TokenRateLimitWindow)
+	if err != nil {
+		common.SysError("failed to record token tpm usage: " + err.Error())
+	}
+}
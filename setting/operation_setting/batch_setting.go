@@ -0,0 +1,31 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type BatchSetting struct {
+	// DiscountRatio 批处理请求的计费倍率，会与分组倍率相乘，1 表示不打折
+	DiscountRatio float64 `json:"discount_ratio"`
+	// WorkerConcurrency 所有批处理任务共享的并发请求数上限
+	WorkerConcurrency int `json:"worker_concurrency"`
+	// MaxRequestsPerBatch 单个输入文件允许的最大请求行数
+	MaxRequestsPerBatch int `json:"max_requests_per_batch"`
+	// PollIntervalSeconds 批处理调度轮询间隔
+	PollIntervalSeconds int `json:"poll_interval_seconds"`
+}
+
+// 默认配置
+var batchSetting = BatchSetting{
+	DiscountRatio:       0.5,
+	WorkerConcurrency:   4,
+	MaxRequestsPerBatch: 50000,
+	PollIntervalSeconds: 10,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("batch_setting", &batchSetting)
+}
+
+func GetBatchSetting() *BatchSetting {
+	return &batchSetting
+}
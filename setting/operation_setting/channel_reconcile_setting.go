@@ -0,0 +1,28 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ChannelReconcileSetting struct {
+	// Enabled 是否在自动更新余额时记录余额快照并与网关记录的用量对账
+	Enabled bool `json:"enabled"`
+	// DriftThresholdPercent 上游扣减与网关用量的偏差超过该百分比时通知管理员
+	DriftThresholdPercent float64 `json:"drift_threshold_percent"`
+	// MinDriftUsd 偏差金额（美元）低于该值时不通知，避免小额用量下百分比失真
+	MinDriftUsd float64 `json:"min_drift_usd"`
+}
+
+// 默认配置
+var channelReconcileSetting = ChannelReconcileSetting{
+	Enabled:               false,
+	DriftThresholdPercent: 20,
+	MinDriftUsd:           1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_reconcile_setting", &channelReconcileSetting)
+}
+
+func GetChannelReconcileSetting() *ChannelReconcileSetting {
+	return &channelReconcileSetting
+}
@@ -0,0 +1,53 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	// ChannelSelectionModeWeight 按优先级与静态权重随机选择渠道
+	ChannelSelectionModeWeight = "weight"
+	// ChannelSelectionModeAdaptive 在静态权重的基础上按近期首字延迟、总耗时与错误率动态调整
+	ChannelSelectionModeAdaptive = "adaptive"
+)
+
+type ChannelSelectionSetting struct {
+	// DefaultMode 未在 GroupModes 中配置的分组使用的选择模式
+	DefaultMode string `json:"default_mode"`
+	// GroupModes 分组 -> 选择模式
+	GroupModes map[string]string `json:"group_modes"`
+	// WindowSeconds 统计滑动窗口长度
+	WindowSeconds int `json:"window_seconds"`
+	// MinRequests 窗口内请求数少于该值时不调整权重
+	MinRequests int `json:"min_requests"`
+	// ErrorRatePenalty 错误率惩罚指数，权重乘以 (1-错误率)^ErrorRatePenalty
+	ErrorRatePenalty float64 `json:"error_rate_penalty"`
+	// MinWeightFactor 动态调整后的权重系数下限，保证慢渠道仍有少量流量用于恢复探测
+	MinWeightFactor float64 `json:"min_weight_factor"`
+}
+
+// 默认配置
+var channelSelectionSetting = ChannelSelectionSetting{
+	DefaultMode:      ChannelSelectionModeWeight,
+	GroupModes:       map[string]string{},
+	WindowSeconds:    300,
+	MinRequests:      5,
+	ErrorRatePenalty: 2,
+	MinWeightFactor:  0.05,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_selection_setting", &channelSelectionSetting)
+}
+
+func GetChannelSelectionSetting() *ChannelSelectionSetting {
+	return &channelSelectionSetting
+}
+
+// IsAdaptiveChannelSelection 判断分组是否启用动态权重选择
+func IsAdaptiveChannelSelection(group string) bool {
+	mode, ok := channelSelectionSetting.GroupModes[group]
+	if !ok {
+		mode = channelSelectionSetting.DefaultMode
+	}
+	return mode == ChannelSelectionModeAdaptive
+}
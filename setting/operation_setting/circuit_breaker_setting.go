@@ -0,0 +1,40 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type CircuitBreakerSetting struct {
+	// Enabled 是否启用渠道与多 Key 熔断
+	Enabled bool `json:"enabled"`
+	// ConsecutiveFailures 连续失败达到该次数时熔断，0 表示不按连续失败熔断
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// FailureRatio 统计窗口内失败率达到该值时熔断，0 表示不按失败率熔断
+	FailureRatio float64 `json:"failure_ratio"`
+	// RatioWindowSeconds 失败率统计窗口长度
+	RatioWindowSeconds int `json:"ratio_window_seconds"`
+	// RatioMinRequests 窗口内请求数达到该值后才按失败率判断
+	RatioMinRequests int `json:"ratio_min_requests"`
+	// CooldownSeconds 熔断后的冷却时间，冷却结束进入半开状态
+	CooldownSeconds int `json:"cooldown_seconds"`
+	// HalfOpenProbes 半开状态下放行的探测请求数，全部成功后恢复
+	HalfOpenProbes int `json:"half_open_probes"`
+}
+
+// 默认配置
+var circuitBreakerSetting = CircuitBreakerSetting{
+	Enabled:             false,
+	ConsecutiveFailures: 5,
+	FailureRatio:        0.5,
+	RatioWindowSeconds:  60,
+	RatioMinRequests:    20,
+	CooldownSeconds:     60,
+	HalfOpenProbes:      3,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("circuit_breaker_setting", &circuitBreakerSetting)
+}
+
+func GetCircuitBreakerSetting() *CircuitBreakerSetting {
+	return &circuitBreakerSetting
+}
@@ -0,0 +1,36 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type CreditLotSetting struct {
+	// RegisterValidDays 注册赠送额度的有效天数，0 表示永不过期
+	RegisterValidDays int `json:"register_valid_days"`
+	// InviteValidDays 邀请奖励额度的有效天数，0 表示永不过期
+	InviteValidDays int `json:"invite_valid_days"`
+	// CheckIntervalSeconds 调度器清理过期额度批次的间隔
+	CheckIntervalSeconds int `json:"check_interval_seconds"`
+}
+
+// 默认配置
+var creditLotSetting = CreditLotSetting{
+	RegisterValidDays:    0,
+	InviteValidDays:      0,
+	CheckIntervalSeconds: 300,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("credit_lot_setting", &creditLotSetting)
+}
+
+func GetCreditLotSetting() *CreditLotSetting {
+	return &creditLotSetting
+}
+
+// CreditExpiresAt 根据有效天数计算额度批次的过期时间，days 不大于 0 时返回 0（永不过期）
+func CreditExpiresAt(now int64, days int) int64 {
+	if days <= 0 {
+		return 0
+	}
+	return now + int64(days)*86400
+}
@@ -0,0 +1,25 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type FileSetting struct {
+	// UpstreamModel 用于为 /v1/files 选择上游渠道的模型名，渠道需在模型列表中包含该名称
+	UpstreamModel string `json:"upstream_model"`
+	// QuotaPerMB 每 MB 上传文件扣除的额度，0 表示上传免费
+	QuotaPerMB int `json:"quota_per_mb"`
+}
+
+// 默认配置
+var fileSetting = FileSetting{
+	UpstreamModel: "files",
+	QuotaPerMB:    0,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("file_setting", &fileSetting)
+}
+
+func GetFileSetting() *FileSetting {
+	return &fileSetting
+}
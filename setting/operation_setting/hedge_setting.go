@@ -0,0 +1,33 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type HedgeSetting struct {
+	// Enabled 是否启用对冲请求
+	Enabled bool `json:"enabled"`
+	// GroupDelayMs 分组 -> 首个请求未返回首字节时发起第二个请求前等待的毫秒数，未配置的分组不启用
+	GroupDelayMs map[string]int `json:"group_delay_ms"`
+}
+
+// 默认配置
+var hedgeSetting = HedgeSetting{
+	Enabled:      false,
+	GroupDelayMs: map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("hedge_setting", &hedgeSetting)
+}
+
+func GetHedgeSetting() *HedgeSetting {
+	return &hedgeSetting
+}
+
+// GetHedgeDelayMs 返回分组的对冲等待时间，未启用时返回 0
+func GetHedgeDelayMs(group string) int {
+	if !hedgeSetting.Enabled {
+		return 0
+	}
+	return hedgeSetting.GroupDelayMs[group]
+}
@@ -0,0 +1,34 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type IdempotencySetting struct {
+	// Enabled 是否支持 Idempotency-Key 请求头
+	Enabled bool `json:"enabled"`
+	// TTLSeconds 成功响应的保存时长，期间相同令牌与幂等键的重试直接返回保存的结果
+	TTLSeconds int `json:"ttl_seconds"`
+	// LockTimeoutSeconds 首个请求处理中的占用时长，防止进程异常退出后幂等键永久不可用
+	LockTimeoutSeconds int `json:"lock_timeout_seconds"`
+	// WaitTimeoutSeconds 并发的重复请求等待首个请求完成的最长时间，超时返回 409
+	WaitTimeoutSeconds int `json:"wait_timeout_seconds"`
+	// MaxBodyBytes 可保存的最大响应体，超过则只保留计费结果，重试返回 409
+	MaxBodyBytes int `json:"max_body_bytes"`
+}
+
+// 默认配置
+var idempotencySetting = IdempotencySetting{
+	Enabled:            true,
+	TTLSeconds:         86400,
+	LockTimeoutSeconds: 600,
+	WaitTimeoutSeconds: 60,
+	MaxBodyBytes:       4 << 20,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("idempotency_setting", &idempotencySetting)
+}
+
+func GetIdempotencySetting() *IdempotencySetting {
+	return &idempotencySetting
+}
@@ -0,0 +1,83 @@
+package operation_setting
+
+import (
+	"encoding/json"
+	"one-api/setting/config"
+	"sync/atomic"
+)
+
+type LogSetting struct {
+	// Level 默认日志级别：debug、info、warn、error
+	Level string `json:"level"`
+	// ModuleLevels 按模块覆盖日志级别，例如 {"distributor": "warn", "relay": "debug"}
+	ModuleLevels ModuleLevels `json:"module_levels"`
+}
+
+// ModuleLevels 每条日志都会读取，选项更新时解析为新的 map 整体替换，读取无需加锁，
+// 已从选项中删除的模块也会随之失效
+type ModuleLevels struct {
+	levels *atomic.Pointer[map[string]string]
+}
+
+func NewModuleLevels(levels map[string]string) ModuleLevels {
+	m := ModuleLevels{levels: &atomic.Pointer[map[string]string]{}}
+	m.levels.Store(&levels)
+	return m
+}
+
+func (m ModuleLevels) Get(module string) (string, bool) {
+	if m.levels == nil {
+		return "", false
+	}
+	levels := m.levels.Load()
+	if levels == nil {
+		return "", false
+	}
+	level, ok := (*levels)[module]
+	return level, ok
+}
+
+func (m ModuleLevels) MarshalJSON() ([]byte, error) {
+	levels := map[string]string{}
+	if m.levels != nil {
+		if current := m.levels.Load(); current != nil {
+			levels = *current
+		}
+	}
+	return json.Marshal(levels)
+}
+
+func (m *ModuleLevels) UnmarshalJSON(data []byte) error {
+	levels := make(map[string]string)
+	if err := json.Unmarshal(data, &levels); err != nil {
+		return err
+	}
+	if m.levels == nil {
+		m.levels = &atomic.Pointer[map[string]string]{}
+	}
+	m.levels.Store(&levels)
+	return nil
+}
+
+// 默认配置
+var logSetting = LogSetting{
+	Level:        "info",
+	ModuleLevels: NewModuleLevels(map[string]string{}),
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("log_setting", &logSetting)
+}
+
+func GetLogSetting() *LogSetting {
+	return &logSetting
+}
+
+// LevelFor 返回模块生效的日志级别，未单独配置时使用默认级别
+func (s *LogSetting) LevelFor(module string) string {
+	if level, ok := s.ModuleLevels.Get(module); ok && level != "" {
+		return level
+	}
+	return s.Level
+}
@@ -0,0 +1,41 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ModelFallbackSetting struct {
+	// Enabled 是否启用管理员配置的模型降级链，令牌上配置的降级链不受此开关影响
+	Enabled bool `json:"enabled"`
+	// Chains 全局降级链：模型 -> 按顺序尝试的备选模型
+	Chains map[string][]string `json:"chains"`
+	// GroupChains 分组降级链：分组 -> 模型 -> 备选模型，优先于全局配置
+	GroupChains map[string]map[string][]string `json:"group_chains"`
+}
+
+// 默认配置
+var modelFallbackSetting = ModelFallbackSetting{
+	Enabled:     false,
+	Chains:      map[string][]string{},
+	GroupChains: map[string]map[string][]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_fallback_setting", &modelFallbackSetting)
+}
+
+func GetModelFallbackSetting() *ModelFallbackSetting {
+	return &modelFallbackSetting
+}
+
+// GetGroupModelFallbacks 返回分组下模型的降级链，分组未配置时使用全局配置
+func GetGroupModelFallbacks(group string, model string) []string {
+	if !modelFallbackSetting.Enabled {
+		return nil
+	}
+	if chains, ok := modelFallbackSetting.GroupChains[group]; ok {
+		if fallbacks, ok := chains[model]; ok {
+			return fallbacks
+		}
+	}
+	return modelFallbackSetting.Chains[model]
+}
@@ -0,0 +1,75 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	PayloadCaptureScopeUser    = "user"
+	PayloadCaptureScopeToken   = "token"
+	PayloadCaptureScopeChannel = "channel"
+)
+
+// PayloadCaptureRule 对指定用户、令牌或渠道按采样率抓取请求与响应内容
+type PayloadCaptureRule struct {
+	// Scope 取值 user、token、channel
+	Scope string `json:"scope"`
+	Id    int    `json:"id"`
+	// SampleRate 采样率，0-1，1 表示全部抓取
+	SampleRate float64 `json:"sample_rate"`
+}
+
+type PayloadCaptureSetting struct {
+	// Enabled 总开关，关闭时所有规则均不生效
+	Enabled bool                 `json:"enabled"`
+	Rules   []PayloadCaptureRule `json:"rules"`
+	// MaxBodyBytes 每段内容保存的最大字节数，超出部分截断
+	MaxBodyBytes int `json:"max_body_bytes"`
+	// RedactFields 需要脱敏的 JSON 字段名，不区分大小写
+	RedactFields []string `json:"redact_fields"`
+	// RedactPatterns 需要脱敏的正则表达式，匹配到的内容替换为 [REDACTED]
+	RedactPatterns []string `json:"redact_patterns"`
+	// RetentionDays 保留天数，过期记录由后台任务删除
+	RetentionDays int `json:"retention_days"`
+}
+
+// 默认配置
+var payloadCaptureSetting = PayloadCaptureSetting{
+	Enabled:      false,
+	Rules:        []PayloadCaptureRule{},
+	MaxBodyBytes: 32 * 1024,
+	RedactFields: []string{
+		"api_key", "apikey", "authorization", "password", "secret", "access_token", "refresh_token",
+	},
+	RedactPatterns: []string{
+		`sk-[A-Za-z0-9_\-]{16,}`,
+	},
+	RetentionDays: 7,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("payload_capture_setting", &payloadCaptureSetting)
+}
+
+func GetPayloadCaptureSetting() *PayloadCaptureSetting {
+	return &payloadCaptureSetting
+}
+
+// SampleRateFor 返回匹配到的规则中最高的采样率，没有匹配的规则时返回 0
+func (s *PayloadCaptureSetting) SampleRateFor(userId, tokenId, channelId int) float64 {
+	rate := 0.0
+	for _, rule := range s.Rules {
+		matched := false
+		switch rule.Scope {
+		case PayloadCaptureScopeUser:
+			matched = rule.Id == userId
+		case PayloadCaptureScopeToken:
+			matched = rule.Id == tokenId
+		case PayloadCaptureScopeChannel:
+			matched = rule.Id == channelId
+		}
+		if matched && rule.SampleRate > rate {
+			rate = rule.SampleRate
+		}
+	}
+	return rate
+}
@@ -0,0 +1,221 @@
+package operation_setting
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/setting/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PriceTier 月度用量阶梯，用户当月累计 token 数达到 MinMonthlyTokens 后使用该档倍率
+type PriceTier struct {
+	Name             string  `json:"name"`
+	MinMonthlyTokens int64   `json:"min_monthly_tokens"`
+	Ratio            float64 `json:"ratio"`
+}
+
+// PriceTimeWindow 时段倍率，Start/End 为 HH:MM，End 小于 Start 时表示跨天；Weekdays 为空表示每天（0 表示周日）
+type PriceTimeWindow struct {
+	Name     string  `json:"name"`
+	Start    string  `json:"start"`
+	End      string  `json:"end"`
+	Weekdays []int   `json:"weekdays"`
+	Ratio    float64 `json:"ratio"`
+}
+
+// PriceRule 定价规则，按顺序匹配第一条适用的规则，阶梯倍率与时段倍率相乘后叠加到分组倍率上
+type PriceRule struct {
+	Name string `json:"name"`
+	// Models 适用模型，支持以 * 结尾的前缀匹配，为空表示全部模型
+	Models []string `json:"models"`
+	// Groups 适用分组，为空表示全部分组
+	Groups      []string          `json:"groups"`
+	Tiers       []PriceTier       `json:"tiers"`
+	TimeWindows []PriceTimeWindow `json:"time_windows"`
+}
+
+type PriceRuleSetting struct {
+	Enabled bool `json:"enabled"`
+	// Timezone 计算自然月与时段所用的时区，为空时使用服务器时区
+	Timezone string      `json:"timezone"`
+	Rules    []PriceRule `json:"rules"`
+}
+
+// 默认配置
+var priceRuleSetting = PriceRuleSetting{
+	Enabled: false,
+	Rules:   []PriceRule{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("price_rule_setting", &priceRuleSetting)
+}
+
+func GetPriceRuleSetting() *PriceRuleSetting {
+	return &priceRuleSetting
+}
+
+var priceRuleLocations sync.Map
+
+// GetPriceRuleLocation 返回定价规则所用时区
+func GetPriceRuleLocation() *time.Location {
+	timezone := priceRuleSetting.Timezone
+	if timezone == "" {
+		return time.Local
+	}
+	if loc, ok := priceRuleLocations.Load(timezone); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Local
+	}
+	priceRuleLocations.Store(timezone, loc)
+	return loc
+}
+
+// MatchPriceRule 返回适用于该模型与分组的第一条规则
+func MatchPriceRule(modelName string, group string) *PriceRule {
+	if !priceRuleSetting.Enabled {
+		return nil
+	}
+	for i := range priceRuleSetting.Rules {
+		rule := &priceRuleSetting.Rules[i]
+		if len(rule.Groups) > 0 && !containsString(rule.Groups, group) {
+			continue
+		}
+		if len(rule.Models) > 0 && !matchRuleModel(rule.Models, modelName) {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+// MatchTier 返回月度用量所处的最高档位
+func (rule *PriceRule) MatchTier(monthlyTokens int64) *PriceTier {
+	var matched *PriceTier
+	for i := range rule.Tiers {
+		tier := &rule.Tiers[i]
+		if monthlyTokens >= tier.MinMonthlyTokens && (matched == nil || tier.MinMonthlyTokens > matched.MinMonthlyTokens) {
+			matched = tier
+		}
+	}
+	return matched
+}
+
+// MatchTimeWindow 返回 now 所处的第一个时段
+func (rule *PriceRule) MatchTimeWindow(now time.Time) *PriceTimeWindow {
+	minutes := now.Hour()*60 + now.Minute()
+	for i := range rule.TimeWindows {
+		window := &rule.TimeWindows[i]
+		start, err1 := parseClock(window.Start)
+		end, err2 := parseClock(window.End)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		weekday := int(now.Weekday())
+		var inWindow bool
+		if start <= end {
+			inWindow = minutes >= start && minutes < end
+		} else {
+			// 跨天时段，凌晨部分属于前一天的时段
+			inWindow = minutes >= start || minutes < end
+			if minutes < end {
+				weekday = (weekday + 6) % 7
+			}
+		}
+		if !inWindow {
+			continue
+		}
+		if len(window.Weekdays) > 0 && !containsInt(window.Weekdays, weekday) {
+			continue
+		}
+		return window
+	}
+	return nil
+}
+
+// ValidatePriceRuleTimezone 校验定价规则时区
+func ValidatePriceRuleTimezone(timezone string) error {
+	if timezone == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("无效的时区: %s", timezone)
+	}
+	return nil
+}
+
+// ValidatePriceRules 校验定价规则 JSON
+func ValidatePriceRules(rulesJSON string) error {
+	var rules []PriceRule
+	if err := common.Unmarshal([]byte(rulesJSON), &rules); err != nil {
+		return fmt.Errorf("定价规则格式错误: %v", err)
+	}
+	for _, rule := range rules {
+		// 月度用量来自消费日志或数据看板，两者都未开启时阶梯永远不会生效
+		if len(rule.Tiers) > 0 && !common.LogConsumeEnabled && !common.DataExportEnabled {
+			return fmt.Errorf("定价规则 %s 配置了月度用量阶梯，需要开启消费日志或数据看板", rule.Name)
+		}
+		for _, tier := range rule.Tiers {
+			if tier.Ratio < 0 || tier.MinMonthlyTokens < 0 {
+				return fmt.Errorf("定价规则 %s 的阶梯 %s 配置无效", rule.Name, tier.Name)
+			}
+		}
+		for _, window := range rule.TimeWindows {
+			if _, err := parseClock(window.Start); err != nil {
+				return fmt.Errorf("定价规则 %s 的时段开始时间无效: %s", rule.Name, window.Start)
+			}
+			if _, err := parseClock(window.End); err != nil {
+				return fmt.Errorf("定价规则 %s 的时段结束时间无效: %s", rule.Name, window.End)
+			}
+			if window.Ratio < 0 {
+				return fmt.Errorf("定价规则 %s 的时段 %s 倍率无效", rule.Name, window.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func matchRuleModel(models []string, modelName string) bool {
+	for _, m := range models {
+		if strings.HasSuffix(m, "*") {
+			if strings.HasPrefix(modelName, strings.TrimSuffix(m, "*")) {
+				return true
+			}
+		} else if m == modelName {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, v int) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package operation_setting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPriceRuleMatchTier(t *testing.T) {
+	rule := &PriceRule{
+		Tiers: []PriceTier{
+			{Name: "large", MinMonthlyTokens: 10_000_000, Ratio: 0.8},
+			{Name: "base", MinMonthlyTokens: 0, Ratio: 1},
+			{Name: "medium", MinMonthlyTokens: 1_000_000, Ratio: 0.9},
+		},
+	}
+	tests := []struct {
+		name          string
+		rule          *PriceRule
+		monthlyTokens int64
+		want          string
+	}{
+		{name: "no usage uses base tier", rule: rule, monthlyTokens: 0, want: "base"},
+		{name: "just below threshold", rule: rule, monthlyTokens: 999_999, want: "base"},
+		{name: "threshold is inclusive", rule: rule, monthlyTokens: 1_000_000, want: "medium"},
+		{name: "highest reached tier regardless of order", rule: rule, monthlyTokens: 50_000_000, want: "large"},
+		{name: "no tier reached", rule: &PriceRule{Tiers: []PriceTier{{Name: "big", MinMonthlyTokens: 100}}}, monthlyTokens: 99, want: ""},
+		{name: "no tiers", rule: &PriceRule{}, monthlyTokens: 100, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if tier := tt.rule.MatchTier(tt.monthlyTokens); tier != nil {
+				got = tier.Name
+			}
+			if got != tt.want {
+				t.Fatalf("MatchTier(%d) = %q, want %q", tt.monthlyTokens, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriceRuleMatchTimeWindow(t *testing.T) {
+	rule := &PriceRule{
+		TimeWindows: []PriceTimeWindow{
+			{Name: "invalid", Start: "25:00", End: "26:00"},
+			{Name: "weekday-peak", Start: "09:00", End: "18:00", Weekdays: []int{1, 2, 3, 4, 5}},
+			{Name: "friday-night", Start: "22:00", End: "06:00", Weekdays: []int{5}},
+			{Name: "daily-lunch", Start: "12:00", End: "13:00"},
+		},
+	}
+	// 2024-03-15 为周五
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2024, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{name: "inside weekday window", now: at(15, 10, 30), want: "weekday-peak"},
+		{name: "start is inclusive", now: at(15, 9, 0), want: "weekday-peak"},
+		{name: "end is exclusive", now: at(15, 18, 0), want: ""},
+		{name: "first matching window wins", now: at(15, 12, 30), want: "weekday-peak"},
+		{name: "weekend falls through to daily window", now: at(16, 12, 30), want: "daily-lunch"},
+		{name: "weekend outside any window", now: at(16, 10, 0), want: ""},
+		{name: "overnight window before midnight", now: at(15, 23, 0), want: "friday-night"},
+		{name: "overnight window after midnight belongs to previous day", now: at(16, 5, 59), want: "friday-night"},
+		{name: "overnight window on wrong day", now: at(14, 23, 0), want: ""},
+		{name: "overnight window ends exclusive", now: at(16, 6, 0), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if window := rule.MatchTimeWindow(tt.now); window != nil {
+				got = window.Name
+			}
+			if got != tt.want {
+				t.Fatalf("MatchTimeWindow(%s) = %q, want %q", tt.now.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ResponseCacheSetting struct {
+	// Enabled 是否启用对话补全响应缓存
+	Enabled bool `json:"enabled"`
+	// DefaultEnabled 未在 GroupEnabled 中配置的分组是否默认使用缓存
+	DefaultEnabled bool `json:"default_enabled"`
+	// GroupEnabled 按分组开关缓存，优先于 DefaultEnabled
+	GroupEnabled map[string]bool `json:"group_enabled"`
+	// TTLSeconds 缓存有效期
+	TTLSeconds int `json:"ttl_seconds"`
+	// BillingRatio 命中缓存时按正常费用的该比例计费，0 表示免费
+	BillingRatio float64 `json:"billing_ratio"`
+	// MaxEntryBytes 单条缓存的最大字节数，超过则不缓存
+	MaxEntryBytes int `json:"max_entry_bytes"`
+	// MaxMemoryEntries 未启用 Redis 时内存缓存的最大条数
+	MaxMemoryEntries int `json:"max_memory_entries"`
+	// ShareAcrossUsers 是否在不同用户之间共享缓存，默认仅同一用户可命中
+	ShareAcrossUsers bool `json:"share_across_users"`
+	// SemanticEnabled 精确匹配未命中时是否按消息内容的向量相似度匹配
+	SemanticEnabled bool `json:"semantic_enabled"`
+	// SemanticGroupEnabled 按分组开关语义匹配，优先于 SemanticEnabled
+	SemanticGroupEnabled map[string]bool `json:"semantic_group_enabled"`
+	// SemanticThreshold 语义匹配的余弦相似度阈值
+	SemanticThreshold float64 `json:"semantic_threshold"`
+	// SemanticGroupThresholds 按分组覆盖相似度阈值
+	SemanticGroupThresholds map[string]float64 `json:"semantic_group_thresholds"`
+	// EmbeddingModel 计算消息向量使用的模型，需在请求分组下有 OpenAI 兼容渠道
+	EmbeddingModel string `json:"embedding_model"`
+	// SemanticMaxCandidates 每组相同参数的请求最多保留的向量条数
+	SemanticMaxCandidates int `json:"semantic_max_candidates"`
+}
+
+// 默认配置
+var responseCacheSetting = ResponseCacheSetting{
+	Enabled:          false,
+	DefaultEnabled:   true,
+	GroupEnabled:     map[string]bool{},
+	TTLSeconds:       3600,
+	BillingRatio:     0,
+	MaxEntryBytes:    1 << 20,
+	MaxMemoryEntries: 1000,
+	ShareAcrossUsers: false,
+
+	SemanticEnabled:         false,
+	SemanticGroupEnabled:    map[string]bool{},
+	SemanticThreshold:       0.95,
+	SemanticGroupThresholds: map[string]float64{},
+	EmbeddingModel:          "text-embedding-3-small",
+	SemanticMaxCandidates:   500,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_cache_setting", &responseCacheSetting)
+}
+
+func GetResponseCacheSetting() *ResponseCacheSetting {
+	return &responseCacheSetting
+}
+
+// IsResponseCacheEnabled 判断分组是否启用响应缓存，tokenMode 为令牌级配置（enabled/semantic/disabled/空）
+func IsResponseCacheEnabled(group string, tokenMode string) bool {
+	if !responseCacheSetting.Enabled {
+		return false
+	}
+	switch tokenMode {
+	case "enabled", "semantic":
+		return true
+	case "disabled":
+		return false
+	}
+	if enabled, ok := responseCacheSetting.GroupEnabled[group]; ok {
+		return enabled
+	}
+	return responseCacheSetting.DefaultEnabled
+}
+
+// SemanticCacheThreshold 返回语义匹配的相似度阈值，未启用语义匹配时返回 false。
+// 令牌配置为 semantic 时总是启用，其余情况跟随分组配置
+func SemanticCacheThreshold(group string, tokenMode string) (float64, bool) {
+	if responseCacheSetting.EmbeddingModel == "" {
+		return 0, false
+	}
+	enabled := responseCacheSetting.SemanticEnabled
+	if groupEnabled, ok := responseCacheSetting.SemanticGroupEnabled[group]; ok {
+		enabled = groupEnabled
+	}
+	if tokenMode == "semantic" {
+		enabled = true
+	}
+	if !enabled {
+		return 0, false
+	}
+	threshold := responseCacheSetting.SemanticThreshold
+	if groupThreshold, ok := responseCacheSetting.SemanticGroupThresholds[group]; ok {
+		threshold = groupThreshold
+	}
+	return threshold, threshold > 0
+}
@@ -0,0 +1,25 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type SubscriptionSetting struct {
+	// Enabled 是否启用订阅计划，关闭时扣费不查询计划额度
+	Enabled bool `json:"enabled"`
+	// CheckIntervalSeconds 调度器重置额度周期与处理到期订阅的间隔
+	CheckIntervalSeconds int `json:"check_interval_seconds"`
+}
+
+// 默认配置
+var subscriptionSetting = SubscriptionSetting{
+	Enabled:              false,
+	CheckIntervalSeconds: 60,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("subscription_setting", &subscriptionSetting)
+}
+
+func GetSubscriptionSetting() *SubscriptionSetting {
+	return &subscriptionSetting
+}
@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"one-api/common"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OpenTelemetry 链路追踪。未设置 OTEL_EXPORTER_OTLP_ENDPOINT 时使用默认的 no-op 实现，
+// 导出地址、请求头、采样率等均沿用 OTel 标准环境变量。
+
+const tracerName = "one-api"
+
+const (
+	AttrChannelId        = attribute.Key("newapi.channel.id")
+	AttrChannelType      = attribute.Key("newapi.channel.type")
+	AttrModel            = attribute.Key("newapi.model")
+	AttrGroup            = attribute.Key("newapi.group")
+	AttrRetryIndex       = attribute.Key("newapi.retry.index")
+	AttrRequestId        = attribute.Key("newapi.request.id")
+	AttrPromptTokens     = attribute.Key("newapi.usage.prompt_tokens")
+	AttrCompletionTokens = attribute.Key("newapi.usage.completion_tokens")
+	AttrQuota            = attribute.Key("newapi.usage.quota")
+)
+
+var (
+	enabled           bool
+	propagateUpstream bool
+	propagator        = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+)
+
+// Init 按环境变量初始化 OTLP 导出，返回进程退出时调用的 shutdown
+func Init() (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagator)
+	propagateUpstream = common.GetEnvOrDefaultBool("OTEL_PROPAGATE_UPSTREAM", false)
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	ctx := context.Background()
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	serviceName := common.GetEnvOrDefaultString("OTEL_SERVICE_NAME", "new-api")
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+		attribute.String("service.version", common.Version),
+	))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	enabled = true
+	common.SysLog("opentelemetry tracing enabled, service name: " + serviceName)
+	return provider.Shutdown, nil
+}
+
+func Enabled() bool {
+	return enabled
+}
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Extract 从请求头中解析上游传入的 traceparent
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// InjectUpstream 开启 OTEL_PROPAGATE_UPSTREAM 时向上游请求转发 trace 上下文
+func InjectUpstream(ctx context.Context, header http.Header) {
+	if !enabled || !propagateUpstream {
+		return
+	}
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// StartSpan 以当前请求的上下文为父 span 创建子 span，不修改 c.Request
+func StartSpan(c *gin.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(c.Request.Context(), name, trace.WithAttributes(attrs...))
+}
+
+// StartRequestSpan 创建子 span 并替换 c.Request 的上下文，之后的阶段都挂在该 span 下，
+// 返回的 end 会恢复原上下文并结束 span
+func StartRequestSpan(c *gin.Context, name string, attrs ...attribute.KeyValue) (trace.Span, func()) {
+	parent := c.Request.Context()
+	ctx, span := Tracer().Start(parent, name, trace.WithAttributes(attrs...))
+	c.Request = c.Request.WithContext(ctx)
+	return span, func() {
+		span.End()
+		c.Request = c.Request.WithContext(parent)
+	}
+}
+
+// SetError 标记 span 失败
+func SetError(span trace.Span, message string) {
+	span.SetStatus(codes.Error, message)
+}
+
+// SetAttributes 给当前请求所在的 span 添加属性
+func SetAttributes(c *gin.Context, attrs ...attribute.KeyValue) {
+	if c == nil || c.Request == nil {
+		return
+	}
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(attrs...)
+}
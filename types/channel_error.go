@@ -5,6 +5,7 @@ type ChannelError struct {
 	ChannelType int    `json:"channel_type"`
 	ChannelName string `json:"channel_name"`
 	IsMultiKey  bool   `json:"is_multi_key"`
+	KeyIndex    int    `json:"key_index"`
 	AutoBan     bool   `json:"auto_ban"`
 	UsingKey    string `json:"using_key"`
 }
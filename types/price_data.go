@@ -21,6 +21,18 @@ type PriceData struct {
 	UsePrice               bool
 	ShouldPreConsumedQuota int
 	GroupRatioInfo         GroupRatioInfo
+	PriceRule              *PriceRuleInfo // 命中的定价规则，未命中时为 nil
+}
+
+// PriceRuleInfo 记录本次请求命中的定价规则档位，Ratio 已叠加到分组倍率上
+type PriceRuleInfo struct {
+	Rule          string  `json:"rule"`
+	Tier          string  `json:"tier,omitempty"`
+	TierRatio     float64 `json:"tier_ratio,omitempty"`
+	TimeWindow    string  `json:"time_window,omitempty"`
+	WindowRatio   float64 `json:"window_ratio,omitempty"`
+	MonthlyTokens int64   `json:"monthly_tokens"`
+	Ratio         float64 `json:"ratio"`
 }
 
 type PerCallPriceData struct {